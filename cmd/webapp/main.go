@@ -46,7 +46,15 @@ func main() {
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
 
-		shutdownError <- srv.Shutdown(ctx)
+		err := srv.Shutdown(ctx)
+		if err != nil {
+			shutdownError <- err
+			return
+		}
+
+		// Wait for queued emails to be delivered before exiting
+		app.Logger.Info("completing background tasks", "addr", srv.Addr)
+		shutdownError <- app.Mailer.Shutdown(ctx)
 	}()
 
 	app.Logger.Info("starting server", "port", srv.Addr, "env", app.Config.Env)
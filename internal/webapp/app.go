@@ -2,19 +2,33 @@ package webapp
 
 import (
 	"database/sql"
+	"io/fs"
 	"log/slog"
 	"os"
+	"time"
 
+	"github.com/navazjm/pixelarcade/internal/webapp/audit"
 	"github.com/navazjm/pixelarcade/internal/webapp/auth"
 	"github.com/navazjm/pixelarcade/internal/webapp/games"
+	"github.com/navazjm/pixelarcade/internal/webapp/idempotency"
+	"github.com/navazjm/pixelarcade/internal/webapp/mailer"
+	"github.com/navazjm/pixelarcade/internal/webapp/ratelimit"
+	"github.com/navazjm/pixelarcade/internal/webapp/storage"
 	"github.com/navazjm/pixelarcade/internal/webapp/utils/logger"
 )
 
 type Application struct {
 	Config       *Config
 	Logger       *slog.Logger
+	Mailer       *mailer.Background
+	Static       fs.FS
+	Storage      storage.Blob
+	RateLimiter  ratelimit.Store
+	AuditService *audit.Service
 	AuthService  *auth.Service
 	GamesService *games.Service
+
+	IdempotencyService *idempotency.Service
 }
 
 func New() *Application {
@@ -25,17 +39,41 @@ func New() *Application {
 		os.Exit(1)
 	}
 
+	mailer, err := mailer.New(&cfg.Mailer, logger)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	blob, err := storage.New(&cfg.Storage)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	limiter, err := ratelimit.New(&cfg.RateLimit)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
 	app := &Application{
-		Config: cfg,
-		Logger: logger,
+		Config:      cfg,
+		Logger:      logger,
+		Mailer:      mailer,
+		Static:      os.DirFS(cfg.StaticDir),
+		Storage:     blob,
+		RateLimiter: limiter,
 	}
 
 	return app
 }
 
 func (app *Application) InitServices(db *sql.DB) {
-	app.AuthService = auth.NewService(db, app.Logger)
-	app.GamesService = games.NewService(db, app.Logger)
+	app.AuditService = audit.NewService(db, app.Logger)
+	app.AuthService = auth.NewService(db, app.Logger, app.Mailer, app.Storage, app.AuditService, &app.Config.Auth)
+	app.GamesService = games.NewService(db, app.Logger, app.AuthService, app.Storage, app.AuditService, &app.Config.Games)
+	app.IdempotencyService = idempotency.NewService(db, app.Logger, &app.Config.Idempotency)
 }
 
 // ============================================================================
@@ -46,7 +84,16 @@ func setupTestApp() *Application {
 	return &Application{
 		Config: &Config{
 			TrustedOrigins: []string{"https://example.com", "https://trusted.com"},
+			RateLimit: ratelimit.Config{
+				Enabled:     true,
+				Backend:     ratelimit.BackendMemory,
+				IdleTimeout: time.Minute,
+				Default:     ratelimit.Rule{Rate: 2, Burst: 8},
+				Routes:      []ratelimit.Rule{{Prefix: "/api/auth/login", Rate: 0.2, Burst: 2}},
+			},
 		},
-		Logger: logger.NewMock(),
+		Logger:      logger.NewMock(),
+		Mailer:      mailer.NewBackground(mailer.NewMock(), logger.NewMock(), 1),
+		RateLimiter: ratelimit.NewMemory(time.Minute),
 	}
 }
@@ -5,6 +5,7 @@ import (
 )
 
 func TestNewApplicationAndInitServices(t *testing.T) {
+	setSecretEnvVars(t)
 	app := New()
 
 	if app.Logger == nil {
@@ -15,6 +16,10 @@ func TestNewApplicationAndInitServices(t *testing.T) {
 		t.Errorf("expected config to be initialized, got nil")
 	}
 
+	if app.Storage == nil {
+		t.Errorf("expected storage to be initialized, got nil")
+	}
+
 	app.InitServices(nil)
 	if app.AuthService == nil {
 		t.Errorf("expected Auth service to be initialized, got nil")
@@ -22,4 +27,7 @@ func TestNewApplicationAndInitServices(t *testing.T) {
 	if app.GamesService == nil {
 		t.Errorf("expected Games service to be initialized, got nil")
 	}
+	if app.IdempotencyService == nil {
+		t.Errorf("expected Idempotency service to be initialized, got nil")
+	}
 }
@@ -0,0 +1,172 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/navazjm/pixelarcade/internal/webapp/utils/validator"
+)
+
+// Actions recorded in the audit log. Named "<target>.<verb>" so related actions can be
+// found by prefix.
+const (
+	ActionLogin             = "auth.login"
+	ActionLoginFailed       = "auth.login_failed"
+	ActionPasswordChange    = "auth.password_change"
+	ActionTokensRevoke      = "auth.tokens_revoke"
+	ActionSessionRevoke     = "auth.session_revoke"
+	ActionTwoFactorEnable   = "auth.two_factor_enable"
+	ActionTwoFactorDisable  = "auth.two_factor_disable"
+	ActionUserUpdate        = "user.update"
+	ActionUserDelete        = "user.delete"
+	ActionUserLock          = "user.lock"
+	ActionUserUnlock        = "user.unlock"
+	ActionGameCreate        = "game.create"
+	ActionGameUpdate        = "game.update"
+	ActionGameDelete        = "game.delete"
+	ActionGameVersionCreate = "game.version_create"
+	ActionScoreModerate     = "score.moderate"
+)
+
+// Types of records an event can target
+const (
+	TargetUser  = "user"
+	TargetGame  = "game"
+	TargetScore = "score"
+)
+
+// Bookkeeping fields maintained by the database, left out of diffs
+var ignoredFields = []string{"created_at", "updated_at", "version"}
+
+type Event struct {
+	ID         int64           `json:"id"`
+	CreatedAt  time.Time       `json:"created_at"`
+	Action     string          `json:"action"`
+	ActorID    int64           `json:"actor_id,omitempty"` // zero for anonymous requests, e.g. failed logins
+	TargetType string          `json:"target_type"`
+	TargetID   int64           `json:"target_id,omitempty"`
+	RequestID  string          `json:"request_id"`
+	IP         string          `json:"ip"`
+	Diff       json.RawMessage `json:"diff,omitempty"`
+}
+
+// Recorder stores audit events. Implemented by the Service, and by Mock for testing.
+type Recorder interface {
+	Record(r *http.Request, event *Event)
+}
+
+// Diff returns the fields whose JSON encoding differs between before and after, as
+// {"field": {"old": ..., "new": ...}}. Both must encode to JSON objects, e.g. a copy of
+// a record taken before updating it and the record itself. Returns nil if nothing
+// changed.
+func Diff(before, after any) json.RawMessage {
+	previous, err := fields(before)
+	if err != nil {
+		return nil
+	}
+	current, err := fields(after)
+	if err != nil {
+		return nil
+	}
+
+	changes := map[string]map[string]json.RawMessage{}
+	for key, value := range current {
+		if validator.PermittedValue(key, ignoredFields...) || bytes.Equal(previous[key], value) {
+			continue
+		}
+		changes[key] = map[string]json.RawMessage{"old": nullIfMissing(previous[key]), "new": value}
+	}
+	for key, value := range previous {
+		if _, ok := current[key]; !ok && !validator.PermittedValue(key, ignoredFields...) {
+			changes[key] = map[string]json.RawMessage{"old": value, "new": nullIfMissing(nil)}
+		}
+	}
+
+	if len(changes) == 0 {
+		return nil
+	}
+
+	diff, err := json.Marshal(changes)
+	if err != nil {
+		return nil
+	}
+	return diff
+}
+
+// Details encodes data describing an event that isn't a change to a record, e.g. the
+// email address of a failed login.
+func Details(data map[string]any) json.RawMessage {
+	js, err := json.Marshal(data)
+	if err != nil {
+		return nil
+	}
+	return js
+}
+
+func fields(v any) (map[string]json.RawMessage, error) {
+	js, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var m map[string]json.RawMessage
+	err = json.Unmarshal(js, &m)
+	return m, err
+}
+
+func nullIfMissing(value json.RawMessage) json.RawMessage {
+	if value == nil {
+		return json.RawMessage("null")
+	}
+	return value
+}
+
+// Filters narrows down the events returned by the admin API. Empty fields match every
+// event.
+type Filters struct {
+	Action     string `json:"action"`
+	ActorID    int64  `json:"actor_id"`
+	TargetType string `json:"target_type"`
+	TargetID   int64  `json:"target_id"`
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset"`
+}
+
+func ValidateFilters(v *validator.Validator, f Filters) {
+	v.Check(len(f.Action) <= 100, "action", "must not be more than 100 bytes long")
+	v.Check(f.ActorID >= 0, "actor_id", "must be zero or greater")
+	v.Check(f.TargetType == "" || validator.PermittedValue(f.TargetType, TargetUser, TargetGame, TargetScore), "target_type", "must be one of user, game or score")
+	v.Check(f.TargetID >= 0, "target_id", "must be zero or greater")
+	v.Check(f.Limit > 0, "limit", "must be greater than zero")
+	v.Check(f.Limit <= 100, "limit", "must be a maximum of 100")
+	v.Check(f.Offset >= 0, "offset", "must be zero or greater")
+	v.Check(f.Offset <= 10_000, "offset", "must be a maximum of 10000")
+}
+
+// ============================================================================
+// Mock Recorder for testing purposes
+// ============================================================================
+
+type Mock struct {
+	mu       sync.Mutex
+	Recorded []*Event
+}
+
+func (m *Mock) Record(r *http.Request, event *Event) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Recorded = append(m.Recorded, event)
+}
+
+func (m *Mock) Events() []*Event {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*Event(nil), m.Recorded...)
+}
+
+func NewMock() *Mock {
+	return &Mock{}
+}
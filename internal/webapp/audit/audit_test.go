@@ -0,0 +1,123 @@
+package audit
+
+import (
+	"testing"
+
+	"github.com/navazjm/pixelarcade/internal/webapp/utils/validator"
+)
+
+func TestDiff(t *testing.T) {
+	type record struct {
+		Name      string `json:"name"`
+		IsActive  bool   `json:"is_active"`
+		UpdatedAt string `json:"updated_at"`
+		Version   int    `json:"version"`
+	}
+
+	before := record{Name: "Alice", IsActive: true, UpdatedAt: "yesterday", Version: 1}
+
+	tests := []struct {
+		name     string
+		before   any
+		after    any
+		expected string
+	}{
+		{
+			name:     "Changed fields",
+			before:   before,
+			after:    record{Name: "Bob", IsActive: false, UpdatedAt: "today", Version: 2},
+			expected: `{"is_active":{"new":false,"old":true},"name":{"new":"Bob","old":"Alice"}}`,
+		},
+		{
+			name:     "Only bookkeeping fields changed",
+			before:   before,
+			after:    record{Name: "Alice", IsActive: true, UpdatedAt: "today", Version: 2},
+			expected: "",
+		},
+		{
+			name:     "Deleted record",
+			before:   before,
+			after:    struct{}{},
+			expected: `{"is_active":{"new":null,"old":true},"name":{"new":null,"old":"Alice"}}`,
+		},
+		{
+			name:     "Not an object",
+			before:   before,
+			after:    "Bob",
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := Diff(tt.before, tt.after)
+			if string(diff) != tt.expected {
+				t.Errorf("expected diff %q, got %q", tt.expected, diff)
+			}
+		})
+	}
+}
+
+func TestDetails(t *testing.T) {
+	details := Details(map[string]any{"email": "alice@example.com", "reason": "wrong password"})
+
+	expected := `{"email":"alice@example.com","reason":"wrong password"}`
+	if string(details) != expected {
+		t.Errorf("expected details %q, got %q", expected, details)
+	}
+}
+
+func TestValidateFilters(t *testing.T) {
+	tests := []struct {
+		name        string
+		filters     Filters
+		expectValid bool
+		errorKey    string
+	}{
+		{
+			name:        "Valid filters",
+			filters:     Filters{Action: ActionLogin, ActorID: 1, TargetType: TargetUser, TargetID: 2, Limit: 50},
+			expectValid: true,
+		},
+		{
+			name:        "Invalid target type",
+			filters:     Filters{TargetType: "session", Limit: 50},
+			expectValid: false,
+			errorKey:    "target_type",
+		},
+		{
+			name:        "Negative actor",
+			filters:     Filters{ActorID: -1, Limit: 50},
+			expectValid: false,
+			errorKey:    "actor_id",
+		},
+		{
+			name:        "Limit too large",
+			filters:     Filters{Limit: 101},
+			expectValid: false,
+			errorKey:    "limit",
+		},
+		{
+			name:        "Offset too large",
+			filters:     Filters{Limit: 50, Offset: 10_001},
+			expectValid: false,
+			errorKey:    "offset",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateFilters(v, tt.filters)
+
+			if v.Valid() != tt.expectValid {
+				t.Fatalf("expected valid %v, got %v (%v)", tt.expectValid, v.Valid(), v.Errors)
+			}
+			if !tt.expectValid {
+				if _, ok := v.Errors[tt.errorKey]; !ok {
+					t.Errorf("expected error for %q, got %v", tt.errorKey, v.Errors)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package audit
+
+import (
+	"net/http"
+
+	"github.com/navazjm/pixelarcade/internal/webapp/utils/json"
+	"github.com/navazjm/pixelarcade/internal/webapp/utils/param"
+	"github.com/navazjm/pixelarcade/internal/webapp/utils/response"
+	"github.com/navazjm/pixelarcade/internal/webapp/utils/validator"
+)
+
+// GetEventsHandler lists audit events for admins, newest first, optionally filtered by
+// action, actor and target.
+func (s *Service) GetEventsHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	filters := Filters{
+		Action:     param.ReadQueryString(r, "action", ""),
+		ActorID:    int64(param.ReadQueryInt(r, "actor_id", 0, v)),
+		TargetType: param.ReadQueryString(r, "target_type", ""),
+		TargetID:   int64(param.ReadQueryInt(r, "target_id", 0, v)),
+		Limit:      param.ReadQueryInt(r, "limit", 50, v),
+		Offset:     param.ReadQueryInt(r, "offset", 0, v),
+	}
+
+	if ValidateFilters(v, filters); !v.Valid() {
+		response.FailedValidation(w, r, s.Logger, v.Errors)
+		return
+	}
+
+	events, total, err := s.Models.GetEvents(filters)
+	if err != nil {
+		response.ServerError(w, r, s.Logger, err)
+		return
+	}
+
+	err = json.WriteResponse(w, http.StatusOK, json.Envelope{"events": events, "total": total, "filters": filters}, nil)
+	if err != nil {
+		response.ServerError(w, r, s.Logger, err)
+	}
+}
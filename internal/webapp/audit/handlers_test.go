@@ -0,0 +1,111 @@
+package audit
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/navazjm/pixelarcade/internal/webapp/utils/requestid"
+)
+
+func TestRecord(t *testing.T) {
+	service, mock := newMockService(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/login", nil)
+	req.RemoteAddr = "192.0.2.1:4321"
+	req = requestid.Set(req, "abc")
+
+	mock.ExpectQuery("INSERT INTO audit_events").
+		WithArgs(ActionLogin, int64(1), TargetUser, int64(1), "abc", "192.0.2.1", []byte(nil)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, time.Now()))
+
+	event := &Event{Action: ActionLogin, ActorID: 1, TargetType: TargetUser, TargetID: 1}
+	service.Record(req, event)
+
+	if event.RequestID != "abc" || event.IP != "192.0.2.1" {
+		t.Errorf("expected request ID and IP to be filled in, got %q and %q", event.RequestID, event.IP)
+	}
+
+	// Failures must not surface to the caller
+	mock.ExpectQuery("INSERT INTO audit_events").WillReturnError(sql.ErrConnDone)
+	service.Record(req, &Event{Action: ActionLogin, TargetType: TargetUser})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unmet expectations: %v", err)
+	}
+}
+
+func TestGetEventsHandler(t *testing.T) {
+	columns := []string{"count", "id", "created_at", "action", "actor_id", "target_type", "target_id", "request_id", "ip", "diff"}
+
+	t.Run("SUCCESS Filtered events", func(t *testing.T) {
+		service, mock := newMockService(t)
+
+		mock.ExpectQuery("SELECT COUNT\\(\\*\\) OVER\\(\\)").
+			WithArgs(ActionUserUpdate, int64(1), "", int64(0), 10, 0).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow(1, 1, time.Now(), ActionUserUpdate, 1, TargetUser, 2, "abc", "192.0.2.1", []byte(`{"role_id":{"new":1,"old":2}}`)))
+
+		req := httptest.NewRequest(http.MethodGet, "/api/admin/audit?action=user.update&actor_id=1&limit=10", nil)
+		w := httptest.NewRecorder()
+		service.GetEventsHandler(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+
+		var resp struct {
+			Events []*Event `json:"events"`
+			Total  int64    `json:"total"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+
+		if resp.Total != 1 || len(resp.Events) != 1 || resp.Events[0].TargetID != 2 {
+			t.Errorf("unexpected response: %+v", resp)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("ERROR Invalid filters", func(t *testing.T) {
+		service, mock := newMockService(t)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/admin/audit?target_type=session&limit=1000", nil)
+		w := httptest.NewRecorder()
+		service.GetEventsHandler(w, req)
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status 422, got %d", w.Code)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("ERROR Database failure", func(t *testing.T) {
+		service, mock := newMockService(t)
+
+		mock.ExpectQuery("SELECT COUNT\\(\\*\\) OVER\\(\\)").WillReturnError(sql.ErrConnDone)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/admin/audit", nil)
+		w := httptest.NewRecorder()
+		service.GetEventsHandler(w, req)
+
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("expected status 500, got %d", w.Code)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+}
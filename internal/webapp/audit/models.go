@@ -0,0 +1,86 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type Model struct {
+	DB *sql.DB
+}
+
+func (m Model) InsertEvent(event *Event) error {
+	query := `
+        INSERT INTO audit_events (action, actor_id, target_type, target_id, request_id, ip, diff)
+        VALUES ($1, NULLIF($2::BIGINT, 0), $3, NULLIF($4::BIGINT, 0), $5, $6, $7)
+        RETURNING id, created_at`
+
+	// A nil diff is stored as NULL rather than an empty JSON document
+	var diff []byte
+	if len(event.Diff) > 0 {
+		diff = event.Diff
+	}
+
+	args := []any{event.Action, event.ActorID, event.TargetType, event.TargetID, event.RequestID, event.IP, diff}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&event.ID, &event.CreatedAt)
+}
+
+// GetEvents returns a page of the events matching the filters, newest first, along
+// with the total number of matching events.
+func (m Model) GetEvents(filters Filters) ([]*Event, int64, error) {
+	query := `
+        SELECT COUNT(*) OVER(), id, created_at, action, COALESCE(actor_id, 0), target_type, COALESCE(target_id, 0), request_id, ip, diff
+        FROM audit_events
+        WHERE (action = $1 OR $1 = '')
+        AND (actor_id = $2 OR $2 = 0)
+        AND (target_type = $3 OR $3 = '')
+        AND (target_id = $4 OR $4 = 0)
+        ORDER BY id DESC
+        LIMIT $5 OFFSET $6`
+
+	args := []any{filters.Action, filters.ActorID, filters.TargetType, filters.TargetID, filters.Limit, filters.Offset}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var total int64
+	events := []*Event{}
+	for rows.Next() {
+		var event Event
+		var diff []byte
+		err := rows.Scan(
+			&total,
+			&event.ID,
+			&event.CreatedAt,
+			&event.Action,
+			&event.ActorID,
+			&event.TargetType,
+			&event.TargetID,
+			&event.RequestID,
+			&event.IP,
+			&diff,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+		event.Diff = diff
+		events = append(events, &event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return events, total, nil
+}
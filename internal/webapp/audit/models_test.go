@@ -0,0 +1,131 @@
+package audit
+
+import (
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestInsertEvent(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	model := Model{DB: mockDB}
+
+	event := &Event{
+		Action:     ActionUserUpdate,
+		ActorID:    1,
+		TargetType: TargetUser,
+		TargetID:   2,
+		RequestID:  "abc",
+		IP:         "192.0.2.1",
+		Diff:       json.RawMessage(`{"role_id":{"new":2,"old":1}}`),
+	}
+
+	// Test Case 1: Successful insertion
+	mock.ExpectQuery("INSERT INTO audit_events .* RETURNING id, created_at").
+		WithArgs(event.Action, event.ActorID, event.TargetType, event.TargetID, event.RequestID, event.IP, []byte(event.Diff)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, time.Now()))
+
+	err = model.InsertEvent(event)
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	if event.ID != 1 {
+		t.Errorf("expected event ID to be 1, got %d", event.ID)
+	}
+
+	// Test Case 2: Events without a diff store NULL
+	anonymous := &Event{Action: ActionLoginFailed, TargetType: TargetUser, RequestID: "def", IP: "192.0.2.1"}
+	mock.ExpectQuery("INSERT INTO audit_events .* RETURNING id, created_at").
+		WithArgs(anonymous.Action, int64(0), anonymous.TargetType, int64(0), anonymous.RequestID, anonymous.IP, []byte(nil)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(2, time.Now()))
+
+	err = model.InsertEvent(anonymous)
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	// Test Case 3: Database error during insertion
+	mock.ExpectQuery("INSERT INTO audit_events .* RETURNING id, created_at").
+		WillReturnError(sql.ErrConnDone)
+
+	err = model.InsertEvent(event)
+	if err != sql.ErrConnDone {
+		t.Errorf("expected sql.ErrConnDone, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestGetEvents(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	model := Model{DB: mockDB}
+	filters := Filters{TargetType: TargetUser, TargetID: 2, Limit: 50}
+	columns := []string{"count", "id", "created_at", "action", "actor_id", "target_type", "target_id", "request_id", "ip", "diff"}
+
+	// Test Case 1: Events found
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) OVER\\(\\), id, created_at, action, .* FROM audit_events").
+		WithArgs("", int64(0), TargetUser, int64(2), 50, 0).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(2, 2, time.Now(), ActionUserUpdate, 1, TargetUser, 2, "abc", "192.0.2.1", []byte(`{"is_active":{"new":false,"old":true}}`)).
+			AddRow(2, 1, time.Now(), ActionLoginFailed, 0, TargetUser, 2, "def", "192.0.2.1", nil))
+
+	events, total, err := model.GetEvents(filters)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if total != 2 || len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d (total %d)", len(events), total)
+	}
+
+	if events[0].ActorID != 1 || string(events[0].Diff) != `{"is_active":{"new":false,"old":true}}` {
+		t.Errorf("unexpected first event: %+v", events[0])
+	}
+
+	if events[1].ActorID != 0 || events[1].Diff != nil {
+		t.Errorf("unexpected second event: %+v", events[1])
+	}
+
+	// Test Case 2: No events found
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) OVER\\(\\), id, created_at, action, .* FROM audit_events").
+		WithArgs("", int64(0), TargetUser, int64(2), 50, 0).
+		WillReturnRows(sqlmock.NewRows(columns))
+
+	events, total, err = model.GetEvents(filters)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if total != 0 || len(events) != 0 {
+		t.Errorf("expected no events, got %d (total %d)", len(events), total)
+	}
+
+	// Test Case 3: Database error
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) OVER\\(\\), id, created_at, action, .* FROM audit_events").
+		WillReturnError(sql.ErrConnDone)
+
+	_, _, err = model.GetEvents(filters)
+	if err != sql.ErrConnDone {
+		t.Errorf("expected sql.ErrConnDone, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+package audit
+
+import (
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/navazjm/pixelarcade/internal/webapp/utils/clientip"
+	"github.com/navazjm/pixelarcade/internal/webapp/utils/logger"
+	"github.com/navazjm/pixelarcade/internal/webapp/utils/requestid"
+)
+
+type Service struct {
+	Models Model
+	Logger *slog.Logger
+}
+
+func NewService(db *sql.DB, logger *slog.Logger) *Service {
+	return &Service{
+		Models: Model{DB: db},
+		Logger: logger,
+	}
+}
+
+// Record stores the event along with the ID and client IP of the request. The action
+// being audited has already happened, so failures are logged rather than returned.
+func (s *Service) Record(r *http.Request, event *Event) {
+	event.RequestID = requestid.Get(r)
+	event.IP = clientip.Get(r)
+
+	err := s.Models.InsertEvent(event)
+	if err != nil {
+		s.Logger.Error(err.Error(), "action", event.Action, "request_id", event.RequestID)
+	}
+}
+
+// ============================================================================
+// Mock Service for testing purposes
+// ============================================================================
+
+func newMockService(t *testing.T) (*Service, sqlmock.Sqlmock) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock DB: %v", err)
+	}
+
+	service := &Service{
+		Models: Model{DB: mockDB},
+		Logger: logger.NewMock(),
+	}
+
+	return service, mock
+}
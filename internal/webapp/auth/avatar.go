@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"strings"
+)
+
+var ErrInvalidAvatar = errors.New("invalid avatar")
+
+// Largest width or height accepted for an uploaded avatar. Checked before the image is
+// decoded, so small files can't expand into huge images in memory.
+const avatarMaxDimension = 4096
+
+// Square thumbnail sizes, in pixels, generated for every uploaded avatar, largest
+// first. The largest is used as the user's profile picture.
+var AvatarSizes = []int{256, 128, 64}
+
+// AvatarURL returns the URL an uploaded avatar thumbnail is served from.
+func AvatarURL(userID int64, hash string, size int) string {
+	return fmt.Sprintf("/api/users/%d/avatars/%s/%d.png", userID, hash, size)
+}
+
+func avatarKey(userID int64, hash string, size int) string {
+	return fmt.Sprintf("avatars/%d/%s/%d.png", userID, hash, size)
+}
+
+// avatarHash returns the hash an uploaded avatar is stored under, so a new upload
+// always gets a new URL and browsers never show a stale cached image.
+func avatarHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// parseAvatarURL returns the hash of the uploaded avatar the profile picture points
+// to, or false if it isn't one, e.g. the default picture.
+func parseAvatarURL(userID int64, profilePicture string) (string, bool) {
+	prefix := fmt.Sprintf("/api/users/%d/avatars/", userID)
+	rest, found := strings.CutPrefix(profilePicture, prefix)
+	if !found {
+		return "", false
+	}
+
+	hash, _, found := strings.Cut(rest, "/")
+	return hash, found && hash != ""
+}
+
+// processAvatar decodes an uploaded PNG, JPEG or GIF and returns PNG encoded square
+// thumbnails keyed by size. Only the pixels are re-encoded, which strips EXIF data,
+// text chunks and any other metadata. Animated GIFs are reduced to their first frame.
+func processAvatar(data []byte) (map[int][]byte, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || (format != "png" && format != "jpeg" && format != "gif") {
+		return nil, fmt.Errorf("%w: must be a PNG, JPEG or GIF image", ErrInvalidAvatar)
+	}
+	if cfg.Width > avatarMaxDimension || cfg.Height > avatarMaxDimension {
+		return nil, fmt.Errorf("%w: must not be larger than %dx%d pixels", ErrInvalidAvatar, avatarMaxDimension, avatarMaxDimension)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: must be a PNG, JPEG or GIF image", ErrInvalidAvatar)
+	}
+
+	thumbnails := make(map[int][]byte, len(AvatarSizes))
+	for _, size := range AvatarSizes {
+		// Each size is scaled down from the previous one, so the full image is only
+		// read once
+		img = thumbnail(img, size)
+
+		var buf bytes.Buffer
+		err = png.Encode(&buf, img)
+		if err != nil {
+			return nil, err
+		}
+		thumbnails[size] = buf.Bytes()
+	}
+
+	return thumbnails, nil
+}
+
+// thumbnail crops the centre square out of the image and scales it to size x size
+// pixels, averaging the source pixels covered by each thumbnail pixel.
+func thumbnail(img image.Image, size int) *image.NRGBA {
+	bounds := img.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	x0 := bounds.Min.X + (bounds.Dx()-side)/2
+	y0 := bounds.Min.Y + (bounds.Dy()-side)/2
+
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		sy0 := y0 + y*side/size
+		sy1 := max(y0+(y+1)*side/size, sy0+1)
+
+		for x := 0; x < size; x++ {
+			sx0 := x0 + x*side/size
+			sx1 := max(x0+(x+1)*side/size, sx0+1)
+
+			// RGBA returns alpha premultiplied values, so transparent pixels don't
+			// bleed their colour into the average
+			var r, g, b, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+
+			dst.Set(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n)})
+		}
+	}
+
+	return dst
+}
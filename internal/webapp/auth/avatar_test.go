@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// newTestImage encodes a width x height image in the given format, left half red and
+// right half blue.
+func newTestImage(t *testing.T, format string, width, height int) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if x < width/2 {
+				img.Set(x, y, color.RGBA{R: 255, A: 255})
+			} else {
+				img.Set(x, y, color.RGBA{B: 255, A: 255})
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "png":
+		err = png.Encode(&buf, img)
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, nil)
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	}
+	if err != nil {
+		t.Fatalf("failed to encode %s: %v", format, err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestProcessAvatar(t *testing.T) {
+	for _, format := range []string{"png", "jpeg", "gif"} {
+		t.Run("SUCCESS "+format, func(t *testing.T) {
+			// Wide image, so the centre crop keeps both halves
+			thumbnails, err := processAvatar(newTestImage(t, format, 600, 300))
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			for _, size := range AvatarSizes {
+				img, imgFormat, err := image.Decode(bytes.NewReader(thumbnails[size]))
+				if err != nil {
+					t.Fatalf("failed to decode %d thumbnail: %v", size, err)
+				}
+				if imgFormat != "png" || img.Bounds().Dx() != size || img.Bounds().Dy() != size {
+					t.Errorf("expected %dx%d png, got %dx%d %s", size, size, img.Bounds().Dx(), img.Bounds().Dy(), imgFormat)
+				}
+
+				left, _, _, _ := img.At(0, size/2).RGBA()
+				_, _, right, _ := img.At(size-1, size/2).RGBA()
+				if left < 0xe000 || right < 0xe000 {
+					t.Errorf("expected red left and blue right edges, got %x %x", left, right)
+				}
+			}
+		})
+	}
+
+	t.Run("SUCCESS Image smaller than thumbnail", func(t *testing.T) {
+		thumbnails, err := processAvatar(newTestImage(t, "png", 10, 10))
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		img, _, _ := image.Decode(bytes.NewReader(thumbnails[AvatarSizes[0]]))
+		if img.Bounds().Dx() != AvatarSizes[0] {
+			t.Errorf("expected image to be scaled up to %d, got %d", AvatarSizes[0], img.Bounds().Dx())
+		}
+	})
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"ERROR Not an image", []byte("<svg></svg>")},
+		{"ERROR Truncated image", newTestImage(t, "png", 100, 100)[:100]},
+		{"ERROR Too wide", newTestImage(t, "png", avatarMaxDimension+1, 1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := processAvatar(tt.data)
+			if !errors.Is(err, ErrInvalidAvatar) {
+				t.Errorf("expected ErrInvalidAvatar, got %v", err)
+			}
+		})
+	}
+}
+
+func TestParseAvatarURL(t *testing.T) {
+	tests := []struct {
+		profilePicture string
+		hash           string
+		ok             bool
+	}{
+		{AvatarURL(1, "0123456789abcdef", 256), "0123456789abcdef", true},
+		{AvatarURL(2, "0123456789abcdef", 256), "", false},
+		{"", "", false},
+		{IdenticonURL(1), "", false},
+		{"/api/users/1/avatars/", "", false},
+	}
+
+	for _, tt := range tests {
+		hash, ok := parseAvatarURL(1, tt.profilePicture)
+		if hash != tt.hash || ok != tt.ok {
+			t.Errorf("parseAvatarURL(%q) = %q, %v; expected %q, %v", tt.profilePicture, hash, ok, tt.hash, tt.ok)
+		}
+	}
+}
@@ -8,7 +8,8 @@ import (
 type contextKey string
 
 const (
-	CtxKeyUser = contextKey("user")
+	CtxKeyUser    = contextKey("user")
+	CtxKeySession = contextKey("session")
 )
 
 func ContextSetUser(r *http.Request, user *User) *http.Request {
@@ -24,3 +25,15 @@ func ContextGetUser(r *http.Request) *User {
 
 	return user
 }
+
+func ContextSetSession(r *http.Request, session *Session) *http.Request {
+	ctx := context.WithValue(r.Context(), CtxKeySession, session)
+	return r.WithContext(ctx)
+}
+
+// ContextGetSession returns the session the request was authenticated with, nil for
+// anonymous users.
+func ContextGetSession(r *http.Request) *Session {
+	session, _ := r.Context().Value(CtxKeySession).(*Session)
+	return session
+}
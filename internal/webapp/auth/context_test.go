@@ -51,3 +51,16 @@ func TestContextGetUser_PanicOnMissingUser(t *testing.T) {
 	r := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
 	_ = ContextGetUser(r) // Should panic
 }
+
+func TestContextGetSession(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+
+	if session := ContextGetSession(r); session != nil {
+		t.Errorf("expected no session for anonymous requests, got %+v", session)
+	}
+
+	r = ContextSetSession(r, &Session{ID: 7})
+	if session := ContextGetSession(r); session == nil || session.ID != 7 {
+		t.Errorf("expected session 7 from context, got %+v", session)
+	}
+}
@@ -1,12 +1,26 @@
 package auth
 
 import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"image/png"
+	"io"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/navazjm/pixelarcade/internal/webapp/audit"
+	"github.com/navazjm/pixelarcade/internal/webapp/oidc"
+	"github.com/navazjm/pixelarcade/internal/webapp/storage"
+	"github.com/navazjm/pixelarcade/internal/webapp/utils/clientip"
 	"github.com/navazjm/pixelarcade/internal/webapp/utils/database"
 	"github.com/navazjm/pixelarcade/internal/webapp/utils/json"
+	"github.com/navazjm/pixelarcade/internal/webapp/utils/param"
 	"github.com/navazjm/pixelarcade/internal/webapp/utils/response"
 	"github.com/navazjm/pixelarcade/internal/webapp/utils/validator"
 )
@@ -25,13 +39,12 @@ func (as *Service) RegisterNewUserHandler(w http.ResponseWriter, r *http.Request
 	}
 
 	user := &User{
-		Email:          input.Email,
-		IsActive:       true,
-		IsVerified:     false,
-		Name:           input.Name,
-		ProfilePicture: defaultProfilePicture,
-		Provider:       "N/A",
-		RoleID:         RoleBasic,
+		Email:      input.Email,
+		IsActive:   true,
+		IsVerified: false,
+		Name:       input.Name,
+		Provider:   ProviderNone,
+		RoleID:     RoleBasic,
 	}
 
 	err = user.Password.Set(input.Password)
@@ -59,12 +72,85 @@ func (as *Service) RegisterNewUserHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	token, err := as.Models.NewToken(user.ID, 3*24*time.Hour, ScopeActivation)
+	if err != nil {
+		response.ServerError(w, r, as.Logger, err)
+		return
+	}
+
+	data := map[string]any{
+		"userName":        user.Name,
+		"activationToken": token.Plaintext,
+		"activationURL":   fmt.Sprintf("%s/activate?token=%s", as.Config.BaseURL, token.Plaintext),
+	}
+
+	// The user has already been created at this point, so a failed email should not
+	// fail the request. Log it so the activation email can be resent manually.
+	err = as.Mailer.Send(user.Email, "user_welcome.tmpl", data)
+	if err != nil {
+		as.Logger.Error(err.Error(), "user_id", user.ID)
+	}
+
 	err = json.WriteResponse(w, http.StatusCreated, json.Envelope{"user": user}, nil)
 	if err != nil {
 		response.ServerError(w, r, as.Logger, err)
 	}
 }
 
+func (as *Service) ActivateUserHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Token string `json:"token"`
+	}
+
+	err := json.ReadRequestBody(w, r, &input)
+	if err != nil {
+		response.BadRequest(w, r, as.Logger, err)
+		return
+	}
+
+	v := validator.New()
+	if ValidateTokenPlaintext(v, input.Token); !v.Valid() {
+		response.FailedValidation(w, r, as.Logger, v.Errors)
+		return
+	}
+
+	user, err := as.Models.GetUserFromToken(ScopeActivation, input.Token)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			v.AddError("token", "invalid or expired activation token")
+			response.FailedValidation(w, r, as.Logger, v.Errors)
+		default:
+			response.ServerError(w, r, as.Logger, err)
+		}
+		return
+	}
+
+	user.IsVerified = true
+
+	err = as.Models.UpdateUserByID(user)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrEditConflict):
+			response.EditConflict(w, r, as.Logger)
+		default:
+			response.ServerError(w, r, as.Logger, err)
+		}
+		return
+	}
+
+	err = as.Models.DeleteAllTokensForUser(ScopeActivation, user.ID)
+	if err != nil && !errors.Is(err, database.ErrRecordNotFound) {
+		response.ServerError(w, r, as.Logger, err)
+		return
+	}
+
+	err = json.WriteResponse(w, http.StatusOK, json.Envelope{"user": user}, nil)
+	if err != nil {
+		response.ServerError(w, r, as.Logger, err)
+	}
+}
+
 func (as *Service) LoginUserHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
 		Email    string `json:"email"`
@@ -85,11 +171,17 @@ func (as *Service) LoginUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Checked before the password, so locked accounts don't cost a bcrypt evaluation
+	if !as.loginAllowed(w, r, input.Email) {
+		return
+	}
+
 	user, err := as.Models.GetUserByEmail(input.Email)
 	if err != nil {
 		switch {
 		case errors.Is(err, database.ErrRecordNotFound):
-			response.InvalidCredentials(w, r, as.Logger)
+			as.recordFailedLogin(r, 0, input.Email, "unknown email")
+			as.loginFailed(w, r, input.Email, nil)
 		default:
 			response.ServerError(w, r, as.Logger, err)
 		}
@@ -103,26 +195,52 @@ func (as *Service) LoginUserHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if !match {
-		response.InvalidCredentials(w, r, as.Logger)
+		as.recordFailedLogin(r, user.ID, input.Email, "wrong password")
+		as.loginFailed(w, r, input.Email, user)
+		return
+	}
+
+	if !user.IsActive {
+		as.recordFailedLogin(r, user.ID, input.Email, "account inactive")
+		response.AccountInactive(w, r, as.Logger)
+		return
+	}
+
+	// The password alone isn't enough, a code has to be exchanged along with the
+	// challenge token. Failed logins are only forgotten once the code checks out too.
+	tf, err := as.Models.GetTwoFactor(user.ID)
+	if err != nil && !errors.Is(err, database.ErrRecordNotFound) {
+		response.ServerError(w, r, as.Logger, err)
+		return
+	}
+
+	if tf.Enabled() {
+		challenge, err := as.Models.NewToken(user.ID, twoFactorChallengeTTL, ScopeTwoFactor)
+		if err != nil {
+			response.ServerError(w, r, as.Logger, err)
+			return
+		}
+
+		err = json.WriteResponse(w, http.StatusAccepted, json.Envelope{"two_factor_token": challenge}, nil)
+		if err != nil {
+			response.ServerError(w, r, as.Logger, err)
+		}
+		return
+	}
+
+	err = as.Models.DeleteLoginFailures(LoginScopeAccount, accountSubject(user.Email))
+	if err != nil && !errors.Is(err, database.ErrRecordNotFound) {
+		response.ServerError(w, r, as.Logger, err)
 		return
 	}
 
-	token, err := as.Models.NewToken(user.ID, 24*time.Hour, ScopeAuthentication)
+	err = as.startSession(w, r, user, false)
 	if err != nil {
 		response.ServerError(w, r, as.Logger, err)
 		return
 	}
 
-	// Set token as an HttpOnly cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     CookieAuthToken,
-		Value:    token.Plaintext,
-		HttpOnly: true,
-		Secure:   false, // Use true for HTTPS
-		SameSite: http.SameSiteStrictMode,
-		Path:     "/",
-		Expires:  token.Expiry,
-	})
+	as.Audit.Record(r, &audit.Event{Action: audit.ActionLogin, ActorID: user.ID, TargetType: audit.TargetUser, TargetID: user.ID})
 
 	err = json.WriteResponse(w, http.StatusCreated, json.Envelope{"user": user}, nil)
 	if err != nil {
@@ -130,26 +248,134 @@ func (as *Service) LoginUserHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (as *Service) GetCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
-	user := ContextGetUser(r)
+// LoginTwoFactorHandler completes the login of users with two-factor authentication,
+// exchanging the challenge token handed out by LoginUserHandler and a TOTP or recovery
+// code for an authentication token. Wrong codes count as failed logins. After an OAuth
+// login the challenge token comes in a cookie instead of the body.
+func (as *Service) LoginTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Token string `json:"token"`
+		Code  string `json:"code"`
+	}
 
-	err := json.WriteResponse(w, http.StatusOK, json.Envelope{"user": user}, nil)
+	err := json.ReadRequestBody(w, r, &input)
+	if err != nil {
+		response.BadRequest(w, r, as.Logger, err)
+		return
+	}
+
+	fromCookie := false
+	if input.Token == "" {
+		if cookie, err := r.Cookie(CookieTwoFactorToken); err == nil {
+			input.Token = cookie.Value
+			fromCookie = true
+		}
+	}
+
+	v := validator.New()
+	ValidateTokenPlaintext(v, input.Token)
+	ValidateTwoFactorCode(v, input.Code)
+	if !v.Valid() {
+		response.FailedValidation(w, r, as.Logger, v.Errors)
+		return
+	}
+
+	user, err := as.Models.GetUserFromToken(ScopeTwoFactor, input.Token)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			v.AddError("token", "invalid or expired two-factor token")
+			response.FailedValidation(w, r, as.Logger, v.Errors)
+		default:
+			response.ServerError(w, r, as.Logger, err)
+		}
+		return
+	}
+
+	if !user.IsActive {
+		response.AccountInactive(w, r, as.Logger)
+		return
+	}
+
+	if !as.loginAllowed(w, r, user.Email) {
+		return
+	}
+
+	tf, err := as.Models.GetTwoFactor(user.ID)
+	if err != nil && !errors.Is(err, database.ErrRecordNotFound) {
+		response.ServerError(w, r, as.Logger, err)
+		return
+	}
+
+	// Disabled since the password was checked, the user has to log in again
+	if !tf.Enabled() {
+		v.AddError("token", "invalid or expired two-factor token")
+		response.FailedValidation(w, r, as.Logger, v.Errors)
+		return
+	}
+
+	ok, err := as.useTwoFactorCode(tf, input.Code)
+	if err != nil {
+		response.ServerError(w, r, as.Logger, err)
+		return
+	}
+
+	if !ok {
+		as.recordFailedLogin(r, user.ID, user.Email, "wrong two-factor code")
+		as.loginFailed(w, r, user.Email, user)
+		return
+	}
+
+	err = as.Models.DeleteLoginFailures(LoginScopeAccount, accountSubject(user.Email))
+	if err != nil && !errors.Is(err, database.ErrRecordNotFound) {
+		response.ServerError(w, r, as.Logger, err)
+		return
+	}
+
+	err = as.Models.DeleteAllTokensForUser(ScopeTwoFactor, user.ID)
+	if err != nil && !errors.Is(err, database.ErrRecordNotFound) {
+		response.ServerError(w, r, as.Logger, err)
+		return
+	}
+
+	err = as.startSession(w, r, user, true)
+	if err != nil {
+		response.ServerError(w, r, as.Logger, err)
+		return
+	}
+	if fromCookie {
+		clearTwoFactorCookie(w)
+	}
+
+	method := "totp"
+	if isRecoveryCode(input.Code) {
+		method = "recovery_code"
+	}
+
+	as.Audit.Record(r, &audit.Event{
+		Action:     audit.ActionLogin,
+		ActorID:    user.ID,
+		TargetType: audit.TargetUser,
+		TargetID:   user.ID,
+		Diff:       audit.Details(map[string]any{"two_factor": method}),
+	})
+
+	err = json.WriteResponse(w, http.StatusCreated, json.Envelope{"user": user}, nil)
 	if err != nil {
 		response.ServerError(w, r, as.Logger, err)
 	}
 }
 
-func (as *Service) UpdateCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
+// EnrollTwoFactorHandler starts setting up two-factor authentication, returning a new
+// secret both as text and as the otpauth:// URI to show as a QR code. It only takes
+// effect once confirmed with a code through ConfirmTwoFactorHandler, starting over
+// replaces an unconfirmed enrollment. The current password is required, so a stolen
+// session can't be used to enroll an authenticator of its own.
+func (as *Service) EnrollTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
 	user := ContextGetUser(r)
 
 	var input struct {
-		Email          *string `json:"email"`
-		Name           *string `json:"name"`
-		ProfilePicture *string `json:"profile_picture"`
-		Password       *string `json:"password"`
-		Provider       *string `json:"provider"`
-		RoleID         *RoleID `json:"role_id"`
-		IsActive       *bool   `json:"is_active"`
+		CurrentPassword string `json:"current_password"`
 	}
 
 	err := json.ReadRequestBody(w, r, &input)
@@ -158,77 +384,1524 @@ func (as *Service) UpdateCurrentUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	if input.Email != nil {
-		user.Email = *input.Email
+	v := validator.New()
+
+	// Users who signed up via an OAuth provider have no password to confirm
+	if user.Password.hash == nil {
+		v.AddError("two_factor", "cannot be enabled until a password is set")
+		response.FailedValidation(w, r, as.Logger, v.Errors)
+		return
 	}
-	if input.Name != nil {
-		user.Name = *input.Name
+
+	if ValidateCurrentPassword(v, input.CurrentPassword); !v.Valid() {
+		response.FailedValidation(w, r, as.Logger, v.Errors)
+		return
 	}
-	if input.ProfilePicture != nil {
-		user.ProfilePicture = *input.ProfilePicture
+
+	match, err := user.Password.Matches(input.CurrentPassword)
+	if err != nil {
+		response.ServerError(w, r, as.Logger, err)
+		return
 	}
-	if input.RoleID != nil {
-		user.RoleID = *input.RoleID
+
+	if !match {
+		v.AddError("current_password", "is incorrect")
+		response.FailedValidation(w, r, as.Logger, v.Errors)
+		return
 	}
-	if input.Password != nil {
-		err = user.Password.Set(*input.Password)
-		if err != nil {
+
+	tf, err := generateTwoFactor(user.ID)
+	if err != nil {
+		response.ServerError(w, r, as.Logger, err)
+		return
+	}
+
+	err = as.Models.InsertTwoFactor(tf)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrEditConflict):
+			v.AddError("two_factor", "is already enabled")
+			response.FailedValidation(w, r, as.Logger, v.Errors)
+		default:
 			response.ServerError(w, r, as.Logger, err)
-			return
 		}
+		return
 	}
-	if input.Provider != nil {
-		user.Provider = *input.Provider
+
+	env := json.Envelope{
+		"two_factor": map[string]string{
+			"secret":      tf.EncodedSecret(),
+			"otpauth_uri": tf.URI(user.Email),
+		},
 	}
-	if input.IsActive != nil {
-		user.IsActive = *input.IsActive
+
+	err = json.WriteResponse(w, http.StatusCreated, env, nil)
+	if err != nil {
+		response.ServerError(w, r, as.Logger, err)
+	}
+}
+
+// ConfirmTwoFactorHandler enables two-factor authentication once the user proves their
+// authenticator is set up with a code, and returns the recovery codes. Only their
+// hashes are stored, so they are shown this once. The user's other sessions are
+// revoked, none of them was started with a second factor.
+func (as *Service) ConfirmTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	user := ContextGetUser(r)
+	session := ContextGetSession(r)
+
+	var input struct {
+		Code string `json:"code"`
+	}
+
+	err := json.ReadRequestBody(w, r, &input)
+	if err != nil {
+		response.BadRequest(w, r, as.Logger, err)
+		return
 	}
 
 	v := validator.New()
-	if ValidateUser(v, user); !v.Valid() {
+	if ValidateTwoFactorCode(v, input.Code); !v.Valid() {
 		response.FailedValidation(w, r, as.Logger, v.Errors)
 		return
 	}
 
-	err = as.Models.UpdateUserByID(user)
+	tf, err := as.Models.GetTwoFactor(user.ID)
+	if err != nil && !errors.Is(err, database.ErrRecordNotFound) {
+		response.ServerError(w, r, as.Logger, err)
+		return
+	}
+
+	switch {
+	case tf == nil:
+		v.AddError("two_factor", "must be enrolled first")
+	case tf.Enabled():
+		v.AddError("two_factor", "is already enabled")
+	}
+	if !v.Valid() {
+		response.FailedValidation(w, r, as.Logger, v.Errors)
+		return
+	}
+
+	step, ok := tf.ValidateCode(input.Code, time.Now())
+	if !ok {
+		v.AddError("code", "is invalid or expired")
+		response.FailedValidation(w, r, as.Logger, v.Errors)
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		response.ServerError(w, r, as.Logger, err)
+		return
+	}
+
+	err = as.Models.ConfirmTwoFactor(tf, step, hashes)
 	if err != nil {
 		switch {
-		case errors.Is(err, database.ErrDuplicateEmail):
-			v.AddError("email", "a user with this email address already exists")
-			response.FailedValidation(w, r, as.Logger, v.Errors)
+		case errors.Is(err, database.ErrEditConflict):
+			response.EditConflict(w, r, as.Logger)
 		default:
 			response.ServerError(w, r, as.Logger, err)
 		}
 		return
 	}
 
-	err = json.WriteResponse(w, http.StatusOK, json.Envelope{"user": user}, nil)
+	err = as.Models.DeleteOtherSessions(user.ID, session.ID)
+	if err != nil && !errors.Is(err, database.ErrRecordNotFound) {
+		response.ServerError(w, r, as.Logger, err)
+		return
+	}
+
+	as.Audit.Record(r, &audit.Event{Action: audit.ActionTwoFactorEnable, ActorID: user.ID, TargetType: audit.TargetUser, TargetID: user.ID})
+
+	err = json.WriteResponse(w, http.StatusOK, json.Envelope{"recovery_codes": codes}, nil)
 	if err != nil {
 		response.ServerError(w, r, as.Logger, err)
 	}
 }
 
-func (as *Service) LogoutUserHandler(w http.ResponseWriter, r *http.Request) {
+// DisableTwoFactorHandler turns two-factor authentication off. It takes a TOTP or
+// recovery code, so a stolen session isn't enough, and wrong codes count as failed
+// logins.
+func (as *Service) DisableTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
 	user := ContextGetUser(r)
-	err := as.Models.DeleteAllTokensForUser(ScopeAuthentication, user.ID)
+
+	var input struct {
+		Code string `json:"code"`
+	}
+
+	err := json.ReadRequestBody(w, r, &input)
 	if err != nil {
-		response.ServerError(w, r, as.Logger, err)
+		response.BadRequest(w, r, as.Logger, err)
 		return
 	}
 
-	// Set the cookie with an expired date to remove it
-	http.SetCookie(w, &http.Cookie{
-		Name:     CookieAuthToken,
-		Value:    "",
-		Expires:  time.Unix(0, 0), // Expire in the past (January 1, 1970)
+	v := validator.New()
+	if ValidateTwoFactorCode(v, input.Code); !v.Valid() {
+		response.FailedValidation(w, r, as.Logger, v.Errors)
+		return
+	}
+
+	tf, err := as.Models.GetTwoFactor(user.ID)
+	if err != nil && !errors.Is(err, database.ErrRecordNotFound) {
+		response.ServerError(w, r, as.Logger, err)
+		return
+	}
+
+	if !tf.Enabled() {
+		v.AddError("two_factor", "is not enabled")
+		response.FailedValidation(w, r, as.Logger, v.Errors)
+		return
+	}
+
+	if !as.loginAllowed(w, r, user.Email) {
+		return
+	}
+
+	ok, err := as.useTwoFactorCode(tf, input.Code)
+	if err != nil {
+		response.ServerError(w, r, as.Logger, err)
+		return
+	}
+
+	if !ok {
+		as.recordFailedLogin(r, user.ID, user.Email, "wrong two-factor code")
+		as.loginFailed(w, r, user.Email, user)
+		return
+	}
+
+	err = as.Models.DeleteTwoFactor(user.ID)
+	if err != nil && !errors.Is(err, database.ErrRecordNotFound) {
+		response.ServerError(w, r, as.Logger, err)
+		return
+	}
+
+	as.Audit.Record(r, &audit.Event{Action: audit.ActionTwoFactorDisable, ActorID: user.ID, TargetType: audit.TargetUser, TargetID: user.ID})
+
+	err = json.WriteResponse(w, http.StatusOK, json.Envelope{"message": "two-factor authentication was successfully disabled"}, nil)
+	if err != nil {
+		response.ServerError(w, r, as.Logger, err)
+	}
+}
+
+func (as *Service) GetCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
+	user := ContextGetUser(r)
+
+	err := json.WriteResponse(w, http.StatusOK, json.Envelope{"user": user}, nil)
+	if err != nil {
+		response.ServerError(w, r, as.Logger, err)
+	}
+}
+
+// UpdateCurrentUserHandler lets users edit their own profile. Only the name, email and
+// password can be changed here, the role, status and provider of an account are
+// managed by admins through UpdateUserHandler. Changing the email or password requires
+// the current password, and a new email address is only applied once it is confirmed
+// through ConfirmEmailChangeHandler.
+func (as *Service) UpdateCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
+	user := ContextGetUser(r)
+
+	var input struct {
+		Name            *string `json:"name"`
+		Email           *string `json:"email"`
+		Password        *string `json:"password"`
+		CurrentPassword string  `json:"current_password"`
+	}
+
+	err := json.ReadRequestBody(w, r, &input)
+	if err != nil {
+		response.BadRequest(w, r, as.Logger, err)
+		return
+	}
+
+	emailChanged := input.Email != nil && *input.Email != user.Email
+	passwordChanged := input.Password != nil
+
+	v := validator.New()
+
+	// Users who signed up via an OAuth provider have no password to confirm, so they
+	// may set one without it. Their email is tied to the provider until they do.
+	hasPassword := user.Password.hash != nil
+	if emailChanged && !hasPassword {
+		v.AddError("email", "cannot be changed until a password is set")
+		response.FailedValidation(w, r, as.Logger, v.Errors)
+		return
+	}
+
+	if emailChanged || (passwordChanged && hasPassword) {
+		ValidateCurrentPassword(v, input.CurrentPassword)
+		if !v.Valid() {
+			response.FailedValidation(w, r, as.Logger, v.Errors)
+			return
+		}
+
+		match, err := user.Password.Matches(input.CurrentPassword)
+		if err != nil {
+			response.ServerError(w, r, as.Logger, err)
+			return
+		}
+
+		if !match {
+			v.AddError("current_password", "is incorrect")
+			response.FailedValidation(w, r, as.Logger, v.Errors)
+			return
+		}
+	}
+
+	if input.Name != nil {
+		user.Name = *input.Name
+	}
+	if passwordChanged {
+		err = user.Password.Set(*input.Password)
+		if err != nil {
+			response.ServerError(w, r, as.Logger, err)
+			return
+		}
+	}
+
+	if emailChanged {
+		ValidateEmail(v, *input.Email)
+	}
+	if ValidateUser(v, user); !v.Valid() {
+		response.FailedValidation(w, r, as.Logger, v.Errors)
+		return
+	}
+
+	// Checked now so users learn the address is taken right away, the change is refused
+	// on confirmation too if someone registered it in the meantime
+	if emailChanged {
+		_, err = as.Models.GetUserByEmail(*input.Email)
+		switch {
+		case err == nil:
+			v.AddError("email", "a user with this email address already exists")
+			response.FailedValidation(w, r, as.Logger, v.Errors)
+			return
+		case !errors.Is(err, database.ErrRecordNotFound):
+			response.ServerError(w, r, as.Logger, err)
+			return
+		}
+	}
+
+	err = as.Models.UpdateUserByID(user)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrEditConflict):
+			response.EditConflict(w, r, as.Logger)
+		default:
+			response.ServerError(w, r, as.Logger, err)
+		}
+		return
+	}
+
+	// Outstanding reset links were requested for the old password, so burn them. Like
+	// after a reset, every other session is ended too, whoever started them may have
+	// known the old password.
+	if passwordChanged {
+		err = as.Models.DeleteAllTokensForUser(ScopePasswordReset, user.ID)
+		if err != nil && !errors.Is(err, database.ErrRecordNotFound) {
+			response.ServerError(w, r, as.Logger, err)
+			return
+		}
+
+		err = as.Models.DeleteOtherSessions(user.ID, ContextGetSession(r).ID)
+		if err != nil && !errors.Is(err, database.ErrRecordNotFound) {
+			response.ServerError(w, r, as.Logger, err)
+			return
+		}
+
+		as.Audit.Record(r, &audit.Event{Action: audit.ActionPasswordChange, ActorID: user.ID, TargetType: audit.TargetUser, TargetID: user.ID})
+	}
+
+	if emailChanged {
+		err = as.sendEmailChange(user, *input.Email)
+		if err != nil {
+			response.ServerError(w, r, as.Logger, err)
+			return
+		}
+	}
+
+	err = json.WriteResponse(w, http.StatusOK, json.Envelope{"user": user}, nil)
+	if err != nil {
+		response.ServerError(w, r, as.Logger, err)
+	}
+}
+
+// sendEmailChange emails a token confirming the change to the new address, which is
+// kept on the token until then. Tokens issued for an earlier change are deleted first,
+// so only the latest address requested can be confirmed.
+func (as *Service) sendEmailChange(user *User, email string) error {
+	err := as.Models.DeleteAllTokensForUser(ScopeEmailChange, user.ID)
+	if err != nil && !errors.Is(err, database.ErrRecordNotFound) {
+		return err
+	}
+
+	token, err := generateToken(user.ID, 3*24*time.Hour, ScopeEmailChange)
+	if err != nil {
+		return err
+	}
+	token.Email = email
+
+	err = as.Models.InsertToken(token)
+	if err != nil {
+		return err
+	}
+
+	data := map[string]any{
+		"userName":         user.Name,
+		"emailChangeToken": token.Plaintext,
+		"emailChangeURL":   fmt.Sprintf("%s/confirm-email?token=%s", as.Config.BaseURL, token.Plaintext),
+	}
+
+	err = as.Mailer.Send(email, "user_email_change.tmpl", data)
+	if err != nil {
+		as.Logger.Error(err.Error(), "user_id", user.ID)
+	}
+
+	return nil
+}
+
+// ConfirmEmailChangeHandler applies the email change the token was issued for. Only the
+// new address received the token, so the account stays verified. The previous address
+// is told about the change, in case it wasn't made by its owner.
+func (as *Service) ConfirmEmailChangeHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Token string `json:"token"`
+	}
+
+	err := json.ReadRequestBody(w, r, &input)
+	if err != nil {
+		response.BadRequest(w, r, as.Logger, err)
+		return
+	}
+
+	v := validator.New()
+	if ValidateTokenPlaintext(v, input.Token); !v.Valid() {
+		response.FailedValidation(w, r, as.Logger, v.Errors)
+		return
+	}
+
+	user, email, err := as.Models.GetEmailChangeFromToken(input.Token)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			v.AddError("token", "invalid or expired email change token")
+			response.FailedValidation(w, r, as.Logger, v.Errors)
+		default:
+			response.ServerError(w, r, as.Logger, err)
+		}
+		return
+	}
+
+	previousEmail := user.Email
+	user.Email = email
+	user.IsVerified = true
+
+	err = as.Models.UpdateUserByID(user)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrDuplicateEmail):
+			v.AddError("email", "a user with this email address already exists")
+			response.FailedValidation(w, r, as.Logger, v.Errors)
+		case errors.Is(err, database.ErrEditConflict):
+			response.EditConflict(w, r, as.Logger)
+		default:
+			response.ServerError(w, r, as.Logger, err)
+		}
+		return
+	}
+
+	// Outstanding reset links were sent to the previous address, so burn them too
+	for _, scope := range []string{ScopeEmailChange, ScopePasswordReset} {
+		err = as.Models.DeleteAllTokensForUser(scope, user.ID)
+		if err != nil && !errors.Is(err, database.ErrRecordNotFound) {
+			response.ServerError(w, r, as.Logger, err)
+			return
+		}
+	}
+
+	data := map[string]any{
+		"userName": user.Name,
+		"newEmail": user.Email,
+	}
+
+	err = as.Mailer.Send(previousEmail, "user_email_change_notice.tmpl", data)
+	if err != nil {
+		as.Logger.Error(err.Error(), "user_id", user.ID)
+	}
+
+	err = json.WriteResponse(w, http.StatusOK, json.Envelope{"user": user}, nil)
+	if err != nil {
+		response.ServerError(w, r, as.Logger, err)
+	}
+}
+
+// UpdateCurrentUserAvatarHandler accepts an image as the "avatar" multipart form field,
+// stores square thumbnails of it and makes the largest the user's profile picture.
+func (as *Service) UpdateCurrentUserAvatarHandler(w http.ResponseWriter, r *http.Request) {
+	user := ContextGetUser(r)
+
+	r.Body = http.MaxBytesReader(w, r.Body, as.Config.AvatarMaxSize)
+
+	v := validator.New()
+	file, _, err := r.FormFile("avatar")
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesError):
+			v.AddError("avatar", fmt.Sprintf("must not be larger than %d bytes", maxBytesError.Limit))
+		case errors.Is(err, http.ErrMissingFile):
+			v.AddError("avatar", "must be provided")
+		default:
+			response.BadRequest(w, r, as.Logger, err)
+			return
+		}
+		response.FailedValidation(w, r, as.Logger, v.Errors)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		response.ServerError(w, r, as.Logger, err)
+		return
+	}
+
+	thumbnails, err := processAvatar(data)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidAvatar):
+			v.AddError("avatar", err.Error())
+			response.FailedValidation(w, r, as.Logger, v.Errors)
+		default:
+			response.ServerError(w, r, as.Logger, err)
+		}
+		return
+	}
+
+	hash := avatarHash(data)
+	for size, thumbnail := range thumbnails {
+		err = as.Storage.Put(r.Context(), avatarKey(user.ID, hash, size), bytes.NewReader(thumbnail), int64(len(thumbnail)), "image/png")
+		if err != nil {
+			response.ServerError(w, r, as.Logger, err)
+			return
+		}
+	}
+
+	previous := user.ProfilePicture
+	user.ProfilePicture = AvatarURL(user.ID, hash, AvatarSizes[0])
+
+	err = as.Models.UpdateUserByID(user)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrEditConflict):
+			response.EditConflict(w, r, as.Logger)
+		default:
+			response.ServerError(w, r, as.Logger, err)
+		}
+		return
+	}
+
+	if previous != user.ProfilePicture {
+		as.deleteAvatar(r, user.ID, previous)
+	}
+
+	err = json.WriteResponse(w, http.StatusOK, json.Envelope{"user": user}, nil)
+	if err != nil {
+		response.ServerError(w, r, as.Logger, err)
+	}
+}
+
+// DeleteCurrentUserAvatarHandler removes the user's uploaded avatar, reverting to the
+// default profile picture.
+func (as *Service) DeleteCurrentUserAvatarHandler(w http.ResponseWriter, r *http.Request) {
+	user := ContextGetUser(r)
+
+	previous := user.ProfilePicture
+	user.ProfilePicture = ""
+
+	err := as.Models.UpdateUserByID(user)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrEditConflict):
+			response.EditConflict(w, r, as.Logger)
+		default:
+			response.ServerError(w, r, as.Logger, err)
+		}
+		return
+	}
+
+	as.deleteAvatar(r, user.ID, previous)
+
+	err = json.WriteResponse(w, http.StatusOK, json.Envelope{"user": user}, nil)
+	if err != nil {
+		response.ServerError(w, r, as.Logger, err)
+	}
+}
+
+// GetAvatarHandler serves an uploaded avatar thumbnail. Thumbnails are stored under
+// the hash of the uploaded image, so they never change and can be cached indefinitely.
+func (as *Service) GetAvatarHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := param.ReadID(r)
+	if err != nil {
+		response.NotFound(w, r, as.Logger)
+		return
+	}
+
+	hash := param.ReadString(r, "hash")
+	size, err := strconv.Atoi(strings.TrimSuffix(param.ReadString(r, "file"), ".png"))
+	if err != nil || !slices.Contains(AvatarSizes, size) || len(hash) != 16 {
+		response.NotFound(w, r, as.Logger)
+		return
+	}
+	if _, err := hex.DecodeString(hash); err != nil {
+		response.NotFound(w, r, as.Logger)
+		return
+	}
+
+	obj, err := as.Storage.Get(r.Context(), avatarKey(userID, hash, size))
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			response.NotFound(w, r, as.Logger)
+		default:
+			response.ServerError(w, r, as.Logger, err)
+		}
+		return
+	}
+	defer obj.Body.Close()
+
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+
+	err = storage.ServeObject(w, r, param.ReadString(r, "file"), obj)
+	if err != nil {
+		response.LogError(r, as.Logger, err)
+	}
+}
+
+// GetIdenticonHandler serves the generated avatar of a user without a profile picture.
+// The image only depends on the user ID, so it is drawn on every request instead of
+// being stored. An optional "size" query parameter selects one of the avatar sizes.
+func (as *Service) GetIdenticonHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := param.ReadID(r)
+	if err != nil {
+		response.NotFound(w, r, as.Logger)
+		return
+	}
+
+	v := validator.New()
+	size := param.ReadQueryInt(r, "size", AvatarSizes[0], v)
+	v.Check(slices.Contains(AvatarSizes, size), "size", "must be a supported avatar size")
+	if !v.Valid() {
+		response.FailedValidation(w, r, as.Logger, v.Errors)
+		return
+	}
+
+	var buf bytes.Buffer
+	err = png.Encode(&buf, identicon(userID, size))
+	if err != nil {
+		response.ServerError(w, r, as.Logger, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.WriteHeader(http.StatusOK)
+
+	_, err = w.Write(buf.Bytes())
+	if err != nil {
+		response.LogError(r, as.Logger, err)
+	}
+}
+
+// deleteAvatar removes the stored thumbnails of a replaced profile picture, if it was
+// an uploaded avatar. Failures are only logged, as the user record is already updated.
+func (as *Service) deleteAvatar(r *http.Request, userID int64, profilePicture string) {
+	hash, ok := parseAvatarURL(userID, profilePicture)
+	if !ok {
+		return
+	}
+
+	for _, size := range AvatarSizes {
+		err := as.Storage.Delete(r.Context(), avatarKey(userID, hash, size))
+		if err != nil {
+			response.LogError(r, as.Logger, err)
+		}
+	}
+}
+
+// LogoutUserHandler ends the session the request was made with, the user stays logged
+// in on other devices. Those can be ended through DeleteSessionHandler.
+func (as *Service) LogoutUserHandler(w http.ResponseWriter, r *http.Request) {
+	user := ContextGetUser(r)
+	session := ContextGetSession(r)
+
+	err := as.Models.DeleteSession(user.ID, session.ID)
+	if err != nil && !errors.Is(err, database.ErrRecordNotFound) {
+		response.ServerError(w, r, as.Logger, err)
+		return
+	}
+
+	as.Audit.Record(r, &audit.Event{
+		Action:     audit.ActionSessionRevoke,
+		ActorID:    user.ID,
+		TargetType: audit.TargetUser,
+		TargetID:   user.ID,
+		Diff:       audit.Details(map[string]any{"session_id": session.ID}),
+	})
+
+	clearAuthCookie(w)
+
+	err = json.WriteResponse(w, http.StatusOK, json.Envelope{"message": "token was successfully deleted"}, nil)
+	if err != nil {
+		response.ServerError(w, r, as.Logger, err)
+	}
+}
+
+// GetSessionsHandler lists the devices the user is logged in on, flagging the one the
+// request was made with.
+func (as *Service) GetSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := ContextGetUser(r)
+	current := ContextGetSession(r)
+
+	sessions, err := as.Models.GetSessionsForUser(user.ID)
+	if err != nil {
+		response.ServerError(w, r, as.Logger, err)
+		return
+	}
+
+	for _, session := range sessions {
+		session.Current = current != nil && session.ID == current.ID
+	}
+
+	err = json.WriteResponse(w, http.StatusOK, json.Envelope{"sessions": sessions}, nil)
+	if err != nil {
+		response.ServerError(w, r, as.Logger, err)
+	}
+}
+
+// DeleteSessionHandler logs the user out of one of their devices, e.g. one they lost or
+// no longer use. Revoking the current session logs them out like LogoutUserHandler.
+func (as *Service) DeleteSessionHandler(w http.ResponseWriter, r *http.Request) {
+	user := ContextGetUser(r)
+	current := ContextGetSession(r)
+
+	sessionID, err := param.ReadID(r)
+	if err != nil {
+		response.NotFound(w, r, as.Logger)
+		return
+	}
+
+	// Scoped to the user, so other users' sessions are reported as not found
+	err = as.Models.DeleteSession(user.ID, sessionID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			response.NotFound(w, r, as.Logger)
+		default:
+			response.ServerError(w, r, as.Logger, err)
+		}
+		return
+	}
+
+	as.Audit.Record(r, &audit.Event{
+		Action:     audit.ActionSessionRevoke,
+		ActorID:    user.ID,
+		TargetType: audit.TargetUser,
+		TargetID:   user.ID,
+		Diff:       audit.Details(map[string]any{"session_id": sessionID}),
+	})
+
+	if current != nil && current.ID == sessionID {
+		clearAuthCookie(w)
+	}
+
+	err = json.WriteResponse(w, http.StatusOK, json.Envelope{"message": "session was successfully deleted"}, nil)
+	if err != nil {
+		response.ServerError(w, r, as.Logger, err)
+	}
+}
+
+func (as *Service) RequestPasswordResetHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Email string `json:"email"`
+	}
+
+	err := json.ReadRequestBody(w, r, &input)
+	if err != nil {
+		response.BadRequest(w, r, as.Logger, err)
+		return
+	}
+
+	v := validator.New()
+	if ValidateEmail(v, input.Email); !v.Valid() {
+		response.FailedValidation(w, r, as.Logger, v.Errors)
+		return
+	}
+
+	// Always respond with the same message, regardless of whether the email belongs
+	// to an account, so this endpoint can't be used to enumerate users.
+	env := json.Envelope{"message": "if an account with that email address exists, you will receive password reset instructions shortly"}
+
+	user, err := as.Models.GetUserByEmail(input.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			err = json.WriteResponse(w, http.StatusAccepted, env, nil)
+			if err != nil {
+				response.ServerError(w, r, as.Logger, err)
+			}
+		default:
+			response.ServerError(w, r, as.Logger, err)
+		}
+		return
+	}
+
+	if user.IsActive {
+		token, err := as.Models.NewToken(user.ID, 45*time.Minute, ScopePasswordReset)
+		if err != nil {
+			response.ServerError(w, r, as.Logger, err)
+			return
+		}
+
+		data := map[string]any{
+			"userName":           user.Name,
+			"passwordResetToken": token.Plaintext,
+			"passwordResetURL":   fmt.Sprintf("%s/password-reset?token=%s", as.Config.BaseURL, token.Plaintext),
+		}
+
+		err = as.Mailer.Send(user.Email, "token_password_reset.tmpl", data)
+		if err != nil {
+			as.Logger.Error(err.Error(), "user_id", user.ID)
+		}
+	}
+
+	err = json.WriteResponse(w, http.StatusAccepted, env, nil)
+	if err != nil {
+		response.ServerError(w, r, as.Logger, err)
+	}
+}
+
+func (as *Service) ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Password string `json:"password"`
+		Token    string `json:"token"`
+	}
+
+	err := json.ReadRequestBody(w, r, &input)
+	if err != nil {
+		response.BadRequest(w, r, as.Logger, err)
+		return
+	}
+
+	v := validator.New()
+	ValidatePasswordPlaintext(v, input.Password)
+	ValidateTokenPlaintext(v, input.Token)
+	if !v.Valid() {
+		response.FailedValidation(w, r, as.Logger, v.Errors)
+		return
+	}
+
+	user, err := as.Models.GetUserFromToken(ScopePasswordReset, input.Token)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			v.AddError("token", "invalid or expired password reset token")
+			response.FailedValidation(w, r, as.Logger, v.Errors)
+		default:
+			response.ServerError(w, r, as.Logger, err)
+		}
+		return
+	}
+
+	err = user.Password.Set(input.Password)
+	if err != nil {
+		response.ServerError(w, r, as.Logger, err)
+		return
+	}
+
+	err = as.Models.UpdateUserByID(user)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrEditConflict):
+			response.EditConflict(w, r, as.Logger)
+		default:
+			response.ServerError(w, r, as.Logger, err)
+		}
+		return
+	}
+
+	// Burn the reset token and log the user out of every existing session, since
+	// anyone holding an old session may be the reason the password was reset.
+	for _, scope := range []string{ScopePasswordReset, ScopeAuthentication} {
+		err = as.Models.DeleteAllTokensForUser(scope, user.ID)
+		if err != nil && !errors.Is(err, database.ErrRecordNotFound) {
+			response.ServerError(w, r, as.Logger, err)
+			return
+		}
+	}
+
+	as.Audit.Record(r, &audit.Event{
+		Action:     audit.ActionTokensRevoke,
+		ActorID:    user.ID,
+		TargetType: audit.TargetUser,
+		TargetID:   user.ID,
+		Diff:       audit.Details(map[string]any{"reason": "password reset"}),
+	})
+
+	err = json.WriteResponse(w, http.StatusOK, json.Envelope{"message": "your password was successfully reset"}, nil)
+	if err != nil {
+		response.ServerError(w, r, as.Logger, err)
+	}
+}
+
+// UpdateUserHandler lets admins change the fields of an account users can't change
+// themselves: its role, whether it is active, and the provider it signs in with.
+func (as *Service) UpdateUserHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		RoleID   *RoleID `json:"role_id"`
+		IsActive *bool   `json:"is_active"`
+		Provider *string `json:"provider"`
+	}
+
+	err := json.ReadRequestBody(w, r, &input)
+	if err != nil {
+		response.BadRequest(w, r, as.Logger, err)
+		return
+	}
+
+	user, ok := as.readUser(w, r)
+	if !ok {
+		return
+	}
+	before := *user
+
+	v := validator.New()
+
+	// Stop admins from locking themselves, and possibly everyone, out of the admin API
+	if user.ID == ContextGetUser(r).ID {
+		v.Check(input.RoleID == nil || *input.RoleID == user.RoleID, "role_id", "cannot change your own role")
+		v.Check(input.IsActive == nil || *input.IsActive, "is_active", "cannot deactivate yourself")
+	}
+
+	if input.RoleID != nil {
+		v.Check(validator.PermittedValue(*input.RoleID, RoleBasic, RoleAdmin), "role_id", "must be a valid role")
+		user.RoleID = *input.RoleID
+	}
+	if input.IsActive != nil {
+		user.IsActive = *input.IsActive
+	}
+	if input.Provider != nil {
+		providers := []string{ProviderNone}
+		for name := range as.Providers {
+			providers = append(providers, name)
+		}
+		v.Check(validator.PermittedValue(*input.Provider, providers...), "provider", "must be a configured provider")
+		v.Check(*input.Provider != ProviderNone || user.Password.hash != nil, "provider", "user has no password to sign in with")
+		user.Provider = *input.Provider
+	}
+
+	if !v.Valid() {
+		response.FailedValidation(w, r, as.Logger, v.Errors)
+		return
+	}
+
+	err = as.Models.UpdateUserByID(user)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrEditConflict):
+			response.EditConflict(w, r, as.Logger)
+		default:
+			response.ServerError(w, r, as.Logger, err)
+		}
+		return
+	}
+
+	as.Audit.Record(r, &audit.Event{
+		Action:     audit.ActionUserUpdate,
+		ActorID:    ContextGetUser(r).ID,
+		TargetType: audit.TargetUser,
+		TargetID:   user.ID,
+		Diff:       audit.Diff(before, user),
+	})
+
+	// Deactivated users are refused at login, end the sessions they already have too
+	if !user.IsActive {
+		err = as.Models.DeleteAllTokensForUser(ScopeAuthentication, user.ID)
+		if err != nil && !errors.Is(err, database.ErrRecordNotFound) {
+			response.ServerError(w, r, as.Logger, err)
+			return
+		}
+	}
+
+	err = json.WriteResponse(w, http.StatusOK, json.Envelope{"user": user}, nil)
+	if err != nil {
+		response.ServerError(w, r, as.Logger, err)
+	}
+}
+
+// GetUsersHandler lists users for admins, optionally filtered by a part of their email
+// address, role, and verified or active status.
+func (as *Service) GetUsersHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	filters := UserFilters{
+		Email:      param.ReadQueryString(r, "email", ""),
+		RoleID:     RoleID(param.ReadQueryInt(r, "role_id", 0, v)),
+		IsVerified: param.ReadQueryBool(r, "is_verified", v),
+		IsActive:   param.ReadQueryBool(r, "is_active", v),
+		Limit:      param.ReadQueryInt(r, "limit", 50, v),
+		Offset:     param.ReadQueryInt(r, "offset", 0, v),
+	}
+
+	if ValidateUserFilters(v, filters); !v.Valid() {
+		response.FailedValidation(w, r, as.Logger, v.Errors)
+		return
+	}
+
+	users, total, err := as.Models.GetUsers(filters)
+	if err != nil {
+		response.ServerError(w, r, as.Logger, err)
+		return
+	}
+
+	err = json.WriteResponse(w, http.StatusOK, json.Envelope{"users": users, "total": total, "filters": filters}, nil)
+	if err != nil {
+		response.ServerError(w, r, as.Logger, err)
+	}
+}
+
+func (as *Service) GetUserHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := as.readUser(w, r)
+	if !ok {
+		return
+	}
+
+	err := json.WriteResponse(w, http.StatusOK, json.Envelope{"user": user}, nil)
+	if err != nil {
+		response.ServerError(w, r, as.Logger, err)
+	}
+}
+
+// DeleteUserHandler permanently deletes a user along with their tokens and scores. Admins
+// can't delete themselves.
+func (as *Service) DeleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := as.readUser(w, r)
+	if !ok {
+		return
+	}
+
+	if user.ID == ContextGetUser(r).ID {
+		v := validator.New()
+		v.AddError("id", "cannot delete yourself")
+		response.FailedValidation(w, r, as.Logger, v.Errors)
+		return
+	}
+
+	err := as.Models.DeleteUserByID(user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			response.NotFound(w, r, as.Logger)
+		default:
+			response.ServerError(w, r, as.Logger, err)
+		}
+		return
+	}
+
+	as.Audit.Record(r, &audit.Event{
+		Action:     audit.ActionUserDelete,
+		ActorID:    ContextGetUser(r).ID,
+		TargetType: audit.TargetUser,
+		TargetID:   user.ID,
+		Diff:       audit.Diff(user, struct{}{}),
+	})
+	as.deleteAvatar(r, user.ID, user.ProfilePicture)
+
+	err = json.WriteResponse(w, http.StatusOK, json.Envelope{"message": "user successfully deleted"}, nil)
+	if err != nil {
+		response.ServerError(w, r, as.Logger, err)
+	}
+}
+
+// DeleteUserSessionsHandler force logs out a user by revoking every authentication
+// token they hold, e.g. when their account is suspected to be compromised.
+func (as *Service) DeleteUserSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := as.readUser(w, r)
+	if !ok {
+		return
+	}
+
+	err := as.Models.DeleteAllTokensForUser(ScopeAuthentication, user.ID)
+	if err != nil && !errors.Is(err, database.ErrRecordNotFound) {
+		response.ServerError(w, r, as.Logger, err)
+		return
+	}
+
+	as.Audit.Record(r, &audit.Event{Action: audit.ActionTokensRevoke, ActorID: ContextGetUser(r).ID, TargetType: audit.TargetUser, TargetID: user.ID})
+
+	err = json.WriteResponse(w, http.StatusOK, json.Envelope{"message": "user was logged out of every session"}, nil)
+	if err != nil {
+		response.ServerError(w, r, as.Logger, err)
+	}
+}
+
+// UnlockUserHandler lifts the lock put on a user's account after too many failed
+// logins, e.g. once they have confirmed it was them, without waiting for it to expire.
+func (as *Service) UnlockUserHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := as.readUser(w, r)
+	if !ok {
+		return
+	}
+
+	err := as.Models.DeleteLoginFailures(LoginScopeAccount, accountSubject(user.Email))
+	if err != nil && !errors.Is(err, database.ErrRecordNotFound) {
+		response.ServerError(w, r, as.Logger, err)
+		return
+	}
+
+	as.Audit.Record(r, &audit.Event{Action: audit.ActionUserUnlock, ActorID: ContextGetUser(r).ID, TargetType: audit.TargetUser, TargetID: user.ID})
+
+	err = json.WriteResponse(w, http.StatusOK, json.Envelope{"message": "user account was unlocked"}, nil)
+	if err != nil {
+		response.ServerError(w, r, as.Logger, err)
+	}
+}
+
+// readUser looks up the user identified by the "id" URL parameter. On failure an error
+// response has already been written and false is returned.
+func (as *Service) readUser(w http.ResponseWriter, r *http.Request) (*User, bool) {
+	userID, err := param.ReadID(r)
+	if err != nil {
+		response.NotFound(w, r, as.Logger)
+		return nil, false
+	}
+
+	user, err := as.Models.GetUserByID(userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			response.NotFound(w, r, as.Logger)
+		default:
+			response.ServerError(w, r, as.Logger, err)
+		}
+		return nil, false
+	}
+
+	return user, true
+}
+
+// recordFailedLogin audits a rejected login attempt. The user ID is zero if no account
+// matches the email address.
+func (as *Service) recordFailedLogin(r *http.Request, userID int64, email, reason string) {
+	as.Audit.Record(r, &audit.Event{
+		Action:     audit.ActionLoginFailed,
+		TargetType: audit.TargetUser,
+		TargetID:   userID,
+		Diff:       audit.Details(map[string]any{"email": email, "reason": reason}),
+	})
+}
+
+// loginAllowed reports whether a login attempt for the email can be made from the
+// client's IP, writing the error response if not.
+func (as *Service) loginAllowed(w http.ResponseWriter, r *http.Request, email string) bool {
+	account, byIP, err := as.Models.GetLoginFailures(email, clientip.Get(r))
+	if err != nil {
+		response.ServerError(w, r, as.Logger, err)
+		return false
+	}
+
+	now := time.Now()
+	if wait := byIP.Locked(now); wait > 0 {
+		response.RetryAfter(w, wait)
+		response.RateLimitExceeded(w, r, as.Logger)
+		return false
+	}
+	if wait := account.Locked(now); wait > 0 {
+		response.AccountLocked(w, r, as.Logger, wait)
+		return false
+	}
+	if wait := account.Delay(now); wait > 0 {
+		response.RetryAfter(w, wait)
+		response.RateLimitExceeded(w, r, as.Logger)
+		return false
+	}
+
+	return true
+}
+
+// loginFailed counts a failed login against the account with the email and the
+// client's IP, locking either once it has failed too often, and writes the error
+// response. The user is nil if no account matches the email.
+func (as *Service) loginFailed(w http.ResponseWriter, r *http.Request, email string, user *User) {
+	ip := clientip.Get(r)
+	lockout := as.Config.LoginLockout
+
+	// Failures older than the lockout no longer count for anything
+	err := as.Models.DeleteStaleLoginFailures(lockout)
+	if err != nil {
+		as.Logger.Error(err.Error())
+	}
+
+	byIP, err := as.Models.IncrementLoginFailures(LoginScopeIP, ip, as.Config.LoginMaxFailuresIP, lockout)
+	switch {
+	case errors.Is(err, database.ErrRecordNotFound):
+		// Locked by an attempt made at the same time
+	case err != nil:
+		response.ServerError(w, r, as.Logger, err)
+		return
+	case byIP.LockedUntil != nil:
+		as.Logger.Warn("too many failed logins, IP locked", "ip", ip, "failures", byIP.Count)
+	}
+
+	account, err := as.Models.IncrementLoginFailures(LoginScopeAccount, accountSubject(email), as.Config.LoginMaxFailures, lockout)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			// Locked by an attempt made at the same time, which told the user
+			response.AccountLocked(w, r, as.Logger, lockout)
+		default:
+			response.ServerError(w, r, as.Logger, err)
+		}
+		return
+	}
+	if account.LockedUntil == nil {
+		response.InvalidCredentials(w, r, as.Logger)
+		return
+	}
+
+	// Unknown emails are locked the same, there is just nobody to tell
+	if user != nil {
+		as.Audit.Record(r, &audit.Event{
+			Action:     audit.ActionUserLock,
+			TargetType: audit.TargetUser,
+			TargetID:   user.ID,
+			Diff:       audit.Details(map[string]any{"failures": account.Count, "locked_until": account.LockedUntil}),
+		})
+
+		data := map[string]any{
+			"userName":       user.Name,
+			"lockoutMinutes": int(lockout.Minutes()),
+		}
+
+		err = as.Mailer.Send(user.Email, "user_account_locked.tmpl", data)
+		if err != nil {
+			as.Logger.Error(err.Error(), "user_id", user.ID)
+		}
+	}
+
+	response.AccountLocked(w, r, as.Logger, lockout)
+}
+
+// useTwoFactorCode checks a TOTP or recovery code, and uses it up so it can't be
+// replayed. Returns false if it's wrong or was already used.
+func (as *Service) useTwoFactorCode(tf *TwoFactor, code string) (bool, error) {
+	if isRecoveryCode(code) {
+		err := as.Models.UseRecoveryCode(tf.UserID, hashRecoveryCode(code))
+		if errors.Is(err, database.ErrRecordNotFound) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	step, ok := tf.ValidateCode(code, time.Now())
+	if !ok {
+		return false, nil
+	}
+
+	err := as.Models.UseTwoFactorStep(tf.UserID, step)
+	if errors.Is(err, database.ErrEditConflict) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (as *Service) OAuthStartHandler(w http.ResponseWriter, r *http.Request) {
+	provider, ok := as.Providers[param.ReadString(r, "provider")]
+	if !ok {
+		response.NotFound(w, r, as.Logger)
+		return
+	}
+
+	var values [3]string // state, nonce, PKCE code verifier
+	for i := range values {
+		value, err := oidc.RandomString()
+		if err != nil {
+			response.ServerError(w, r, as.Logger, err)
+			return
+		}
+		values[i] = value
+	}
+	state, nonce, verifier := values[0], values[1], values[2]
+
+	authURL, err := provider.AuthCodeURL(r.Context(), state, nonce, verifier)
+	if err != nil {
+		response.ServerError(w, r, as.Logger, err)
+		return
+	}
+
+	// Lax so the cookie is sent along with the provider's top-level redirect back
+	// to the callback endpoint
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieOAuthState,
+		Value:    strings.Join(values[:], "."),
 		HttpOnly: true,
-		Secure:   false, // Use true if your app is served over HTTPS
-		SameSite: http.SameSiteStrictMode,
-		Path:     "/",
+		Secure:   false, // Use true for HTTPS
+		SameSite: http.SameSiteLaxMode,
+		Path:     "/api/auth/oauth/",
+		MaxAge:   int((10 * time.Minute).Seconds()),
+	})
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+func (as *Service) OAuthCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	providerName := param.ReadString(r, "provider")
+	provider, ok := as.Providers[providerName]
+	if !ok {
+		response.NotFound(w, r, as.Logger)
+		return
+	}
+
+	cookie, err := r.Cookie(CookieOAuthState)
+	if err != nil {
+		response.BadRequest(w, r, as.Logger, errors.New("missing or expired oauth state"))
+		return
+	}
+
+	// The state cookie is single use
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieOAuthState,
+		Value:    "",
+		HttpOnly: true,
+		Secure:   false, // Use true for HTTPS
+		SameSite: http.SameSiteLaxMode,
+		Path:     "/api/auth/oauth/",
+		MaxAge:   -1,
 	})
 
-	err = json.WriteResponse(w, http.StatusOK, json.Envelope{"message": "tokens were successfully deleted"}, nil)
+	values := strings.Split(cookie.Value, ".")
+	if len(values) != 3 {
+		response.BadRequest(w, r, as.Logger, errors.New("missing or expired oauth state"))
+		return
+	}
+	state, nonce, verifier := values[0], values[1], values[2]
+
+	query := r.URL.Query()
+	if providerErr := query.Get("error"); providerErr != "" {
+		response.BadRequest(w, r, as.Logger, fmt.Errorf("oauth provider returned an error: %s", providerErr))
+		return
+	}
+
+	if subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(state)) != 1 {
+		response.BadRequest(w, r, as.Logger, errors.New("invalid oauth state"))
+		return
+	}
+
+	claims, err := provider.Exchange(r.Context(), query.Get("code"), verifier, nonce)
+	if err != nil {
+		response.LogError(r, as.Logger, err)
+		response.InvalidCredentials(w, r, as.Logger)
+		return
+	}
+
+	v := validator.New()
+	ValidateEmail(v, claims.Email)
+	v.Check(claims.EmailVerified, "email", "must be verified by the provider")
+	if !v.Valid() {
+		response.FailedValidation(w, r, as.Logger, v.Errors)
+		return
+	}
+
+	user, err := as.Models.GetUserByEmail(claims.Email)
+	switch {
+	case errors.Is(err, database.ErrRecordNotFound):
+		name := claims.Name
+		if name == "" {
+			name, _, _ = strings.Cut(claims.Email, "@")
+		}
+
+		user = &User{
+			Email:      claims.Email,
+			IsActive:   true,
+			IsVerified: true,
+			Name:       name,
+			Provider:   providerName,
+			RoleID:     RoleBasic,
+		}
+
+		if ValidateUser(v, user); !v.Valid() {
+			response.FailedValidation(w, r, as.Logger, v.Errors)
+			return
+		}
+
+		// Registered since the lookup above, signing in again links it instead
+		err = as.Models.InsertUser(user)
+		if err != nil {
+			switch {
+			case errors.Is(err, database.ErrDuplicateEmail):
+				response.AccountExists(w, r, as.Logger)
+			default:
+				response.ServerError(w, r, as.Logger, err)
+			}
+			return
+		}
+	case err != nil:
+		response.ServerError(w, r, as.Logger, err)
+		return
+	default:
+		if !user.IsActive {
+			as.recordFailedLogin(r, user.ID, claims.Email, "account inactive")
+			response.AccountInactive(w, r, as.Logger)
+			return
+		}
+
+		// Link the existing account. The provider has verified the email address, so
+		// the account can be marked as verified too. Whoever registered an unverified
+		// account never proved owning the address though, so its password, sessions and
+		// second factor are dropped and only the owner of the address keeps access.
+		if !user.IsVerified || user.Provider == ProviderNone {
+			unverified := !user.IsVerified
+			if unverified {
+				user.Password.hash = nil
+			}
+
+			user.IsVerified = true
+			if user.Provider == ProviderNone {
+				user.Provider = providerName
+			}
+
+			err = as.Models.UpdateUserByID(user)
+			if err != nil {
+				switch {
+				case errors.Is(err, database.ErrEditConflict):
+					response.EditConflict(w, r, as.Logger)
+				default:
+					response.ServerError(w, r, as.Logger, err)
+				}
+				return
+			}
+
+			if unverified {
+				err = as.Models.DeleteTokensForUser(user.ID)
+				if err != nil && !errors.Is(err, database.ErrRecordNotFound) {
+					response.ServerError(w, r, as.Logger, err)
+					return
+				}
+
+				err = as.Models.DeleteTwoFactor(user.ID)
+				if err != nil && !errors.Is(err, database.ErrRecordNotFound) {
+					response.ServerError(w, r, as.Logger, err)
+					return
+				}
+			}
+		}
+	}
+
+	tf, err := as.Models.GetTwoFactor(user.ID)
+	if err != nil && !errors.Is(err, database.ErrRecordNotFound) {
+		response.ServerError(w, r, as.Logger, err)
+		return
+	}
+
+	// The provider stands in for the password only, the frontend asks for a code and
+	// completes the login through LoginTwoFactorHandler
+	if tf.Enabled() {
+		challenge, err := as.Models.NewToken(user.ID, twoFactorChallengeTTL, ScopeTwoFactor)
+		if err != nil {
+			response.ServerError(w, r, as.Logger, err)
+			return
+		}
+
+		// Handed over in a cookie rather than the URL, which ends up in the browser
+		// history and server logs
+		http.SetCookie(w, &http.Cookie{
+			Name:     CookieTwoFactorToken,
+			Value:    challenge.Plaintext,
+			HttpOnly: true,
+			Secure:   false, // Use true for HTTPS
+			SameSite: http.SameSiteStrictMode,
+			Path:     "/api/auth/login/2fa",
+			Expires:  challenge.Expiry,
+		})
+
+		http.Redirect(w, r, as.Config.BaseURL+"/login/2fa", http.StatusSeeOther)
+		return
+	}
+
+	err = as.startSession(w, r, user, false)
 	if err != nil {
 		response.ServerError(w, r, as.Logger, err)
+		return
+	}
+
+	as.Audit.Record(r, &audit.Event{
+		Action:     audit.ActionLogin,
+		ActorID:    user.ID,
+		TargetType: audit.TargetUser,
+		TargetID:   user.ID,
+		Diff:       audit.Details(map[string]any{"provider": providerName}),
+	})
+
+	http.Redirect(w, r, as.Config.BaseURL, http.StatusSeeOther)
+}
+
+// startSession issues an authentication token for the device the request was made
+// from, and sets it as the auth cookie. twoFactor records that a second factor was
+// checked, which only LoginTwoFactorHandler does.
+func (as *Service) startSession(w http.ResponseWriter, r *http.Request, user *User, twoFactor bool) error {
+	token, err := generateToken(user.ID, sessionTTL, ScopeAuthentication)
+	if err != nil {
+		return err
+	}
+
+	token.UserAgent = truncateUserAgent(r.UserAgent())
+	token.IP = clientip.Get(r)
+	token.TwoFactor = twoFactor
+
+	err = as.Models.InsertToken(token)
+	if err != nil {
+		return err
 	}
+
+	setAuthCookie(w, token)
+	return nil
+}
+
+// Set token as an HttpOnly cookie
+func setAuthCookie(w http.ResponseWriter, token *Token) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieAuthToken,
+		Value:    token.Plaintext,
+		HttpOnly: true,
+		Secure:   false, // Use true for HTTPS
+		SameSite: http.SameSiteStrictMode,
+		Path:     "/",
+		Expires:  token.Expiry,
+	})
+}
+
+// The challenge token is single use, remove its cookie once exchanged
+func clearTwoFactorCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieTwoFactorToken,
+		Value:    "",
+		HttpOnly: true,
+		Secure:   false, // Use true for HTTPS
+		SameSite: http.SameSiteStrictMode,
+		Path:     "/api/auth/login/2fa",
+		MaxAge:   -1,
+	})
+}
+
+// Set the cookie with an expired date to remove it
+func clearAuthCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieAuthToken,
+		Value:    "",
+		Expires:  time.Unix(0, 0), // Expire in the past (January 1, 1970)
+		HttpOnly: true,
+		Secure:   false, // Use true if your app is served over HTTPS
+		SameSite: http.SameSiteStrictMode,
+		Path:     "/",
+	})
 }
@@ -2,15 +2,26 @@ package auth
 
 import (
 	"bytes"
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"image/png"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/navazjm/pixelarcade/internal/webapp/audit"
+	"github.com/navazjm/pixelarcade/internal/webapp/mailer"
+	"github.com/navazjm/pixelarcade/internal/webapp/oidc"
+	"github.com/navazjm/pixelarcade/internal/webapp/storage"
 	"github.com/navazjm/pixelarcade/internal/webapp/utils/database"
+	"github.com/navazjm/pixelarcade/internal/webapp/utils/param"
 )
 
 func TestRegisterNewUserHandler(t *testing.T) {
@@ -18,10 +29,14 @@ func TestRegisterNewUserHandler(t *testing.T) {
 
 	now := time.Now()
 	mock.ExpectQuery("INSERT INTO auth_users").
-		WithArgs("mike", "mike@test.com", sqlmock.AnyArg(), defaultProfilePicture, "N/A", true, false).
+		WithArgs("mike", "mike@test.com", sqlmock.AnyArg(), "", "N/A", true, false).
 		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "version", "role_id"}).
 			AddRow(1, now, now, 1, 1))
 
+	mock.ExpectExec("INSERT INTO auth_tokens").
+		WithArgs(sqlmock.AnyArg(), 1, sqlmock.AnyArg(), ScopeActivation, "", "", "", false).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
 	reqBody := map[string]any{
 		"name":     "mike",
 		"email":    "mike@test.com",
@@ -42,6 +57,11 @@ func TestRegisterNewUserHandler(t *testing.T) {
 		t.Errorf("expected status %d, got %d", http.StatusCreated, resp.StatusCode)
 	}
 
+	sent := authService.Mailer.(*mailer.Mock).Messages()
+	if len(sent) != 1 || sent[0].Recipient != "mike@test.com" {
+		t.Errorf("expected activation email to be sent to mike@test.com, got %v", sent)
+	}
+
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unmet expectations: %v", err)
 	}
@@ -52,7 +72,7 @@ func TestRegisterUser_EmailTaken(t *testing.T) {
 
 	// Simulate a unique constraint violation error on email
 	mock.ExpectQuery("INSERT INTO auth_users").
-		WithArgs("mike", "mike@test.com", sqlmock.AnyArg(), defaultProfilePicture, "N/A", true, false).
+		WithArgs("mike", "mike@test.com", sqlmock.AnyArg(), "", "N/A", true, false).
 		WillReturnError(fmt.Errorf("pq: duplicate key value violates unique constraint \"auth_users_email_key\""))
 
 	reqBody := map[string]any{
@@ -80,6 +100,45 @@ func TestRegisterUser_EmailTaken(t *testing.T) {
 	}
 }
 
+var loginFailureColumns = []string{"scope", "subject", "count", "last_failed_at", "locked_until"}
+
+// expectLoginAllowed expects the failed logins of the email and of the test client's IP
+// to be looked up, finding none.
+func expectLoginAllowed(mock sqlmock.Sqlmock, email string) {
+	mock.ExpectQuery("SELECT scope, subject, count, last_failed_at, locked_until FROM auth_login_failures").
+		WithArgs(LoginScopeAccount, email, LoginScopeIP, "192.0.2.1").
+		WillReturnRows(sqlmock.NewRows(loginFailureColumns))
+}
+
+// expectLoginFailed expects a failed login to be counted against the test client's IP
+// and the email, the latter reaching the given count without being locked.
+func expectLoginFailed(mock sqlmock.Sqlmock, email string, count int) {
+	mock.ExpectExec("DELETE FROM auth_login_failures WHERE last_failed_at").
+		WithArgs((15 * time.Minute).Seconds()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	mock.ExpectQuery("INSERT INTO auth_login_failures").
+		WithArgs(LoginScopeIP, "192.0.2.1", 20, (15 * time.Minute).Seconds()).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "last_failed_at", "locked_until"}).AddRow(1, time.Now(), nil))
+
+	mock.ExpectQuery("INSERT INTO auth_login_failures").
+		WithArgs(LoginScopeAccount, email, 5, (15 * time.Minute).Seconds()).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "last_failed_at", "locked_until"}).AddRow(count, time.Now(), nil))
+}
+
+// expectTwoFactor expects the user's two-factor authentication to be looked up, enabled
+// with the secret, or never enrolled if the secret is nil.
+func expectTwoFactor(mock sqlmock.Sqlmock, userID int64, secret []byte) {
+	query := mock.ExpectQuery("SELECT user_id, secret, confirmed_at, last_used_step FROM auth_two_factor").
+		WithArgs(userID)
+	if secret == nil {
+		query.WillReturnError(sql.ErrNoRows)
+		return
+	}
+	query.WillReturnRows(sqlmock.NewRows([]string{"user_id", "secret", "confirmed_at", "last_used_step"}).
+		AddRow(userID, secret, time.Now(), 0))
+}
+
 func TestLoginUser_ValidLogin(t *testing.T) {
 	authService, mock := newMockService(t)
 
@@ -89,6 +148,8 @@ func TestLoginUser_ValidLogin(t *testing.T) {
 		t.Errorf("failed to hash password: %s", err.Error())
 	}
 
+	expectLoginAllowed(mock, "mike@test.com")
+
 	mock.ExpectQuery("SELECT .* FROM auth_users WHERE email = ?").
 		WithArgs("mike@test.com").
 		WillReturnRows(sqlmock.NewRows([]string{
@@ -99,8 +160,16 @@ func TestLoginUser_ValidLogin(t *testing.T) {
 			"default_profile_pic.jpg", password.hash, "N/A", 1, false,
 		))
 
+	expectTwoFactor(mock, 1, nil)
+
+	// A successful login forgets earlier failed ones
+	mock.ExpectExec("DELETE FROM auth_login_failures").
+		WithArgs(LoginScopeAccount, "mike@test.com").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// The session records the device it was started from
 	mock.ExpectExec("INSERT INTO auth_tokens").
-		WithArgs(sqlmock.AnyArg(), 1, sqlmock.AnyArg(), "authentication").
+		WithArgs(sqlmock.AnyArg(), 1, sqlmock.AnyArg(), ScopeAuthentication, "Mozilla/5.0", "192.0.2.1", "", false).
 		WillReturnResult(sqlmock.NewResult(1, 1)) // Simulating an insert with 1 affected row
 
 	reqBody := map[string]any{
@@ -111,6 +180,7 @@ func TestLoginUser_ValidLogin(t *testing.T) {
 
 	req := httptest.NewRequest(http.MethodPost, "/api/auth/login", bytes.NewReader(jsonData))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Mozilla/5.0")
 	w := httptest.NewRecorder()
 
 	authService.LoginUserHandler(w, req)
@@ -127,6 +197,42 @@ func TestLoginUser_ValidLogin(t *testing.T) {
 	}
 }
 
+func TestLoginUser_InactiveUser(t *testing.T) {
+	authService, mock := newMockService(t)
+
+	var password password
+	err := password.Set("SecurePass123!")
+	if err != nil {
+		t.Errorf("failed to hash password: %s", err.Error())
+	}
+
+	expectLoginAllowed(mock, "mike@test.com")
+
+	mock.ExpectQuery("SELECT .* FROM auth_users WHERE email = ?").
+		WithArgs("mike@test.com").
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "created_at", "updated_at", "version", "is_active", "email", "name",
+			"profile_picture", "password", "provider", "role_id", "is_verified",
+		}).AddRow(
+			1, time.Now(), time.Now(), 1, false, "mike@test.com", "Mike",
+			"", password.hash, "N/A", 1, true,
+		))
+
+	jsonData, _ := json.Marshal(map[string]any{"email": "mike@test.com", "password": "SecurePass123!"})
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/login", bytes.NewReader(jsonData))
+	w := httptest.NewRecorder()
+
+	authService.LoginUserHandler(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status %d, got %d", http.StatusForbidden, w.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unmet expectations: %v", err)
+	}
+}
+
 func TestLoginUser_MissingEmail(t *testing.T) {
 	authService, _ := newMockService(t)
 
@@ -197,10 +303,15 @@ func TestLoginUser_InvalidEmailFormat(t *testing.T) {
 func TestLoginUser_UserNotFound(t *testing.T) {
 	authService, mock := newMockService(t)
 
+	expectLoginAllowed(mock, "nonexistent@test.com")
+
 	mock.ExpectQuery("SELECT .* FROM auth_users WHERE email = ?").
 		WithArgs("nonexistent@test.com").
 		WillReturnError(database.ErrRecordNotFound)
 
+	// Unknown emails are counted the same as registered ones
+	expectLoginFailed(mock, "nonexistent@test.com", 1)
+
 	reqBody := map[string]any{
 		"email":    "nonexistent@test.com",
 		"password": "SecurePass123!",
@@ -234,6 +345,8 @@ func TestLoginUser_PasswordMismatch(t *testing.T) {
 		t.Errorf("failed to hash password: %s", err.Error())
 	}
 
+	expectLoginAllowed(mock, "mike@test.com")
+
 	mock.ExpectQuery("SELECT .* FROM auth_users WHERE email = ?").
 		WithArgs("mike@test.com").
 		WillReturnRows(sqlmock.NewRows([]string{
@@ -244,6 +357,8 @@ func TestLoginUser_PasswordMismatch(t *testing.T) {
 			"default_profile_pic.jpg", password.hash, "N/A", 1, false,
 		))
 
+	expectLoginFailed(mock, "mike@test.com", 1)
+
 	reqBody := map[string]any{
 		"email":    "mike@test.com",
 		"password": "SecurePass123!", // Incorrect password
@@ -263,7 +378,2171 @@ func TestLoginUser_PasswordMismatch(t *testing.T) {
 		t.Errorf("expected status %d, got %d", http.StatusUnauthorized, resp.StatusCode)
 	}
 
+	events := authService.Audit.(*audit.Mock).Events()
+	if len(events) != 1 || events[0].Action != audit.ActionLoginFailed || events[0].TargetID != 1 || events[0].ActorID != 0 {
+		t.Errorf("expected an anonymous failed login for user 1 to be audited, got %+v", events)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unmet expectations: %v", err)
+	}
+}
+
+func TestLoginUser_Lockout(t *testing.T) {
+	var password password
+	err := password.Set("SecurePass123!")
+	if err != nil {
+		t.Fatalf("failed to hash password: %s", err.Error())
+	}
+
+	userRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{
+			"id", "created_at", "updated_at", "version", "is_active", "email", "name",
+			"profile_picture", "password", "provider", "role_id", "is_verified",
+		}).AddRow(1, time.Now(), time.Now(), 1, true, "mike@test.com", "Mike", "", password.hash, "N/A", 1, true)
+	}
+
+	newRequest := func(password string) *http.Request {
+		jsonData, _ := json.Marshal(map[string]any{"email": "Mike@test.com", "password": password})
+		return httptest.NewRequest(http.MethodPost, "/api/auth/login", bytes.NewReader(jsonData))
+	}
+
+	t.Run("SUCCESS Account locked after too many failures", func(t *testing.T) {
+		authService, mock := newMockService(t)
+
+		expectLoginAllowed(mock, "mike@test.com")
+		mock.ExpectQuery("SELECT .* FROM auth_users WHERE email = ?").
+			WithArgs("Mike@test.com").
+			WillReturnRows(userRows())
+		mock.ExpectExec("DELETE FROM auth_login_failures WHERE last_failed_at").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("INSERT INTO auth_login_failures").
+			WithArgs(LoginScopeIP, "192.0.2.1", 20, (15 * time.Minute).Seconds()).
+			WillReturnRows(sqlmock.NewRows([]string{"count", "last_failed_at", "locked_until"}).AddRow(5, time.Now(), nil))
+		mock.ExpectQuery("INSERT INTO auth_login_failures").
+			WithArgs(LoginScopeAccount, "mike@test.com", 5, (15 * time.Minute).Seconds()).
+			WillReturnRows(sqlmock.NewRows([]string{"count", "last_failed_at", "locked_until"}).
+				AddRow(5, time.Now(), time.Now().Add(15*time.Minute)))
+
+		w := httptest.NewRecorder()
+		authService.LoginUserHandler(w, newRequest("WrongPass123!"))
+
+		if w.Code != http.StatusLocked {
+			t.Errorf("expected status %d, got %d", http.StatusLocked, w.Code)
+		}
+		if w.Header().Get("Retry-After") != "900" {
+			t.Errorf("expected Retry-After header to be 900, got %q", w.Header().Get("Retry-After"))
+		}
+
+		sent := authService.Mailer.(*mailer.Mock).Messages()
+		if len(sent) != 1 || sent[0].Recipient != "mike@test.com" {
+			t.Errorf("expected the user to be notified of the lockout, got %d emails", len(sent))
+		}
+
+		events := authService.Audit.(*audit.Mock).Events()
+		if len(events) != 2 || events[1].Action != audit.ActionUserLock || events[1].TargetID != 1 {
+			t.Errorf("expected the failed login and lockout to be audited, got %+v", events)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("SUCCESS Account locked by an attempt made at the same time", func(t *testing.T) {
+		authService, mock := newMockService(t)
+
+		expectLoginAllowed(mock, "mike@test.com")
+		mock.ExpectQuery("SELECT .* FROM auth_users WHERE email = ?").
+			WithArgs("Mike@test.com").
+			WillReturnRows(userRows())
+		mock.ExpectExec("DELETE FROM auth_login_failures WHERE last_failed_at").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("INSERT INTO auth_login_failures").
+			WithArgs(LoginScopeIP, "192.0.2.1", 20, (15 * time.Minute).Seconds()).
+			WillReturnRows(sqlmock.NewRows([]string{"count", "last_failed_at", "locked_until"}).AddRow(6, time.Now(), nil))
+		mock.ExpectQuery("INSERT INTO auth_login_failures").
+			WithArgs(LoginScopeAccount, "mike@test.com", 5, (15 * time.Minute).Seconds()).
+			WillReturnError(sql.ErrNoRows)
+
+		w := httptest.NewRecorder()
+		authService.LoginUserHandler(w, newRequest("WrongPass123!"))
+
+		if w.Code != http.StatusLocked {
+			t.Errorf("expected status %d, got %d", http.StatusLocked, w.Code)
+		}
+
+		// The attempt that locked the account already told the user
+		if sent := authService.Mailer.(*mailer.Mock).Messages(); len(sent) != 0 {
+			t.Errorf("expected no email, got %d", len(sent))
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("ERROR Locked account refused before checking the password", func(t *testing.T) {
+		authService, mock := newMockService(t)
+
+		mock.ExpectQuery("SELECT scope, subject, count, last_failed_at, locked_until FROM auth_login_failures").
+			WithArgs(LoginScopeAccount, "mike@test.com", LoginScopeIP, "192.0.2.1").
+			WillReturnRows(sqlmock.NewRows(loginFailureColumns).
+				AddRow(LoginScopeAccount, "mike@test.com", 5, time.Now().Add(-5*time.Minute), time.Now().Add(10*time.Minute)))
+
+		w := httptest.NewRecorder()
+		authService.LoginUserHandler(w, newRequest("SecurePass123!"))
+
+		if w.Code != http.StatusLocked {
+			t.Errorf("expected status %d, got %d", http.StatusLocked, w.Code)
+		}
+		if w.Header().Get("Retry-After") != "600" {
+			t.Errorf("expected Retry-After header to be 600, got %q", w.Header().Get("Retry-After"))
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("ERROR Attempt made before the delay is over", func(t *testing.T) {
+		authService, mock := newMockService(t)
+
+		// The 4th failure delays the next attempt by 2 seconds
+		mock.ExpectQuery("SELECT scope, subject, count, last_failed_at, locked_until FROM auth_login_failures").
+			WithArgs(LoginScopeAccount, "mike@test.com", LoginScopeIP, "192.0.2.1").
+			WillReturnRows(sqlmock.NewRows(loginFailureColumns).
+				AddRow(LoginScopeAccount, "mike@test.com", 4, time.Now(), nil))
+
+		w := httptest.NewRecorder()
+		authService.LoginUserHandler(w, newRequest("SecurePass123!"))
+
+		if w.Code != http.StatusTooManyRequests {
+			t.Errorf("expected status %d, got %d", http.StatusTooManyRequests, w.Code)
+		}
+		if w.Header().Get("Retry-After") != "2" {
+			t.Errorf("expected Retry-After header to be 2, got %q", w.Header().Get("Retry-After"))
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("ERROR IP locked", func(t *testing.T) {
+		authService, mock := newMockService(t)
+
+		mock.ExpectQuery("SELECT scope, subject, count, last_failed_at, locked_until FROM auth_login_failures").
+			WithArgs(LoginScopeAccount, "mike@test.com", LoginScopeIP, "192.0.2.1").
+			WillReturnRows(sqlmock.NewRows(loginFailureColumns).
+				AddRow(LoginScopeIP, "192.0.2.1", 20, time.Now(), time.Now().Add(15*time.Minute)))
+
+		w := httptest.NewRecorder()
+		authService.LoginUserHandler(w, newRequest("SecurePass123!"))
+
+		if w.Code != http.StatusTooManyRequests {
+			t.Errorf("expected status %d, got %d", http.StatusTooManyRequests, w.Code)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+}
+func TestLoginUser_TwoFactor(t *testing.T) {
+	authService, mock := newMockService(t)
+
+	var password password
+	err := password.Set("SecurePass123!")
+	if err != nil {
+		t.Fatalf("failed to hash password: %s", err.Error())
+	}
+
+	expectLoginAllowed(mock, "mike@test.com")
+
+	mock.ExpectQuery("SELECT .* FROM auth_users WHERE email = ?").
+		WithArgs("mike@test.com").
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "created_at", "updated_at", "version", "is_active", "email", "name",
+			"profile_picture", "password", "provider", "role_id", "is_verified",
+		}).AddRow(1, time.Now(), time.Now(), 1, true, "mike@test.com", "Mike", "", password.hash, "N/A", 1, true))
+
+	expectTwoFactor(mock, 1, []byte("12345678901234567890"))
+
+	// Failed logins are kept until the code checks out, and no authentication token
+	// is issued yet
+	mock.ExpectExec("INSERT INTO auth_tokens").
+		WithArgs(sqlmock.AnyArg(), 1, sqlmock.AnyArg(), ScopeTwoFactor, "", "", "", false).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	jsonData, _ := json.Marshal(map[string]any{"email": "mike@test.com", "password": *password.plaintext})
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/login", bytes.NewReader(jsonData))
+	w := httptest.NewRecorder()
+
+	authService.LoginUserHandler(w, req)
+
+	resp := w.Result()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Errorf("expected status %d, got %d", http.StatusAccepted, resp.StatusCode)
+	}
+
+	if len(resp.Cookies()) != 0 {
+		t.Errorf("expected no cookie to be set, got %v", resp.Cookies())
+	}
+
+	var body struct {
+		TwoFactorToken Token `json:"two_factor_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	if len(body.TwoFactorToken.Plaintext) != 26 || time.Until(body.TwoFactorToken.Expiry) > twoFactorChallengeTTL {
+		t.Errorf("unexpected challenge token %+v", body.TwoFactorToken)
+	}
+
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unmet expectations: %v", err)
 	}
 }
+
+func TestLoginTwoFactorHandler(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	challenge := "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+	expectChallenge := func(mock sqlmock.Sqlmock) {
+		mock.ExpectQuery("SELECT au.\\* FROM auth_users as au INNER JOIN auth_tokens").
+			WithArgs(sqlmock.AnyArg(), ScopeTwoFactor, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{
+				"id", "created_at", "updated_at", "version", "is_active", "email", "name",
+				"profile_picture", "password", "provider", "role_id", "is_verified",
+			}).AddRow(1, time.Now(), time.Now(), 1, true, "mike@test.com", "Mike", "", []byte("hash"), "N/A", 1, true))
+
+		expectLoginAllowed(mock, "mike@test.com")
+		expectTwoFactor(mock, 1, secret)
+	}
+
+	expectLogin := func(mock sqlmock.Sqlmock) {
+		mock.ExpectExec("DELETE FROM auth_login_failures").
+			WithArgs(LoginScopeAccount, "mike@test.com").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		// The challenge token can't be exchanged twice
+		mock.ExpectExec("DELETE FROM auth_tokens").
+			WithArgs(ScopeTwoFactor, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		mock.ExpectExec("INSERT INTO auth_tokens").
+			WithArgs(sqlmock.AnyArg(), 1, sqlmock.AnyArg(), ScopeAuthentication, "", "192.0.2.1", "", true).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+
+	newRequest := func(token, code string) *http.Request {
+		jsonData, _ := json.Marshal(map[string]any{"token": token, "code": code})
+		return httptest.NewRequest(http.MethodPost, "/api/auth/login/2fa", bytes.NewReader(jsonData))
+	}
+
+	t.Run("SUCCESS Logged in with a TOTP code", func(t *testing.T) {
+		authService, mock := newMockService(t)
+		code := totpCode(secret, totpStep(time.Now()))
+
+		expectChallenge(mock)
+		mock.ExpectExec("UPDATE auth_two_factor SET last_used_step").
+			WithArgs(1, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectLogin(mock)
+
+		w := httptest.NewRecorder()
+		authService.LoginTwoFactorHandler(w, newRequest(challenge, code))
+
+		resp := w.Result()
+		if resp.StatusCode != http.StatusCreated {
+			t.Errorf("expected status %d, got %d", http.StatusCreated, resp.StatusCode)
+		}
+
+		cookies := resp.Cookies()
+		if len(cookies) != 1 || cookies[0].Name != CookieAuthToken || cookies[0].Value == "" {
+			t.Errorf("expected %s cookie to be set, got %v", CookieAuthToken, cookies)
+		}
+
+		events := authService.Audit.(*audit.Mock).Events()
+		if len(events) != 1 || events[0].Action != audit.ActionLogin || string(events[0].Diff) != `{"two_factor":"totp"}` {
+			t.Errorf("expected the login to be audited, got %+v", events)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("SUCCESS Logged in with the challenge token of an OAuth login", func(t *testing.T) {
+		authService, mock := newMockService(t)
+		code := totpCode(secret, totpStep(time.Now()))
+
+		expectChallenge(mock)
+		mock.ExpectExec("UPDATE auth_two_factor SET last_used_step").
+			WithArgs(1, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectLogin(mock)
+
+		req := newRequest("", code)
+		req.AddCookie(&http.Cookie{Name: CookieTwoFactorToken, Value: challenge})
+		w := httptest.NewRecorder()
+		authService.LoginTwoFactorHandler(w, req)
+
+		resp := w.Result()
+		if resp.StatusCode != http.StatusCreated {
+			t.Errorf("expected status %d, got %d", http.StatusCreated, resp.StatusCode)
+		}
+
+		// The challenge cookie is removed once exchanged
+		cleared := false
+		for _, cookie := range resp.Cookies() {
+			if cookie.Name == CookieTwoFactorToken && cookie.MaxAge < 0 {
+				cleared = true
+			}
+		}
+		if !cleared {
+			t.Errorf("expected %s cookie to be cleared, got %v", CookieTwoFactorToken, resp.Cookies())
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("SUCCESS Logged in with a recovery code", func(t *testing.T) {
+		authService, mock := newMockService(t)
+
+		expectChallenge(mock)
+		mock.ExpectExec("DELETE FROM auth_recovery_codes").
+			WithArgs(hashRecoveryCode("abcd-efgh"), 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectLogin(mock)
+
+		w := httptest.NewRecorder()
+		authService.LoginTwoFactorHandler(w, newRequest(challenge, "ABCD-EFGH"))
+
+		if w.Code != http.StatusCreated {
+			t.Errorf("expected status %d, got %d", http.StatusCreated, w.Code)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("ERROR Wrong code counted as a failed login", func(t *testing.T) {
+		authService, mock := newMockService(t)
+		// Long expired, so never valid
+		code := totpCode(secret, totpStep(time.Now())-10)
+
+		expectChallenge(mock)
+		expectLoginFailed(mock, "mike@test.com", 1)
+
+		w := httptest.NewRecorder()
+		authService.LoginTwoFactorHandler(w, newRequest(challenge, code))
+
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("expected status %d, got %d", http.StatusUnauthorized, w.Code)
+		}
+
+		events := authService.Audit.(*audit.Mock).Events()
+		if len(events) != 1 || events[0].Action != audit.ActionLoginFailed {
+			t.Errorf("expected the failed login to be audited, got %+v", events)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("ERROR Used recovery code", func(t *testing.T) {
+		authService, mock := newMockService(t)
+
+		expectChallenge(mock)
+		mock.ExpectExec("DELETE FROM auth_recovery_codes").
+			WithArgs(hashRecoveryCode("abcd-efgh"), 1).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		expectLoginFailed(mock, "mike@test.com", 1)
+
+		w := httptest.NewRecorder()
+		authService.LoginTwoFactorHandler(w, newRequest(challenge, "abcd-efgh"))
+
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("expected status %d, got %d", http.StatusUnauthorized, w.Code)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("ERROR Challenge token expired", func(t *testing.T) {
+		authService, mock := newMockService(t)
+
+		mock.ExpectQuery("SELECT au.\\* FROM auth_users as au INNER JOIN auth_tokens").
+			WithArgs(sqlmock.AnyArg(), ScopeTwoFactor, sqlmock.AnyArg()).
+			WillReturnError(sql.ErrNoRows)
+
+		w := httptest.NewRecorder()
+		authService.LoginTwoFactorHandler(w, newRequest(challenge, "123456"))
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, w.Code)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+}
+
+func TestActivateUserHandler(t *testing.T) {
+	token := "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+	t.Run("SUCCESS User activated", func(t *testing.T) {
+		authService, mock := newMockService(t)
+		now := time.Now()
+
+		mock.ExpectQuery("SELECT au.* FROM auth_users as au INNER JOIN auth_tokens").
+			WithArgs(sqlmock.AnyArg(), ScopeActivation, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{
+				"id", "created_at", "updated_at", "version", "is_active", "email", "name",
+				"profile_picture", "password", "provider", "role_id", "is_verified",
+			}).AddRow(
+				1, now, now, 1, true, "mike@test.com", "Mike",
+				"default_profile_pic.jpg", []byte("hash"), "N/A", 1, false,
+			))
+
+		mock.ExpectQuery("UPDATE auth_users").
+			WithArgs(true, "mike@test.com", "Mike", "default_profile_pic.jpg", []byte("hash"), "N/A", RoleBasic, true, 1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"created_at", "updated_at", "version"}).AddRow(now, now, 2))
+
+		mock.ExpectExec("DELETE FROM auth_tokens").
+			WithArgs(ScopeActivation, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		jsonData, _ := json.Marshal(map[string]any{"token": token})
+		req := httptest.NewRequest(http.MethodPut, "/api/auth/user/activate", bytes.NewReader(jsonData))
+		w := httptest.NewRecorder()
+
+		authService.ActivateUserHandler(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("ERROR Invalid token format", func(t *testing.T) {
+		authService, _ := newMockService(t)
+
+		jsonData, _ := json.Marshal(map[string]any{"token": "short"})
+		req := httptest.NewRequest(http.MethodPut, "/api/auth/user/activate", bytes.NewReader(jsonData))
+		w := httptest.NewRecorder()
+
+		authService.ActivateUserHandler(w, req)
+
+		if w.Result().StatusCode != http.StatusUnprocessableEntity {
+			t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, w.Result().StatusCode)
+		}
+	})
+
+	t.Run("ERROR Token not found or expired", func(t *testing.T) {
+		authService, mock := newMockService(t)
+
+		mock.ExpectQuery("SELECT au.* FROM auth_users as au INNER JOIN auth_tokens").
+			WithArgs(sqlmock.AnyArg(), ScopeActivation, sqlmock.AnyArg()).
+			WillReturnError(sql.ErrNoRows)
+
+		jsonData, _ := json.Marshal(map[string]any{"token": token})
+		req := httptest.NewRequest(http.MethodPut, "/api/auth/user/activate", bytes.NewReader(jsonData))
+		w := httptest.NewRecorder()
+
+		authService.ActivateUserHandler(w, req)
+
+		if w.Result().StatusCode != http.StatusUnprocessableEntity {
+			t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, w.Result().StatusCode)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+}
+
+func TestRequestPasswordResetHandler(t *testing.T) {
+	userColumns := []string{
+		"id", "created_at", "updated_at", "version", "is_active", "email", "name",
+		"profile_picture", "password", "provider", "role_id", "is_verified",
+	}
+
+	t.Run("SUCCESS Reset email sent", func(t *testing.T) {
+		authService, mock := newMockService(t)
+		now := time.Now()
+
+		mock.ExpectQuery("SELECT .* FROM auth_users WHERE email = ?").
+			WithArgs("mike@test.com").
+			WillReturnRows(sqlmock.NewRows(userColumns).AddRow(
+				1, now, now, 1, true, "mike@test.com", "Mike",
+				"default_profile_pic.jpg", []byte("hash"), "N/A", 1, true,
+			))
+
+		mock.ExpectExec("INSERT INTO auth_tokens").
+			WithArgs(sqlmock.AnyArg(), 1, sqlmock.AnyArg(), ScopePasswordReset, "", "", "", false).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		jsonData, _ := json.Marshal(map[string]any{"email": "mike@test.com"})
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/password-reset", bytes.NewReader(jsonData))
+		w := httptest.NewRecorder()
+
+		authService.RequestPasswordResetHandler(w, req)
+
+		if w.Result().StatusCode != http.StatusAccepted {
+			t.Errorf("expected status %d, got %d", http.StatusAccepted, w.Result().StatusCode)
+		}
+
+		sent := authService.Mailer.(*mailer.Mock).Messages()
+		if len(sent) != 1 || sent[0].Recipient != "mike@test.com" {
+			t.Errorf("expected password reset email to be sent to mike@test.com, got %v", sent)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("SUCCESS Unknown email still accepted", func(t *testing.T) {
+		authService, mock := newMockService(t)
+
+		mock.ExpectQuery("SELECT .* FROM auth_users WHERE email = ?").
+			WithArgs("nobody@test.com").
+			WillReturnError(sql.ErrNoRows)
+
+		jsonData, _ := json.Marshal(map[string]any{"email": "nobody@test.com"})
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/password-reset", bytes.NewReader(jsonData))
+		w := httptest.NewRecorder()
+
+		authService.RequestPasswordResetHandler(w, req)
+
+		if w.Result().StatusCode != http.StatusAccepted {
+			t.Errorf("expected status %d, got %d", http.StatusAccepted, w.Result().StatusCode)
+		}
+
+		if len(authService.Mailer.(*mailer.Mock).Messages()) != 0 {
+			t.Errorf("expected no email to be sent")
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("ERROR Invalid email format", func(t *testing.T) {
+		authService, _ := newMockService(t)
+
+		jsonData, _ := json.Marshal(map[string]any{"email": "invalid-email"})
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/password-reset", bytes.NewReader(jsonData))
+		w := httptest.NewRecorder()
+
+		authService.RequestPasswordResetHandler(w, req)
+
+		if w.Result().StatusCode != http.StatusUnprocessableEntity {
+			t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, w.Result().StatusCode)
+		}
+	})
+}
+
+func TestResetPasswordHandler(t *testing.T) {
+	token := "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+	t.Run("SUCCESS Password reset and sessions revoked", func(t *testing.T) {
+		authService, mock := newMockService(t)
+		now := time.Now()
+
+		mock.ExpectQuery("SELECT au.* FROM auth_users as au INNER JOIN auth_tokens").
+			WithArgs(sqlmock.AnyArg(), ScopePasswordReset, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{
+				"id", "created_at", "updated_at", "version", "is_active", "email", "name",
+				"profile_picture", "password", "provider", "role_id", "is_verified",
+			}).AddRow(
+				1, now, now, 1, true, "mike@test.com", "Mike",
+				"default_profile_pic.jpg", []byte("hash"), "N/A", 1, true,
+			))
+
+		mock.ExpectQuery("UPDATE auth_users").
+			WillReturnRows(sqlmock.NewRows([]string{"created_at", "updated_at", "version"}).AddRow(now, now, 2))
+
+		mock.ExpectExec("DELETE FROM auth_tokens").
+			WithArgs(ScopePasswordReset, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		mock.ExpectExec("DELETE FROM auth_tokens").
+			WithArgs(ScopeAuthentication, 1).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		jsonData, _ := json.Marshal(map[string]any{"password": "NewSecurePass123!", "token": token})
+		req := httptest.NewRequest(http.MethodPut, "/api/auth/password", bytes.NewReader(jsonData))
+		w := httptest.NewRecorder()
+
+		authService.ResetPasswordHandler(w, req)
+
+		if w.Result().StatusCode != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, w.Result().StatusCode)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("ERROR Password too short", func(t *testing.T) {
+		authService, _ := newMockService(t)
+
+		jsonData, _ := json.Marshal(map[string]any{"password": "short", "token": token})
+		req := httptest.NewRequest(http.MethodPut, "/api/auth/password", bytes.NewReader(jsonData))
+		w := httptest.NewRecorder()
+
+		authService.ResetPasswordHandler(w, req)
+
+		if w.Result().StatusCode != http.StatusUnprocessableEntity {
+			t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, w.Result().StatusCode)
+		}
+	})
+
+	t.Run("ERROR Token not found or expired", func(t *testing.T) {
+		authService, mock := newMockService(t)
+
+		mock.ExpectQuery("SELECT au.* FROM auth_users as au INNER JOIN auth_tokens").
+			WithArgs(sqlmock.AnyArg(), ScopePasswordReset, sqlmock.AnyArg()).
+			WillReturnError(sql.ErrNoRows)
+
+		jsonData, _ := json.Marshal(map[string]any{"password": "NewSecurePass123!", "token": token})
+		req := httptest.NewRequest(http.MethodPut, "/api/auth/password", bytes.NewReader(jsonData))
+		w := httptest.NewRecorder()
+
+		authService.ResetPasswordHandler(w, req)
+
+		if w.Result().StatusCode != http.StatusUnprocessableEntity {
+			t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, w.Result().StatusCode)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+}
+
+func newOAuthTestService(t *testing.T) (*Service, sqlmock.Sqlmock, *oidc.MockIdP) {
+	t.Helper()
+
+	authService, mock := newMockService(t)
+
+	idp := oidc.NewMockIdP("pixelarcade")
+	t.Cleanup(idp.Close)
+
+	authService.Providers["mock"] = oidc.NewProvider(oidc.Config{
+		Name:        "mock",
+		Issuer:      idp.Issuer(),
+		ClientID:    "pixelarcade",
+		RedirectURL: "http://localhost:3000/api/auth/oauth/mock/callback",
+	})
+
+	return authService, mock, idp
+}
+
+// startOAuthFlow runs the start handler and signs in at the mock IdP, returning the
+// callback request the browser would make afterwards.
+func startOAuthFlow(t *testing.T, authService *Service, idp *oidc.MockIdP, claims oidc.Claims) *http.Request {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/auth/oauth/mock/start", nil)
+	req = param.Inject(req, "provider", "mock")
+	w := httptest.NewRecorder()
+
+	authService.OAuthStartHandler(w, req)
+
+	resp := w.Result()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("expected status %d, got %d", http.StatusFound, resp.StatusCode)
+	}
+
+	code, state, err := idp.Authorize(resp.Header.Get("Location"), claims)
+	if err != nil {
+		t.Fatalf("failed to authorize at mock idp: %v", err)
+	}
+
+	callback := httptest.NewRequest(http.MethodGet, "/api/auth/oauth/mock/callback?code="+code+"&state="+state, nil)
+	callback = param.Inject(callback, "provider", "mock")
+	for _, cookie := range resp.Cookies() {
+		callback.AddCookie(cookie)
+	}
+
+	return callback
+}
+
+func TestOAuthStartHandler_UnknownProvider(t *testing.T) {
+	authService, _ := newMockService(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/auth/oauth/unknown/start", nil)
+	req = param.Inject(req, "provider", "unknown")
+	w := httptest.NewRecorder()
+
+	authService.OAuthStartHandler(w, req)
+
+	if w.Result().StatusCode != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Result().StatusCode)
+	}
+}
+
+func TestOAuthCallbackHandler(t *testing.T) {
+	userColumns := []string{
+		"id", "created_at", "updated_at", "version", "is_active", "email", "name",
+		"profile_picture", "password", "provider", "role_id", "is_verified",
+	}
+	claims := oidc.Claims{Subject: "123", Email: "mike@test.com", EmailVerified: true, Name: "Mike"}
+
+	t.Run("SUCCESS New user created", func(t *testing.T) {
+		authService, mock, idp := newOAuthTestService(t)
+		now := time.Now()
+
+		mock.ExpectQuery("SELECT .* FROM auth_users WHERE email = ?").
+			WithArgs("mike@test.com").
+			WillReturnError(sql.ErrNoRows)
+
+		mock.ExpectQuery("INSERT INTO auth_users").
+			WithArgs("Mike", "mike@test.com", []byte(nil), "", "mock", true, true).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "version", "role_id"}).
+				AddRow(1, now, now, 1, 1))
+
+		expectTwoFactor(mock, 1, nil)
+
+		mock.ExpectExec("INSERT INTO auth_tokens").
+			WithArgs(sqlmock.AnyArg(), 1, sqlmock.AnyArg(), ScopeAuthentication, "", "192.0.2.1", "", false).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		req := startOAuthFlow(t, authService, idp, claims)
+		w := httptest.NewRecorder()
+
+		authService.OAuthCallbackHandler(w, req)
+
+		resp := w.Result()
+		if resp.StatusCode != http.StatusSeeOther {
+			t.Errorf("expected status %d, got %d", http.StatusSeeOther, resp.StatusCode)
+		}
+
+		var authCookie *http.Cookie
+		for _, cookie := range resp.Cookies() {
+			if cookie.Name == CookieAuthToken {
+				authCookie = cookie
+			}
+		}
+		if authCookie == nil || authCookie.Value == "" {
+			t.Errorf("expected %s cookie to be set", CookieAuthToken)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("SUCCESS Existing user linked", func(t *testing.T) {
+		authService, mock, idp := newOAuthTestService(t)
+		now := time.Now()
+
+		mock.ExpectQuery("SELECT .* FROM auth_users WHERE email = ?").
+			WithArgs("mike@test.com").
+			WillReturnRows(sqlmock.NewRows(userColumns).AddRow(
+				1, now, now, 1, true, "mike@test.com", "Mike",
+				"default_profile_pic.jpg", []byte("hash"), ProviderNone, 1, false,
+			))
+
+		mock.ExpectQuery("UPDATE auth_users").
+			WithArgs(true, "mike@test.com", "Mike", "default_profile_pic.jpg", []byte(nil), "mock", RoleBasic, true, 1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"created_at", "updated_at", "version"}).AddRow(now, now, 2))
+
+		// The account was never verified, so whoever set its password loses access
+		mock.ExpectExec("DELETE FROM auth_tokens").
+			WithArgs(1).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+
+		mock.ExpectExec("DELETE FROM auth_two_factor").
+			WithArgs(1).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		expectTwoFactor(mock, 1, nil)
+
+		mock.ExpectExec("INSERT INTO auth_tokens").
+			WithArgs(sqlmock.AnyArg(), 1, sqlmock.AnyArg(), ScopeAuthentication, "", "192.0.2.1", "", false).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		req := startOAuthFlow(t, authService, idp, claims)
+		w := httptest.NewRecorder()
+
+		authService.OAuthCallbackHandler(w, req)
+
+		if w.Result().StatusCode != http.StatusSeeOther {
+			t.Errorf("expected status %d, got %d", http.StatusSeeOther, w.Result().StatusCode)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("SUCCESS Redirected to the two-factor step", func(t *testing.T) {
+		authService, mock, idp := newOAuthTestService(t)
+		now := time.Now()
+
+		mock.ExpectQuery("SELECT .* FROM auth_users WHERE email = ?").
+			WithArgs("mike@test.com").
+			WillReturnRows(sqlmock.NewRows(userColumns).AddRow(
+				1, now, now, 1, true, "mike@test.com", "Mike",
+				"default_profile_pic.jpg", []byte("hash"), "mock", 1, true,
+			))
+
+		expectTwoFactor(mock, 1, []byte("12345678901234567890"))
+
+		mock.ExpectExec("INSERT INTO auth_tokens").
+			WithArgs(sqlmock.AnyArg(), 1, sqlmock.AnyArg(), ScopeTwoFactor, "", "", "", false).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		req := startOAuthFlow(t, authService, idp, claims)
+		w := httptest.NewRecorder()
+
+		authService.OAuthCallbackHandler(w, req)
+
+		resp := w.Result()
+		if resp.StatusCode != http.StatusSeeOther {
+			t.Errorf("expected status %d, got %d", http.StatusSeeOther, resp.StatusCode)
+		}
+
+		if location := resp.Header.Get("Location"); location != "http://localhost:3000/login/2fa" {
+			t.Errorf("expected redirect to the two-factor step, got %q", location)
+		}
+
+		// The challenge token is handed over in a cookie, never in the URL
+		var challenge *http.Cookie
+		for _, cookie := range resp.Cookies() {
+			switch cookie.Name {
+			case CookieAuthToken:
+				t.Errorf("expected no %s cookie to be set", CookieAuthToken)
+			case CookieTwoFactorToken:
+				challenge = cookie
+			}
+		}
+		if challenge == nil || len(challenge.Value) != 26 || !challenge.HttpOnly || challenge.Path != "/api/auth/login/2fa" {
+			t.Errorf("expected an HttpOnly %s cookie to be set, got %+v", CookieTwoFactorToken, challenge)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("ERROR Email registered meanwhile", func(t *testing.T) {
+		authService, mock, idp := newOAuthTestService(t)
+
+		mock.ExpectQuery("SELECT .* FROM auth_users WHERE email = ?").
+			WithArgs("mike@test.com").
+			WillReturnError(sql.ErrNoRows)
+
+		mock.ExpectQuery("INSERT INTO auth_users").
+			WithArgs("Mike", "mike@test.com", []byte(nil), "", "mock", true, true).
+			WillReturnError(errors.New(`pq: duplicate key value violates unique constraint "auth_users_email_key"`))
+
+		req := startOAuthFlow(t, authService, idp, claims)
+		w := httptest.NewRecorder()
+
+		authService.OAuthCallbackHandler(w, req)
+
+		if w.Result().StatusCode != http.StatusConflict {
+			t.Errorf("expected status %d, got %d", http.StatusConflict, w.Result().StatusCode)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("ERROR Unverified email", func(t *testing.T) {
+		authService, _, idp := newOAuthTestService(t)
+
+		req := startOAuthFlow(t, authService, idp, oidc.Claims{Subject: "123", Email: "mike@test.com"})
+		w := httptest.NewRecorder()
+
+		authService.OAuthCallbackHandler(w, req)
+
+		if w.Result().StatusCode != http.StatusUnprocessableEntity {
+			t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, w.Result().StatusCode)
+		}
+	})
+
+	t.Run("ERROR State mismatch", func(t *testing.T) {
+		authService, _, idp := newOAuthTestService(t)
+
+		req := startOAuthFlow(t, authService, idp, claims)
+		q := req.URL.Query()
+		q.Set("state", "forged")
+		req.URL.RawQuery = q.Encode()
+		w := httptest.NewRecorder()
+
+		authService.OAuthCallbackHandler(w, req)
+
+		if w.Result().StatusCode != http.StatusBadRequest {
+			t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Result().StatusCode)
+		}
+	})
+
+	t.Run("ERROR Missing state cookie", func(t *testing.T) {
+		authService, _, _ := newOAuthTestService(t)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/auth/oauth/mock/callback?code=abc&state=xyz", nil)
+		req = param.Inject(req, "provider", "mock")
+		w := httptest.NewRecorder()
+
+		authService.OAuthCallbackHandler(w, req)
+
+		if w.Result().StatusCode != http.StatusBadRequest {
+			t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Result().StatusCode)
+		}
+	})
+}
+
+func TestUpdateCurrentUserAvatarHandler(t *testing.T) {
+	newAvatarRequest := func(t *testing.T, user *User, avatar []byte) *http.Request {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		if avatar != nil {
+			fw, err := mw.CreateFormFile("avatar", "avatar.png")
+			if err != nil {
+				t.Fatalf("failed to create form file: %v", err)
+			}
+			fw.Write(avatar)
+		}
+		mw.Close()
+
+		req := httptest.NewRequest(http.MethodPut, "/api/auth/user/avatar", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		return ContextSetUser(req, user)
+	}
+
+	t.Run("SUCCESS Avatar uploaded and previous one removed", func(t *testing.T) {
+		authService, mock := newMockService(t)
+		now := time.Now()
+		ctx := context.Background()
+		avatar := newTestImage(t, "png", 300, 300)
+		hash := avatarHash(avatar)
+
+		previous := AvatarURL(1, "0123456789abcdef", 256)
+		for _, size := range AvatarSizes {
+			authService.Storage.Put(ctx, avatarKey(1, "0123456789abcdef", size), strings.NewReader("old"), 3, "image/png")
+		}
+
+		user := &User{ID: 1, Version: 1, IsActive: true, Email: "mike@test.com", Name: "Mike", ProfilePicture: previous, Provider: "N/A", RoleID: RoleBasic, IsVerified: true}
+		user.Password.hash = []byte("hash")
+
+		mock.ExpectQuery("UPDATE auth_users").
+			WithArgs(true, "mike@test.com", "Mike", AvatarURL(1, hash, 256), []byte("hash"), "N/A", RoleBasic, true, 1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"created_at", "updated_at", "version"}).AddRow(now, now, 2))
+
+		w := httptest.NewRecorder()
+
+		authService.UpdateCurrentUserAvatarHandler(w, newAvatarRequest(t, user, avatar))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+
+		for _, size := range AvatarSizes {
+			obj, err := authService.Storage.Get(ctx, avatarKey(1, hash, size))
+			if err != nil {
+				t.Errorf("expected %d thumbnail to be stored, got %v", size, err)
+				continue
+			}
+			obj.Body.Close()
+
+			_, err = authService.Storage.Get(ctx, avatarKey(1, "0123456789abcdef", size))
+			if !errors.Is(err, storage.ErrNotFound) {
+				t.Errorf("expected previous %d thumbnail to be deleted, got %v", size, err)
+			}
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	tests := []struct {
+		name    string
+		avatar  []byte
+		message string
+	}{
+		{"ERROR Missing avatar", nil, "must be provided"},
+		{"ERROR Not an image", []byte("<svg></svg>"), "must be a PNG, JPEG or GIF image"},
+		{"ERROR Avatar too large", bytes.Repeat([]byte("a"), 2<<20), "must not be larger than"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authService, mock := newMockService(t)
+			user := &User{ID: 1}
+
+			w := httptest.NewRecorder()
+
+			authService.UpdateCurrentUserAvatarHandler(w, newAvatarRequest(t, user, tt.avatar))
+
+			if w.Code != http.StatusUnprocessableEntity {
+				t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, w.Code)
+			}
+			if !strings.Contains(w.Body.String(), tt.message) {
+				t.Errorf("expected error message %q, got %s", tt.message, w.Body.String())
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestGetAvatarHandler(t *testing.T) {
+	authService, _ := newMockService(t)
+	hash := "0123456789abcdef"
+	authService.Storage.Put(context.Background(), avatarKey(1, hash, 64), strings.NewReader("png"), 3, "image/png")
+
+	tests := []struct {
+		name   string
+		hash   string
+		file   string
+		status int
+	}{
+		{"SUCCESS Thumbnail served", hash, "64.png", http.StatusOK},
+		{"ERROR Thumbnail not stored", hash, "128.png", http.StatusNotFound},
+		{"ERROR Unknown size", hash, "32.png", http.StatusNotFound},
+		{"ERROR Invalid hash", "../../../secrets", "64.png", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/users/1/avatars/"+tt.hash+"/"+tt.file, nil)
+			req = param.InjectID(req, 1)
+			req = param.Inject(req, "hash", tt.hash)
+			req = param.Inject(req, "file", tt.file)
+			w := httptest.NewRecorder()
+
+			authService.GetAvatarHandler(w, req)
+
+			if w.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, w.Code)
+			}
+			if tt.status == http.StatusOK && (w.Header().Get("Content-Type") != "image/png" || w.Body.String() != "png") {
+				t.Errorf("unexpected response: %s %q", w.Header().Get("Content-Type"), w.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetIdenticonHandler(t *testing.T) {
+	authService, _ := newMockService(t)
+
+	tests := []struct {
+		name   string
+		id     string
+		query  string
+		status int
+		size   int
+	}{
+		{"SUCCESS Default size", "1", "", http.StatusOK, 256},
+		{"SUCCESS Requested size", "1", "?size=64", http.StatusOK, 64},
+		{"ERROR Unsupported size", "1", "?size=1000", http.StatusUnprocessableEntity, 0},
+		{"ERROR Invalid ID", "abc", "", http.StatusNotFound, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/users/"+tt.id+"/avatar.png"+tt.query, nil)
+			req = param.Inject(req, "id", tt.id)
+			w := httptest.NewRecorder()
+
+			authService.GetIdenticonHandler(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, w.Code)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			if w.Header().Get("Content-Type") != "image/png" {
+				t.Errorf("expected image/png, got %s", w.Header().Get("Content-Type"))
+			}
+			cfg, err := png.DecodeConfig(w.Body)
+			if err != nil {
+				t.Fatalf("expected a PNG image: %v", err)
+			}
+			if cfg.Width != tt.size || cfg.Height != tt.size {
+				t.Errorf("expected %dx%d image, got %dx%d", tt.size, tt.size, cfg.Width, cfg.Height)
+			}
+		})
+	}
+}
+
+func TestUpdateCurrentUserHandler(t *testing.T) {
+	newUser := func(t *testing.T) *User {
+		t.Helper()
+		user := &User{ID: 1, Version: 1, IsActive: true, Email: "mike@test.com", Name: "Mike", Provider: ProviderNone, RoleID: RoleBasic, IsVerified: true}
+		if err := user.Password.Set("CurrentPass123"); err != nil {
+			t.Fatalf("failed to set password: %v", err)
+		}
+		return user
+	}
+
+	newRequest := func(user *User, body map[string]any) *http.Request {
+		jsonData, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPatch, "/api/auth/user", bytes.NewReader(jsonData))
+		return ContextSetSession(ContextSetUser(req, user), &Session{ID: 7})
+	}
+
+	t.Run("SUCCESS Name changed without current password", func(t *testing.T) {
+		authService, mock := newMockService(t)
+		user := newUser(t)
+		now := time.Now()
+
+		mock.ExpectQuery("UPDATE auth_users").
+			WithArgs(true, "mike@test.com", "Michael", "", user.Password.hash, ProviderNone, RoleBasic, true, 1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"created_at", "updated_at", "version"}).AddRow(now, now, 2))
+
+		w := httptest.NewRecorder()
+		authService.UpdateCurrentUserHandler(w, newRequest(user, map[string]any{"name": "Michael"}))
+
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("SUCCESS Password changed with current password", func(t *testing.T) {
+		authService, mock := newMockService(t)
+		user := newUser(t)
+		now := time.Now()
+
+		mock.ExpectQuery("UPDATE auth_users").
+			WillReturnRows(sqlmock.NewRows([]string{"created_at", "updated_at", "version"}).AddRow(now, now, 2))
+
+		mock.ExpectExec("DELETE FROM auth_tokens").
+			WithArgs(ScopePasswordReset, 1).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		// Only the session the password was changed from is kept
+		mock.ExpectExec("DELETE FROM auth_tokens").
+			WithArgs(1, ScopeAuthentication, 7).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+
+		w := httptest.NewRecorder()
+		authService.UpdateCurrentUserHandler(w, newRequest(user, map[string]any{"password": "NewSecurePass123", "current_password": "CurrentPass123"}))
+
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+
+		if match, _ := user.Password.Matches("NewSecurePass123"); !match {
+			t.Errorf("expected password to be changed")
+		}
+
+		events := authService.Audit.(*audit.Mock).Events()
+		if len(events) != 1 || events[0].Action != audit.ActionPasswordChange || events[0].TargetID != 1 {
+			t.Errorf("expected the password change to be audited, got %+v", events)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("SUCCESS Email change pending confirmation", func(t *testing.T) {
+		authService, mock := newMockService(t)
+		user := newUser(t)
+		now := time.Now()
+
+		mock.ExpectQuery("SELECT .* FROM auth_users WHERE email = ?").
+			WithArgs("new@test.com").
+			WillReturnError(sql.ErrNoRows)
+
+		// The current address is kept until the new one is confirmed
+		mock.ExpectQuery("UPDATE auth_users").
+			WithArgs(true, "mike@test.com", "Mike", "", user.Password.hash, ProviderNone, RoleBasic, true, 1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"created_at", "updated_at", "version"}).AddRow(now, now, 2))
+
+		mock.ExpectExec("DELETE FROM auth_tokens").
+			WithArgs(ScopeEmailChange, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		mock.ExpectExec("INSERT INTO auth_tokens").
+			WithArgs(sqlmock.AnyArg(), 1, sqlmock.AnyArg(), ScopeEmailChange, "", "", "new@test.com", false).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		w := httptest.NewRecorder()
+		authService.UpdateCurrentUserHandler(w, newRequest(user, map[string]any{"email": "new@test.com", "current_password": "CurrentPass123"}))
+
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+
+		if user.Email != "mike@test.com" || !user.IsVerified {
+			t.Errorf("expected the current address to be kept, got %+v", user)
+		}
+
+		sent := authService.Mailer.(*mailer.Mock).Messages()
+		if len(sent) != 1 || sent[0].Recipient != "new@test.com" {
+			t.Errorf("expected confirmation email to be sent to new@test.com, got %v", sent)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("ERROR Email already taken", func(t *testing.T) {
+		authService, mock := newMockService(t)
+		user := newUser(t)
+		now := time.Now()
+
+		mock.ExpectQuery("SELECT .* FROM auth_users WHERE email = ?").
+			WithArgs("new@test.com").
+			WillReturnRows(sqlmock.NewRows([]string{
+				"id", "created_at", "updated_at", "version", "is_active", "email", "name",
+				"profile_picture", "password", "provider", "role_id", "is_verified",
+			}).AddRow(
+				2, now, now, 1, true, "new@test.com", "Other", "", []byte("hash"), ProviderNone, 1, true,
+			))
+
+		w := httptest.NewRecorder()
+		authService.UpdateCurrentUserHandler(w, newRequest(user, map[string]any{"email": "new@test.com", "current_password": "CurrentPass123"}))
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, w.Code)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	tests := []struct {
+		name   string
+		oauth  bool
+		body   map[string]any
+		status int
+		field  string
+	}{
+		{"ERROR Role cannot be changed", false, map[string]any{"role_id": RoleAdmin}, http.StatusBadRequest, ""},
+		{"ERROR Active status cannot be changed", false, map[string]any{"is_active": false}, http.StatusBadRequest, ""},
+		{"ERROR Provider cannot be changed", false, map[string]any{"provider": "google"}, http.StatusBadRequest, ""},
+		{"ERROR Password change without current password", false, map[string]any{"password": "NewSecurePass123"}, http.StatusUnprocessableEntity, "current_password"},
+		{"ERROR Password change with wrong current password", false, map[string]any{"password": "NewSecurePass123", "current_password": "WrongPass123"}, http.StatusUnprocessableEntity, "current_password"},
+		{"ERROR Email change without current password", false, map[string]any{"email": "new@test.com"}, http.StatusUnprocessableEntity, "current_password"},
+		{"ERROR Email change for OAuth user without password", true, map[string]any{"email": "new@test.com"}, http.StatusUnprocessableEntity, "email"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authService, mock := newMockService(t)
+
+			user := newUser(t)
+			if tt.oauth {
+				user.Password = password{}
+				user.Provider = "mock"
+			}
+
+			w := httptest.NewRecorder()
+			authService.UpdateCurrentUserHandler(w, newRequest(user, tt.body))
+
+			if w.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, w.Code)
+			}
+			if tt.field != "" && !strings.Contains(w.Body.String(), `"`+tt.field+`"`) {
+				t.Errorf("expected error for %s, got %s", tt.field, w.Body.String())
+			}
+			if user.RoleID != RoleBasic || !user.IsActive || user.Email != "mike@test.com" {
+				t.Errorf("expected user to be unchanged, got %+v", user)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestConfirmEmailChangeHandler(t *testing.T) {
+	token := "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+	newRequest := func() *http.Request {
+		jsonData, _ := json.Marshal(map[string]any{"token": token})
+		return httptest.NewRequest(http.MethodPut, "/api/auth/user/email", bytes.NewReader(jsonData))
+	}
+
+	expectEmailChange := func(mock sqlmock.Sqlmock, now time.Time) {
+		mock.ExpectQuery("SELECT au.*, at.email FROM auth_users as au INNER JOIN auth_tokens").
+			WithArgs(sqlmock.AnyArg(), ScopeEmailChange, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{
+				"id", "created_at", "updated_at", "version", "is_active", "email", "name",
+				"profile_picture", "password", "provider", "role_id", "is_verified", "new_email",
+			}).AddRow(
+				1, now, now, 1, true, "mike@test.com", "Mike",
+				"default_profile_pic.jpg", []byte("hash"), ProviderNone, 1, true, "new@test.com",
+			))
+	}
+
+	t.Run("SUCCESS Email changed and previous address notified", func(t *testing.T) {
+		authService, mock := newMockService(t)
+		now := time.Now()
+
+		expectEmailChange(mock, now)
+
+		mock.ExpectQuery("UPDATE auth_users").
+			WithArgs(true, "new@test.com", "Mike", "default_profile_pic.jpg", []byte("hash"), ProviderNone, RoleBasic, true, 1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"created_at", "updated_at", "version"}).AddRow(now, now, 2))
+
+		mock.ExpectExec("DELETE FROM auth_tokens").
+			WithArgs(ScopeEmailChange, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		mock.ExpectExec("DELETE FROM auth_tokens").
+			WithArgs(ScopePasswordReset, 1).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		w := httptest.NewRecorder()
+		authService.ConfirmEmailChangeHandler(w, newRequest())
+
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+
+		sent := authService.Mailer.(*mailer.Mock).Messages()
+		if len(sent) != 1 || sent[0].Recipient != "mike@test.com" {
+			t.Errorf("expected notice to be sent to mike@test.com, got %v", sent)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("ERROR Email taken in the meantime", func(t *testing.T) {
+		authService, mock := newMockService(t)
+
+		expectEmailChange(mock, time.Now())
+
+		mock.ExpectQuery("UPDATE auth_users").
+			WillReturnError(fmt.Errorf("pq: duplicate key value violates unique constraint \"auth_users_email_key\""))
+
+		w := httptest.NewRecorder()
+		authService.ConfirmEmailChangeHandler(w, newRequest())
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, w.Code)
+		}
+
+		if sent := authService.Mailer.(*mailer.Mock).Messages(); len(sent) != 0 {
+			t.Errorf("expected no email to be sent, got %v", sent)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("ERROR Token not found or expired", func(t *testing.T) {
+		authService, mock := newMockService(t)
+
+		mock.ExpectQuery("SELECT au.*, at.email FROM auth_users as au INNER JOIN auth_tokens").
+			WithArgs(sqlmock.AnyArg(), ScopeEmailChange, sqlmock.AnyArg()).
+			WillReturnError(sql.ErrNoRows)
+
+		w := httptest.NewRecorder()
+		authService.ConfirmEmailChangeHandler(w, newRequest())
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, w.Code)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+}
+
+func TestUpdateUserHandler(t *testing.T) {
+	admin := &User{ID: 1, RoleID: RoleAdmin, IsActive: true}
+
+	userRows := func() *sqlmock.Rows {
+		now := time.Now()
+		return sqlmock.NewRows([]string{
+			"id", "created_at", "updated_at", "version", "is_active", "email", "name",
+			"profile_picture", "password", "provider", "role_id", "is_verified",
+		}).AddRow(2, now, now, 1, true, "jane@test.com", "Jane", "", nil, "mock", RoleBasic, true)
+	}
+
+	newRequest := func(id int64, body map[string]any) *http.Request {
+		jsonData, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPatch, fmt.Sprintf("/api/admin/users/%d", id), bytes.NewReader(jsonData))
+		req = param.InjectID(req, id)
+		return ContextSetUser(req, admin)
+	}
+
+	t.Run("SUCCESS User promoted and deactivated", func(t *testing.T) {
+		authService, mock := newMockService(t)
+		now := time.Now()
+
+		mock.ExpectQuery("SELECT .* FROM auth_users WHERE id = ?").
+			WithArgs(2).
+			WillReturnRows(userRows())
+
+		mock.ExpectQuery("UPDATE auth_users").
+			WithArgs(false, "jane@test.com", "Jane", "", []byte(nil), "mock", RoleAdmin, true, 2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"created_at", "updated_at", "version"}).AddRow(now, now, 2))
+
+		mock.ExpectExec("DELETE FROM auth_tokens").
+			WithArgs(ScopeAuthentication, 2).
+			WillReturnResult(sqlmock.NewResult(0, 3))
+
+		w := httptest.NewRecorder()
+		authService.UpdateUserHandler(w, newRequest(2, map[string]any{"role_id": RoleAdmin, "is_active": false}))
+
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+
+		events := authService.Audit.(*audit.Mock).Events()
+		expected := `{"is_active":{"new":false,"old":true},"role_id":{"new":2,"old":1}}`
+		if len(events) != 1 || events[0].Action != audit.ActionUserUpdate || string(events[0].Diff) != expected {
+			t.Errorf("expected the role and status change to be audited, got %+v", events)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("ERROR User not found", func(t *testing.T) {
+		authService, mock := newMockService(t)
+
+		mock.ExpectQuery("SELECT .* FROM auth_users WHERE id = ?").
+			WithArgs(3).
+			WillReturnError(sql.ErrNoRows)
+
+		w := httptest.NewRecorder()
+		authService.UpdateUserHandler(w, newRequest(3, map[string]any{"role_id": RoleAdmin}))
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	tests := []struct {
+		name  string
+		id    int64
+		body  map[string]any
+		field string
+	}{
+		{"ERROR Invalid role", 2, map[string]any{"role_id": 99}, "role_id"},
+		{"ERROR Unknown provider", 2, map[string]any{"provider": "myspace"}, "provider"},
+		{"ERROR Password provider for user without password", 2, map[string]any{"provider": ProviderNone}, "provider"},
+		{"ERROR Admin demoting themselves", 1, map[string]any{"role_id": RoleBasic}, "role_id"},
+		{"ERROR Admin deactivating themselves", 1, map[string]any{"is_active": false}, "is_active"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authService, mock := newMockService(t)
+
+			rows := userRows()
+			if tt.id == admin.ID {
+				now := time.Now()
+				rows = sqlmock.NewRows([]string{
+					"id", "created_at", "updated_at", "version", "is_active", "email", "name",
+					"profile_picture", "password", "provider", "role_id", "is_verified",
+				}).AddRow(1, now, now, 1, true, "admin@test.com", "Admin", "", []byte("hash"), ProviderNone, RoleAdmin, true)
+			}
+
+			mock.ExpectQuery("SELECT .* FROM auth_users WHERE id = ?").
+				WithArgs(tt.id).
+				WillReturnRows(rows)
+
+			w := httptest.NewRecorder()
+			authService.UpdateUserHandler(w, newRequest(tt.id, tt.body))
+
+			if w.Code != http.StatusUnprocessableEntity {
+				t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, w.Code)
+			}
+			if !strings.Contains(w.Body.String(), `"`+tt.field+`"`) {
+				t.Errorf("expected error for %s, got %s", tt.field, w.Body.String())
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestGetUsersHandler(t *testing.T) {
+	columns := []string{"count", "id", "created_at", "updated_at", "version", "is_active", "email", "name", "profile_picture", "password_hash", "provider", "role_id", "is_verified"}
+
+	t.Run("SUCCESS Users filtered and paginated", func(t *testing.T) {
+		authService, mock := newMockService(t)
+		now := time.Now()
+		verified := false
+
+		mock.ExpectQuery("SELECT COUNT\\(\\*\\) OVER\\(\\), .* FROM auth_users").
+			WithArgs("jane", RoleBasic, &verified, nil, 10, 0).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow(1, 2, now, now, 1, true, "jane@test.com", "Jane", "", []byte("hash"), "N/A", 1, false))
+
+		req := httptest.NewRequest(http.MethodGet, "/api/admin/users?email=jane&role_id=1&is_verified=false&limit=10", nil)
+		w := httptest.NewRecorder()
+
+		authService.GetUsersHandler(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+
+		var body struct {
+			Users []map[string]any `json:"users"`
+			Total int64            `json:"total"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if len(body.Users) != 1 || body.Total != 1 || body.Users[0]["email"] != "jane@test.com" {
+			t.Errorf("unexpected response: %+v", body)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	tests := []struct {
+		name  string
+		query string
+		field string
+	}{
+		{"ERROR Invalid role", "?role_id=99", "role_id"},
+		{"ERROR Invalid verified flag", "?is_verified=maybe", "is_verified"},
+		{"ERROR Limit too large", "?limit=1000", "limit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authService, _ := newMockService(t)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/admin/users"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			authService.GetUsersHandler(w, req)
+
+			if w.Code != http.StatusUnprocessableEntity {
+				t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, w.Code)
+			}
+			if !strings.Contains(w.Body.String(), `"`+tt.field+`"`) {
+				t.Errorf("expected error for %s, got %s", tt.field, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetUserHandler(t *testing.T) {
+	authService, mock := newMockService(t)
+	now := time.Now()
+
+	mock.ExpectQuery("SELECT .* FROM auth_users WHERE id = ?").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "created_at", "updated_at", "version", "is_active", "email", "name",
+			"profile_picture", "password", "provider", "role_id", "is_verified",
+		}).AddRow(2, now, now, 1, true, "jane@test.com", "Jane", "", []byte("hash"), "N/A", 1, true))
+
+	mock.ExpectQuery("SELECT .* FROM auth_users WHERE id = ?").
+		WithArgs(3).
+		WillReturnError(sql.ErrNoRows)
+
+	tests := []struct {
+		name   string
+		id     int64
+		status int
+	}{
+		{"SUCCESS User found", 2, http.StatusOK},
+		{"ERROR User not found", 3, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/admin/users/%d", tt.id), nil)
+			req = param.InjectID(req, tt.id)
+			w := httptest.NewRecorder()
+
+			authService.GetUserHandler(w, req)
+
+			if w.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, w.Code)
+			}
+		})
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unmet expectations: %v", err)
+	}
+}
+
+func TestDeleteUserHandler(t *testing.T) {
+	admin := &User{ID: 1, RoleID: RoleAdmin, IsActive: true}
+
+	userRows := func(id int64, profilePicture string) *sqlmock.Rows {
+		now := time.Now()
+		return sqlmock.NewRows([]string{
+			"id", "created_at", "updated_at", "version", "is_active", "email", "name",
+			"profile_picture", "password", "provider", "role_id", "is_verified",
+		}).AddRow(id, now, now, 1, true, "jane@test.com", "Jane", profilePicture, []byte("hash"), "N/A", 1, true)
+	}
+
+	newRequest := func(id int64) *http.Request {
+		req := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/api/admin/users/%d", id), nil)
+		req = param.InjectID(req, id)
+		return ContextSetUser(req, admin)
+	}
+
+	t.Run("SUCCESS User and avatar deleted", func(t *testing.T) {
+		authService, mock := newMockService(t)
+		hash := "0123456789abcdef"
+		authService.Storage.Put(context.Background(), avatarKey(2, hash, 64), strings.NewReader("png"), 3, "image/png")
+
+		mock.ExpectQuery("SELECT .* FROM auth_users WHERE id = ?").
+			WithArgs(2).
+			WillReturnRows(userRows(2, AvatarURL(2, hash, 256)))
+
+		mock.ExpectExec("DELETE FROM auth_users").
+			WithArgs(2).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		w := httptest.NewRecorder()
+		authService.DeleteUserHandler(w, newRequest(2))
+
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+
+		if _, err := authService.Storage.Get(context.Background(), avatarKey(2, hash, 64)); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("expected avatar to be deleted, got %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("ERROR Admin deleting themselves", func(t *testing.T) {
+		authService, mock := newMockService(t)
+
+		mock.ExpectQuery("SELECT .* FROM auth_users WHERE id = ?").
+			WithArgs(1).
+			WillReturnRows(userRows(1, ""))
+
+		w := httptest.NewRecorder()
+		authService.DeleteUserHandler(w, newRequest(1))
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, w.Code)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+}
+
+func TestDeleteUserSessionsHandler(t *testing.T) {
+	authService, mock := newMockService(t)
+	now := time.Now()
+
+	mock.ExpectQuery("SELECT .* FROM auth_users WHERE id = ?").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "created_at", "updated_at", "version", "is_active", "email", "name",
+			"profile_picture", "password", "provider", "role_id", "is_verified",
+		}).AddRow(2, now, now, 1, true, "jane@test.com", "Jane", "", []byte("hash"), "N/A", 1, true))
+
+	// Logging out a user without any sessions is not an error
+	mock.ExpectExec("DELETE FROM auth_tokens").
+		WithArgs(ScopeAuthentication, 2).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/admin/users/2/sessions", nil)
+	req = ContextSetUser(param.InjectID(req, 2), &User{ID: 1, RoleID: RoleAdmin})
+	w := httptest.NewRecorder()
+
+	authService.DeleteUserSessionsHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unmet expectations: %v", err)
+	}
+}
+
+func TestUnlockUserHandler(t *testing.T) {
+	authService, mock := newMockService(t)
+	now := time.Now()
+
+	mock.ExpectQuery("SELECT .* FROM auth_users WHERE id = ?").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "created_at", "updated_at", "version", "is_active", "email", "name",
+			"profile_picture", "password", "provider", "role_id", "is_verified",
+		}).AddRow(2, now, now, 1, true, "Jane@test.com", "Jane", "", []byte("hash"), "N/A", 1, true))
+
+	mock.ExpectExec("DELETE FROM auth_login_failures").
+		WithArgs(LoginScopeAccount, "jane@test.com").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/admin/users/2/lock", nil)
+	req = ContextSetUser(param.InjectID(req, 2), &User{ID: 1, RoleID: RoleAdmin})
+	w := httptest.NewRecorder()
+
+	authService.UnlockUserHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	events := authService.Audit.(*audit.Mock).Events()
+	if len(events) != 1 || events[0].Action != audit.ActionUserUnlock || events[0].ActorID != 1 {
+		t.Errorf("expected the unlock to be audited, got %+v", events)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unmet expectations: %v", err)
+	}
+}
+
+func TestEnrollTwoFactorHandler(t *testing.T) {
+	user := &User{ID: 1, Email: "mike@test.com", IsActive: true}
+	if err := user.Password.Set("CurrentPass123"); err != nil {
+		t.Fatalf("failed to set password: %v", err)
+	}
+
+	newRequest := func(user *User, body map[string]any) *http.Request {
+		jsonData, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/2fa", bytes.NewReader(jsonData))
+		return ContextSetUser(req, user)
+	}
+
+	t.Run("SUCCESS Enrollment started", func(t *testing.T) {
+		authService, mock := newMockService(t)
+
+		mock.ExpectExec("INSERT INTO auth_two_factor").
+			WithArgs(1, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		w := httptest.NewRecorder()
+		authService.EnrollTwoFactorHandler(w, newRequest(user, map[string]any{"current_password": "CurrentPass123"}))
+
+		if w.Code != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d", http.StatusCreated, w.Code)
+		}
+
+		var body struct {
+			TwoFactor struct {
+				Secret     string `json:"secret"`
+				OTPAuthURI string `json:"otpauth_uri"`
+			} `json:"two_factor"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatalf("failed to decode response body: %v", err)
+		}
+		if len(body.TwoFactor.Secret) != 32 || !strings.Contains(body.TwoFactor.OTPAuthURI, "secret="+body.TwoFactor.Secret) {
+			t.Errorf("unexpected enrollment %+v", body.TwoFactor)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("ERROR Already enabled", func(t *testing.T) {
+		authService, mock := newMockService(t)
+
+		mock.ExpectExec("INSERT INTO auth_two_factor").
+			WithArgs(1, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		w := httptest.NewRecorder()
+		authService.EnrollTwoFactorHandler(w, newRequest(user, map[string]any{"current_password": "CurrentPass123"}))
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, w.Code)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	tests := []struct {
+		name  string
+		user  *User
+		body  map[string]any
+		field string
+	}{
+		{"ERROR Without current password", user, map[string]any{}, "current_password"},
+		{"ERROR Wrong current password", user, map[string]any{"current_password": "WrongPass123"}, "current_password"},
+		{"ERROR OAuth user without password", &User{ID: 1, Email: "mike@test.com", IsActive: true, Provider: "mock"}, map[string]any{"current_password": "CurrentPass123"}, "two_factor"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authService, mock := newMockService(t)
+
+			w := httptest.NewRecorder()
+			authService.EnrollTwoFactorHandler(w, newRequest(tt.user, tt.body))
+
+			if w.Code != http.StatusUnprocessableEntity {
+				t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, w.Code)
+			}
+			if !strings.Contains(w.Body.String(), `"`+tt.field+`"`) {
+				t.Errorf("expected error for %s, got %s", tt.field, w.Body.String())
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestConfirmTwoFactorHandler(t *testing.T) {
+	user := &User{ID: 1, Email: "mike@test.com", IsActive: true}
+	secret := []byte("12345678901234567890")
+
+	expectEnrollment := func(mock sqlmock.Sqlmock) {
+		mock.ExpectQuery("SELECT user_id, secret, confirmed_at, last_used_step FROM auth_two_factor").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "secret", "confirmed_at", "last_used_step"}).
+				AddRow(1, secret, nil, 0))
+	}
+
+	newRequest := func(code string) *http.Request {
+		jsonData, _ := json.Marshal(map[string]any{"code": code})
+		req := httptest.NewRequest(http.MethodPut, "/api/auth/2fa", bytes.NewReader(jsonData))
+		return ContextSetSession(ContextSetUser(req, user), &Session{ID: 7})
+	}
+
+	t.Run("SUCCESS Enabled and recovery codes returned", func(t *testing.T) {
+		authService, mock := newMockService(t)
+		step := totpStep(time.Now())
+
+		expectEnrollment(mock)
+		mock.ExpectBegin()
+		mock.ExpectQuery("UPDATE auth_two_factor SET confirmed_at").
+			WithArgs(1, step).
+			WillReturnRows(sqlmock.NewRows([]string{"confirmed_at"}).AddRow(time.Now()))
+		for range recoveryCodeCount {
+			mock.ExpectExec("INSERT INTO auth_recovery_codes").
+				WithArgs(sqlmock.AnyArg(), 1).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+		}
+		mock.ExpectCommit()
+
+		// None of the other sessions was started with a second factor
+		mock.ExpectExec("DELETE FROM auth_tokens").
+			WithArgs(1, ScopeAuthentication, 7).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+
+		w := httptest.NewRecorder()
+		authService.ConfirmTwoFactorHandler(w, newRequest(totpCode(secret, step)))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+
+		var body struct {
+			RecoveryCodes []string `json:"recovery_codes"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatalf("failed to decode response body: %v", err)
+		}
+		if len(body.RecoveryCodes) != recoveryCodeCount {
+			t.Errorf("expected %d recovery codes, got %v", recoveryCodeCount, body.RecoveryCodes)
+		}
+
+		events := authService.Audit.(*audit.Mock).Events()
+		if len(events) != 1 || events[0].Action != audit.ActionTwoFactorEnable {
+			t.Errorf("expected two-factor authentication being enabled to be audited, got %+v", events)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("ERROR Wrong code", func(t *testing.T) {
+		authService, mock := newMockService(t)
+
+		expectEnrollment(mock)
+
+		w := httptest.NewRecorder()
+		authService.ConfirmTwoFactorHandler(w, newRequest(totpCode(secret, totpStep(time.Now())-10)))
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, w.Code)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("ERROR Not enrolled", func(t *testing.T) {
+		authService, mock := newMockService(t)
+
+		expectTwoFactor(mock, 1, nil)
+
+		w := httptest.NewRecorder()
+		authService.ConfirmTwoFactorHandler(w, newRequest("123456"))
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, w.Code)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+}
+
+func TestDisableTwoFactorHandler(t *testing.T) {
+	user := &User{ID: 1, Email: "mike@test.com", IsActive: true}
+	secret := []byte("12345678901234567890")
+
+	newRequest := func(code string) *http.Request {
+		jsonData, _ := json.Marshal(map[string]any{"code": code})
+		req := httptest.NewRequest(http.MethodDelete, "/api/auth/2fa", bytes.NewReader(jsonData))
+		return ContextSetUser(req, user)
+	}
+
+	t.Run("SUCCESS Disabled with a TOTP code", func(t *testing.T) {
+		authService, mock := newMockService(t)
+
+		expectTwoFactor(mock, 1, secret)
+		expectLoginAllowed(mock, "mike@test.com")
+		mock.ExpectExec("UPDATE auth_two_factor SET last_used_step").
+			WithArgs(1, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("DELETE FROM auth_two_factor").
+			WithArgs(1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		w := httptest.NewRecorder()
+		authService.DisableTwoFactorHandler(w, newRequest(totpCode(secret, totpStep(time.Now()))))
+
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+
+		events := authService.Audit.(*audit.Mock).Events()
+		if len(events) != 1 || events[0].Action != audit.ActionTwoFactorDisable {
+			t.Errorf("expected two-factor authentication being disabled to be audited, got %+v", events)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("ERROR Wrong code counted as a failed login", func(t *testing.T) {
+		authService, mock := newMockService(t)
+
+		expectTwoFactor(mock, 1, secret)
+		expectLoginAllowed(mock, "mike@test.com")
+		expectLoginFailed(mock, "mike@test.com", 1)
+
+		w := httptest.NewRecorder()
+		authService.DisableTwoFactorHandler(w, newRequest(totpCode(secret, totpStep(time.Now())-10)))
+
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("expected status %d, got %d", http.StatusUnauthorized, w.Code)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+}
+
+func TestLogoutUserHandler(t *testing.T) {
+	authService, mock := newMockService(t)
+
+	// Only the current session is revoked, not every session of the user
+	mock.ExpectExec("DELETE FROM auth_tokens WHERE id = \\$1 AND user_id = \\$2").
+		WithArgs(7, 1, ScopeAuthentication).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/auth/logout", nil)
+	req = ContextSetSession(ContextSetUser(req, &User{ID: 1}), &Session{ID: 7})
+	w := httptest.NewRecorder()
+
+	authService.LogoutUserHandler(w, req)
+
+	resp := w.Result()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	cookies := resp.Cookies()
+	if len(cookies) != 1 || cookies[0].Name != CookieAuthToken || cookies[0].Value != "" {
+		t.Errorf("expected %s cookie to be cleared, got %v", CookieAuthToken, cookies)
+	}
+
+	events := authService.Audit.(*audit.Mock).Events()
+	if len(events) != 1 || events[0].Action != audit.ActionSessionRevoke || string(events[0].Diff) != `{"session_id":7}` {
+		t.Errorf("expected the revoked session to be audited, got %+v", events)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unmet expectations: %v", err)
+	}
+}
+
+func TestGetSessionsHandler(t *testing.T) {
+	authService, mock := newMockService(t)
+	now := time.Now()
+
+	mock.ExpectQuery("SELECT id, created_at, last_seen_at, expiry, user_agent, ip, two_factor FROM auth_tokens").
+		WithArgs(1, ScopeAuthentication, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "last_seen_at", "expiry", "user_agent", "ip", "two_factor"}).
+			AddRow(8, now, now, now.Add(time.Hour), "Firefox", "192.0.2.1", false).
+			AddRow(7, now, now, now.Add(time.Hour), "Chrome", "198.51.100.1", false))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/auth/sessions", nil)
+	req = ContextSetSession(ContextSetUser(req, &User{ID: 1}), &Session{ID: 7})
+	w := httptest.NewRecorder()
+
+	authService.GetSessionsHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var body struct {
+		Sessions []Session `json:"sessions"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+
+	if len(body.Sessions) != 2 || body.Sessions[0].Current || !body.Sessions[1].Current || body.Sessions[1].UserAgent != "Chrome" {
+		t.Errorf("expected session 7 to be flagged as current, got %+v", body.Sessions)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unmet expectations: %v", err)
+	}
+}
+
+func TestDeleteSessionHandler(t *testing.T) {
+	newRequest := func(sessionID int64) *http.Request {
+		req := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/api/auth/sessions/%d", sessionID), nil)
+		return ContextSetSession(ContextSetUser(param.InjectID(req, sessionID), &User{ID: 1}), &Session{ID: 7})
+	}
+
+	t.Run("SUCCESS Other device logged out", func(t *testing.T) {
+		authService, mock := newMockService(t)
+
+		mock.ExpectExec("DELETE FROM auth_tokens").
+			WithArgs(8, 1, ScopeAuthentication).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		w := httptest.NewRecorder()
+		authService.DeleteSessionHandler(w, newRequest(8))
+
+		resp := w.Result()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
+		}
+
+		// The current session is left alone
+		if len(resp.Cookies()) != 0 {
+			t.Errorf("expected no cookie to be set, got %v", resp.Cookies())
+		}
+
+		events := authService.Audit.(*audit.Mock).Events()
+		if len(events) != 1 || events[0].Action != audit.ActionSessionRevoke || string(events[0].Diff) != `{"session_id":8}` {
+			t.Errorf("expected the revoked session to be audited, got %+v", events)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("SUCCESS Current session clears the cookie", func(t *testing.T) {
+		authService, mock := newMockService(t)
+
+		mock.ExpectExec("DELETE FROM auth_tokens").
+			WithArgs(7, 1, ScopeAuthentication).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		w := httptest.NewRecorder()
+		authService.DeleteSessionHandler(w, newRequest(7))
+
+		cookies := w.Result().Cookies()
+		if len(cookies) != 1 || cookies[0].Name != CookieAuthToken || cookies[0].Value != "" {
+			t.Errorf("expected %s cookie to be cleared, got %v", CookieAuthToken, cookies)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("ERROR Session of another user", func(t *testing.T) {
+		authService, mock := newMockService(t)
+
+		mock.ExpectExec("DELETE FROM auth_tokens").
+			WithArgs(9, 1, ScopeAuthentication).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		w := httptest.NewRecorder()
+		authService.DeleteSessionHandler(w, newRequest(9))
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+		}
+
+		if len(authService.Audit.(*audit.Mock).Events()) != 0 {
+			t.Errorf("expected nothing to be audited")
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+}
@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strconv"
+)
+
+// Number of cells along each side of an identicon. Only the left half, plus the middle
+// column, is derived from the hash and the rest is mirrored, which makes the result
+// look like a sprite rather than noise.
+const identiconGrid = 5
+
+var identiconBackground = color.RGBA{R: 240, G: 240, B: 240, A: 255}
+
+// IdenticonURL returns the URL of the generated avatar used for users without a
+// profile picture.
+func IdenticonURL(userID int64) string {
+	return fmt.Sprintf("/api/users/%d/avatar.png", userID)
+}
+
+// ProfilePictureURL returns the profile picture of the user, falling back to their
+// generated identicon if none is set.
+func ProfilePictureURL(userID int64, profilePicture string) string {
+	if profilePicture == "" {
+		return IdenticonURL(userID)
+	}
+	return profilePicture
+}
+
+// identicon draws the pixel-art avatar of the given user. The same user ID always
+// produces the same image, so it never has to be stored.
+func identicon(userID int64, size int) *image.Paletted {
+	sum := sha256.Sum256([]byte(strconv.FormatInt(userID, 10)))
+
+	hue := float64(binary.BigEndian.Uint16(sum[0:2])) / 65536 * 360
+	palette := color.Palette{identiconBackground, hslToRGB(hue, 0.65, 0.5)}
+	img := image.NewPaletted(image.Rect(0, 0, size, size), palette)
+
+	// About half a cell of padding on each side. The cell shrinks by a pixel if needed
+	// so the padding splits evenly and the mirrored halves line up exactly.
+	cell := size / (identiconGrid + 1)
+	if (size-cell*identiconGrid)%2 != 0 {
+		cell--
+	}
+	offset := (size - cell*identiconGrid) / 2
+
+	half := (identiconGrid + 1) / 2
+	for row := 0; row < identiconGrid; row++ {
+		for col := 0; col < half; col++ {
+			if sum[2+row*half+col]&1 == 0 {
+				continue
+			}
+
+			for _, c := range []int{col, identiconGrid - 1 - col} {
+				x, y := offset+c*cell, offset+row*cell
+				draw.Draw(img, image.Rect(x, y, x+cell, y+cell), &image.Uniform{palette[1]}, image.Point{}, draw.Src)
+			}
+		}
+	}
+
+	return img
+}
+
+// hslToRGB converts a hue in degrees, and saturation and lightness between 0 and 1, to
+// an opaque color. Picking the hue from the hash keeps every identicon equally vivid.
+func hslToRGB(h, s, l float64) color.RGBA {
+	c := (1 - math.Abs(2*l-1)) * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := l - c/2
+
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+
+	return color.RGBA{
+		R: uint8(math.Round((r + m) * 255)),
+		G: uint8(math.Round((g + m) * 255)),
+		B: uint8(math.Round((b + m) * 255)),
+		A: 255,
+	}
+}
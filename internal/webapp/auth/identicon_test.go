@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"image"
+	"testing"
+)
+
+func TestIdenticon(t *testing.T) {
+	t.Run("SUCCESS Same user gets the same image", func(t *testing.T) {
+		a, b := identicon(42, 64), identicon(42, 64)
+		if !samePixels(a, b) {
+			t.Errorf("expected identicon of the same user to be identical")
+		}
+	})
+
+	t.Run("SUCCESS Different users get different images", func(t *testing.T) {
+		if samePixels(identicon(1, 64), identicon(2, 64)) {
+			t.Errorf("expected identicons of different users to differ")
+		}
+	})
+
+	t.Run("SUCCESS Image is mirrored and sized", func(t *testing.T) {
+		for _, size := range AvatarSizes {
+			img := identicon(7, size)
+			if img.Bounds() != image.Rect(0, 0, size, size) {
+				t.Fatalf("expected %dx%d image, got %v", size, size, img.Bounds())
+			}
+
+			for y := 0; y < size; y++ {
+				for x := 0; x < size/2; x++ {
+					if img.ColorIndexAt(x, y) != img.ColorIndexAt(size-1-x, y) {
+						t.Fatalf("expected %d pixel image to be mirrored, differs at (%d, %d)", size, x, y)
+					}
+				}
+			}
+		}
+	})
+}
+
+func TestHSLToRGB(t *testing.T) {
+	tests := []struct {
+		h, s, l float64
+		r, g, b uint8
+	}{
+		{0, 1, 0.5, 255, 0, 0},
+		{120, 1, 0.5, 0, 255, 0},
+		{240, 1, 0.5, 0, 0, 255},
+		{0, 0, 1, 255, 255, 255},
+	}
+
+	for _, tt := range tests {
+		c := hslToRGB(tt.h, tt.s, tt.l)
+		if c.R != tt.r || c.G != tt.g || c.B != tt.b || c.A != 255 {
+			t.Errorf("hslToRGB(%v, %v, %v) = %v; expected {%d %d %d 255}", tt.h, tt.s, tt.l, c, tt.r, tt.g, tt.b)
+		}
+	}
+}
+
+func TestProfilePictureURL(t *testing.T) {
+	if got := ProfilePictureURL(3, ""); got != "/api/users/3/avatar.png" {
+		t.Errorf("expected identicon URL, got %q", got)
+	}
+	if got := ProfilePictureURL(3, "https://example.com/me.png"); got != "https://example.com/me.png" {
+		t.Errorf("expected profile picture to be kept, got %q", got)
+	}
+}
+
+func samePixels(a, b *image.Paletted) bool {
+	if a.Bounds() != b.Bounds() || len(a.Palette) != len(b.Palette) {
+		return false
+	}
+	for i := range a.Palette {
+		if a.Palette[i] != b.Palette[i] {
+			return false
+		}
+	}
+	return string(a.Pix) == string(b.Pix)
+}
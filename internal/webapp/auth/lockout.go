@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"strings"
+	"time"
+)
+
+// Failed logins are tracked per account, against password guessing, and per IP, against
+// one client trying many accounts.
+const (
+	LoginScopeAccount = "account"
+	LoginScopeIP      = "ip"
+)
+
+const (
+	// Failed logins to an account before each further attempt has to wait, starting at
+	// a second and doubling with every failure
+	loginFreeFailures = 3
+	loginMaxDelay     = time.Minute
+)
+
+type LoginFailures struct {
+	Scope        string
+	Subject      string
+	Count        int
+	LastFailedAt time.Time
+	LockedUntil  *time.Time
+}
+
+// accountSubject returns the subject failed logins to an account are tracked under.
+// Accounts are tracked by email, whether or not one exists for it, so a lockout doesn't
+// give away which emails are registered.
+func accountSubject(email string) string {
+	return strings.ToLower(email)
+}
+
+// Locked returns how long the account or IP stays locked, zero if it isn't.
+func (f *LoginFailures) Locked(now time.Time) time.Duration {
+	if f == nil || f.LockedUntil == nil || !f.LockedUntil.After(now) {
+		return 0
+	}
+	return f.LockedUntil.Sub(now)
+}
+
+// Delay returns how long is left to wait before the next login attempt, zero if it can
+// be made right away. Only applies to accounts, an IP may be shared by many users.
+func (f *LoginFailures) Delay(now time.Time) time.Duration {
+	if f == nil || f.Scope != LoginScopeAccount || f.Count < loginFreeFailures {
+		return 0
+	}
+
+	delay := loginMaxDelay
+	if shift := f.Count - loginFreeFailures; shift < 6 {
+		delay = min(time.Second<<shift, loginMaxDelay)
+	}
+
+	wait := f.LastFailedAt.Add(delay).Sub(now)
+	return max(wait, 0)
+}
@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoginFailures_Locked(t *testing.T) {
+	now := time.Now()
+	future, past := now.Add(time.Minute), now.Add(-time.Minute)
+
+	tests := []struct {
+		name     string
+		failures *LoginFailures
+		expected time.Duration
+	}{
+		{name: "No failures", failures: nil, expected: 0},
+		{name: "Never locked", failures: &LoginFailures{Count: 2}, expected: 0},
+		{name: "Lock expired", failures: &LoginFailures{Count: 5, LockedUntil: &past}, expected: 0},
+		{name: "Locked", failures: &LoginFailures{Count: 5, LockedUntil: &future}, expected: time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if wait := tt.failures.Locked(now); wait != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, wait)
+			}
+		})
+	}
+}
+
+func TestLoginFailures_Delay(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		failures *LoginFailures
+		expected time.Duration
+	}{
+		{name: "No failures", failures: nil, expected: 0},
+		{name: "Free failures", failures: &LoginFailures{Scope: LoginScopeAccount, Count: 2, LastFailedAt: now}, expected: 0},
+		{name: "First delay", failures: &LoginFailures{Scope: LoginScopeAccount, Count: 3, LastFailedAt: now}, expected: time.Second},
+		{name: "Doubled delay", failures: &LoginFailures{Scope: LoginScopeAccount, Count: 5, LastFailedAt: now}, expected: 4 * time.Second},
+		{name: "Capped delay", failures: &LoginFailures{Scope: LoginScopeAccount, Count: 40, LastFailedAt: now}, expected: time.Minute},
+		{name: "Delay partly waited", failures: &LoginFailures{Scope: LoginScopeAccount, Count: 5, LastFailedAt: now.Add(-3 * time.Second)}, expected: time.Second},
+		{name: "Delay over", failures: &LoginFailures{Scope: LoginScopeAccount, Count: 5, LastFailedAt: now.Add(-time.Hour)}, expected: 0},
+		{name: "No delay for IPs", failures: &LoginFailures{Scope: LoginScopeIP, Count: 10, LastFailedAt: now}, expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if wait := tt.failures.Delay(now); wait != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, wait)
+			}
+		})
+	}
+}
@@ -3,14 +3,20 @@ package auth
 import (
 	"errors"
 	"net/http"
+	"time"
 
+	"github.com/navazjm/pixelarcade/internal/webapp/utils/clientip"
 	"github.com/navazjm/pixelarcade/internal/webapp/utils/database"
 	"github.com/navazjm/pixelarcade/internal/webapp/utils/response"
 	"github.com/navazjm/pixelarcade/internal/webapp/utils/validator"
 )
 
 const (
-	CookieAuthToken = "auth_token"
+	CookieAuthToken  = "auth_token"
+	CookieOAuthState = "oauth_state"
+	// Challenge token of users logging in with an OAuth provider, who still have to
+	// enter a code
+	CookieTwoFactorToken = "two_factor_token"
 )
 
 func (s *Service) Authenticate(next http.Handler) http.Handler {
@@ -34,8 +40,8 @@ func (s *Service) Authenticate(next http.Handler) http.Handler {
 			return
 		}
 
-		// Retrieve the user based on the token
-		user, err := s.Models.GetUserFromToken(ScopeAuthentication, token)
+		// Retrieve the user and session based on the token
+		user, session, err := s.Models.GetSessionFromToken(token)
 		if err != nil {
 			switch {
 			case errors.Is(err, database.ErrRecordNotFound):
@@ -46,8 +52,23 @@ func (s *Service) Authenticate(next http.Handler) http.Handler {
 			return
 		}
 
-		// Set the user in the request context
+		// Tokens of deactivated users are revoked, this covers any issued in between
+		if !user.IsActive {
+			response.AccountInactive(w, r, s.Logger)
+			return
+		}
+
+		if time.Since(session.LastSeenAt) > sessionTouchInterval {
+			// Not worth failing the request over, the session is only shown to the user
+			err = s.Models.TouchSession(session.ID, clientip.Get(r))
+			if err != nil {
+				s.Logger.Error(err.Error(), "session_id", session.ID)
+			}
+		}
+
+		// Set the user and session in the request context
 		r = ContextSetUser(r, user)
+		r = ContextSetSession(r, session)
 		s.Logger.Info("set user in context")
 		next.ServeHTTP(w, r)
 	})
@@ -65,3 +86,69 @@ func (s *Service) RequireAuthenticatedUser(next http.HandlerFunc) http.HandlerFu
 		next.ServeHTTP(w, r)
 	})
 }
+
+func (s *Service) RequireVerifiedUser(next http.HandlerFunc) http.HandlerFunc {
+	fn := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := ContextGetUser(r)
+
+		if !user.IsVerified {
+			response.VerificationRequired(w, r, s.Logger)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+
+	return s.RequireAuthenticatedUser(fn)
+}
+
+func (s *Service) RequirePermission(code string, next http.HandlerFunc) http.HandlerFunc {
+	fn := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := ContextGetUser(r)
+
+		ok, err := s.HasPermission(user, code)
+		if err != nil {
+			response.ServerError(w, r, s.Logger, err)
+			return
+		}
+
+		if !ok {
+			response.PermissionDenied(w, r, s.Logger)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+
+	return s.RequireAuthenticatedUser(fn)
+}
+
+// RequireTwoFactor only lets sessions started with a second factor through, for
+// resources too sensitive to be protected by a password alone. Users who turned
+// two-factor authentication off since are refused too.
+func (s *Service) RequireTwoFactor(next http.HandlerFunc) http.HandlerFunc {
+	fn := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := ContextGetUser(r)
+
+		session := ContextGetSession(r)
+		if session == nil || !session.TwoFactor {
+			response.TwoFactorRequired(w, r, s.Logger)
+			return
+		}
+
+		tf, err := s.Models.GetTwoFactor(user.ID)
+		if err != nil && !errors.Is(err, database.ErrRecordNotFound) {
+			response.ServerError(w, r, s.Logger, err)
+			return
+		}
+
+		if !tf.Enabled() {
+			response.TwoFactorRequired(w, r, s.Logger)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+
+	return s.RequireAuthenticatedUser(fn)
+}
@@ -5,6 +5,9 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
 )
 
 func TestAuthenticateNoCookie(t *testing.T) {
@@ -55,6 +58,96 @@ func TestAuthenticateInvalidToken(t *testing.T) {
 	}
 }
 
+// Columns of a user joined with their session
+var sessionColumns = []string{
+	"id", "created_at", "updated_at", "version", "is_active", "email", "name",
+	"profile_picture", "password", "provider", "role_id", "is_verified",
+	"id", "created_at", "last_seen_at", "expiry", "user_agent", "ip", "two_factor",
+}
+
+func TestAuthenticateInactiveUser(t *testing.T) {
+	service, mock := newMockService(t)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req := httptest.NewRequest("GET", "http://example.com", nil)
+	req.AddCookie(&http.Cookie{Name: CookieAuthToken, Value: "ABCDEFGHIJKLMNOPQRSTUVWXYZ"})
+	w := httptest.NewRecorder()
+
+	// Token is still valid, but the account was deactivated
+	mock.ExpectQuery("SELECT au.*, at.id, .* FROM auth_users as au INNER JOIN auth_tokens").
+		WithArgs(sqlmock.AnyArg(), ScopeAuthentication, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(sessionColumns).AddRow(
+			1, time.Now(), time.Now(), 1, false, "mike@test.com", "Mike", "", []byte("hash"), "N/A", 1, true,
+			7, time.Now(), time.Now(), time.Now().Add(time.Hour), "", "192.0.2.1", false,
+		))
+
+	service.Authenticate(next).ServeHTTP(w, req)
+
+	if w.Result().StatusCode != http.StatusForbidden {
+		t.Errorf("Expected status code %d, but got %d", http.StatusForbidden, w.Result().StatusCode)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unmet expectations: %v", err)
+	}
+}
+
+func TestAuthenticateSession(t *testing.T) {
+	tests := []struct {
+		name       string
+		lastSeenAt time.Time
+		touched    bool
+	}{
+		{"Recently seen", time.Now().Add(-10 * time.Second), false},
+		{"Not seen for a while", time.Now().Add(-time.Hour), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, mock := newMockService(t)
+
+			var session *Session
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				session = ContextGetSession(r)
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest("GET", "http://example.com", nil)
+			req.AddCookie(&http.Cookie{Name: CookieAuthToken, Value: "ABCDEFGHIJKLMNOPQRSTUVWXYZ"})
+			w := httptest.NewRecorder()
+
+			mock.ExpectQuery("SELECT au.*, at.id, .* FROM auth_users as au INNER JOIN auth_tokens").
+				WithArgs(sqlmock.AnyArg(), ScopeAuthentication, sqlmock.AnyArg()).
+				WillReturnRows(sqlmock.NewRows(sessionColumns).AddRow(
+					1, time.Now(), time.Now(), 1, true, "mike@test.com", "Mike", "", []byte("hash"), "N/A", 1, true,
+					7, time.Now(), tt.lastSeenAt, time.Now().Add(time.Hour), "Mozilla/5.0", "198.51.100.1", false,
+				))
+			if tt.touched {
+				mock.ExpectExec("UPDATE auth_tokens SET last_seen_at = NOW\\(\\), ip = \\$2 WHERE id = \\$1").
+					WithArgs(7, "192.0.2.1").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+
+			service.Authenticate(next).ServeHTTP(w, req)
+
+			if w.Result().StatusCode != http.StatusOK {
+				t.Errorf("Expected status code %d, but got %d", http.StatusOK, w.Result().StatusCode)
+			}
+
+			if session == nil || session.ID != 7 || session.UserAgent != "Mozilla/5.0" {
+				t.Errorf("expected the session to be set in the request context, got %+v", session)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
 func TestRequireAuthenticatedUser(t *testing.T) {
 	service, _ := newMockService(t)
 
@@ -103,3 +196,129 @@ func TestRequireAuthenticatedUserAuthenticated(t *testing.T) {
 		t.Errorf("Expected status code %d, but got %d", http.StatusOK, w.Result().StatusCode)
 	}
 }
+
+func TestRequireVerifiedUser(t *testing.T) {
+	service, _ := newMockService(t)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name     string
+		user     *User
+		expected int
+	}{
+		{"Anonymous user", AnonymousUser, http.StatusUnauthorized},
+		{"Unverified user", &User{ID: 1, IsVerified: false}, http.StatusForbidden},
+		{"Verified user", &User{ID: 1, IsVerified: true}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+			req = ContextSetUser(req, tt.user)
+			w := httptest.NewRecorder()
+
+			service.RequireVerifiedUser(next).ServeHTTP(w, req)
+
+			if w.Result().StatusCode != tt.expected {
+				t.Errorf("Expected status code %d, but got %d", tt.expected, w.Result().StatusCode)
+			}
+		})
+	}
+}
+
+func TestRequirePermission(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	t.Run("Anonymous user", func(t *testing.T) {
+		service, _ := newMockService(t)
+
+		req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+		req = ContextSetUser(req, AnonymousUser)
+		w := httptest.NewRecorder()
+
+		service.RequirePermission(PermissionGamesWrite, next).ServeHTTP(w, req)
+
+		if w.Result().StatusCode != http.StatusUnauthorized {
+			t.Errorf("Expected status code %d, but got %d", http.StatusUnauthorized, w.Result().StatusCode)
+		}
+	})
+
+	t.Run("Missing permission", func(t *testing.T) {
+		service, mock := newMockService(t)
+		mock.ExpectQuery("SELECT p.code FROM auth_permissions").
+			WithArgs(RoleBasic).
+			WillReturnRows(sqlmock.NewRows([]string{"code"}))
+
+		req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+		req = ContextSetUser(req, &User{ID: 1, RoleID: RoleBasic})
+		w := httptest.NewRecorder()
+
+		service.RequirePermission(PermissionGamesWrite, next).ServeHTTP(w, req)
+
+		if w.Result().StatusCode != http.StatusForbidden {
+			t.Errorf("Expected status code %d, but got %d", http.StatusForbidden, w.Result().StatusCode)
+		}
+	})
+
+	t.Run("Has permission", func(t *testing.T) {
+		service, mock := newMockService(t)
+		mock.ExpectQuery("SELECT p.code FROM auth_permissions").
+			WithArgs(RoleAdmin).
+			WillReturnRows(sqlmock.NewRows([]string{"code"}).AddRow(PermissionGamesWrite))
+
+		req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+		req = ContextSetUser(req, &User{ID: 1, RoleID: RoleAdmin})
+		w := httptest.NewRecorder()
+
+		service.RequirePermission(PermissionGamesWrite, next).ServeHTTP(w, req)
+
+		if w.Result().StatusCode != http.StatusOK {
+			t.Errorf("Expected status code %d, but got %d", http.StatusOK, w.Result().StatusCode)
+		}
+	})
+}
+
+func TestRequireTwoFactor(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name     string
+		session  *Session
+		secret   []byte
+		expected int
+	}{
+		{"Session without a second factor", &Session{ID: 7}, nil, http.StatusForbidden},
+		{"Not enrolled anymore", &Session{ID: 7, TwoFactor: true}, nil, http.StatusForbidden},
+		{"Enabled", &Session{ID: 7, TwoFactor: true}, []byte("12345678901234567890"), http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, mock := newMockService(t)
+			if tt.session.TwoFactor {
+				expectTwoFactor(mock, 1, tt.secret)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+			req = ContextSetSession(ContextSetUser(req, &User{ID: 1, RoleID: RoleAdmin}), tt.session)
+			w := httptest.NewRecorder()
+
+			service.RequireTwoFactor(next).ServeHTTP(w, req)
+
+			if w.Result().StatusCode != tt.expected {
+				t.Errorf("Expected status code %d, but got %d", tt.expected, w.Result().StatusCode)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unmet expectations: %v", err)
+			}
+		})
+	}
+}
@@ -5,15 +5,12 @@ import (
 	"crypto/sha256"
 	"database/sql"
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/navazjm/pixelarcade/internal/webapp/utils/database"
 )
 
-const (
-	defaultProfilePicture = "link-to-default.jpg"
-)
-
 type Model struct {
 	DB *sql.DB
 }
@@ -26,10 +23,6 @@ func (m Model) InsertUser(user *User) error {
         VALUES ($1, $2, $3, $4, $5, $6, $7)
         RETURNING id, created_at, updated_at, version, role_id`
 
-	if user.ProfilePicture == "" {
-		user.ProfilePicture = defaultProfilePicture
-	}
-
 	args := []any{user.Name, user.Email, user.Password.hash, user.ProfilePicture, user.Provider, user.IsActive, user.IsVerified}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
@@ -169,6 +162,115 @@ func (m Model) GetUserFromToken(tokenScope, tokenPlaintext string) (*User, error
 	return &user, nil
 }
 
+// GetEmailChangeFromToken returns the user holding the email change token, along with
+// the new address the token was issued for.
+func (m Model) GetEmailChangeFromToken(tokenPlaintext string) (*User, string, error) {
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	query := `
+        SELECT au.*, at.email
+        FROM auth_users as au
+        INNER JOIN auth_tokens as at
+        ON au.id = at.user_id
+        WHERE at.hash = $1
+        AND at.scope = $2 
+        AND at.expiry > $3`
+
+	args := []any{tokenHash[:], ScopeEmailChange, time.Now()}
+
+	var user User
+	var email string
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(
+		&user.ID,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&user.Version,
+		&user.IsActive,
+		&user.Email,
+		&user.Name,
+		&user.ProfilePicture,
+		&user.Password.hash,
+		&user.Provider,
+		&user.RoleID,
+		&user.IsVerified,
+		&email,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, "", database.ErrRecordNotFound
+		default:
+			return nil, "", err
+		}
+	}
+
+	return &user, email, nil
+}
+
+// GetUsers returns a page of the users matching the filters, ordered by ID, along with
+// the total number of matching users. The email filter matches any part of the address.
+func (m Model) GetUsers(filters UserFilters) ([]*User, int64, error) {
+	query := `
+        SELECT COUNT(*) OVER(), id, created_at, updated_at, version, is_active, email, name, profile_picture, password_hash, provider, role_id, is_verified
+        FROM auth_users
+        WHERE (email ILIKE '%' || $1 || '%' OR $1 = '')
+        AND (role_id = $2 OR $2 = 0)
+        AND (is_verified = $3 OR $3 IS NULL)
+        AND (is_active = $4 OR $4 IS NULL)
+        ORDER BY id
+        LIMIT $5 OFFSET $6`
+
+	email := likeEscaper.Replace(filters.Email)
+	args := []any{email, filters.RoleID, filters.IsVerified, filters.IsActive, filters.Limit, filters.Offset}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var total int64
+	users := []*User{}
+	for rows.Next() {
+		var user User
+		err := rows.Scan(
+			&total,
+			&user.ID,
+			&user.CreatedAt,
+			&user.UpdatedAt,
+			&user.Version,
+			&user.IsActive,
+			&user.Email,
+			&user.Name,
+			&user.ProfilePicture,
+			&user.Password.hash,
+			&user.Provider,
+			&user.RoleID,
+			&user.IsVerified,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, &user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+// Escapes the wildcards of a LIKE pattern, so user input is matched literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
 func (m Model) UpdateUserByID(user *User) error {
 	query := `
         UPDATE auth_users 
@@ -236,10 +338,10 @@ func (m Model) DeleteUserByID(userID int64) error {
 
 func (m Model) InsertToken(token *Token) error {
 	query := `
-        INSERT INTO auth_tokens (hash, user_id, expiry, scope) 
-        VALUES ($1, $2, $3, $4)`
+        INSERT INTO auth_tokens (hash, user_id, expiry, scope, user_agent, ip, email, two_factor) 
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
 
-	args := []any{token.Hash, token.UserID, token.Expiry, token.Scope}
+	args := []any{token.Hash, token.UserID, token.Expiry, token.Scope, token.UserAgent, token.IP, token.Email, token.TwoFactor}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
@@ -272,3 +374,530 @@ func (m Model) DeleteAllTokensForUser(scope string, userID int64) error {
 
 	return nil
 }
+
+// DeleteTokensForUser deletes every token of the user, whatever its scope.
+func (m Model) DeleteTokensForUser(userID int64) error {
+	query := `
+        DELETE FROM auth_tokens 
+        WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return database.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Sessions
+
+// GetSessionFromToken returns the user holding the authentication token, along with
+// the session it belongs to.
+func (m Model) GetSessionFromToken(tokenPlaintext string) (*User, *Session, error) {
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	query := `
+        SELECT au.*, at.id, at.created_at, at.last_seen_at, at.expiry, at.user_agent, at.ip, at.two_factor
+        FROM auth_users as au
+        INNER JOIN auth_tokens as at
+        ON au.id = at.user_id
+        WHERE at.hash = $1
+        AND at.scope = $2
+        AND at.expiry > $3`
+
+	args := []any{tokenHash[:], ScopeAuthentication, time.Now()}
+
+	var user User
+	var session Session
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(
+		&user.ID,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&user.Version,
+		&user.IsActive,
+		&user.Email,
+		&user.Name,
+		&user.ProfilePicture,
+		&user.Password.hash,
+		&user.Provider,
+		&user.RoleID,
+		&user.IsVerified,
+		&session.ID,
+		&session.CreatedAt,
+		&session.LastSeenAt,
+		&session.Expiry,
+		&session.UserAgent,
+		&session.IP,
+		&session.TwoFactor,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, nil, database.ErrRecordNotFound
+		default:
+			return nil, nil, err
+		}
+	}
+
+	return &user, &session, nil
+}
+
+// GetSessionsForUser returns the sessions of the user that haven't expired, the most
+// recently seen first.
+func (m Model) GetSessionsForUser(userID int64) ([]*Session, error) {
+	query := `
+        SELECT id, created_at, last_seen_at, expiry, user_agent, ip, two_factor
+        FROM auth_tokens
+        WHERE user_id = $1 AND scope = $2 AND expiry > $3
+        ORDER BY last_seen_at DESC, id DESC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, ScopeAuthentication, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*Session{}
+	for rows.Next() {
+		var session Session
+		err := rows.Scan(&session.ID, &session.CreatedAt, &session.LastSeenAt, &session.Expiry, &session.UserAgent, &session.IP, &session.TwoFactor)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, &session)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// TouchSession records that the session was just seen, from the IP.
+func (m Model) TouchSession(sessionID int64, ip string) error {
+	query := `
+        UPDATE auth_tokens
+        SET last_seen_at = NOW(), ip = $2
+        WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, sessionID, ip)
+	return err
+}
+
+// DeleteSession revokes one of the user's sessions. Returns ErrRecordNotFound if the
+// user has no such session.
+func (m Model) DeleteSession(userID, sessionID int64) error {
+	query := `
+        DELETE FROM auth_tokens
+        WHERE id = $1 AND user_id = $2 AND scope = $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, sessionID, userID, ScopeAuthentication)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return database.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// DeleteOtherSessions revokes every session of the user but the one given. Returns
+// ErrRecordNotFound if there were no others.
+func (m Model) DeleteOtherSessions(userID, sessionID int64) error {
+	query := `
+        DELETE FROM auth_tokens
+        WHERE user_id = $1 AND scope = $2 AND id <> $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID, ScopeAuthentication, sessionID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return database.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Login failures
+
+// GetLoginFailures returns the failed logins tracked for the account with the email and
+// for the IP, nil for either if there are none.
+func (m Model) GetLoginFailures(email, ip string) (account *LoginFailures, byIP *LoginFailures, err error) {
+	query := `
+        SELECT scope, subject, count, last_failed_at, locked_until
+        FROM auth_login_failures
+        WHERE (scope = $1 AND subject = $2) OR (scope = $3 AND subject = $4)`
+
+	args := []any{LoginScopeAccount, accountSubject(email), LoginScopeIP, ip}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var failures LoginFailures
+		err := rows.Scan(&failures.Scope, &failures.Subject, &failures.Count, &failures.LastFailedAt, &failures.LockedUntil)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if failures.Scope == LoginScopeAccount {
+			account = &failures
+		} else {
+			byIP = &failures
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return account, byIP, nil
+}
+
+// IncrementLoginFailures counts a failed login, starting over if the last one was longer
+// ago than the lockout, and locks the account or IP for the lockout once max failures
+// are reached. Counting and locking are done in one statement, so attempts made at the
+// same time can't both reach max. Returns ErrRecordNotFound if it is already locked, in
+// which case the failure isn't counted. LockedUntil is only set on the failures returned
+// if this failure locked it.
+func (m Model) IncrementLoginFailures(scope, subject string, max int, lockout time.Duration) (*LoginFailures, error) {
+	query := `
+        INSERT INTO auth_login_failures (scope, subject, count, last_failed_at, locked_until)
+        VALUES ($1, $2, 1, NOW(), CASE WHEN $3 <= 1 THEN NOW() + $4 * INTERVAL '1 second' END)
+        ON CONFLICT (scope, subject) DO UPDATE
+        SET count = CASE
+                WHEN auth_login_failures.last_failed_at < NOW() - $4 * INTERVAL '1 second' THEN 1
+                ELSE auth_login_failures.count + 1
+            END,
+            last_failed_at = NOW(),
+            locked_until = CASE
+                WHEN CASE
+                    WHEN auth_login_failures.last_failed_at < NOW() - $4 * INTERVAL '1 second' THEN 1
+                    ELSE auth_login_failures.count + 1
+                END >= $3 THEN NOW() + $4 * INTERVAL '1 second'
+            END
+        WHERE auth_login_failures.locked_until IS NULL OR auth_login_failures.locked_until <= NOW()
+        RETURNING count, last_failed_at, locked_until`
+
+	failures := &LoginFailures{Scope: scope, Subject: subject}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, scope, subject, max, lockout.Seconds()).Scan(&failures.Count, &failures.LastFailedAt, &failures.LockedUntil)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, database.ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return failures, nil
+}
+
+// DeleteStaleLoginFailures forgets the failed logins nobody has added to for longer than
+// the lockout. Locks end a lockout after the last failure, so none are lifted early.
+func (m Model) DeleteStaleLoginFailures(lockout time.Duration) error {
+	query := `
+        DELETE FROM auth_login_failures
+        WHERE last_failed_at < NOW() - $1 * INTERVAL '1 second'`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, lockout.Seconds())
+	return err
+}
+
+// DeleteLoginFailures forgets the failed logins, lifting any lock. Returns
+// ErrRecordNotFound if there were none.
+func (m Model) DeleteLoginFailures(scope, subject string) error {
+	query := `
+        DELETE FROM auth_login_failures
+        WHERE scope = $1 AND subject = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, scope, subject)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return database.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Two-factor authentication
+
+func (m Model) GetTwoFactor(userID int64) (*TwoFactor, error) {
+	query := `
+        SELECT user_id, secret, confirmed_at, last_used_step
+        FROM auth_two_factor
+        WHERE user_id = $1`
+
+	var tf TwoFactor
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, userID).Scan(&tf.UserID, &tf.Secret, &tf.ConfirmedAt, &tf.LastUsedStep)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, database.ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &tf, nil
+}
+
+// InsertTwoFactor starts an enrollment, replacing any earlier one that was never
+// confirmed. Returns ErrEditConflict if two-factor authentication is already enabled.
+func (m Model) InsertTwoFactor(tf *TwoFactor) error {
+	query := `
+        INSERT INTO auth_two_factor (user_id, secret)
+        VALUES ($1, $2)
+        ON CONFLICT (user_id) DO UPDATE
+        SET secret = EXCLUDED.secret, last_used_step = 0
+        WHERE auth_two_factor.confirmed_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, tf.UserID, tf.Secret)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return database.ErrEditConflict
+	}
+
+	return nil
+}
+
+// ConfirmTwoFactor enables two-factor authentication with the code of the step that
+// confirmed the enrollment marked as used, and stores the hashes of the recovery codes.
+// Returns ErrEditConflict if the enrollment was confirmed in the meantime.
+func (m Model) ConfirmTwoFactor(tf *TwoFactor, step int64, recoveryCodeHashes [][]byte) error {
+	query := `
+        UPDATE auth_two_factor
+        SET confirmed_at = NOW(), last_used_step = $2
+        WHERE user_id = $1 AND confirmed_at IS NULL
+        RETURNING confirmed_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, tf.UserID, step).Scan(&tf.ConfirmedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return database.ErrEditConflict
+		default:
+			return err
+		}
+	}
+	tf.LastUsedStep = step
+
+	for _, hash := range recoveryCodeHashes {
+		_, err = tx.ExecContext(ctx, `INSERT INTO auth_recovery_codes (hash, user_id) VALUES ($1, $2)`, hash, tf.UserID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UseTwoFactorStep marks the codes of the step as used. Returns ErrEditConflict if the
+// step, or a later one, was used in the meantime.
+func (m Model) UseTwoFactorStep(userID int64, step int64) error {
+	query := `
+        UPDATE auth_two_factor
+        SET last_used_step = $2
+        WHERE user_id = $1 AND last_used_step < $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID, step)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return database.ErrEditConflict
+	}
+
+	return nil
+}
+
+// UseRecoveryCode deletes the recovery code, so it can't be used again. Returns
+// ErrRecordNotFound if the user has no such code.
+func (m Model) UseRecoveryCode(userID int64, hash []byte) error {
+	query := `
+        DELETE FROM auth_recovery_codes
+        WHERE hash = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, hash, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return database.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// DeleteTwoFactor disables two-factor authentication, the recovery codes are deleted
+// along with it.
+func (m Model) DeleteTwoFactor(userID int64) error {
+	query := `
+        DELETE FROM auth_two_factor
+        WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return database.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Permissions
+
+func (m Model) GetPermissionsForRole(roleID RoleID) (Permissions, error) {
+	query := `
+        SELECT p.code
+        FROM auth_permissions as p
+        INNER JOIN auth_roles_permissions as rp
+        ON p.id = rp.permission_id
+        WHERE rp.role_id = $1
+        AND p.is_active = TRUE`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, roleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	permissions := Permissions{}
+	for rows.Next() {
+		var code string
+		err := rows.Scan(&code)
+		if err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, code)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return permissions, nil
+}
@@ -126,6 +126,56 @@ func TestGetUserByID(t *testing.T) {
 	}
 }
 
+func TestGetUsers(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	model := Model{DB: mockDB}
+	columns := []string{"count", "id", "created_at", "updated_at", "version", "is_active", "email", "name", "profile_picture", "password_hash", "provider", "role_id", "is_verified"}
+	active := true
+
+	// Test Case 1: Filtered page with wildcards in the email escaped
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) OVER\\(\\), id, .* FROM auth_users WHERE .* ORDER BY id LIMIT \\$5 OFFSET \\$6").
+		WithArgs(`100\%\_off`, RoleAdmin, nil, &active, 10, 20).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(25, 1, time.Now(), time.Now(), 1, true, "john@example.com", "John Doe", "", []byte("hashedpassword"), "N/A", 2, true).
+			AddRow(25, 2, time.Now(), time.Now(), 1, true, "jane@example.com", "Jane Doe", "", nil, "google", 2, true))
+
+	users, total, err := model.GetUsers(UserFilters{Email: "100%_off", RoleID: RoleAdmin, IsActive: &active, Limit: 10, Offset: 20})
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	if len(users) != 2 || total != 25 {
+		t.Errorf("expected 2 users of 25, got %d of %d", len(users), total)
+	}
+
+	// Test Case 2: No users found
+	mock.ExpectQuery("SELECT .* FROM auth_users").
+		WillReturnRows(sqlmock.NewRows(columns))
+
+	users, total, err = model.GetUsers(UserFilters{Limit: 50})
+	if err != nil || len(users) != 0 || total != 0 {
+		t.Errorf("expected no users and no error, got %d of %d (err: %v)", len(users), total, err)
+	}
+
+	// Test Case 3: Database error
+	mock.ExpectQuery("SELECT .* FROM auth_users").
+		WillReturnError(sql.ErrConnDone)
+
+	_, _, err = model.GetUsers(UserFilters{Limit: 50})
+	if err != sql.ErrConnDone {
+		t.Errorf("expected sql.ErrConnDone, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
 func TestGetUserByEmail(t *testing.T) {
 	mockDB, mock, err := sqlmock.New()
 	if err != nil {
@@ -240,6 +290,57 @@ func TestGetUserFromToken(t *testing.T) {
 	}
 }
 
+func TestGetEmailChangeFromToken(t *testing.T) {
+	// Create a mock database
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	model := Model{DB: db}
+	tokenPlaintext := "testtoken"
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+	currentTime := time.Now()
+
+	// Test Case 1: The user is returned along with the new address
+	mock.ExpectQuery(`SELECT au\..*, at.email FROM auth_users as au INNER JOIN auth_tokens as at ON au.id = at.user_id WHERE at.hash = \$1 AND at.scope = \$2 AND at.expiry > \$3`).
+		WithArgs(tokenHash[:], ScopeEmailChange, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "created_at", "updated_at", "version", "is_active", "email", "name", "profile_picture", "password_hash", "provider", "role_id", "is_verified", "email",
+		}).
+			AddRow(1, currentTime, currentTime, 1, true, "test@example.com", "Test User", "profile.jpg", "hashedpassword", "", 1, true, "new@example.com"))
+
+	user, email, err := model.GetEmailChangeFromToken(tokenPlaintext)
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if user == nil || user.Email != "test@example.com" {
+		t.Errorf("expected the user with the current address, got %v", user)
+	}
+	if email != "new@example.com" {
+		t.Errorf("expected new address new@example.com, got %q", email)
+	}
+
+	// Test Case 2: No rows found (token does not exist)
+	mock.ExpectQuery(`SELECT au\..*, at.email FROM auth_users as au`).
+		WithArgs(tokenHash[:], ScopeEmailChange, sqlmock.AnyArg()).
+		WillReturnError(sql.ErrNoRows)
+
+	user, _, err = model.GetEmailChangeFromToken(tokenPlaintext)
+	if err != database.ErrRecordNotFound {
+		t.Errorf("expected ErrRecordNotFound, got %v", err)
+	}
+	if user != nil {
+		t.Errorf("expected nil user, got %v", user)
+	}
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
 func TestUpdateUserByID(t *testing.T) {
 	// Create a mock database
 	db, mock, err := sqlmock.New()
@@ -371,15 +472,17 @@ func TestInsertToken(t *testing.T) {
 	model := Model{DB: db}
 
 	token := &Token{
-		Hash:   []byte("samplehash"),
-		UserID: 1,
-		Expiry: time.Now().Add(24 * time.Hour),
-		Scope:  "authentication",
+		Hash:      []byte("samplehash"),
+		UserID:    1,
+		Expiry:    time.Now().Add(24 * time.Hour),
+		Scope:     "authentication",
+		UserAgent: "Mozilla/5.0",
+		IP:        "192.0.2.1",
 	}
 
 	// Test Case 1: Successful token insertion
-	mock.ExpectExec(`INSERT INTO auth_tokens \(hash, user_id, expiry, scope\) VALUES \(\$1, \$2, \$3, \$4\)`).
-		WithArgs(token.Hash, token.UserID, token.Expiry, token.Scope).
+	mock.ExpectExec(`INSERT INTO auth_tokens \(hash, user_id, expiry, scope, user_agent, ip, email, two_factor\) VALUES \(\$1, \$2, \$3, \$4, \$5, \$6, \$7, \$8\)`).
+		WithArgs(token.Hash, token.UserID, token.Expiry, token.Scope, token.UserAgent, token.IP, token.Email, token.TwoFactor).
 		WillReturnResult(sqlmock.NewResult(1, 1)) // Simulates successful insertion
 
 	err = model.InsertToken(token)
@@ -388,8 +491,8 @@ func TestInsertToken(t *testing.T) {
 	}
 
 	// Test Case 2: Database error
-	mock.ExpectExec(`INSERT INTO auth_tokens \(hash, user_id, expiry, scope\) VALUES \(\$1, \$2, \$3, \$4\)`).
-		WithArgs(token.Hash, token.UserID, token.Expiry, token.Scope).
+	mock.ExpectExec(`INSERT INTO auth_tokens \(hash, user_id, expiry, scope, user_agent, ip, email, two_factor\) VALUES \(\$1, \$2, \$3, \$4, \$5, \$6, \$7, \$8\)`).
+		WithArgs(token.Hash, token.UserID, token.Expiry, token.Scope, token.UserAgent, token.IP, token.Email, token.TwoFactor).
 		WillReturnError(sql.ErrConnDone) // Simulate a database connection issue
 
 	err = model.InsertToken(token)
@@ -450,3 +553,649 @@ func TestDeleteAllTokensForUser(t *testing.T) {
 		t.Errorf("unfulfilled expectations: %v", err)
 	}
 }
+
+func TestDeleteTokensForUser(t *testing.T) {
+	// Create a mock database
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	model := Model{DB: db}
+	userID := int64(1)
+
+	// Test Case 1: Tokens of every scope deleted
+	mock.ExpectExec(`DELETE FROM auth_tokens WHERE user_id = \$1`).
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	err = model.DeleteTokensForUser(userID)
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	// Test Case 2: No rows affected (record not found)
+	mock.ExpectExec(`DELETE FROM auth_tokens WHERE user_id = \$1`).
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = model.DeleteTokensForUser(userID)
+	if err != database.ErrRecordNotFound {
+		t.Errorf("expected ErrRecordNotFound, got %v", err)
+	}
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestGetSessionFromToken(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	model := Model{DB: mockDB}
+	plaintext := "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	hash := sha256.Sum256([]byte(plaintext))
+
+	// Test Case 1: Session found
+	mock.ExpectQuery("SELECT au.\\*, at.id, at.created_at, at.last_seen_at, at.expiry, at.user_agent, at.ip, at.two_factor FROM auth_users as au INNER JOIN auth_tokens").
+		WithArgs(hash[:], ScopeAuthentication, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "created_at", "updated_at", "version", "is_active", "email", "name",
+			"profile_picture", "password", "provider", "role_id", "is_verified",
+			"id", "created_at", "last_seen_at", "expiry", "user_agent", "ip", "two_factor",
+		}).AddRow(
+			1, time.Now(), time.Now(), 1, true, "mike@test.com", "Mike", "", []byte("hash"), "N/A", 1, true,
+			7, time.Now(), time.Now(), time.Now().Add(time.Hour), "Mozilla/5.0", "192.0.2.1", true,
+		))
+
+	user, session, err := model.GetSessionFromToken(plaintext)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if user.ID != 1 || session.ID != 7 || session.UserAgent != "Mozilla/5.0" || session.IP != "192.0.2.1" || !session.TwoFactor {
+		t.Errorf("unexpected user %+v and session %+v", user, session)
+	}
+
+	// Test Case 2: Token not found or expired
+	mock.ExpectQuery("SELECT au.\\*, at.id, .* FROM auth_users as au INNER JOIN auth_tokens").
+		WithArgs(hash[:], ScopeAuthentication, sqlmock.AnyArg()).
+		WillReturnError(sql.ErrNoRows)
+
+	_, _, err = model.GetSessionFromToken(plaintext)
+	if !errors.Is(err, database.ErrRecordNotFound) {
+		t.Errorf("expected ErrRecordNotFound, got %v", err)
+	}
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestGetSessionsForUser(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	model := Model{DB: mockDB}
+	columns := []string{"id", "created_at", "last_seen_at", "expiry", "user_agent", "ip", "two_factor"}
+
+	// Test Case 1: Sessions found
+	mock.ExpectQuery("SELECT id, created_at, last_seen_at, expiry, user_agent, ip, two_factor FROM auth_tokens WHERE user_id = \\$1 AND scope = \\$2 AND expiry > \\$3 ORDER BY last_seen_at DESC").
+		WithArgs(1, ScopeAuthentication, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(8, time.Now(), time.Now(), time.Now().Add(time.Hour), "Firefox", "192.0.2.1", true).
+			AddRow(7, time.Now(), time.Now(), time.Now().Add(time.Hour), "Chrome", "198.51.100.1", false))
+
+	sessions, err := model.GetSessionsForUser(1)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(sessions) != 2 || sessions[0].ID != 8 || sessions[1].UserAgent != "Chrome" {
+		t.Errorf("unexpected sessions %+v", sessions)
+	}
+
+	// Test Case 2: No sessions
+	mock.ExpectQuery("SELECT id, created_at, last_seen_at, expiry, user_agent, ip, two_factor FROM auth_tokens").
+		WithArgs(2, ScopeAuthentication, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(columns))
+
+	sessions, err = model.GetSessionsForUser(2)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if sessions == nil || len(sessions) != 0 {
+		t.Errorf("expected an empty list, got %v", sessions)
+	}
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestDeleteSession(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	model := Model{DB: mockDB}
+
+	// Test Case 1: Session deleted
+	mock.ExpectExec("DELETE FROM auth_tokens WHERE id = \\$1 AND user_id = \\$2 AND scope = \\$3").
+		WithArgs(7, 1, ScopeAuthentication).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = model.DeleteSession(1, 7)
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	// Test Case 2: Session of another user
+	mock.ExpectExec("DELETE FROM auth_tokens").
+		WithArgs(7, 2, ScopeAuthentication).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = model.DeleteSession(2, 7)
+	if !errors.Is(err, database.ErrRecordNotFound) {
+		t.Errorf("expected ErrRecordNotFound, got %v", err)
+	}
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestDeleteOtherSessions(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	model := Model{DB: mockDB}
+
+	// Test Case 1: Every session but the current one deleted
+	mock.ExpectExec("DELETE FROM auth_tokens WHERE user_id = \\$1 AND scope = \\$2 AND id <> \\$3").
+		WithArgs(1, ScopeAuthentication, 7).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	err = model.DeleteOtherSessions(1, 7)
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	// Test Case 2: No other sessions
+	mock.ExpectExec("DELETE FROM auth_tokens WHERE user_id = \\$1 AND scope = \\$2 AND id <> \\$3").
+		WithArgs(1, ScopeAuthentication, 7).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = model.DeleteOtherSessions(1, 7)
+	if !errors.Is(err, database.ErrRecordNotFound) {
+		t.Errorf("expected ErrRecordNotFound, got %v", err)
+	}
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestGetLoginFailures(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	model := Model{DB: mockDB}
+	columns := []string{"scope", "subject", "count", "last_failed_at", "locked_until"}
+	lockedUntil := time.Now().Add(time.Minute)
+
+	// Test Case 1: Failures tracked for both the account and the IP
+	mock.ExpectQuery("SELECT scope, subject, count, last_failed_at, locked_until FROM auth_login_failures").
+		WithArgs(LoginScopeAccount, "mike@test.com", LoginScopeIP, "192.0.2.1").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(LoginScopeAccount, "mike@test.com", 5, time.Now(), lockedUntil).
+			AddRow(LoginScopeIP, "192.0.2.1", 2, time.Now(), nil))
+
+	account, byIP, err := model.GetLoginFailures("Mike@test.com", "192.0.2.1")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if account == nil || account.Count != 5 || account.LockedUntil == nil || !account.LockedUntil.Equal(lockedUntil) {
+		t.Errorf("unexpected account failures %+v", account)
+	}
+	if byIP == nil || byIP.Count != 2 || byIP.LockedUntil != nil {
+		t.Errorf("unexpected IP failures %+v", byIP)
+	}
+
+	// Test Case 2: No failures tracked
+	mock.ExpectQuery("SELECT scope, subject, count, last_failed_at, locked_until FROM auth_login_failures").
+		WillReturnRows(sqlmock.NewRows(columns))
+
+	account, byIP, err = model.GetLoginFailures("mike@test.com", "192.0.2.1")
+	if err != nil || account != nil || byIP != nil {
+		t.Errorf("expected no failures, got %+v, %+v (%v)", account, byIP, err)
+	}
+
+	// Test Case 3: Database error
+	mock.ExpectQuery("SELECT scope, subject, count, last_failed_at, locked_until FROM auth_login_failures").
+		WillReturnError(sql.ErrConnDone)
+
+	_, _, err = model.GetLoginFailures("mike@test.com", "192.0.2.1")
+	if err != sql.ErrConnDone {
+		t.Errorf("expected sql.ErrConnDone, got %v", err)
+	}
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestIncrementLoginFailures(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	model := Model{DB: mockDB}
+
+	// Test Case 1: Failure counted
+	mock.ExpectQuery("INSERT INTO auth_login_failures .* ON CONFLICT \\(scope, subject\\) DO UPDATE .* WHERE auth_login_failures.locked_until IS NULL").
+		WithArgs(LoginScopeAccount, "mike@test.com", 5, float64(900)).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "last_failed_at", "locked_until"}).AddRow(3, time.Now(), nil))
+
+	failures, err := model.IncrementLoginFailures(LoginScopeAccount, "mike@test.com", 5, 15*time.Minute)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if failures.Count != 3 || failures.Scope != LoginScopeAccount || failures.Subject != "mike@test.com" || failures.LockedUntil != nil {
+		t.Errorf("unexpected failures %+v", failures)
+	}
+
+	// Test Case 2: Failure locks the account
+	lockedUntil := time.Now().Add(15 * time.Minute)
+	mock.ExpectQuery("INSERT INTO auth_login_failures").
+		WithArgs(LoginScopeAccount, "mike@test.com", 5, float64(900)).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "last_failed_at", "locked_until"}).AddRow(5, time.Now(), lockedUntil))
+
+	failures, err = model.IncrementLoginFailures(LoginScopeAccount, "mike@test.com", 5, 15*time.Minute)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if failures.LockedUntil == nil || !failures.LockedUntil.Equal(lockedUntil) {
+		t.Errorf("expected the account to be locked until %v, got %v", lockedUntil, failures.LockedUntil)
+	}
+
+	// Test Case 3: Already locked
+	mock.ExpectQuery("INSERT INTO auth_login_failures").
+		WithArgs(LoginScopeAccount, "mike@test.com", 5, float64(900)).
+		WillReturnError(sql.ErrNoRows)
+
+	_, err = model.IncrementLoginFailures(LoginScopeAccount, "mike@test.com", 5, 15*time.Minute)
+	if !errors.Is(err, database.ErrRecordNotFound) {
+		t.Errorf("expected ErrRecordNotFound, got %v", err)
+	}
+
+	// Test Case 4: Database error
+	mock.ExpectQuery("INSERT INTO auth_login_failures").
+		WillReturnError(sql.ErrConnDone)
+
+	_, err = model.IncrementLoginFailures(LoginScopeAccount, "mike@test.com", 5, 15*time.Minute)
+	if err != sql.ErrConnDone {
+		t.Errorf("expected sql.ErrConnDone, got %v", err)
+	}
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestDeleteStaleLoginFailures(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	model := Model{DB: mockDB}
+
+	// Test Case 1: Stale failures deleted
+	mock.ExpectExec("DELETE FROM auth_login_failures WHERE last_failed_at < NOW\\(\\)").
+		WithArgs(float64(900)).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	err = model.DeleteStaleLoginFailures(15 * time.Minute)
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	// Test Case 2: Database error
+	mock.ExpectExec("DELETE FROM auth_login_failures").
+		WillReturnError(sql.ErrConnDone)
+
+	err = model.DeleteStaleLoginFailures(15 * time.Minute)
+	if err != sql.ErrConnDone {
+		t.Errorf("expected sql.ErrConnDone, got %v", err)
+	}
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestDeleteLoginFailures(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	model := Model{DB: mockDB}
+
+	// Test Case 1: Failures deleted
+	mock.ExpectExec("DELETE FROM auth_login_failures").
+		WithArgs(LoginScopeAccount, "mike@test.com").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = model.DeleteLoginFailures(LoginScopeAccount, "mike@test.com")
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	// Test Case 2: No failures tracked
+	mock.ExpectExec("DELETE FROM auth_login_failures").
+		WithArgs(LoginScopeAccount, "mike@test.com").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = model.DeleteLoginFailures(LoginScopeAccount, "mike@test.com")
+	if !errors.Is(err, database.ErrRecordNotFound) {
+		t.Errorf("expected ErrRecordNotFound, got %v", err)
+	}
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestGetTwoFactor(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	model := Model{DB: mockDB}
+	columns := []string{"user_id", "secret", "confirmed_at", "last_used_step"}
+
+	// Test Case 1: Enrollment confirmed
+	mock.ExpectQuery("SELECT user_id, secret, confirmed_at, last_used_step FROM auth_two_factor WHERE user_id = ?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(1, []byte("secret"), time.Now(), 42))
+
+	tf, err := model.GetTwoFactor(1)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !tf.Enabled() || tf.LastUsedStep != 42 || string(tf.Secret) != "secret" {
+		t.Errorf("unexpected two-factor %+v", tf)
+	}
+
+	// Test Case 2: Enrollment not confirmed yet
+	mock.ExpectQuery("SELECT user_id, secret, confirmed_at, last_used_step FROM auth_two_factor").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(1, []byte("secret"), nil, 0))
+
+	tf, err = model.GetTwoFactor(1)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if tf.Enabled() {
+		t.Errorf("expected unconfirmed enrollment not to be enabled")
+	}
+
+	// Test Case 3: Never enrolled
+	mock.ExpectQuery("SELECT user_id, secret, confirmed_at, last_used_step FROM auth_two_factor").
+		WithArgs(2).
+		WillReturnError(sql.ErrNoRows)
+
+	tf, err = model.GetTwoFactor(2)
+	if !errors.Is(err, database.ErrRecordNotFound) {
+		t.Errorf("expected ErrRecordNotFound, got %v", err)
+	}
+	if tf.Enabled() {
+		t.Errorf("expected no enrollment not to be enabled")
+	}
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestInsertTwoFactor(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	model := Model{DB: mockDB}
+	tf := &TwoFactor{UserID: 1, Secret: []byte("secret")}
+
+	// Test Case 1: Enrollment started
+	mock.ExpectExec("INSERT INTO auth_two_factor .* ON CONFLICT \\(user_id\\) DO UPDATE .* WHERE auth_two_factor.confirmed_at IS NULL").
+		WithArgs(1, []byte("secret")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = model.InsertTwoFactor(tf)
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	// Test Case 2: Already enabled
+	mock.ExpectExec("INSERT INTO auth_two_factor").
+		WithArgs(1, []byte("secret")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = model.InsertTwoFactor(tf)
+	if !errors.Is(err, database.ErrEditConflict) {
+		t.Errorf("expected ErrEditConflict, got %v", err)
+	}
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestConfirmTwoFactor(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	model := Model{DB: mockDB}
+	tf := &TwoFactor{UserID: 1, Secret: []byte("secret")}
+	hashes := [][]byte{[]byte("hash1"), []byte("hash2")}
+
+	// Test Case 1: Enrollment confirmed along with the recovery codes
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE auth_two_factor SET confirmed_at = NOW\\(\\), last_used_step = \\$2 WHERE user_id = \\$1 AND confirmed_at IS NULL").
+		WithArgs(1, 42).
+		WillReturnRows(sqlmock.NewRows([]string{"confirmed_at"}).AddRow(time.Now()))
+	for _, hash := range hashes {
+		mock.ExpectExec("INSERT INTO auth_recovery_codes").
+			WithArgs(hash, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectCommit()
+
+	err = model.ConfirmTwoFactor(tf, 42, hashes)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !tf.Enabled() || tf.LastUsedStep != 42 {
+		t.Errorf("unexpected two-factor %+v", tf)
+	}
+
+	// Test Case 2: Confirmed in the meantime, nothing is stored
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE auth_two_factor").
+		WithArgs(1, 43).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	err = model.ConfirmTwoFactor(&TwoFactor{UserID: 1}, 43, hashes)
+	if !errors.Is(err, database.ErrEditConflict) {
+		t.Errorf("expected ErrEditConflict, got %v", err)
+	}
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestUseTwoFactorStep(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	model := Model{DB: mockDB}
+
+	// Test Case 1: Step used
+	mock.ExpectExec("UPDATE auth_two_factor SET last_used_step = \\$2 WHERE user_id = \\$1 AND last_used_step < \\$2").
+		WithArgs(1, 42).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = model.UseTwoFactorStep(1, 42)
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	// Test Case 2: Step already used
+	mock.ExpectExec("UPDATE auth_two_factor").
+		WithArgs(1, 42).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = model.UseTwoFactorStep(1, 42)
+	if !errors.Is(err, database.ErrEditConflict) {
+		t.Errorf("expected ErrEditConflict, got %v", err)
+	}
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestUseRecoveryCode(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	model := Model{DB: mockDB}
+	hash := hashRecoveryCode("abcd-efgh")
+
+	// Test Case 1: Code used up
+	mock.ExpectExec("DELETE FROM auth_recovery_codes WHERE hash = \\$1 AND user_id = \\$2").
+		WithArgs(hash, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = model.UseRecoveryCode(1, hash)
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	// Test Case 2: Unknown or already used code
+	mock.ExpectExec("DELETE FROM auth_recovery_codes").
+		WithArgs(hash, 1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = model.UseRecoveryCode(1, hash)
+	if !errors.Is(err, database.ErrRecordNotFound) {
+		t.Errorf("expected ErrRecordNotFound, got %v", err)
+	}
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestGetPermissionsForRole(t *testing.T) {
+	// Create a mock database
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	model := Model{DB: db}
+
+	// Test Case 1: Role with permissions
+	mock.ExpectQuery(`SELECT p.code FROM auth_permissions as p INNER JOIN auth_roles_permissions as rp ON p.id = rp.permission_id WHERE rp.role_id = \$1`).
+		WithArgs(RoleAdmin).
+		WillReturnRows(sqlmock.NewRows([]string{"code"}).
+			AddRow(PermissionGamesWrite).
+			AddRow(PermissionScoresModerate))
+
+	permissions, err := model.GetPermissionsForRole(RoleAdmin)
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if !permissions.Include(PermissionGamesWrite) || !permissions.Include(PermissionScoresModerate) {
+		t.Errorf("unexpected permissions %v", permissions)
+	}
+
+	// Test Case 2: Role without permissions
+	mock.ExpectQuery(`SELECT p.code FROM auth_permissions`).
+		WithArgs(RoleBasic).
+		WillReturnRows(sqlmock.NewRows([]string{"code"}))
+
+	permissions, err = model.GetPermissionsForRole(RoleBasic)
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if len(permissions) != 0 {
+		t.Errorf("expected no permissions, got %v", permissions)
+	}
+
+	// Test Case 3: Database error
+	mock.ExpectQuery(`SELECT p.code FROM auth_permissions`).
+		WithArgs(RoleBasic).
+		WillReturnError(sql.ErrConnDone)
+
+	_, err = model.GetPermissionsForRole(RoleBasic)
+	if err != sql.ErrConnDone {
+		t.Errorf("expected sql.ErrConnDone, got %v", err)
+	}
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
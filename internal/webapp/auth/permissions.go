@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// permission codes should match those in "/migrations/000006_create_auth_permissions_table.up.sql"
+const (
+	PermissionGamesWrite     = "games:write"
+	PermissionScoresModerate = "scores:moderate"
+	PermissionUsersAdmin     = "users:admin"
+)
+
+// How long a role's permissions are cached before being read from the database again
+const permissionsCacheTTL = 5 * time.Minute
+
+type Permissions []string
+
+func (p Permissions) Include(code string) bool {
+	return slices.Contains(p, code)
+}
+
+type cachedPermissions struct {
+	permissions Permissions
+	expiry      time.Time
+}
+
+type permissionsCache struct {
+	mu    sync.RWMutex
+	roles map[RoleID]cachedPermissions
+}
+
+func (c *permissionsCache) get(roleID RoleID) (Permissions, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	cached, ok := c.roles[roleID]
+	if !ok || time.Now().After(cached.expiry) {
+		return nil, false
+	}
+
+	return cached.permissions, true
+}
+
+func (c *permissionsCache) set(roleID RoleID, permissions Permissions) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.roles == nil {
+		c.roles = make(map[RoleID]cachedPermissions)
+	}
+
+	c.roles[roleID] = cachedPermissions{
+		permissions: permissions,
+		expiry:      time.Now().Add(permissionsCacheTTL),
+	}
+}
+
+// GetPermissionsForRole returns the permissions granted to a role, only reading
+// from the database when the cached entry is missing or expired.
+func (s *Service) GetPermissionsForRole(roleID RoleID) (Permissions, error) {
+	permissions, ok := s.permissions.get(roleID)
+	if ok {
+		return permissions, nil
+	}
+
+	permissions, err := s.Models.GetPermissionsForRole(roleID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.permissions.set(roleID, permissions)
+	return permissions, nil
+}
+
+// HasPermission reports whether the user's role has been granted the permission.
+func (s *Service) HasPermission(user *User, code string) (bool, error) {
+	permissions, err := s.GetPermissionsForRole(user.RoleID)
+	if err != nil {
+		return false, err
+	}
+
+	return permissions.Include(code), nil
+}
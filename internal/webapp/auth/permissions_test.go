@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/navazjm/pixelarcade/internal/webapp/utils/database"
+)
+
+func TestPermissions_Include(t *testing.T) {
+	permissions := Permissions{PermissionGamesWrite, PermissionScoresModerate}
+
+	if !permissions.Include(PermissionGamesWrite) {
+		t.Errorf("expected permissions to include %s", PermissionGamesWrite)
+	}
+	if permissions.Include(PermissionUsersAdmin) {
+		t.Errorf("expected permissions not to include %s", PermissionUsersAdmin)
+	}
+}
+
+func TestService_GetPermissionsForRole(t *testing.T) {
+	service, mock := newMockService(t)
+
+	// Only the first lookup should hit the database
+	mock.ExpectQuery("SELECT p.code FROM auth_permissions").
+		WithArgs(RoleAdmin).
+		WillReturnRows(sqlmock.NewRows([]string{"code"}).
+			AddRow(PermissionGamesWrite).
+			AddRow(PermissionUsersAdmin))
+
+	for i := 0; i < 2; i++ {
+		permissions, err := service.GetPermissionsForRole(RoleAdmin)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(permissions) != 2 {
+			t.Errorf("expected 2 permissions, got %d", len(permissions))
+		}
+	}
+
+	// Errors are not cached
+	mock.ExpectQuery("SELECT p.code FROM auth_permissions").
+		WithArgs(RoleBasic).
+		WillReturnError(database.ErrMockDatabase)
+
+	_, err := service.GetPermissionsForRole(RoleBasic)
+	if err != database.ErrMockDatabase {
+		t.Errorf("expected ErrMockDatabase, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unmet expectations: %v", err)
+	}
+}
+
+func TestService_HasPermission(t *testing.T) {
+	service, mock := newMockService(t)
+	user := &User{ID: 1, RoleID: RoleAdmin}
+
+	mock.ExpectQuery("SELECT p.code FROM auth_permissions").
+		WithArgs(RoleAdmin).
+		WillReturnRows(sqlmock.NewRows([]string{"code"}).AddRow(PermissionScoresModerate))
+
+	ok, err := service.HasPermission(user, PermissionScoresModerate)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !ok {
+		t.Errorf("expected user to have %s", PermissionScoresModerate)
+	}
+
+	ok, err = service.HasPermission(user, PermissionUsersAdmin)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if ok {
+		t.Errorf("expected user not to have %s", PermissionUsersAdmin)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unmet expectations: %v", err)
+	}
+}
@@ -4,20 +4,55 @@ import (
 	"database/sql"
 	"log/slog"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/navazjm/pixelarcade/internal/webapp/audit"
+	"github.com/navazjm/pixelarcade/internal/webapp/mailer"
+	"github.com/navazjm/pixelarcade/internal/webapp/oidc"
+	"github.com/navazjm/pixelarcade/internal/webapp/storage"
 	"github.com/navazjm/pixelarcade/internal/webapp/utils/logger"
 )
 
+type Config struct {
+	// Public URL of the frontend, used to build links sent to users via email
+	BaseURL        string
+	OAuthProviders []oidc.Config
+	// Maximum size in bytes of an uploaded avatar
+	AvatarMaxSize int64
+	// Failed logins before an account, or every login from an IP, is locked
+	LoginMaxFailures   int
+	LoginMaxFailuresIP int
+	// How long an account or IP stays locked, and failed logins are remembered for
+	LoginLockout time.Duration
+}
+
 type Service struct {
-	Models Model
-	Logger *slog.Logger
+	Models    Model
+	Logger    *slog.Logger
+	Mailer    mailer.Mailer
+	Storage   storage.Blob
+	Audit     audit.Recorder
+	Config    *Config
+	Providers map[string]*oidc.Provider
+
+	permissions permissionsCache
 }
 
-func NewService(db *sql.DB, logger *slog.Logger) *Service {
+func NewService(db *sql.DB, logger *slog.Logger, mailer mailer.Mailer, blob storage.Blob, auditor audit.Recorder, cfg *Config) *Service {
+	providers := make(map[string]*oidc.Provider)
+	for _, providerCfg := range cfg.OAuthProviders {
+		providers[providerCfg.Name] = oidc.NewProvider(providerCfg)
+	}
+
 	return &Service{
-		Models: Model{DB: db},
-		Logger: logger,
+		Models:    Model{DB: db},
+		Logger:    logger,
+		Mailer:    mailer,
+		Storage:   blob,
+		Audit:     auditor,
+		Config:    cfg,
+		Providers: providers,
 	}
 }
 
@@ -28,10 +63,26 @@ func newMockService(t *testing.T) (*Service, sqlmock.Sqlmock) {
 		t.Fatalf("failed to create mock DB: %v", err)
 	}
 
+	blob, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create mock storage: %v", err)
+	}
+
 	// Create service with mock DB
 	service := &Service{
-		Models: Model{DB: mockDB},
-		Logger: logger.NewMock(),
+		Models:  Model{DB: mockDB},
+		Logger:  logger.NewMock(),
+		Mailer:  mailer.NewMock(),
+		Storage: blob,
+		Audit:   audit.NewMock(),
+		Config: &Config{
+			BaseURL:            "http://localhost:3000",
+			AvatarMaxSize:      1 << 20,
+			LoginMaxFailures:   5,
+			LoginMaxFailuresIP: 20,
+			LoginLockout:       15 * time.Minute,
+		},
+		Providers: make(map[string]*oidc.Provider),
 	}
 
 	return service, mock
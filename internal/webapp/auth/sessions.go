@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"strings"
+	"time"
+)
+
+// Authentication tokens double as sessions, one for every device a user logged in from.
+const (
+	sessionTTL = 24 * time.Hour
+	// When a session was last seen is only updated this often, sparing a write on every
+	// request
+	sessionTouchInterval = time.Minute
+	// Longer user agents are truncated, they are only shown to help users tell their
+	// devices apart
+	sessionMaxUserAgent = 512
+)
+
+type Session struct {
+	ID         int64     `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Expiry     time.Time `json:"expiry"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	// Whether a second factor was checked when the session was started
+	TwoFactor bool `json:"two_factor"`
+	// Whether the request listing the sessions was made with this one
+	Current bool `json:"current"`
+}
+
+func truncateUserAgent(userAgent string) string {
+	if len(userAgent) <= sessionMaxUserAgent {
+		return userAgent
+	}
+	// Cutting may split a multi-byte character, drop what's left of it
+	return strings.ToValidUTF8(userAgent[:sessionMaxUserAgent], "")
+}
@@ -0,0 +1,20 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateUserAgent(t *testing.T) {
+	short := "Mozilla/5.0 (X11; Linux x86_64)"
+	if got := truncateUserAgent(short); got != short {
+		t.Errorf("expected short user agent to be kept, got %q", got)
+	}
+
+	long := strings.Repeat("a", sessionMaxUserAgent-1) + "é"
+	got := truncateUserAgent(long)
+	if len(got) != sessionMaxUserAgent-1 || !utf8.ValidString(got) {
+		t.Errorf("expected user agent to be cut before the split character, got %d bytes", len(got))
+	}
+}
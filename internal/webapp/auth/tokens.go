@@ -12,6 +12,12 @@ import (
 
 const (
 	ScopeAuthentication = "authentication"
+	ScopeActivation     = "activation"
+	ScopePasswordReset  = "password-reset"
+	ScopeEmailChange    = "email-change"
+	// Issued once the password checks out for users with two-factor authentication,
+	// exchanged for an authentication token along with a code
+	ScopeTwoFactor = "two-factor"
 )
 
 type Token struct {
@@ -20,6 +26,14 @@ type Token struct {
 	UserID    int64     `json:"-"`
 	Expiry    time.Time `json:"expiry"`
 	Scope     string    `json:"-"`
+	// Device the token was issued to, kept for authentication tokens so users can tell
+	// their sessions apart
+	UserAgent string `json:"-"`
+	IP        string `json:"-"`
+	// New address of email change tokens, applied once the token is redeemed
+	Email string `json:"-"`
+	// Set on authentication tokens issued once a second factor checked out
+	TwoFactor bool `json:"-"`
 }
 
 func generateToken(userID int64, ttl time.Duration, scope string) (*Token, error) {
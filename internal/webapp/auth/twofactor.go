@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/navazjm/pixelarcade/internal/webapp/utils/validator"
+)
+
+// Time-based one-time passwords as described in RFC 6238, with the defaults every
+// authenticator app supports: HMAC-SHA1, 6 digits and a 30 second period.
+const (
+	totpIssuer = "PixelArcade"
+	totpPeriod = 30 * time.Second
+	totpDigits = 6
+	// Codes of the periods just before and after the current one are accepted too,
+	// to allow for clock drift and slow typing
+	totpSkew = 1
+)
+
+const (
+	// Recovery codes handed out when two-factor authentication is enabled, each usable
+	// once in place of a code when the authenticator is lost
+	recoveryCodeCount = 10
+	// How long users have to enter a code once their password checks out
+	twoFactorChallengeTTL = 5 * time.Minute
+)
+
+var secretEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type TwoFactor struct {
+	UserID int64
+	Secret []byte
+	// Nil until enrollment is confirmed with a code
+	ConfirmedAt *time.Time
+	// Time step of the last accepted code, codes of that step or earlier are refused
+	LastUsedStep int64
+}
+
+// Enabled reports whether codes are required to log in. Enrollments that were never
+// confirmed don't count, the user may not have set up their authenticator.
+func (tf *TwoFactor) Enabled() bool {
+	return tf != nil && tf.ConfirmedAt != nil
+}
+
+// EncodedSecret returns the secret the way authenticator apps expect it to be typed in.
+func (tf *TwoFactor) EncodedSecret() string {
+	return secretEncoding.EncodeToString(tf.Secret)
+}
+
+// URI returns the otpauth:// URI to add the account to an authenticator app, usually
+// shown as a QR code.
+func (tf *TwoFactor) URI(email string) string {
+	query := url.Values{
+		"secret":    {tf.EncodedSecret()},
+		"issuer":    {totpIssuer},
+		"algorithm": {"SHA1"},
+		"digits":    {fmt.Sprint(totpDigits)},
+		"period":    {fmt.Sprint(int(totpPeriod.Seconds()))},
+	}
+
+	label := url.PathEscape(totpIssuer + ":" + email)
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// ValidateCode checks the code against the time steps around now, and returns the step
+// it matches. Codes of steps already used are refused, so a code can't be replayed.
+func (tf *TwoFactor) ValidateCode(code string, now time.Time) (step int64, ok bool) {
+	current := totpStep(now)
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= tf.LastUsedStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(totpCode(tf.Secret, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+func generateTwoFactor(userID int64) (*TwoFactor, error) {
+	// The key length recommended for HMAC-SHA1 by RFC 4226
+	secret := make([]byte, 20)
+
+	_, err := rand.Read(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TwoFactor{UserID: userID, Secret: secret}, nil
+}
+
+func totpStep(t time.Time) int64 {
+	return t.Unix() / int64(totpPeriod.Seconds())
+}
+
+// totpCode computes the code of the time step, as described in RFC 4226.
+func totpCode(secret []byte, step int64) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", totpDigits, value%uint32(math.Pow10(totpDigits)))
+}
+
+// generateRecoveryCodes returns the recovery codes to hand out, and their hashes to
+// store. Like tokens, they are random enough to be hashed with SHA-256.
+func generateRecoveryCodes() (codes []string, hashes [][]byte, err error) {
+	for range recoveryCodeCount {
+		randomBytes := make([]byte, 5)
+
+		_, err := rand.Read(randomBytes)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		code := strings.ToLower(secretEncoding.EncodeToString(randomBytes))
+		codes = append(codes, code[:4]+"-"+code[4:])
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode hashes the code, ignoring case and dashes as users may type it
+// either way.
+func hashRecoveryCode(code string) []byte {
+	code = strings.ToLower(strings.ReplaceAll(code, "-", ""))
+	hash := sha256.Sum256([]byte(code))
+	return hash[:]
+}
+
+// isRecoveryCode tells recovery codes apart from TOTP codes, which are shorter.
+func isRecoveryCode(code string) bool {
+	return len(code) != totpDigits
+}
+
+func ValidateTwoFactorCode(v *validator.Validator, code string) {
+	v.Check(code != "", "code", "must be provided")
+	v.Check(len(code) <= 16, "code", "must not be more than 16 bytes long")
+}
@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// Test vectors of RFC 6238 for SHA-1, truncated to 6 digits
+	secret := []byte("12345678901234567890")
+
+	tests := []struct {
+		unix     int64
+		expected string
+	}{
+		{unix: 59, expected: "287082"},
+		{unix: 1111111109, expected: "081804"},
+		{unix: 1234567890, expected: "005924"},
+		{unix: 2000000000, expected: "279037"},
+	}
+
+	for _, tt := range tests {
+		if code := totpCode(secret, totpStep(time.Unix(tt.unix, 0))); code != tt.expected {
+			t.Errorf("expected code %s at %d, got %s", tt.expected, tt.unix, code)
+		}
+	}
+}
+
+func TestTwoFactor_ValidateCode(t *testing.T) {
+	tf := &TwoFactor{Secret: []byte("12345678901234567890")}
+	now := time.Unix(1111111109, 0)
+	step := totpStep(now)
+
+	tests := []struct {
+		name         string
+		code         string
+		lastUsedStep int64
+		valid        bool
+	}{
+		{name: "Current code", code: totpCode(tf.Secret, step), valid: true},
+		{name: "Previous code", code: totpCode(tf.Secret, step-1), valid: true},
+		{name: "Next code", code: totpCode(tf.Secret, step+1), valid: true},
+		{name: "Expired code", code: totpCode(tf.Secret, step-2), valid: false},
+		{name: "Wrong code", code: "000000", valid: false},
+		{name: "Replayed code", code: totpCode(tf.Secret, step), lastUsedStep: step, valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tf.LastUsedStep = tt.lastUsedStep
+
+			matched, ok := tf.ValidateCode(tt.code, now)
+			if ok != tt.valid {
+				t.Fatalf("expected code to be valid: %v, got %v", tt.valid, ok)
+			}
+			if ok && totpCode(tf.Secret, matched) != tt.code {
+				t.Errorf("expected the matched step to be returned, got %d", matched)
+			}
+		})
+	}
+}
+
+func TestTwoFactor_URI(t *testing.T) {
+	tf, err := generateTwoFactor(1)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(tf.Secret) != 20 {
+		t.Errorf("expected a 20 bytes secret, got %d", len(tf.Secret))
+	}
+
+	u, err := url.Parse(tf.URI("mike@test.com"))
+	if err != nil {
+		t.Fatalf("expected a valid URI, got %v", err)
+	}
+
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/PixelArcade:mike@test.com" {
+		t.Errorf("unexpected URI %s", u)
+	}
+
+	query := u.Query()
+	if query.Get("secret") != tf.EncodedSecret() || query.Get("issuer") != "PixelArcade" || query.Get("digits") != "6" || query.Get("period") != "30" {
+		t.Errorf("unexpected URI parameters %v", query)
+	}
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(codes) != recoveryCodeCount || len(hashes) != recoveryCodeCount {
+		t.Fatalf("expected %d codes, got %d codes and %d hashes", recoveryCodeCount, len(codes), len(hashes))
+	}
+
+	for i, code := range codes {
+		if len(code) != 9 || code[4] != '-' || !isRecoveryCode(code) {
+			t.Errorf("unexpected recovery code %q", code)
+		}
+
+		// Typed without the dash or in upper case, the code still matches
+		for _, typed := range []string{code, strings.ReplaceAll(code, "-", ""), strings.ToUpper(code)} {
+			if !bytes.Equal(hashRecoveryCode(typed), hashes[i]) {
+				t.Errorf("expected %q to match the hash of %q", typed, code)
+			}
+		}
+	}
+}
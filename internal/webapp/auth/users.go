@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"encoding/json"
 	"errors"
 	"time"
 
@@ -11,6 +12,10 @@ import (
 
 var AnonymousUser = &User{}
 
+// Provider value for users who sign in with an email and password rather than an
+// OAuth provider
+const ProviderNone = "N/A"
+
 type User struct {
 	ID             int64     `json:"id"`
 	CreatedAt      time.Time `json:"created_at"`
@@ -26,6 +31,15 @@ type User struct {
 	IsVerified     bool      `json:"is_verified"`
 }
 
+// MarshalJSON fills in the generated identicon for users without a profile picture,
+// so clients always get an image to display.
+func (u User) MarshalJSON() ([]byte, error) {
+	type user User
+	aux := user(u)
+	aux.ProfilePicture = ProfilePictureURL(u.ID, u.ProfilePicture)
+	return json.Marshal(aux)
+}
+
 func (u *User) IsAnonymous() bool {
 	return u == AnonymousUser
 }
@@ -48,6 +62,11 @@ func (p *password) Set(plaintextPassword string) error {
 }
 
 func (p *password) Matches(plaintextPassword string) (bool, error) {
+	// Users created via OAuth don't have a password
+	if p.hash == nil {
+		return false, nil
+	}
+
 	err := bcrypt.CompareHashAndPassword(p.hash, []byte(plaintextPassword))
 	if err != nil {
 		switch {
@@ -61,6 +80,26 @@ func (p *password) Matches(plaintextPassword string) (bool, error) {
 	return true, nil
 }
 
+// UserFilters narrows down the users listed in the admin API. Optional filters are left
+// empty, or nil, to match every user.
+type UserFilters struct {
+	Email      string `json:"email"`
+	RoleID     RoleID `json:"role_id"`
+	IsVerified *bool  `json:"is_verified"`
+	IsActive   *bool  `json:"is_active"`
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset"`
+}
+
+func ValidateUserFilters(v *validator.Validator, f UserFilters) {
+	v.Check(len(f.Email) <= 500, "email", "must not be more than 500 bytes long")
+	v.Check(f.RoleID == 0 || validator.PermittedValue(f.RoleID, RoleBasic, RoleAdmin), "role_id", "must be a valid role")
+	v.Check(f.Limit > 0, "limit", "must be greater than zero")
+	v.Check(f.Limit <= 100, "limit", "must be a maximum of 100")
+	v.Check(f.Offset >= 0, "offset", "must be zero or greater")
+	v.Check(f.Offset <= 10_000, "offset", "must be a maximum of 10000")
+}
+
 func ValidateUser(v *validator.Validator, user *User) {
 	v.Check(user.Name != "", "name", "must be provided")
 	v.Check(len(user.Name) <= 500, "name", "must not be more than 500 bytes long")
@@ -71,7 +110,8 @@ func ValidateUser(v *validator.Validator, user *User) {
 		ValidatePasswordPlaintext(v, *user.Password.plaintext)
 	}
 
-	if user.Password.hash == nil {
+	// Only users who sign in via an OAuth provider may be missing a password
+	if user.Password.hash == nil && user.Provider == ProviderNone {
 		panic("missing password hash for user")
 	}
 }
@@ -85,6 +125,10 @@ func ValidatePasswordPlaintextEmpty(v *validator.Validator, password string) {
 	v.Check(password != "", "password", "must be provided")
 }
 
+func ValidateCurrentPassword(v *validator.Validator, password string) {
+	v.Check(password != "", "current_password", "must be provided")
+}
+
 func ValidatePasswordPlaintext(v *validator.Validator, password string) {
 	ValidatePasswordPlaintextEmpty(v, password)
 	v.Check(len(password) >= 8, "password", "must be at least 8 bytes long")
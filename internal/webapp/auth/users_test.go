@@ -1,6 +1,8 @@
 package auth
 
 import (
+	"encoding/json"
+	"strings"
 	"testing"
 
 	"github.com/navazjm/pixelarcade/internal/webapp/utils/validator"
@@ -25,6 +27,29 @@ func TestUser_IsAnonymous(t *testing.T) {
 	}
 }
 
+func TestUser_MarshalJSON(t *testing.T) {
+	tests := []struct {
+		user     *User
+		expected string
+	}{
+		{&User{ID: 5}, `"profile_picture":"/api/users/5/avatar.png"`},
+		{&User{ID: 5, ProfilePicture: AvatarURL(5, "0123456789abcdef", 256)}, `"profile_picture":"/api/users/5/avatars/0123456789abcdef/256.png"`},
+	}
+
+	for _, test := range tests {
+		js, err := json.Marshal(test.user)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !strings.Contains(string(js), test.expected) {
+			t.Errorf("expected %s in %s", test.expected, js)
+		}
+		if strings.Contains(string(js), "password") {
+			t.Errorf("expected password to be omitted, got %s", js)
+		}
+	}
+}
+
 func TestValidateUser_Valid(t *testing.T) {
 	v := validator.New()
 	user := &User{
@@ -148,3 +173,46 @@ func TestPassword_Matches(t *testing.T) {
 		}
 	})
 }
+
+func TestPassword_MatchesWithoutHash(t *testing.T) {
+	password := &password{}
+
+	match, err := password.Matches("testpassword123")
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if match {
+		t.Errorf("expected password not to match")
+	}
+}
+
+func TestValidateUser_OAuthUserWithoutPassword(t *testing.T) {
+	v := validator.New()
+	user := &User{
+		Name:     "John Doe",
+		Email:    "johndoe@example.com",
+		Provider: "google",
+	}
+
+	ValidateUser(v, user)
+	if !v.Valid() {
+		t.Errorf("expected validation to be valid, but got errors: %v", v.Errors)
+	}
+}
+
+func TestValidateUser_PanicOnMissingPasswordHash(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("Expected panic on missing password hash but did not panic")
+		}
+	}()
+
+	v := validator.New()
+	user := &User{
+		Name:     "John Doe",
+		Email:    "johndoe@example.com",
+		Provider: ProviderNone,
+	}
+
+	ValidateUser(v, user) // Should panic
+}
@@ -1,13 +1,24 @@
 package webapp
 
 import (
+	"crypto/rand"
 	"flag"
 	"fmt"
+	"net/netip"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
 
+	"github.com/navazjm/pixelarcade/internal/webapp/auth"
+	"github.com/navazjm/pixelarcade/internal/webapp/games"
+	"github.com/navazjm/pixelarcade/internal/webapp/idempotency"
+	"github.com/navazjm/pixelarcade/internal/webapp/mailer"
+	"github.com/navazjm/pixelarcade/internal/webapp/oidc"
+	"github.com/navazjm/pixelarcade/internal/webapp/ratelimit"
+	"github.com/navazjm/pixelarcade/internal/webapp/storage"
 	"github.com/navazjm/pixelarcade/internal/webapp/utils/database"
 )
 
@@ -16,11 +27,25 @@ type Config struct {
 	Env            string
 	Version        string
 	DB             database.Config
+	Auth           auth.Config
+	Mailer         mailer.Config
+	Games          games.Config
+	Idempotency    idempotency.Config
+	Storage        storage.Config
+	RateLimit      ratelimit.Config
+	StaticDir      string
 	TrustedOrigins []string
+	// Proxies allowed to set X-Forwarded-For, e.g. a load balancer
+	TrustedProxies []netip.Prefix
 }
 
 func NewConfig() (*Config, error) {
 	var err error
+	var oauthProviders string
+	var leaderboardTimezone string
+	var sessionSecret string
+	var rateLimitRoutes string
+	var trustedProxies string
 	cfg := &Config{}
 
 	// default config for PROD
@@ -34,6 +59,42 @@ func NewConfig() (*Config, error) {
 	flag.IntVar(&cfg.DB.MaxOpenConns, "db-max-open-conns", 25, "PostgreSQL max open connections")
 	flag.IntVar(&cfg.DB.MaxIdleConns, "db-max-idle-conns", 25, "PostgreSQL max idle connections")
 	flag.DurationVar(&cfg.DB.MaxIdleTime, "db-max-idle-time", 15*time.Minute, "PostgreSQL max connection idle time")
+	flag.StringVar(&cfg.Auth.BaseURL, "base-url", "https://pixelarcade.dev", "Public URL used in links sent to users")
+	flag.Int64Var(&cfg.Auth.AvatarMaxSize, "avatar-max-size", 5<<20, "Maximum size in bytes of an uploaded avatar")
+	flag.IntVar(&cfg.Auth.LoginMaxFailures, "login-max-failures", 10, "Failed logins before an account is locked")
+	flag.IntVar(&cfg.Auth.LoginMaxFailuresIP, "login-max-failures-ip", 50, "Failed logins before every login from an IP is locked")
+	flag.DurationVar(&cfg.Auth.LoginLockout, "login-lockout", 15*time.Minute, "How long an account or IP stays locked after too many failed logins")
+	flag.StringVar(&oauthProviders, "oauth-providers", "", "Comma separated list of OpenID Connect providers, e.g. google,github")
+	flag.StringVar(&cfg.Mailer.Backend, "mailer", "", "Mailer backend, log in dev and smtp otherwise (log|smtp|outbox)")
+	flag.StringVar(&cfg.Mailer.Host, "smtp-host", "", "SMTP host")
+	flag.IntVar(&cfg.Mailer.Port, "smtp-port", 587, "SMTP port")
+	flag.StringVar(&cfg.Mailer.Username, "smtp-username", "", "SMTP username")
+	flag.StringVar(&cfg.Mailer.Password, "smtp-password", "", "SMTP password")
+	flag.StringVar(&cfg.Mailer.Sender, "smtp-sender", "PixelArcade <no-reply@pixelarcade.dev>", "SMTP sender")
+	flag.StringVar(&cfg.Mailer.OutboxDir, "mailer-outbox-dir", "./tmp/outbox", "Directory the outbox mailer writes .eml files to")
+	flag.IntVar(&cfg.Mailer.Retries, "mailer-retries", 3, "Max delivery attempts per email")
+	flag.StringVar(&leaderboardTimezone, "leaderboard-timezone", "UTC", "IANA timezone daily, weekly and monthly leaderboards reset in")
+	flag.StringVar(&sessionSecret, "session-secret", "", "Secret used to sign play session tickets")
+	flag.DurationVar(&cfg.Games.SessionTTL, "play-session-ttl", 2*time.Hour, "How long a play session can submit a score")
+	flag.Int64Var(&cfg.Games.BundleMaxSize, "game-bundle-max-size", 32<<20, "Maximum size in bytes of an uploaded game bundle")
+	flag.StringVar(&cfg.StaticDir, "static-dir", "./web/dist", "Directory containing the built frontend")
+	flag.DurationVar(&cfg.Idempotency.TTL, "idempotency-ttl", 24*time.Hour, "How long responses are replayed for a repeated Idempotency-Key")
+	flag.DurationVar(&cfg.Idempotency.ClaimTTL, "idempotency-claim-ttl", time.Minute, "How long an Idempotency-Key stays claimed by a request that never completes")
+	flag.StringVar(&cfg.Storage.Backend, "storage", storage.BackendLocal, "Storage backend for uploaded files (local|s3)")
+	flag.StringVar(&cfg.Storage.Dir, "storage-dir", "./tmp/storage", "Directory the local storage backend writes files to")
+	flag.StringVar(&cfg.Storage.S3Endpoint, "storage-s3-endpoint", "", "S3 compatible endpoint, e.g. https://s3.us-east-1.amazonaws.com")
+	flag.StringVar(&cfg.Storage.S3Region, "storage-s3-region", "us-east-1", "S3 region")
+	flag.StringVar(&cfg.Storage.S3Bucket, "storage-s3-bucket", "", "S3 bucket")
+	flag.StringVar(&cfg.Storage.S3AccessKey, "storage-s3-access-key", "", "S3 access key")
+	flag.StringVar(&cfg.Storage.S3SecretKey, "storage-s3-secret-key", "", "S3 secret key")
+	flag.BoolVar(&cfg.RateLimit.Enabled, "limiter-enabled", true, "Enable rate limiting")
+	flag.StringVar(&cfg.RateLimit.Backend, "limiter-backend", ratelimit.BackendMemory, "Rate limit backend, redis to share limits between replicas (memory|redis)")
+	flag.StringVar(&cfg.RateLimit.RedisURL, "limiter-redis-url", "", "Redis URL of the rate limit backend, e.g. redis://:password@localhost:6379/0")
+	flag.Float64Var(&cfg.RateLimit.Default.Rate, "limiter-rps", 2, "Requests per second allowed per client")
+	flag.IntVar(&cfg.RateLimit.Default.Burst, "limiter-burst", 8, "Requests per client allowed in a single burst")
+	flag.StringVar(&rateLimitRoutes, "limiter-routes", "/api/auth/login=0.2:5,/api/auth/register=0.05:3,/api/auth/password-reset=0.05:3", "Comma separated list of per route group limits, as prefix=rps:burst")
+	flag.DurationVar(&cfg.RateLimit.IdleTimeout, "limiter-idle-timeout", 3*time.Minute, "How long the limiter of an idle client is kept")
+	flag.StringVar(&trustedProxies, "trusted-proxies", "", "Comma separated list of proxy addresses or CIDRs allowed to set X-Forwarded-For")
 	flag.Parse()
 
 	if cfg.Env == "dev" {
@@ -43,6 +104,7 @@ func NewConfig() (*Config, error) {
 		}
 		// allow requests from frontend client
 		cfg.TrustedOrigins = []string{"http://localhost:3000", "http://127.0.0.1:3000"}
+		cfg.Auth.BaseURL = "http://localhost:3000"
 	}
 
 	// Use the env variable for DSN if the flag is not provided
@@ -53,5 +115,183 @@ func NewConfig() (*Config, error) {
 		}
 	}
 
+	// The log mailer writes every email to the logs, tokens included, and delivers none
+	if cfg.Mailer.Backend == "" {
+		cfg.Mailer.Backend = mailer.BackendSMTP
+		if cfg.Env == "dev" {
+			cfg.Mailer.Backend = mailer.BackendLog
+		}
+	}
+	if cfg.Mailer.Backend == mailer.BackendLog && cfg.Env != "dev" {
+		return nil, fmt.Errorf("log mailer backend is only allowed in dev")
+	}
+
+	// Use the env variable for SMTP password if the flag is not provided
+	if cfg.Mailer.Password == "" {
+		cfg.Mailer.Password = os.Getenv("PIXELARCADE_SMTP_PASSWORD")
+	}
+
+	// Use the env variable for the Redis URL, which may hold a password, if the flag is
+	// not provided
+	if cfg.RateLimit.RedisURL == "" {
+		cfg.RateLimit.RedisURL = os.Getenv("PIXELARCADE_LIMITER_REDIS_URL")
+	}
+
+	// Use the env variable for S3 secret key if the flag is not provided
+	if cfg.Storage.S3SecretKey == "" {
+		cfg.Storage.S3SecretKey = os.Getenv("PIXELARCADE_STORAGE_S3_SECRET_KEY")
+	}
+
+	cfg.Auth.OAuthProviders, err = oauthProvidersFromEnv(oauthProviders, cfg.Auth.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Games.Timezone, err = time.LoadLocation(leaderboardTimezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid leaderboard timezone: %w", err)
+	}
+
+	// Use the env variable for the session secret if the flag is not provided. Without
+	// either, fall back to a random secret in dev only. Tickets issued before a restart,
+	// or by another replica, could not be redeemed otherwise.
+	if sessionSecret == "" {
+		sessionSecret = os.Getenv("PIXELARCADE_SESSION_SECRET")
+		if sessionSecret == "" && cfg.Env != "dev" {
+			return nil, fmt.Errorf("missing required session secret in environment or flags")
+		}
+	}
+	cfg.Games.SessionSecret, err = secretOrRandom(sessionSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	// Checked like the limits of route groups, an empty bucket would refuse everything
+	if cfg.RateLimit.Default.Rate <= 0 {
+		return nil, fmt.Errorf("invalid requests per second for the default rate limit")
+	}
+	if cfg.RateLimit.Default.Burst < 1 {
+		return nil, fmt.Errorf("invalid burst for the default rate limit")
+	}
+
+	cfg.RateLimit.Routes, err = rateLimitRoutesFromFlag(rateLimitRoutes)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.TrustedProxies, err = trustedProxiesFromFlag(trustedProxies)
+	if err != nil {
+		return nil, err
+	}
+
 	return cfg, nil
 }
+
+// secretOrRandom returns the configured secret, or 32 random bytes if it is empty.
+func secretOrRandom(secret string) ([]byte, error) {
+	if secret != "" {
+		return []byte(secret), nil
+	}
+
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// Each OpenID Connect provider is configured through environment variables named
+// after it, e.g. PIXELARCADE_OAUTH_GOOGLE_ISSUER, PIXELARCADE_OAUTH_GOOGLE_CLIENT_ID
+// and PIXELARCADE_OAUTH_GOOGLE_CLIENT_SECRET.
+func oauthProvidersFromEnv(names, baseURL string) ([]oidc.Config, error) {
+	providers := []oidc.Config{}
+
+	for _, name := range strings.Split(names, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "PIXELARCADE_OAUTH_" + strings.ToUpper(name) + "_"
+		provider := oidc.Config{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  fmt.Sprintf("%s/api/auth/oauth/%s/callback", baseURL, name),
+		}
+
+		if provider.Issuer == "" || provider.ClientID == "" {
+			return nil, fmt.Errorf("missing issuer or client ID for OAuth provider %q", name)
+		}
+
+		providers = append(providers, provider)
+	}
+
+	return providers, nil
+}
+
+// Route group limits are given as prefix=rps:burst, e.g. /api/auth/login=0.2:5 allows a
+// login attempt every 5 seconds, or 5 in a row.
+func rateLimitRoutesFromFlag(routes string) ([]ratelimit.Rule, error) {
+	rules := []ratelimit.Rule{}
+
+	for _, route := range strings.Split(routes, ",") {
+		route = strings.TrimSpace(route)
+		if route == "" {
+			continue
+		}
+
+		prefix, limit, ok := strings.Cut(route, "=")
+		rps, burst, ok2 := strings.Cut(limit, ":")
+		if !ok || !ok2 || !strings.HasPrefix(prefix, "/") {
+			return nil, fmt.Errorf("invalid rate limit route %q, expected prefix=rps:burst", route)
+		}
+
+		rule := ratelimit.Rule{Prefix: prefix}
+		var err error
+		rule.Rate, err = strconv.ParseFloat(rps, 64)
+		if err != nil || rule.Rate <= 0 {
+			return nil, fmt.Errorf("invalid requests per second for rate limit route %q", prefix)
+		}
+		rule.Burst, err = strconv.Atoi(burst)
+		if err != nil || rule.Burst < 1 {
+			return nil, fmt.Errorf("invalid burst for rate limit route %q", prefix)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// Trusted proxies are given as single addresses or CIDRs, e.g. 10.0.0.0/8.
+func trustedProxiesFromFlag(proxies string) ([]netip.Prefix, error) {
+	prefixes := []netip.Prefix{}
+
+	for _, proxy := range strings.Split(proxies, ",") {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+
+		if !strings.Contains(proxy, "/") {
+			addr, err := netip.ParseAddr(proxy)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", proxy)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q", proxy)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
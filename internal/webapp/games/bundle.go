@@ -0,0 +1,215 @@
+package games
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/navazjm/pixelarcade/internal/webapp/storage"
+)
+
+var ErrInvalidBundle = errors.New("invalid game bundle")
+
+const (
+	// File every bundle must contain at its root, loaded when the game is played
+	bundleEntryFile = "index.html"
+	// Limits applied while unpacking, guarding against zip bombs
+	bundleMaxFiles        = 2000
+	bundleMaxUnpackedSize = 256 << 20
+)
+
+// PlayContentSecurityPolicy is sent with every file of a hosted game. The sandbox
+// gives the game an opaque origin, so its scripts can't read the arcade's cookies or
+// storage, or call the API as the signed in player.
+const PlayContentSecurityPolicy = "sandbox allow-scripts allow-pointer-lock; " +
+	"default-src 'self'; " +
+	"script-src 'self' 'unsafe-inline' 'wasm-unsafe-eval'; " +
+	"style-src 'self' 'unsafe-inline'; " +
+	"img-src 'self' data: blob:; " +
+	"media-src 'self' data: blob:; " +
+	"font-src 'self' data:; " +
+	"connect-src 'self'; " +
+	"frame-ancestors 'self'; " +
+	"form-action 'none'; " +
+	"base-uri 'none'"
+
+// GameVersion is an uploaded build of a game. Bundles are stored by the checksum of
+// the uploaded zip, so identical uploads share the same stored files.
+type GameVersion struct {
+	ID         int64     `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	Version    int32     `json:"version"`
+	IsActive   bool      `json:"is_active"`
+	GameID     int64     `json:"game_id"`
+	Number     int32     `json:"number"`
+	Checksum   string    `json:"checksum"`
+	Size       int64     `json:"size"`
+	FileCount  int32     `json:"file_count"`
+	UploadedBy *int64    `json:"uploaded_by"`
+	Src        string    `json:"src"` // derived from game_id and number
+}
+
+// PlaySrc returns the URL a version of a game is served under, suitable for Game.Src.
+func PlaySrc(gameID int64, number int32) string {
+	return fmt.Sprintf("/play/%d/%d/", gameID, number)
+}
+
+type bundle struct {
+	checksum  string
+	size      int64
+	fileCount int32
+}
+
+// bundleKey returns the storage key of a file within the bundle with the given checksum.
+func bundleKey(checksum, name string) string {
+	return "bundles/" + checksum + "/" + name
+}
+
+// unpackBundle validates a zipped game build and stores its files under a prefix named
+// after its checksum. Bundles already stored are not uploaded again. Returns
+// ErrInvalidBundle, wrapped with the reason, if the zip is rejected.
+func unpackBundle(ctx context.Context, data []byte, blob storage.Blob) (*bundle, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: must be a zip archive", ErrInvalidBundle)
+	}
+
+	files, prefix, err := bundleFiles(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	b := &bundle{checksum: hex.EncodeToString(sum[:]), fileCount: int32(len(files))}
+	for _, f := range files {
+		b.size += int64(f.UncompressedSize64)
+	}
+
+	// The entry file is stored last, so its presence means the whole bundle is stored
+	obj, err := blob.Get(ctx, bundleKey(b.checksum, bundleEntryFile))
+	switch {
+	case err == nil:
+		obj.Body.Close()
+		return b, nil
+	case !errors.Is(err, storage.ErrNotFound):
+		return nil, err
+	}
+
+	var entry *zip.File
+	for _, f := range files {
+		name := strings.TrimPrefix(f.Name, prefix)
+		if name == bundleEntryFile {
+			entry = f
+			continue
+		}
+		err = storeFile(ctx, blob, f, bundleKey(b.checksum, name))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = storeFile(ctx, blob, entry, bundleKey(b.checksum, bundleEntryFile))
+	if err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// bundleFiles returns the regular files in the zip, along with the directory prefix to
+// strip from their names. Builds zipped with their containing folder, e.g.
+// snake/index.html, are accepted as if index.html was at the root.
+func bundleFiles(reader *zip.Reader) ([]*zip.File, string, error) {
+	files := []*zip.File{}
+	seen := make(map[string]bool)
+	var size uint64
+
+	for _, f := range reader.File {
+		if f.FileInfo().IsDir() || strings.HasPrefix(f.Name, "__MACOSX/") || path.Base(f.Name) == ".DS_Store" {
+			continue
+		}
+		if !f.Mode().IsRegular() {
+			return nil, "", fmt.Errorf("%w: %s is not a regular file", ErrInvalidBundle, f.Name)
+		}
+		if !fs.ValidPath(f.Name) || strings.Contains(f.Name, `\`) {
+			return nil, "", fmt.Errorf("%w: %s is not a valid file path", ErrInvalidBundle, f.Name)
+		}
+		if seen[f.Name] {
+			return nil, "", fmt.Errorf("%w: %s appears more than once", ErrInvalidBundle, f.Name)
+		}
+		seen[f.Name] = true
+
+		size += f.UncompressedSize64
+		files = append(files, f)
+	}
+
+	if len(files) > bundleMaxFiles {
+		return nil, "", fmt.Errorf("%w: must not contain more than %d files", ErrInvalidBundle, bundleMaxFiles)
+	}
+	if size > bundleMaxUnpackedSize {
+		return nil, "", fmt.Errorf("%w: must not be larger than %d MB unpacked", ErrInvalidBundle, bundleMaxUnpackedSize>>20)
+	}
+
+	prefix := ""
+	if !hasBundleFile(files, bundleEntryFile) {
+		if len(files) > 0 {
+			dir, _, found := strings.Cut(files[0].Name, "/")
+			prefix = dir + "/"
+			for _, f := range files {
+				if !found || !strings.HasPrefix(f.Name, prefix) {
+					prefix = ""
+					break
+				}
+			}
+		}
+		if prefix == "" || !hasBundleFile(files, prefix+bundleEntryFile) {
+			return nil, "", fmt.Errorf("%w: must contain %s", ErrInvalidBundle, bundleEntryFile)
+		}
+	}
+
+	return files, prefix, nil
+}
+
+func hasBundleFile(files []*zip.File, name string) bool {
+	for _, f := range files {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func storeFile(ctx context.Context, blob storage.Blob, f *zip.File, key string) error {
+	src, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("%w: %s could not be read", ErrInvalidBundle, f.Name)
+	}
+	defer src.Close()
+
+	// The declared size can't be trusted, so read at most one byte more than it claims
+	data, err := io.ReadAll(io.LimitReader(src, int64(f.UncompressedSize64)+1))
+	if err != nil {
+		return fmt.Errorf("%w: %s could not be read", ErrInvalidBundle, f.Name)
+	}
+	if len(data) != int(f.UncompressedSize64) {
+		return fmt.Errorf("%w: %s does not match its declared size", ErrInvalidBundle, f.Name)
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	return blob.Put(ctx, key, bytes.NewReader(data), int64(len(data)), contentType)
+}
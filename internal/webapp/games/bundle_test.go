@@ -0,0 +1,127 @@
+package games
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/navazjm/pixelarcade/internal/webapp/storage"
+)
+
+// newBundle zips the given files, keyed by name, for use as an uploaded game bundle.
+func newBundle(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("failed to create zip entry: %v", err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("failed to close zip: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func newBundleStorage(t *testing.T) (*storage.Local, string) {
+	t.Helper()
+
+	root := t.TempDir()
+	blob, err := storage.NewLocal(root)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+
+	return blob, root
+}
+
+func TestUnpackBundle(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("SUCCESS Bundle unpacked", func(t *testing.T) {
+		blob, _ := newBundleStorage(t)
+		data := newBundle(t, map[string]string{
+			"index.html":     "<canvas></canvas>",
+			"assets/game.js": "run()",
+		})
+
+		bundle, err := unpackBundle(ctx, data, blob)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(bundle.checksum) != 64 || bundle.fileCount != 2 || bundle.size != 22 {
+			t.Errorf("unexpected bundle: %+v", bundle)
+		}
+
+		obj, err := blob.Get(ctx, bundleKey(bundle.checksum, "assets/game.js"))
+		if err != nil {
+			t.Fatalf("expected stored game.js, got %v", err)
+		}
+		content, _ := io.ReadAll(obj.Body)
+		obj.Body.Close()
+		if string(content) != "run()" || obj.ContentType != "text/javascript; charset=utf-8" {
+			t.Errorf("unexpected game.js: %s %q", obj.ContentType, content)
+		}
+
+		// Uploading the same bundle again reuses the stored files
+		again, err := unpackBundle(ctx, data, blob)
+		if err != nil || again.checksum != bundle.checksum {
+			t.Errorf("expected same checksum, got %+v (err: %v)", again, err)
+		}
+	})
+
+	t.Run("SUCCESS Containing folder is stripped", func(t *testing.T) {
+		blob, _ := newBundleStorage(t)
+		data := newBundle(t, map[string]string{
+			"snake/index.html": "<canvas></canvas>",
+			"snake/game.js":    "run()",
+		})
+
+		bundle, err := unpackBundle(ctx, data, blob)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		obj, err := blob.Get(ctx, bundleKey(bundle.checksum, "index.html"))
+		if err != nil {
+			t.Fatalf("expected index.html at bundle root, got %v", err)
+		}
+		obj.Body.Close()
+	})
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"ERROR Not a zip", []byte("not a zip")},
+		{"ERROR Missing index.html", newBundle(t, map[string]string{"game.js": "run()"})},
+		{"ERROR Index in nested folder only", newBundle(t, map[string]string{"a/index.html": "", "b/game.js": ""})},
+		{"ERROR Path traversal", newBundle(t, map[string]string{"index.html": "", "../evil.js": ""})},
+		{"ERROR Absolute path", newBundle(t, map[string]string{"index.html": "", "/etc/evil.js": ""})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blob, root := newBundleStorage(t)
+
+			_, err := unpackBundle(ctx, tt.data, blob)
+			if !errors.Is(err, ErrInvalidBundle) {
+				t.Errorf("expected ErrInvalidBundle, got %v", err)
+			}
+
+			entries, _ := os.ReadDir(root)
+			if len(entries) != 0 {
+				t.Errorf("expected nothing to be stored, got %d entries", len(entries))
+			}
+		})
+	}
+}
@@ -1,19 +1,85 @@
 package games
 
-import "time"
+import (
+	"time"
+
+	"github.com/navazjm/pixelarcade/internal/webapp/utils/validator"
+)
 
 type Game struct {
-	ID          int64     `json:"id"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	Version     int32     `json:"version"`
-	IsActive    bool      `json:"is_active"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	Logo        string    `json:"logo"`
-	Src         string    `json:"src"`
-	Controls    string    `json:"controls"`
-	HasScore    bool      `json:"has_score"`
+	ID          int64      `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Version     int32      `json:"version"`
+	IsActive    bool       `json:"is_active"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Logo        string     `json:"logo"`
+	Src         string     `json:"src"`
+	Controls    string     `json:"controls"`
+	HasScore    bool       `json:"has_score"`
+	ScoreType   ScoreType  `json:"score_type"`
+	ScoreOrder  ScoreOrder `json:"score_order"`
+	ScoreMin    int64      `json:"score_min"`
+	ScoreMax    int64      `json:"score_max"`
+	// Maximum score gained per second of play, used to reject impossible scores.
+	// Zero disables the check.
+	ScoreRateMax int64 `json:"score_rate_max"`
+}
+
+type ScoreType string
+
+const (
+	ScoreTypePoints ScoreType = "points"
+	ScoreTypeTimeMS ScoreType = "time_ms"
+	ScoreTypeMoves  ScoreType = "moves"
+)
+
+// DefaultOrder returns how scores of this type are usually ranked, e.g. the fastest
+// time or fewest moves wins.
+func (t ScoreType) DefaultOrder() ScoreOrder {
+	switch t {
+	case ScoreTypeTimeMS, ScoreTypeMoves:
+		return ScoreOrderAsc
+	default:
+		return ScoreOrderDesc
+	}
 }
 
-// TODO: Implement validation check during PostGameHandler, UpdateGameByIDHandler
+type ScoreOrder string
+
+const (
+	ScoreOrderDesc ScoreOrder = "desc"
+	ScoreOrderAsc  ScoreOrder = "asc"
+)
+
+// SQL returns the ORDER BY direction for leaderboard queries. Anything other than
+// ascending falls back to descending so the result is always safe to interpolate.
+func (o ScoreOrder) SQL() string {
+	if o == ScoreOrderAsc {
+		return "ASC"
+	}
+	return "DESC"
+}
+
+func ValidateGame(v *validator.Validator, game *Game) {
+	v.Check(game.Name != "", "name", "must be provided")
+	v.Check(len(game.Name) <= 100, "name", "must not be more than 100 bytes long")
+
+	v.Check(len(game.Description) <= 2000, "description", "must not be more than 2000 bytes long")
+
+	v.Check(game.Logo != "", "logo", "must be provided")
+	v.Check(validator.IsURL(game.Logo), "logo", "must be a valid URL")
+
+	v.Check(game.Src != "", "src", "must be provided")
+	v.Check(validator.IsURL(game.Src), "src", "must be a valid URL")
+
+	v.Check(game.Controls != "", "controls", "must be provided")
+	v.Check(len(game.Controls) <= 2000, "controls", "must not be more than 2000 bytes long")
+
+	v.Check(validator.PermittedValue(game.ScoreType, ScoreTypePoints, ScoreTypeTimeMS, ScoreTypeMoves), "score_type", "must be one of points, time_ms or moves")
+	v.Check(validator.PermittedValue(game.ScoreOrder, ScoreOrderDesc, ScoreOrderAsc), "score_order", "must be either desc or asc")
+	v.Check(game.ScoreMin >= 0, "score_min", "must be zero or greater")
+	v.Check(game.ScoreMax >= game.ScoreMin, "score_max", "must be greater than or equal to score_min")
+	v.Check(game.ScoreRateMax >= 0, "score_rate_max", "must be zero or greater")
+}
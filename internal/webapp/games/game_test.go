@@ -0,0 +1,99 @@
+package games
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/navazjm/pixelarcade/internal/webapp/utils/validator"
+)
+
+func TestValidateGame(t *testing.T) {
+	valid := func() *Game {
+		return &Game{
+			Name:        "Snake",
+			Description: "Eat the apples",
+			Logo:        "/games/snake/logo.png",
+			Src:         "https://pixelarcade.dev/play/1/1/",
+			Controls:    "Arrow keys",
+			ScoreType:   ScoreTypePoints,
+			ScoreOrder:  ScoreOrderDesc,
+			ScoreMin:    1,
+			ScoreMax:    1000000,
+		}
+	}
+
+	tests := []struct {
+		name     string
+		modify   func(g *Game)
+		errorKey string
+	}{
+		{"Valid game", func(g *Game) {}, ""},
+		{"Missing name", func(g *Game) { g.Name = "" }, "name"},
+		{"Name too long", func(g *Game) { g.Name = strings.Repeat("a", 101) }, "name"},
+		{"Description too long", func(g *Game) { g.Description = strings.Repeat("a", 2001) }, "description"},
+		{"Invalid logo", func(g *Game) { g.Logo = "logo.png" }, "logo"},
+		{"Invalid src", func(g *Game) { g.Src = "javascript:alert(1)" }, "src"},
+		{"Missing controls", func(g *Game) { g.Controls = "" }, "controls"},
+		{"Unknown score type", func(g *Game) { g.ScoreType = "laps" }, "score_type"},
+		{"Unknown score order", func(g *Game) { g.ScoreOrder = "random" }, "score_order"},
+		{"Negative score min", func(g *Game) { g.ScoreMin = -1 }, "score_min"},
+		{"Score max below min", func(g *Game) { g.ScoreMin, g.ScoreMax = 10, 5 }, "score_max"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			game := valid()
+			tt.modify(game)
+
+			ValidateGame(v, game)
+
+			if tt.errorKey == "" && !v.Valid() {
+				t.Errorf("expected validation to pass, but got errors: %v", v.Errors)
+			}
+			if _, ok := v.Errors[tt.errorKey]; tt.errorKey != "" && !ok {
+				t.Errorf("expected validation error for %q, got %v", tt.errorKey, v.Errors)
+			}
+		})
+	}
+}
+
+func TestScoreType_DefaultOrder(t *testing.T) {
+	if ScoreTypePoints.DefaultOrder() != ScoreOrderDesc {
+		t.Errorf("expected points to rank highest first")
+	}
+	if ScoreTypeTimeMS.DefaultOrder() != ScoreOrderAsc || ScoreTypeMoves.DefaultOrder() != ScoreOrderAsc {
+		t.Errorf("expected time and moves to rank lowest first")
+	}
+}
+
+func TestScoreOrder_SQL(t *testing.T) {
+	if ScoreOrderAsc.SQL() != "ASC" {
+		t.Errorf("expected ASC, got %s", ScoreOrderAsc.SQL())
+	}
+	if ScoreOrderDesc.SQL() != "DESC" || ScoreOrder("; DROP TABLE").SQL() != "DESC" {
+		t.Errorf("expected unknown orders to fall back to DESC")
+	}
+}
+
+func TestValidateScore(t *testing.T) {
+	game := &Game{ScoreMin: 10, ScoreMax: 100}
+
+	tests := []struct {
+		score int64
+		valid bool
+	}{
+		{10, true},
+		{100, true},
+		{9, false},
+		{101, false},
+	}
+
+	for _, tt := range tests {
+		v := validator.New()
+		ValidateScore(v, game, tt.score)
+		if v.Valid() != tt.valid {
+			t.Errorf("expected ValidateScore(%d) valid to be %v, got errors %v", tt.score, tt.valid, v.Errors)
+		}
+	}
+}
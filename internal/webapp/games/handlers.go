@@ -3,9 +3,17 @@ package games
 import (
 	"errors"
 	"fmt"
+	"io"
+	"math"
 	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/navazjm/pixelarcade/internal/webapp/audit"
 	"github.com/navazjm/pixelarcade/internal/webapp/auth"
+	"github.com/navazjm/pixelarcade/internal/webapp/storage"
 	"github.com/navazjm/pixelarcade/internal/webapp/utils/database"
 	"github.com/navazjm/pixelarcade/internal/webapp/utils/json"
 	"github.com/navazjm/pixelarcade/internal/webapp/utils/param"
@@ -13,8 +21,85 @@ import (
 	"github.com/navazjm/pixelarcade/internal/webapp/utils/validator"
 )
 
-// TODO: Post Game Handler, Implement after permissions implementation
-// func (s *Service) PostGameHandler(w http.ResponseWriter, r *http.Request) {}
+func (s *Service) PostGameHandler(w http.ResponseWriter, r *http.Request) {
+	var reqBody struct {
+		Name         string     `json:"name"`
+		Description  string     `json:"description"`
+		Logo         string     `json:"logo"`
+		Src          string     `json:"src"`
+		Controls     string     `json:"controls"`
+		HasScore     bool       `json:"has_score"`
+		ScoreType    ScoreType  `json:"score_type"`
+		ScoreOrder   ScoreOrder `json:"score_order"`
+		ScoreMin     *int64     `json:"score_min"`
+		ScoreMax     *int64     `json:"score_max"`
+		ScoreRateMax int64      `json:"score_rate_max"`
+		IsActive     bool       `json:"is_active"`
+	}
+
+	err := json.ReadRequestBody(w, r, &reqBody)
+	if err != nil {
+		response.BadRequest(w, r, s.Logger, err)
+		return
+	}
+
+	game := &Game{
+		Name:         reqBody.Name,
+		Description:  reqBody.Description,
+		Logo:         reqBody.Logo,
+		Src:          reqBody.Src,
+		Controls:     reqBody.Controls,
+		HasScore:     reqBody.HasScore,
+		ScoreType:    reqBody.ScoreType,
+		ScoreOrder:   reqBody.ScoreOrder,
+		ScoreMin:     1,
+		ScoreMax:     math.MaxInt64,
+		ScoreRateMax: reqBody.ScoreRateMax,
+		IsActive:     reqBody.IsActive,
+	}
+
+	// Omitted score settings default to positive points, highest first
+	if game.ScoreType == "" {
+		game.ScoreType = ScoreTypePoints
+	}
+	if game.ScoreOrder == "" {
+		game.ScoreOrder = game.ScoreType.DefaultOrder()
+	}
+	if reqBody.ScoreMin != nil {
+		game.ScoreMin = *reqBody.ScoreMin
+	}
+	if reqBody.ScoreMax != nil {
+		game.ScoreMax = *reqBody.ScoreMax
+	}
+
+	v := validator.New()
+	if ValidateGame(v, game); !v.Valid() {
+		response.FailedValidation(w, r, s.Logger, v.Errors)
+		return
+	}
+
+	err = s.Models.InsertGame(game)
+	if err != nil {
+		response.ServerError(w, r, s.Logger, err)
+		return
+	}
+
+	s.Audit.Record(r, &audit.Event{
+		Action:     audit.ActionGameCreate,
+		ActorID:    auth.ContextGetUser(r).ID,
+		TargetType: audit.TargetGame,
+		TargetID:   game.ID,
+		Diff:       audit.Diff(struct{}{}, game),
+	})
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/api/games/%d", game.ID))
+
+	err = json.WriteResponse(w, http.StatusCreated, json.Envelope{"game": game}, headers)
+	if err != nil {
+		response.ServerError(w, r, s.Logger, err)
+	}
+}
 
 func (s *Service) GetGamesHandler(w http.ResponseWriter, r *http.Request) {
 	games, err := s.Models.GetGames()
@@ -53,31 +138,215 @@ func (s *Service) GetGameByIDHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// TODO: Update Game Handler, Implement after permissions implementation
-// func (s *Service) UpdateGameByIDHandler(w http.ResponseWriter, r *http.Request) {}
+func (s *Service) UpdateGameByIDHandler(w http.ResponseWriter, r *http.Request) {
+	gameID, err := param.ReadID(r)
+	if err != nil {
+		response.NotFound(w, r, s.Logger)
+		return
+	}
 
-// TODO: Delete Game Handler, Implement after permissions implementation
-// func (s *Service) DeleteGameByIDHandler(w http.ResponseWriter, r *http.Request) {}
+	game, err := s.Models.GetGameByID(gameID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			response.NotFound(w, r, s.Logger)
+		default:
+			response.ServerError(w, r, s.Logger, err)
+		}
+		return
+	}
 
-func (s *Service) PostScoreHandler(w http.ResponseWriter, r *http.Request) {
-	user := auth.ContextGetUser(r)
+	// Clients may send the version they last read to guard against overwriting
+	// changes made since then
+	if expected := r.Header.Get("X-Expected-Version"); expected != "" {
+		if strconv.FormatInt(int64(game.Version), 10) != expected {
+			response.EditConflict(w, r, s.Logger)
+			return
+		}
+	}
+
+	before := *game
 
 	var reqBody struct {
-		Score int64 `json:"score"`
+		Name         *string     `json:"name"`
+		Description  *string     `json:"description"`
+		Logo         *string     `json:"logo"`
+		Src          *string     `json:"src"`
+		Controls     *string     `json:"controls"`
+		HasScore     *bool       `json:"has_score"`
+		ScoreType    *ScoreType  `json:"score_type"`
+		ScoreOrder   *ScoreOrder `json:"score_order"`
+		ScoreMin     *int64      `json:"score_min"`
+		ScoreMax     *int64      `json:"score_max"`
+		ScoreRateMax *int64      `json:"score_rate_max"`
+		IsActive     *bool       `json:"is_active"`
 	}
 
-	err := json.ReadRequestBody(w, r, &reqBody)
+	err = json.ReadRequestBody(w, r, &reqBody)
 	if err != nil {
 		response.BadRequest(w, r, s.Logger, err)
 		return
 	}
 
+	if reqBody.Name != nil {
+		game.Name = *reqBody.Name
+	}
+	if reqBody.Description != nil {
+		game.Description = *reqBody.Description
+	}
+	if reqBody.Logo != nil {
+		game.Logo = *reqBody.Logo
+	}
+	if reqBody.Src != nil {
+		game.Src = *reqBody.Src
+	}
+	if reqBody.Controls != nil {
+		game.Controls = *reqBody.Controls
+	}
+	if reqBody.HasScore != nil {
+		game.HasScore = *reqBody.HasScore
+	}
+	if reqBody.ScoreType != nil {
+		game.ScoreType = *reqBody.ScoreType
+	}
+	if reqBody.ScoreOrder != nil {
+		game.ScoreOrder = *reqBody.ScoreOrder
+	}
+	if reqBody.ScoreMin != nil {
+		game.ScoreMin = *reqBody.ScoreMin
+	}
+	if reqBody.ScoreMax != nil {
+		game.ScoreMax = *reqBody.ScoreMax
+	}
+	if reqBody.ScoreRateMax != nil {
+		game.ScoreRateMax = *reqBody.ScoreRateMax
+	}
+	if reqBody.IsActive != nil {
+		game.IsActive = *reqBody.IsActive
+	}
+
 	v := validator.New()
-	if ValidateScore(v, reqBody.Score); !v.Valid() {
+	if ValidateGame(v, game); !v.Valid() {
 		response.FailedValidation(w, r, s.Logger, v.Errors)
 		return
 	}
 
+	err = s.Models.UpdateGameByID(game)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrEditConflict):
+			response.EditConflict(w, r, s.Logger)
+		default:
+			response.ServerError(w, r, s.Logger, err)
+		}
+		return
+	}
+
+	s.recordGameUpdate(r, &before, game)
+
+	err = json.WriteResponse(w, http.StatusOK, json.Envelope{"game": game}, nil)
+	if err != nil {
+		response.ServerError(w, r, s.Logger, err)
+	}
+}
+
+// recordGameUpdate audits a change to a game's settings, including which version of
+// its bundle is served.
+func (s *Service) recordGameUpdate(r *http.Request, before, after *Game) {
+	s.Audit.Record(r, &audit.Event{
+		Action:     audit.ActionGameUpdate,
+		ActorID:    auth.ContextGetUser(r).ID,
+		TargetType: audit.TargetGame,
+		TargetID:   after.ID,
+		Diff:       audit.Diff(before, after),
+	})
+}
+
+func (s *Service) DeleteGameByIDHandler(w http.ResponseWriter, r *http.Request) {
+	gameID, err := param.ReadID(r)
+	if err != nil {
+		response.NotFound(w, r, s.Logger)
+		return
+	}
+
+	err = s.Models.DeleteGameByID(gameID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			response.NotFound(w, r, s.Logger)
+		default:
+			response.ServerError(w, r, s.Logger, err)
+		}
+		return
+	}
+
+	s.Audit.Record(r, &audit.Event{Action: audit.ActionGameDelete, ActorID: auth.ContextGetUser(r).ID, TargetType: audit.TargetGame, TargetID: gameID})
+
+	err = json.WriteResponse(w, http.StatusOK, json.Envelope{"message": "game successfully deleted"}, nil)
+	if err != nil {
+		response.ServerError(w, r, s.Logger, err)
+	}
+}
+
+// PostPlaySessionHandler starts a play session, returning the signed ticket the
+// client must submit along with the score at the end of the run.
+func (s *Service) PostPlaySessionHandler(w http.ResponseWriter, r *http.Request) {
+	user := auth.ContextGetUser(r)
+	gameID, err := param.ReadID(r)
+	if err != nil {
+		response.NotFound(w, r, s.Logger)
+		return
+	}
+
+	game, err := s.Models.GetGameByID(gameID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			response.NotFound(w, r, s.Logger)
+		default:
+			response.ServerError(w, r, s.Logger, err)
+		}
+		return
+	}
+
+	if !game.HasScore {
+		err = errors.New(fmt.Sprintf("%s does not track scores", game.Name))
+		response.ServerError(w, r, s.Logger, err)
+		return
+	}
+
+	session, err := generatePlaySession(game.ID, user.ID, s.Config.SessionTTL, s.Config.SessionSecret)
+	if err != nil {
+		response.ServerError(w, r, s.Logger, err)
+		return
+	}
+
+	err = s.Models.InsertPlaySession(session)
+	if err != nil {
+		response.ServerError(w, r, s.Logger, err)
+		return
+	}
+
+	err = json.WriteResponse(w, http.StatusCreated, json.Envelope{"session": session}, nil)
+	if err != nil {
+		response.ServerError(w, r, s.Logger, err)
+	}
+}
+
+func (s *Service) PostScoreHandler(w http.ResponseWriter, r *http.Request) {
+	user := auth.ContextGetUser(r)
+
+	var reqBody struct {
+		Score  int64  `json:"score"`
+		Ticket string `json:"ticket"`
+	}
+
+	err := json.ReadRequestBody(w, r, &reqBody)
+	if err != nil {
+		response.BadRequest(w, r, s.Logger, err)
+		return
+	}
+
 	gameID, err := param.ReadID(r)
 	if err != nil {
 		response.NotFound(w, r, s.Logger)
@@ -101,6 +370,51 @@ func (s *Service) PostScoreHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	v := validator.New()
+	v.Check(reqBody.Ticket != "", "ticket", "must be provided")
+	if ValidateScore(v, game, reqBody.Score); !v.Valid() {
+		response.FailedValidation(w, r, s.Logger, v.Errors)
+		return
+	}
+
+	rejection := &ScoreRejection{
+		GameID: game.ID,
+		UserID: user.ID,
+		Score:  reqBody.Score,
+	}
+
+	session, err := ParsePlaySession(reqBody.Ticket, s.Config.SessionSecret)
+	if err != nil || session.GameID != game.ID || session.UserID != user.ID {
+		rejection.Reason = RejectionInvalidTicket
+		s.rejectScore(w, r, rejection, "ticket", "must be a valid play session ticket")
+		return
+	}
+
+	rejection.Nonce = session.Nonce
+	if time.Now().After(session.Expiry) {
+		rejection.Reason = RejectionExpiredTicket
+		s.rejectScore(w, r, rejection, "ticket", "play session has expired")
+		return
+	}
+
+	err = s.Models.ConsumePlaySession(session)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrUsedTicket):
+			rejection.Reason = RejectionUsedTicket
+			s.rejectScore(w, r, rejection, "ticket", "play session has already been used")
+		default:
+			response.ServerError(w, r, s.Logger, err)
+		}
+		return
+	}
+
+	if !IsPossibleScore(game, reqBody.Score, time.Since(session.StartedAt)) {
+		rejection.Reason = RejectionImpossible
+		s.rejectScore(w, r, rejection, "score", "is not possible in the time played")
+		return
+	}
+
 	score := &Score{
 		GameID:   game.ID,
 		UserID:   user.ID,
@@ -120,6 +434,17 @@ func (s *Service) PostScoreHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// rejectScore records the rejected submission for review before responding with a
+// failed validation error.
+func (s *Service) rejectScore(w http.ResponseWriter, r *http.Request, rejection *ScoreRejection, key, message string) {
+	err := s.Models.InsertScoreRejection(rejection)
+	if err != nil {
+		s.Logger.Error(err.Error(), "user_id", rejection.UserID, "reason", rejection.Reason)
+	}
+
+	response.FailedValidation(w, r, s.Logger, map[string]string{key: message})
+}
+
 func (s *Service) GetScoresByGameIDHandler(w http.ResponseWriter, r *http.Request) {
 	gameID, err := param.ReadID(r)
 	if err != nil {
@@ -127,6 +452,18 @@ func (s *Service) GetScoresByGameIDHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	v := validator.New()
+	filters := LeaderboardFilters{
+		Window: Window(param.ReadQueryString(r, "window", string(WindowAll))),
+		Limit:  param.ReadQueryInt(r, "limit", 50, v),
+		Offset: param.ReadQueryInt(r, "offset", 0, v),
+	}
+
+	if ValidateLeaderboardFilters(v, filters); !v.Valid() {
+		response.FailedValidation(w, r, s.Logger, v.Errors)
+		return
+	}
+
 	game, err := s.Models.GetGameByID(gameID)
 	if err != nil {
 		switch {
@@ -144,13 +481,15 @@ func (s *Service) GetScoresByGameIDHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	scores, err := s.Models.GetScoresByGameID(gameID)
+	since := filters.Window.Start(time.Now(), s.Config.Timezone)
+
+	scores, err := s.Models.GetScoresByGameID(gameID, game.ScoreOrder, since, filters)
 	if err != nil {
 		response.ServerError(w, r, s.Logger, err)
 		return
 	}
 
-	err = json.WriteResponse(w, http.StatusOK, json.Envelope{"scores": scores}, nil)
+	err = json.WriteResponse(w, http.StatusOK, json.Envelope{"scores": scores, "filters": filters}, nil)
 	if err != nil {
 		response.ServerError(w, r, s.Logger, err)
 	}
@@ -181,7 +520,7 @@ func (s *Service) GetUserScoresByGameIDHandler(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	scores, err := s.Models.GetUsersScoresByGameID(gameID, user.ID)
+	scores, err := s.Models.GetUsersScoresByGameID(gameID, user.ID, game.ScoreOrder)
 	if err != nil {
 		response.ServerError(w, r, s.Logger, err)
 		return
@@ -193,8 +532,499 @@ func (s *Service) GetUserScoresByGameIDHandler(w http.ResponseWriter, r *http.Re
 	}
 }
 
-// TODO: Update Score Handler, Implement after permissions implementation
-// func (s *Service) UpdateScoreByIDHandler(w http.ResponseWriter, r *http.Request) {}
+func (s *Service) GetUserRankByGameIDHandler(w http.ResponseWriter, r *http.Request) {
+	user := auth.ContextGetUser(r)
+	gameID, err := param.ReadID(r)
+	if err != nil {
+		response.NotFound(w, r, s.Logger)
+		return
+	}
+
+	v := validator.New()
+	window := Window(param.ReadQueryString(r, "window", string(WindowAll)))
+	neighbors := param.ReadQueryInt(r, "neighbors", 5, v)
+
+	ValidateWindow(v, window)
+	if ValidateNeighbors(v, neighbors); !v.Valid() {
+		response.FailedValidation(w, r, s.Logger, v.Errors)
+		return
+	}
+
+	game, err := s.Models.GetGameByID(gameID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			response.NotFound(w, r, s.Logger)
+		default:
+			response.ServerError(w, r, s.Logger, err)
+		}
+		return
+	}
 
-// TODO: Delete Score Handler, Implement after permissions implementation
-// func (s *Service) DeleteScoreByIDHandler(w http.ResponseWriter, r *http.Request) {}
+	if !game.HasScore {
+		err = errors.New(fmt.Sprintf("%s does not track scores", game.Name))
+		response.ServerError(w, r, s.Logger, err)
+		return
+	}
+
+	since := window.Start(time.Now(), s.Config.Timezone)
+
+	rank, err := s.Models.GetUserRankByGameID(gameID, user.ID, game.ScoreOrder, since, neighbors)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			response.NotFound(w, r, s.Logger)
+		default:
+			response.ServerError(w, r, s.Logger, err)
+		}
+		return
+	}
+
+	err = json.WriteResponse(w, http.StatusOK, json.Envelope{"rank": rank, "window": window}, nil)
+	if err != nil {
+		response.ServerError(w, r, s.Logger, err)
+	}
+}
+
+// UpdateScoreByIDHandler lets moderators correct a score or hide it from the
+// leaderboards by setting is_active to false. Hidden scores are kept for auditing.
+func (s *Service) UpdateScoreByIDHandler(w http.ResponseWriter, r *http.Request) {
+	score, ok := s.readScore(w, r)
+	if !ok {
+		return
+	}
+
+	if expected := r.Header.Get("X-Expected-Version"); expected != "" {
+		if strconv.FormatInt(int64(score.Version), 10) != expected {
+			response.EditConflict(w, r, s.Logger)
+			return
+		}
+	}
+
+	before := *score
+
+	var reqBody struct {
+		Score    *int64 `json:"score"`
+		IsActive *bool  `json:"is_active"`
+	}
+
+	err := json.ReadRequestBody(w, r, &reqBody)
+	if err != nil {
+		response.BadRequest(w, r, s.Logger, err)
+		return
+	}
+
+	if reqBody.Score != nil {
+		score.Score = *reqBody.Score
+	}
+	if reqBody.IsActive != nil {
+		score.IsActive = *reqBody.IsActive
+	}
+
+	game, err := s.Models.GetGameByID(score.GameID)
+	if err != nil {
+		response.ServerError(w, r, s.Logger, err)
+		return
+	}
+
+	v := validator.New()
+	if ValidateScore(v, game, score.Score); !v.Valid() {
+		response.FailedValidation(w, r, s.Logger, v.Errors)
+		return
+	}
+
+	err = s.Models.UpdateScoreByID(score)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrEditConflict):
+			response.EditConflict(w, r, s.Logger)
+		default:
+			response.ServerError(w, r, s.Logger, err)
+		}
+		return
+	}
+
+	s.recordScoreModeration(r, &before, score)
+
+	err = json.WriteResponse(w, http.StatusOK, json.Envelope{"score": score}, nil)
+	if err != nil {
+		response.ServerError(w, r, s.Logger, err)
+	}
+}
+
+func (s *Service) recordScoreModeration(r *http.Request, before, after *Score) {
+	s.Audit.Record(r, &audit.Event{
+		Action:     audit.ActionScoreModerate,
+		ActorID:    auth.ContextGetUser(r).ID,
+		TargetType: audit.TargetScore,
+		TargetID:   after.ID,
+		Diff:       audit.Diff(before, after),
+	})
+}
+
+// DeleteScoreByIDHandler permanently deletes the score when requested by its owner.
+// Moderators deleting another user's score only hide it, so it remains auditable, and
+// hidden scores can't be deleted by their owner either.
+func (s *Service) DeleteScoreByIDHandler(w http.ResponseWriter, r *http.Request) {
+	user := auth.ContextGetUser(r)
+
+	score, ok := s.readScore(w, r)
+	if !ok {
+		return
+	}
+
+	if score.UserID == user.ID {
+		if !score.IsActive {
+			response.PermissionDenied(w, r, s.Logger)
+			return
+		}
+
+		err := s.Models.DeleteScoreByID(score.ID, score.Version)
+		if err != nil {
+			switch {
+			case errors.Is(err, database.ErrEditConflict):
+				response.EditConflict(w, r, s.Logger)
+			default:
+				response.ServerError(w, r, s.Logger, err)
+			}
+			return
+		}
+
+		err = json.WriteResponse(w, http.StatusOK, json.Envelope{"message": "score successfully deleted"}, nil)
+		if err != nil {
+			response.ServerError(w, r, s.Logger, err)
+		}
+		return
+	}
+
+	allowed, err := s.Authorizer.HasPermission(user, auth.PermissionScoresModerate)
+	if err != nil {
+		response.ServerError(w, r, s.Logger, err)
+		return
+	}
+	if !allowed {
+		response.PermissionDenied(w, r, s.Logger)
+		return
+	}
+
+	before := *score
+	score.IsActive = false
+	err = s.Models.UpdateScoreByID(score)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrEditConflict):
+			response.EditConflict(w, r, s.Logger)
+		default:
+			response.ServerError(w, r, s.Logger, err)
+		}
+		return
+	}
+
+	s.recordScoreModeration(r, &before, score)
+
+	err = json.WriteResponse(w, http.StatusOK, json.Envelope{"message": "score successfully hidden"}, nil)
+	if err != nil {
+		response.ServerError(w, r, s.Logger, err)
+	}
+}
+
+// readScore looks up the score identified by the "scoreId" URL parameter, making sure
+// it belongs to the game identified by "id". On failure an error response has already
+// been written and false is returned.
+func (s *Service) readScore(w http.ResponseWriter, r *http.Request) (*Score, bool) {
+	gameID, err := param.ReadID(r)
+	if err != nil {
+		response.NotFound(w, r, s.Logger)
+		return nil, false
+	}
+
+	scoreID, err := param.ReadNamedID(r, "scoreId")
+	if err != nil {
+		response.NotFound(w, r, s.Logger)
+		return nil, false
+	}
+
+	score, err := s.Models.GetScoreByID(scoreID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			response.NotFound(w, r, s.Logger)
+		default:
+			response.ServerError(w, r, s.Logger, err)
+		}
+		return nil, false
+	}
+
+	if score.GameID != gameID {
+		response.NotFound(w, r, s.Logger)
+		return nil, false
+	}
+
+	return score, true
+}
+
+// PostGameVersionHandler accepts a zipped game build as the "bundle" multipart form
+// field and stores it as the game's next version. Unless the "activate" form field is
+// false, the game's src is pointed at the new version.
+func (s *Service) PostGameVersionHandler(w http.ResponseWriter, r *http.Request) {
+	user := auth.ContextGetUser(r)
+	gameID, err := param.ReadID(r)
+	if err != nil {
+		response.NotFound(w, r, s.Logger)
+		return
+	}
+
+	game, err := s.Models.GetGameByID(gameID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			response.NotFound(w, r, s.Logger)
+		default:
+			response.ServerError(w, r, s.Logger, err)
+		}
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, s.Config.BundleMaxSize)
+
+	v := validator.New()
+	file, _, err := r.FormFile("bundle")
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesError):
+			v.AddError("bundle", fmt.Sprintf("must not be larger than %d bytes", maxBytesError.Limit))
+		case errors.Is(err, http.ErrMissingFile):
+			v.AddError("bundle", "must be provided")
+		default:
+			response.BadRequest(w, r, s.Logger, err)
+			return
+		}
+		response.FailedValidation(w, r, s.Logger, v.Errors)
+		return
+	}
+	defer file.Close()
+
+	activate := true
+	if value := r.FormValue("activate"); value != "" {
+		activate, err = strconv.ParseBool(value)
+		v.Check(err == nil, "activate", "must be a boolean value")
+	}
+
+	if !v.Valid() {
+		response.FailedValidation(w, r, s.Logger, v.Errors)
+		return
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		response.ServerError(w, r, s.Logger, err)
+		return
+	}
+
+	bundle, err := unpackBundle(r.Context(), data, s.Storage)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidBundle):
+			v.AddError("bundle", err.Error())
+			response.FailedValidation(w, r, s.Logger, v.Errors)
+		default:
+			response.ServerError(w, r, s.Logger, err)
+		}
+		return
+	}
+
+	gameVersion := &GameVersion{
+		GameID:     game.ID,
+		Checksum:   bundle.checksum,
+		Size:       bundle.size,
+		FileCount:  bundle.fileCount,
+		UploadedBy: &user.ID,
+	}
+
+	err = s.Models.InsertGameVersion(gameVersion)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrEditConflict):
+			response.EditConflict(w, r, s.Logger)
+		default:
+			response.ServerError(w, r, s.Logger, err)
+		}
+		return
+	}
+
+	s.Audit.Record(r, &audit.Event{
+		Action:     audit.ActionGameVersionCreate,
+		ActorID:    user.ID,
+		TargetType: audit.TargetGame,
+		TargetID:   game.ID,
+		Diff:       audit.Diff(struct{}{}, gameVersion),
+	})
+
+	if activate {
+		before := *game
+		game.Src = gameVersion.Src
+		err = s.Models.UpdateGameByID(game)
+		if err != nil {
+			switch {
+			case errors.Is(err, database.ErrEditConflict):
+				response.EditConflict(w, r, s.Logger)
+			default:
+				response.ServerError(w, r, s.Logger, err)
+			}
+			return
+		}
+
+		s.recordGameUpdate(r, &before, game)
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", gameVersion.Src)
+
+	err = json.WriteResponse(w, http.StatusCreated, json.Envelope{"version": gameVersion, "game": game}, headers)
+	if err != nil {
+		response.ServerError(w, r, s.Logger, err)
+	}
+}
+
+func (s *Service) GetGameVersionsHandler(w http.ResponseWriter, r *http.Request) {
+	gameID, err := param.ReadID(r)
+	if err != nil {
+		response.NotFound(w, r, s.Logger)
+		return
+	}
+
+	exists, err := s.Models.ExistsGameByID(gameID)
+	if err != nil {
+		response.ServerError(w, r, s.Logger, err)
+		return
+	}
+	if !exists {
+		response.NotFound(w, r, s.Logger)
+		return
+	}
+
+	gameVersions, err := s.Models.GetGameVersionsByGameID(gameID)
+	if err != nil {
+		response.ServerError(w, r, s.Logger, err)
+		return
+	}
+
+	err = json.WriteResponse(w, http.StatusOK, json.Envelope{"versions": gameVersions}, nil)
+	if err != nil {
+		response.ServerError(w, r, s.Logger, err)
+	}
+}
+
+// ActivateGameVersionHandler points the game's src at a previously uploaded version,
+// e.g. to roll back a broken build.
+func (s *Service) ActivateGameVersionHandler(w http.ResponseWriter, r *http.Request) {
+	gameVersion, ok := s.readGameVersion(w, r)
+	if !ok {
+		return
+	}
+
+	game, err := s.Models.GetGameByID(gameVersion.GameID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			response.NotFound(w, r, s.Logger)
+		default:
+			response.ServerError(w, r, s.Logger, err)
+		}
+		return
+	}
+
+	before := *game
+	game.Src = gameVersion.Src
+	err = s.Models.UpdateGameByID(game)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrEditConflict):
+			response.EditConflict(w, r, s.Logger)
+		default:
+			response.ServerError(w, r, s.Logger, err)
+		}
+		return
+	}
+
+	s.recordGameUpdate(r, &before, game)
+
+	err = json.WriteResponse(w, http.StatusOK, json.Envelope{"game": game}, nil)
+	if err != nil {
+		response.ServerError(w, r, s.Logger, err)
+	}
+}
+
+// PlayGameHandler serves the files of an uploaded game version. Everything is sent
+// with PlayContentSecurityPolicy so the game runs sandboxed when framed by the arcade.
+func (s *Service) PlayGameHandler(w http.ResponseWriter, r *http.Request) {
+	gameVersion, ok := s.readGameVersion(w, r)
+	if !ok {
+		return
+	}
+	if !gameVersion.IsActive {
+		response.NotFound(w, r, s.Logger)
+		return
+	}
+
+	name := strings.TrimPrefix(path.Clean("/"+param.ReadString(r, "filepath")), "/")
+	if name == "" {
+		name = bundleEntryFile
+	}
+
+	obj, err := s.Storage.Get(r.Context(), bundleKey(gameVersion.Checksum, name))
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			response.NotFound(w, r, s.Logger)
+		default:
+			response.ServerError(w, r, s.Logger, err)
+		}
+		return
+	}
+	defer obj.Body.Close()
+
+	// Sandboxed documents have an opaque origin, so the game's own requests for its
+	// files are cross-origin and must be allowed explicitly.
+	w.Header().Set("Content-Security-Policy", PlayContentSecurityPolicy)
+	w.Header().Set("X-Frame-Options", "sameorigin")
+	w.Header().Set("Cross-Origin-Resource-Policy", "cross-origin")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	// A version number always refers to the same bundle
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+
+	err = storage.ServeObject(w, r, name, obj)
+	if err != nil {
+		response.LogError(r, s.Logger, err)
+	}
+}
+
+// readGameVersion looks up the version identified by the "version" URL parameter of
+// the game identified by "id". On failure an error response has already been sent.
+func (s *Service) readGameVersion(w http.ResponseWriter, r *http.Request) (*GameVersion, bool) {
+	gameID, err := param.ReadID(r)
+	if err != nil {
+		response.NotFound(w, r, s.Logger)
+		return nil, false
+	}
+
+	number, err := param.ReadNamedID(r, "version")
+	if err != nil || number > math.MaxInt32 {
+		response.NotFound(w, r, s.Logger)
+		return nil, false
+	}
+
+	gameVersion, err := s.Models.GetGameVersion(gameID, int32(number))
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			response.NotFound(w, r, s.Logger)
+		default:
+			response.ServerError(w, r, s.Logger, err)
+		}
+		return nil, false
+	}
+
+	return gameVersion, true
+}
@@ -1,14 +1,21 @@
 package games
 
 import (
+	"bytes"
+	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
+	"math"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/navazjm/pixelarcade/internal/webapp/audit"
 	"github.com/navazjm/pixelarcade/internal/webapp/auth"
 	"github.com/navazjm/pixelarcade/internal/webapp/utils/database"
 	pa_json "github.com/navazjm/pixelarcade/internal/webapp/utils/json"
@@ -17,7 +24,84 @@ import (
 	"github.com/DATA-DOG/go-sqlmock"
 )
 
-// func TestPostGameHandler(t *testing.T) {}
+// User making the requests to handlers that are guarded by a permission
+var admin = &auth.User{ID: 99, RoleID: auth.RoleAdmin}
+
+func TestPostGameHandler(t *testing.T) {
+	t.Run("SUCCESS Game inserted", func(t *testing.T) {
+		service, mock := newMockService(t)
+		now := time.Now()
+		reqBody := `{"name": "Snake", "description": "Eat the apples", "logo": "/games/snake/logo.png", "src": "/play/1/1/", "controls": "Arrow keys", "has_score": true, "is_active": true}`
+
+		mock.ExpectQuery("INSERT INTO games_list").
+			WithArgs("Snake", "Eat the apples", "/games/snake/logo.png", "/play/1/1/", "Arrow keys", true, "points", "desc", 1, int64(math.MaxInt64), 0, true).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "version"}).
+				AddRow(1, now, now, 1))
+
+		req := httptest.NewRequest(http.MethodPost, "/api/games", strings.NewReader(reqBody))
+		req = auth.ContextSetUser(req, admin)
+		w := httptest.NewRecorder()
+
+		service.PostGameHandler(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusCreated {
+			t.Errorf("expected status %d, got %d", http.StatusCreated, resp.StatusCode)
+		}
+		if resp.Header.Get("Location") != "/api/games/1" {
+			t.Errorf("expected Location header '/api/games/1', got %s", resp.Header.Get("Location"))
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("ERROR Failed game validation check", func(t *testing.T) {
+		service, _ := newMockService(t)
+		reqBody := `{"name": "", "logo": "not a url", "src": "/play/1/1/", "controls": ""}`
+
+		req := httptest.NewRequest(http.MethodPost, "/api/games", strings.NewReader(reqBody))
+		req = auth.ContextSetUser(req, admin)
+		w := httptest.NewRecorder()
+
+		service.PostGameHandler(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusUnprocessableEntity {
+			t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, resp.StatusCode)
+		}
+	})
+
+	t.Run("ERROR Database error inserting game", func(t *testing.T) {
+		service, mock := newMockService(t)
+		reqBody := `{"name": "Snake", "logo": "/logo.png", "src": "/play/1/1/", "controls": "Arrow keys"}`
+
+		mock.ExpectQuery("INSERT INTO games_list").
+			WillReturnError(database.ErrMockDatabase)
+
+		req := httptest.NewRequest(http.MethodPost, "/api/games", strings.NewReader(reqBody))
+		req = auth.ContextSetUser(req, admin)
+		w := httptest.NewRecorder()
+
+		service.PostGameHandler(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusInternalServerError {
+			t.Errorf("expected status %d, got %d", http.StatusInternalServerError, resp.StatusCode)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+}
 
 func TestGetGamesHandler(t *testing.T) {
 	t.Run("SUCCESS Retrieved games list", func(t *testing.T) {
@@ -26,10 +110,10 @@ func TestGetGamesHandler(t *testing.T) {
 
 		rows := sqlmock.NewRows([]string{
 			"id", "created_at", "updated_at", "version", "is_active",
-			"name", "description", "logo", "src", "controls", "has_score",
+			"name", "description", "logo", "src", "controls", "has_score", "score_type", "score_order", "score_min", "score_max", "score_rate_max",
 		}).
-			AddRow(1, now, now, 1, true, "Game One", "Desc One", "logo1.png", "src1", "controls1", true).
-			AddRow(2, now, now, 1, true, "Game Two", "Desc Two", "logo2.png", "src2", "controls2", false)
+			AddRow(1, now, now, 1, true, "Game One", "Desc One", "logo1.png", "src1", "controls1", true, "points", "desc", 1, 1000000, 0).
+			AddRow(2, now, now, 1, true, "Game Two", "Desc Two", "logo2.png", "src2", "controls2", false, "points", "desc", 1, 1000000, 0)
 
 		mock.ExpectQuery("SELECT .* FROM games_list ORDER BY name").WillReturnRows(rows)
 
@@ -97,9 +181,9 @@ func TestGetGameByIDHandler(t *testing.T) {
 			WithArgs(gameID).
 			WillReturnRows(sqlmock.NewRows([]string{
 				"id", "created_at", "updated_at", "version", "is_active",
-				"name", "description", "logo", "src", "controls", "has_score",
+				"name", "description", "logo", "src", "controls", "has_score", "score_type", "score_order", "score_min", "score_max", "score_rate_max",
 			}).AddRow(
-				gameID, now, now, 1, true, "Game One", "Description One", "logo1.png", "src1", "controls1", true,
+				gameID, now, now, 1, true, "Game One", "Description One", "logo1.png", "src1", "controls1", true, "points", "desc", 1, 1000000, 0,
 			))
 
 		req := httptest.NewRequest(http.MethodGet, endpoint, nil)
@@ -196,9 +280,211 @@ func TestGetGameByIDHandler(t *testing.T) {
 	})
 }
 
-// func TestUpdateGameByIDHandler(t *testing.T) {}
+func TestUpdateGameByIDHandler(t *testing.T) {
+	gameID := int64(1)
+	endpoint := fmt.Sprintf("/api/games/%d", gameID)
+	gameColumns := []string{
+		"id", "created_at", "updated_at", "version", "is_active",
+		"name", "description", "logo", "src", "controls", "has_score", "score_type", "score_order", "score_min", "score_max", "score_rate_max",
+	}
+
+	t.Run("SUCCESS Game updated", func(t *testing.T) {
+		service, mock := newMockService(t)
+		now := time.Now()
+		mock.ExpectQuery("SELECT .* FROM games_list WHERE id = \\$1").
+			WithArgs(gameID).
+			WillReturnRows(sqlmock.NewRows(gameColumns).AddRow(
+				gameID, now, now, 1, true, "Game One", "Description One", "/logo1.png", "/src1", "controls1", true, "points", "desc", 1, 1000000, 0,
+			))
+
+		mock.ExpectQuery("UPDATE games_list").
+			WithArgs("New Name", "Description One", "/logo1.png", "/src1", "controls1", true, "points", "desc", 1, 1000000, 0, true, gameID, int32(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"updated_at", "version"}).AddRow(now, 2))
+
+		req := httptest.NewRequest(http.MethodPatch, endpoint, strings.NewReader(`{"name": "New Name"}`))
+		req.Header.Set("X-Expected-Version", "1")
+		req = param.InjectID(req, gameID)
+		req = auth.ContextSetUser(req, admin)
+		w := httptest.NewRecorder()
+
+		service.UpdateGameByIDHandler(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
+		}
+
+		events := service.Audit.(*audit.Mock).Events()
+		if len(events) != 1 || events[0].Action != audit.ActionGameUpdate || events[0].ActorID != admin.ID {
+			t.Fatalf("expected game update to be audited, got %+v", events)
+		}
+		if diff := string(events[0].Diff); diff != `{"name":{"new":"New Name","old":"Game One"}}` {
+			t.Errorf("unexpected audit diff: %s", diff)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("ERROR Expected version mismatch", func(t *testing.T) {
+		service, mock := newMockService(t)
+		now := time.Now()
+		mock.ExpectQuery("SELECT .* FROM games_list WHERE id = \\$1").
+			WithArgs(gameID).
+			WillReturnRows(sqlmock.NewRows(gameColumns).AddRow(
+				gameID, now, now, 2, true, "Game One", "Description One", "/logo1.png", "/src1", "controls1", true, "points", "desc", 1, 1000000, 0,
+			))
+
+		req := httptest.NewRequest(http.MethodPatch, endpoint, strings.NewReader(`{"name": "New Name"}`))
+		req.Header.Set("X-Expected-Version", "1")
+		req = param.InjectID(req, gameID)
+		req = auth.ContextSetUser(req, admin)
+		w := httptest.NewRecorder()
+
+		service.UpdateGameByIDHandler(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusConflict {
+			t.Errorf("expected status %d, got %d", http.StatusConflict, resp.StatusCode)
+		}
+	})
+
+	t.Run("ERROR Edit conflict", func(t *testing.T) {
+		service, mock := newMockService(t)
+		now := time.Now()
+		mock.ExpectQuery("SELECT .* FROM games_list WHERE id = \\$1").
+			WithArgs(gameID).
+			WillReturnRows(sqlmock.NewRows(gameColumns).AddRow(
+				gameID, now, now, 1, true, "Game One", "Description One", "/logo1.png", "/src1", "controls1", true, "points", "desc", 1, 1000000, 0,
+			))
+
+		mock.ExpectQuery("UPDATE games_list").
+			WillReturnError(sql.ErrNoRows)
+
+		req := httptest.NewRequest(http.MethodPatch, endpoint, strings.NewReader(`{"name": "New Name"}`))
+		req = param.InjectID(req, gameID)
+		req = auth.ContextSetUser(req, admin)
+		w := httptest.NewRecorder()
+
+		service.UpdateGameByIDHandler(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusConflict {
+			t.Errorf("expected status %d, got %d", http.StatusConflict, resp.StatusCode)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("ERROR Failed game validation check", func(t *testing.T) {
+		service, mock := newMockService(t)
+		now := time.Now()
+		mock.ExpectQuery("SELECT .* FROM games_list WHERE id = \\$1").
+			WithArgs(gameID).
+			WillReturnRows(sqlmock.NewRows(gameColumns).AddRow(
+				gameID, now, now, 1, true, "Game One", "Description One", "/logo1.png", "/src1", "controls1", true, "points", "desc", 1, 1000000, 0,
+			))
+
+		req := httptest.NewRequest(http.MethodPatch, endpoint, strings.NewReader(`{"src": "not a url"}`))
+		req = param.InjectID(req, gameID)
+		req = auth.ContextSetUser(req, admin)
+		w := httptest.NewRecorder()
+
+		service.UpdateGameByIDHandler(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusUnprocessableEntity {
+			t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, resp.StatusCode)
+		}
+	})
+
+	t.Run("ERROR DB error game not found", func(t *testing.T) {
+		service, mock := newMockService(t)
+		mock.ExpectQuery("SELECT .* FROM games_list WHERE id = \\$1").
+			WithArgs(gameID).
+			WillReturnError(database.ErrRecordNotFound)
+
+		req := httptest.NewRequest(http.MethodPatch, endpoint, strings.NewReader(`{"name": "New Name"}`))
+		req = param.InjectID(req, gameID)
+		req = auth.ContextSetUser(req, admin)
+		w := httptest.NewRecorder()
+
+		service.UpdateGameByIDHandler(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("expected status %d, got %d", http.StatusNotFound, resp.StatusCode)
+		}
+	})
+}
+
+func TestDeleteGameByIDHandler(t *testing.T) {
+	gameID := int64(1)
+	endpoint := fmt.Sprintf("/api/games/%d", gameID)
+
+	t.Run("SUCCESS Game deleted", func(t *testing.T) {
+		service, mock := newMockService(t)
+		mock.ExpectExec("DELETE FROM games_list WHERE id = \\$1").
+			WithArgs(gameID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		req := httptest.NewRequest(http.MethodDelete, endpoint, nil)
+		req = param.InjectID(req, gameID)
+		req = auth.ContextSetUser(req, admin)
+		w := httptest.NewRecorder()
+
+		service.DeleteGameByIDHandler(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("ERROR Game not found", func(t *testing.T) {
+		service, mock := newMockService(t)
+		mock.ExpectExec("DELETE FROM games_list WHERE id = \\$1").
+			WithArgs(gameID).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		req := httptest.NewRequest(http.MethodDelete, endpoint, nil)
+		req = param.InjectID(req, gameID)
+		req = auth.ContextSetUser(req, admin)
+		w := httptest.NewRecorder()
+
+		service.DeleteGameByIDHandler(w, req)
 
-// func TestDeleteGameByIDHandler(t *testing.T) {}
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("expected status %d, got %d", http.StatusNotFound, resp.StatusCode)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+}
 
 func TestPostScoreHandler(t *testing.T) {
 	gameID := int64(1)
@@ -207,17 +493,22 @@ func TestPostScoreHandler(t *testing.T) {
 	t.Run("SUCCESS Score inserted", func(t *testing.T) {
 		service, mock := newMockService(t)
 		user := &auth.User{ID: 1}
-		reqBody := `{"score": 100}`
+		session := newMockPlaySession(t, service, gameID, user.ID, time.Minute)
+		reqBody := fmt.Sprintf(`{"score": 100, "ticket": %q}`, session.Ticket)
 		now := time.Now()
 		mock.ExpectQuery("SELECT .* FROM games_list WHERE id = \\$1").
 			WithArgs(gameID).
 			WillReturnRows(sqlmock.NewRows([]string{
 				"id", "created_at", "updated_at", "version", "is_active",
-				"name", "description", "logo", "src", "controls", "has_score",
+				"name", "description", "logo", "src", "controls", "has_score", "score_type", "score_order", "score_min", "score_max", "score_rate_max",
 			}).AddRow(
-				gameID, now, now, 1, true, "Game One", "Description One", "logo1.png", "src1", "controls1", true,
+				gameID, now, now, 1, true, "Game One", "Description One", "logo1.png", "src1", "controls1", true, "points", "desc", 1, 1000000, 0,
 			))
 
+		mock.ExpectExec("UPDATE games_play_sessions SET used_at = now\\(\\)").
+			WithArgs(session.Nonce, gameID, user.ID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
 		mock.ExpectQuery("INSERT INTO games_scores").
 			WithArgs(gameID, user.ID, 100, true).
 			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "version"}).
@@ -261,10 +552,50 @@ func TestPostScoreHandler(t *testing.T) {
 	})
 
 	t.Run("ERROR Failed score validation check", func(t *testing.T) {
-		service, _ := newMockService(t)
+		service, mock := newMockService(t)
 		user := &auth.User{ID: 1}
+		now := time.Now()
+		mock.ExpectQuery("SELECT .* FROM games_list WHERE id = \\$1").
+			WithArgs(gameID).
+			WillReturnRows(sqlmock.NewRows([]string{
+				"id", "created_at", "updated_at", "version", "is_active",
+				"name", "description", "logo", "src", "controls", "has_score", "score_type", "score_order", "score_min", "score_max", "score_rate_max",
+			}).AddRow(
+				gameID, now, now, 1, true, "Game One", "Description One", "logo1.png", "src1", "controls1", true, "points", "desc", 1, 1000000, 0,
+			))
+
 		reqBody := `{"score": -10}` // Invalid score
 		req := httptest.NewRequest(http.MethodPost, endpoint, strings.NewReader(reqBody))
+		req = param.InjectID(req, gameID)
+		req = auth.ContextSetUser(req, user)
+		w := httptest.NewRecorder()
+
+		service.PostScoreHandler(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusUnprocessableEntity {
+			t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, resp.StatusCode)
+		}
+	})
+
+	t.Run("ERROR Score outside game bounds", func(t *testing.T) {
+		service, mock := newMockService(t)
+		user := &auth.User{ID: 1}
+		now := time.Now()
+		mock.ExpectQuery("SELECT .* FROM games_list WHERE id = \\$1").
+			WithArgs(gameID).
+			WillReturnRows(sqlmock.NewRows([]string{
+				"id", "created_at", "updated_at", "version", "is_active",
+				"name", "description", "logo", "src", "controls", "has_score", "score_type", "score_order", "score_min", "score_max", "score_rate_max",
+			}).AddRow(
+				gameID, now, now, 1, true, "Speedrun", "Fastest wins", "logo1.png", "src1", "controls1", true, "time_ms", "asc", 5000, 600000, 0,
+			))
+
+		reqBody := `{"score": 1200}` // Faster than the game allows
+		req := httptest.NewRequest(http.MethodPost, endpoint, strings.NewReader(reqBody))
+		req = param.InjectID(req, gameID)
 		req = auth.ContextSetUser(req, user)
 		w := httptest.NewRecorder()
 
@@ -276,6 +607,10 @@ func TestPostScoreHandler(t *testing.T) {
 		if resp.StatusCode != http.StatusUnprocessableEntity {
 			t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, resp.StatusCode)
 		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
 	})
 
 	t.Run("ERROR Param read game ID", func(t *testing.T) {
@@ -360,9 +695,9 @@ func TestPostScoreHandler(t *testing.T) {
 			WithArgs(gameID).
 			WillReturnRows(sqlmock.NewRows([]string{
 				"id", "created_at", "updated_at", "version", "is_active",
-				"name", "description", "logo", "src", "controls", "has_score",
+				"name", "description", "logo", "src", "controls", "has_score", "score_type", "score_order", "score_min", "score_max", "score_rate_max",
 			}).AddRow(
-				gameID, now, now, 1, true, "Game One", "Description One", "logo1.png", "src1", "controls1", false,
+				gameID, now, now, 1, true, "Game One", "Description One", "logo1.png", "src1", "controls1", false, "points", "desc", 1, 1000000, 0,
 			))
 
 		req := httptest.NewRequest(http.MethodPost, endpoint, strings.NewReader(reqBody))
@@ -383,19 +718,24 @@ func TestPostScoreHandler(t *testing.T) {
 	t.Run("ERROR Database error inserting score", func(t *testing.T) {
 		service, mock := newMockService(t)
 		user := &auth.User{ID: 1}
-		reqBody := `{"score": 100}`
 		now := time.Now()
+		session := newMockPlaySession(t, service, gameID, user.ID, time.Minute)
+		reqBody := fmt.Sprintf(`{"score": 100, "ticket": %q}`, session.Ticket)
 		mock.ExpectQuery("SELECT .* FROM games_list WHERE id = \\$1").
 			WithArgs(gameID).
 			WillReturnRows(sqlmock.NewRows([]string{
 				"id", "created_at", "updated_at", "version", "is_active",
-				"name", "description", "logo", "src", "controls", "has_score",
+				"name", "description", "logo", "src", "controls", "has_score", "score_type", "score_order", "score_min", "score_max", "score_rate_max",
 			}).AddRow(
-				gameID, now, now, 1, true, "Game One", "Description One", "logo1.png", "src1", "controls1", true,
+				gameID, now, now, 1, true, "Game One", "Description One", "logo1.png", "src1", "controls1", true, "points", "desc", 1, 1000000, 0,
 			))
 
-		mock.ExpectExec("INSERT INTO scores").
-			WithArgs(gameID, user.ID, 100).
+		mock.ExpectExec("UPDATE games_play_sessions SET used_at = now\\(\\)").
+			WithArgs(session.Nonce, gameID, user.ID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		mock.ExpectQuery("INSERT INTO games_scores").
+			WithArgs(gameID, user.ID, 100, true).
 			WillReturnError(database.ErrMockDatabase)
 
 		req := httptest.NewRequest(http.MethodPost, endpoint, strings.NewReader(reqBody))
@@ -411,29 +751,246 @@ func TestPostScoreHandler(t *testing.T) {
 		if resp.StatusCode != http.StatusInternalServerError {
 			t.Errorf("expected status %d, got %d", http.StatusInternalServerError, resp.StatusCode)
 		}
-	})
-}
 
-func TestGetScoresByGameIDHandler(t *testing.T) {
-	gameID := int64(1)
-	endpoint := fmt.Sprintf("/api/games/%d/scores", gameID)
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
 
-	t.Run("SUCCESS Retrieved scores by game ID", func(t *testing.T) {
+	t.Run("ERROR Invalid ticket signature", func(t *testing.T) {
 		service, mock := newMockService(t)
+		user := &auth.User{ID: 1}
 		now := time.Now()
+		session := newMockPlaySession(t, service, gameID, user.ID, time.Minute)
+		reqBody := fmt.Sprintf(`{"score": 100, "ticket": %q}`, session.Ticket+"tampered")
 		mock.ExpectQuery("SELECT .* FROM games_list WHERE id = \\$1").
 			WithArgs(gameID).
 			WillReturnRows(sqlmock.NewRows([]string{
 				"id", "created_at", "updated_at", "version", "is_active",
-				"name", "description", "logo", "src", "controls", "has_score",
+				"name", "description", "logo", "src", "controls", "has_score", "score_type", "score_order", "score_min", "score_max", "score_rate_max",
 			}).AddRow(
-				gameID, now, now, 1, true, "Game One", "Description One", "logo1.png", "src1", "controls1", true,
+				gameID, now, now, 1, true, "Game One", "Description One", "logo1.png", "src1", "controls1", true, "points", "desc", 1, 1000000, 0,
 			))
 
-		mock.ExpectQuery("SELECT s.id, s.game_id, s.user_id, u.name, u.profile_picture, s.score, s.created_at, s.updated_at, s.version FROM games_scores s JOIN auth_user u ON s.user_id = u.uid WHERE s.game_id = \\$1").
-			WithArgs(gameID).
-			WillReturnRows(sqlmock.NewRows([]string{
-				"id", "game_id", "user_id", "name", "profile_picture", "score", "created_at", "updated_at", "version",
+		mock.ExpectQuery("INSERT INTO games_score_rejections").
+			WithArgs(gameID, user.ID, 100, RejectionInvalidTicket, "").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, now))
+
+		req := httptest.NewRequest(http.MethodPost, endpoint, strings.NewReader(reqBody))
+		req = param.InjectID(req, gameID)
+		req = auth.ContextSetUser(req, user)
+		w := httptest.NewRecorder()
+
+		service.PostScoreHandler(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusUnprocessableEntity {
+			t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, resp.StatusCode)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("ERROR Ticket issued for another user", func(t *testing.T) {
+		service, mock := newMockService(t)
+		user := &auth.User{ID: 1}
+		now := time.Now()
+		session := newMockPlaySession(t, service, gameID, user.ID+1, time.Minute)
+		reqBody := fmt.Sprintf(`{"score": 100, "ticket": %q}`, session.Ticket)
+		mock.ExpectQuery("SELECT .* FROM games_list WHERE id = \\$1").
+			WithArgs(gameID).
+			WillReturnRows(sqlmock.NewRows([]string{
+				"id", "created_at", "updated_at", "version", "is_active",
+				"name", "description", "logo", "src", "controls", "has_score", "score_type", "score_order", "score_min", "score_max", "score_rate_max",
+			}).AddRow(
+				gameID, now, now, 1, true, "Game One", "Description One", "logo1.png", "src1", "controls1", true, "points", "desc", 1, 1000000, 0,
+			))
+
+		mock.ExpectQuery("INSERT INTO games_score_rejections").
+			WithArgs(gameID, user.ID, 100, RejectionInvalidTicket, "").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, now))
+
+		req := httptest.NewRequest(http.MethodPost, endpoint, strings.NewReader(reqBody))
+		req = param.InjectID(req, gameID)
+		req = auth.ContextSetUser(req, user)
+		w := httptest.NewRecorder()
+
+		service.PostScoreHandler(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusUnprocessableEntity {
+			t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, resp.StatusCode)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("ERROR Expired ticket", func(t *testing.T) {
+		service, mock := newMockService(t)
+		user := &auth.User{ID: 1}
+		now := time.Now()
+		session := newMockPlaySession(t, service, gameID, user.ID, 3*time.Hour)
+		reqBody := fmt.Sprintf(`{"score": 100, "ticket": %q}`, session.Ticket)
+		mock.ExpectQuery("SELECT .* FROM games_list WHERE id = \\$1").
+			WithArgs(gameID).
+			WillReturnRows(sqlmock.NewRows([]string{
+				"id", "created_at", "updated_at", "version", "is_active",
+				"name", "description", "logo", "src", "controls", "has_score", "score_type", "score_order", "score_min", "score_max", "score_rate_max",
+			}).AddRow(
+				gameID, now, now, 1, true, "Game One", "Description One", "logo1.png", "src1", "controls1", true, "points", "desc", 1, 1000000, 0,
+			))
+
+		mock.ExpectQuery("INSERT INTO games_score_rejections").
+			WithArgs(gameID, user.ID, 100, RejectionExpiredTicket, session.Nonce).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, now))
+
+		req := httptest.NewRequest(http.MethodPost, endpoint, strings.NewReader(reqBody))
+		req = param.InjectID(req, gameID)
+		req = auth.ContextSetUser(req, user)
+		w := httptest.NewRecorder()
+
+		service.PostScoreHandler(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusUnprocessableEntity {
+			t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, resp.StatusCode)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("ERROR Ticket already used", func(t *testing.T) {
+		service, mock := newMockService(t)
+		user := &auth.User{ID: 1}
+		now := time.Now()
+		session := newMockPlaySession(t, service, gameID, user.ID, time.Minute)
+		reqBody := fmt.Sprintf(`{"score": 100, "ticket": %q}`, session.Ticket)
+		mock.ExpectQuery("SELECT .* FROM games_list WHERE id = \\$1").
+			WithArgs(gameID).
+			WillReturnRows(sqlmock.NewRows([]string{
+				"id", "created_at", "updated_at", "version", "is_active",
+				"name", "description", "logo", "src", "controls", "has_score", "score_type", "score_order", "score_min", "score_max", "score_rate_max",
+			}).AddRow(
+				gameID, now, now, 1, true, "Game One", "Description One", "logo1.png", "src1", "controls1", true, "points", "desc", 1, 1000000, 0,
+			))
+
+		mock.ExpectExec("UPDATE games_play_sessions SET used_at = now\\(\\)").
+			WithArgs(session.Nonce, gameID, user.ID).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		mock.ExpectQuery("INSERT INTO games_score_rejections").
+			WithArgs(gameID, user.ID, 100, RejectionUsedTicket, session.Nonce).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, now))
+
+		req := httptest.NewRequest(http.MethodPost, endpoint, strings.NewReader(reqBody))
+		req = param.InjectID(req, gameID)
+		req = auth.ContextSetUser(req, user)
+		w := httptest.NewRecorder()
+
+		service.PostScoreHandler(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusUnprocessableEntity {
+			t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, resp.StatusCode)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("ERROR Impossible score for time played", func(t *testing.T) {
+		service, mock := newMockService(t)
+		user := &auth.User{ID: 1}
+		now := time.Now()
+		session := newMockPlaySession(t, service, gameID, user.ID, time.Minute)
+		reqBody := fmt.Sprintf(`{"score": 100000, "ticket": %q}`, session.Ticket)
+		mock.ExpectQuery("SELECT .* FROM games_list WHERE id = \\$1").
+			WithArgs(gameID).
+			WillReturnRows(sqlmock.NewRows([]string{
+				"id", "created_at", "updated_at", "version", "is_active",
+				"name", "description", "logo", "src", "controls", "has_score", "score_type", "score_order", "score_min", "score_max", "score_rate_max",
+			}).AddRow(
+				gameID, now, now, 1, true, "Game One", "Description One", "logo1.png", "src1", "controls1", true, "points", "desc", 1, 1000000, 100,
+			))
+
+		mock.ExpectExec("UPDATE games_play_sessions SET used_at = now\\(\\)").
+			WithArgs(session.Nonce, gameID, user.ID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		mock.ExpectQuery("INSERT INTO games_score_rejections").
+			WithArgs(gameID, user.ID, 100000, RejectionImpossible, session.Nonce).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, now))
+
+		req := httptest.NewRequest(http.MethodPost, endpoint, strings.NewReader(reqBody))
+		req = param.InjectID(req, gameID)
+		req = auth.ContextSetUser(req, user)
+		w := httptest.NewRecorder()
+
+		service.PostScoreHandler(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusUnprocessableEntity {
+			t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, resp.StatusCode)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+}
+
+// newMockPlaySession signs a ticket for a play session that started the given
+// duration ago.
+func newMockPlaySession(t *testing.T, service *Service, gameID, userID int64, ago time.Duration) *PlaySession {
+	session, err := generatePlaySession(gameID, userID, service.Config.SessionTTL, service.Config.SessionSecret)
+	if err != nil {
+		t.Fatalf("failed to generate play session: %v", err)
+	}
+
+	session.StartedAt = session.StartedAt.Add(-ago)
+	session.Expiry = session.Expiry.Add(-ago)
+	session.Ticket = session.sign(service.Config.SessionSecret)
+
+	return session
+}
+
+func TestGetScoresByGameIDHandler(t *testing.T) {
+	gameID := int64(1)
+	endpoint := fmt.Sprintf("/api/games/%d/scores", gameID)
+
+	t.Run("SUCCESS Retrieved scores by game ID", func(t *testing.T) {
+		service, mock := newMockService(t)
+		now := time.Now()
+		mock.ExpectQuery("SELECT .* FROM games_list WHERE id = \\$1").
+			WithArgs(gameID).
+			WillReturnRows(sqlmock.NewRows([]string{
+				"id", "created_at", "updated_at", "version", "is_active",
+				"name", "description", "logo", "src", "controls", "has_score", "score_type", "score_order", "score_min", "score_max", "score_rate_max",
+			}).AddRow(
+				gameID, now, now, 1, true, "Game One", "Description One", "logo1.png", "src1", "controls1", true, "points", "desc", 1, 1000000, 0,
+			))
+
+		mock.ExpectQuery("SELECT .* FROM games_scores WHERE game_id = \\$1 AND is_active = true AND created_at >= \\$2 .* LIMIT \\$3 OFFSET \\$4").
+			WithArgs(gameID, time.Time{}, 50, 0).
+			WillReturnRows(sqlmock.NewRows([]string{
+				"id", "game_id", "user_id", "name", "profile_picture", "score", "created_at", "updated_at", "version",
 			}).AddRow(
 				1, gameID, 2, "User One", "profile1.jpg", 500, now, now, 1,
 			).AddRow(
@@ -470,6 +1027,76 @@ func TestGetScoresByGameIDHandler(t *testing.T) {
 		}
 	})
 
+	t.Run("SUCCESS Retrieved daily scores page", func(t *testing.T) {
+		service, mock := newMockService(t)
+		now := time.Now()
+		mock.ExpectQuery("SELECT .* FROM games_list WHERE id = \\$1").
+			WithArgs(gameID).
+			WillReturnRows(sqlmock.NewRows([]string{
+				"id", "created_at", "updated_at", "version", "is_active",
+				"name", "description", "logo", "src", "controls", "has_score", "score_type", "score_order", "score_min", "score_max", "score_rate_max",
+			}).AddRow(
+				gameID, now, now, 1, true, "Game One", "Description One", "logo1.png", "src1", "controls1", true, "points", "desc", 1, 1000000, 0,
+			))
+
+		mock.ExpectQuery("SELECT .* FROM games_scores WHERE game_id = \\$1 AND is_active = true AND created_at >= \\$2 .* LIMIT \\$3 OFFSET \\$4").
+			WithArgs(gameID, WindowDaily.Start(now, time.UTC), 10, 20).
+			WillReturnRows(sqlmock.NewRows([]string{
+				"id", "game_id", "user_id", "name", "profile_picture", "score", "created_at", "updated_at", "version",
+			}))
+
+		req := httptest.NewRequest(http.MethodGet, endpoint+"?window=daily&limit=10&offset=20", nil)
+		req = param.InjectID(req, gameID)
+		w := httptest.NewRecorder()
+
+		service.GetScoresByGameIDHandler(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
+		}
+
+		var jsonResponse struct {
+			Filters LeaderboardFilters `json:"filters"`
+		}
+
+		if err := json.NewDecoder(resp.Body).Decode(&jsonResponse); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+
+		expected := LeaderboardFilters{Window: WindowDaily, Limit: 10, Offset: 20}
+		if jsonResponse.Filters != expected {
+			t.Errorf("expected filters %+v, got %+v", expected, jsonResponse.Filters)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("ERROR Failed leaderboard filters validation check", func(t *testing.T) {
+		service, mock := newMockService(t)
+
+		req := httptest.NewRequest(http.MethodGet, endpoint+"?window=yearly&limit=500", nil)
+		req = param.InjectID(req, gameID)
+		w := httptest.NewRecorder()
+
+		service.GetScoresByGameIDHandler(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusUnprocessableEntity {
+			t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, resp.StatusCode)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
 	t.Run("ERROR Param read game ID", func(t *testing.T) {
 		service, _ := newMockService(t)
 		req := httptest.NewRequest(http.MethodGet, "/api/games/invalid/scores", nil)
@@ -541,9 +1168,9 @@ func TestGetScoresByGameIDHandler(t *testing.T) {
 			WithArgs(gameID).
 			WillReturnRows(sqlmock.NewRows([]string{
 				"id", "created_at", "updated_at", "version", "is_active",
-				"name", "description", "logo", "src", "controls", "has_score",
+				"name", "description", "logo", "src", "controls", "has_score", "score_type", "score_order", "score_min", "score_max", "score_rate_max",
 			}).AddRow(
-				gameID, now, now, 1, true, "Game One", "Description One", "logo1.png", "src1", "controls1", false, // `has_score` is false
+				gameID, now, now, 1, true, "Game One", "Description One", "logo1.png", "src1", "controls1", false, "points", "desc", 1, 1000000, 0, // `has_score` is false
 			))
 
 		req := httptest.NewRequest(http.MethodGet, endpoint, nil)
@@ -571,13 +1198,13 @@ func TestGetScoresByGameIDHandler(t *testing.T) {
 			WithArgs(gameID).
 			WillReturnRows(sqlmock.NewRows([]string{
 				"id", "created_at", "updated_at", "version", "is_active",
-				"name", "description", "logo", "src", "controls", "has_score",
+				"name", "description", "logo", "src", "controls", "has_score", "score_type", "score_order", "score_min", "score_max", "score_rate_max",
 			}).AddRow(
-				gameID, now, now, 1, true, "Game One", "Description One", "logo1.png", "src1", "controls1", true,
+				gameID, now, now, 1, true, "Game One", "Description One", "logo1.png", "src1", "controls1", true, "points", "desc", 1, 1000000, 0,
 			))
 
-		mock.ExpectQuery("SELECT s.id, s.game_id, s.user_id, u.name, u.profile_picture, s.score, s.created_at, s.updated_at, s.version FROM games_scores s JOIN auth_user u ON s.user_id = u.uid WHERE s.game_id = \\$1").
-			WithArgs(gameID).
+		mock.ExpectQuery("SELECT .* FROM games_scores WHERE game_id = \\$1 AND is_active = true AND created_at >= \\$2 .* LIMIT \\$3 OFFSET \\$4").
+			WithArgs(gameID, time.Time{}, 50, 0).
 			WillReturnError(database.ErrMockDatabase)
 
 		req := httptest.NewRequest(http.MethodGet, endpoint, nil)
@@ -616,9 +1243,9 @@ func TestGetUserScoresByGameIDHandler(t *testing.T) {
 			WithArgs(gameID).
 			WillReturnRows(sqlmock.NewRows([]string{
 				"id", "created_at", "updated_at", "version", "is_active",
-				"name", "description", "logo", "src", "controls", "has_score",
+				"name", "description", "logo", "src", "controls", "has_score", "score_type", "score_order", "score_min", "score_max", "score_rate_max",
 			}).AddRow(
-				gameID, now, now, 1, true, "Game One", "Description One", "logo1.png", "src1", "controls1", true,
+				gameID, now, now, 1, true, "Game One", "Description One", "logo1.png", "src1", "controls1", true, "points", "desc", 1, 1000000, 0,
 			))
 
 		mock.ExpectQuery("SELECT .* FROM games_scores").
@@ -719,9 +1346,9 @@ func TestGetUserScoresByGameIDHandler(t *testing.T) {
 			WithArgs(gameID).
 			WillReturnRows(sqlmock.NewRows([]string{
 				"id", "created_at", "updated_at", "version", "is_active",
-				"name", "description", "logo", "src", "controls", "has_score",
+				"name", "description", "logo", "src", "controls", "has_score", "score_type", "score_order", "score_min", "score_max", "score_rate_max",
 			}).AddRow(
-				gameID, now, now, 1, true, "Game One", "Description One", "logo1.png", "src1", "controls1", false,
+				gameID, now, now, 1, true, "Game One", "Description One", "logo1.png", "src1", "controls1", false, "points", "desc", 1, 1000000, 0,
 			))
 
 		service.GetUserScoresByGameIDHandler(respRecorder, req)
@@ -747,9 +1374,9 @@ func TestGetUserScoresByGameIDHandler(t *testing.T) {
 			WithArgs(gameID).
 			WillReturnRows(sqlmock.NewRows([]string{
 				"id", "created_at", "updated_at", "version", "is_active",
-				"name", "description", "logo", "src", "controls", "has_score",
+				"name", "description", "logo", "src", "controls", "has_score", "score_type", "score_order", "score_min", "score_max", "score_rate_max",
 			}).AddRow(
-				gameID, now, now, 1, true, "Game One", "Description One", "logo1.png", "src1", "controls1", true,
+				gameID, now, now, 1, true, "Game One", "Description One", "logo1.png", "src1", "controls1", true, "points", "desc", 1, 1000000, 0,
 			))
 
 		mock.ExpectQuery("SELECT .* FROM games_scores").
@@ -766,3 +1393,857 @@ func TestGetUserScoresByGameIDHandler(t *testing.T) {
 		}
 	})
 }
+
+func TestUpdateScoreByIDHandler(t *testing.T) {
+	gameID := int64(1)
+	scoreID := int64(7)
+	endpoint := fmt.Sprintf("/api/games/%d/scores/%d", gameID, scoreID)
+	scoreColumns := []string{"id", "created_at", "updated_at", "version", "is_active", "game_id", "user_id", "score"}
+	gameColumns := []string{
+		"id", "created_at", "updated_at", "version", "is_active",
+		"name", "description", "logo", "src", "controls", "has_score", "score_type", "score_order", "score_min", "score_max", "score_rate_max",
+	}
+
+	newRequest := func(body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPatch, endpoint, strings.NewReader(body))
+		req = param.InjectID(req, gameID)
+		req = param.Inject(req, "scoreId", strconv.FormatInt(scoreID, 10))
+		return auth.ContextSetUser(req, &auth.User{ID: 99, RoleID: auth.RoleAdmin})
+	}
+
+	t.Run("SUCCESS Score hidden by moderator", func(t *testing.T) {
+		service, mock := newMockService(t)
+		now := time.Now()
+		mock.ExpectQuery("SELECT .* FROM games_scores WHERE id = \\$1").
+			WithArgs(scoreID).
+			WillReturnRows(sqlmock.NewRows(scoreColumns).AddRow(scoreID, now, now, 1, true, gameID, 2, 5000))
+		mock.ExpectQuery("SELECT .* FROM games_list WHERE id = \\$1").
+			WithArgs(gameID).
+			WillReturnRows(sqlmock.NewRows(gameColumns).AddRow(
+				gameID, now, now, 1, true, "Game One", "Description One", "logo1.png", "src1", "controls1", true, "points", "desc", 1, 1000000, 0,
+			))
+
+		mock.ExpectQuery("UPDATE games_scores").
+			WithArgs(int64(5000), false, scoreID, int32(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"updated_at", "version"}).AddRow(now, 2))
+
+		w := httptest.NewRecorder()
+		service.UpdateScoreByIDHandler(w, newRequest(`{"is_active": false}`))
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
+		}
+
+		var respBody struct {
+			Score Score `json:"score"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&respBody); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if respBody.Score.IsActive || respBody.Score.Version != 2 {
+			t.Errorf("expected hidden score at version 2, got %+v", respBody.Score)
+		}
+
+		events := service.Audit.(*audit.Mock).Events()
+		if len(events) != 1 || events[0].Action != audit.ActionScoreModerate || events[0].TargetID != scoreID {
+			t.Errorf("expected score moderation to be audited, got %+v", events)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("SUCCESS Score corrected by moderator", func(t *testing.T) {
+		service, mock := newMockService(t)
+		now := time.Now()
+		mock.ExpectQuery("SELECT .* FROM games_scores WHERE id = \\$1").
+			WithArgs(scoreID).
+			WillReturnRows(sqlmock.NewRows(scoreColumns).AddRow(scoreID, now, now, 1, true, gameID, 2, 5000))
+		mock.ExpectQuery("SELECT .* FROM games_list WHERE id = \\$1").
+			WithArgs(gameID).
+			WillReturnRows(sqlmock.NewRows(gameColumns).AddRow(
+				gameID, now, now, 1, true, "Game One", "Description One", "logo1.png", "src1", "controls1", true, "points", "desc", 1, 1000000, 0,
+			))
+
+		mock.ExpectQuery("UPDATE games_scores").
+			WithArgs(int64(500), true, scoreID, int32(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"updated_at", "version"}).AddRow(now, 2))
+
+		w := httptest.NewRecorder()
+		service.UpdateScoreByIDHandler(w, newRequest(`{"score": 500}`))
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("ERROR Score belongs to another game", func(t *testing.T) {
+		service, mock := newMockService(t)
+		now := time.Now()
+		mock.ExpectQuery("SELECT .* FROM games_scores WHERE id = \\$1").
+			WithArgs(scoreID).
+			WillReturnRows(sqlmock.NewRows(scoreColumns).AddRow(scoreID, now, now, 1, true, gameID+1, 2, 5000))
+
+		w := httptest.NewRecorder()
+		service.UpdateScoreByIDHandler(w, newRequest(`{"is_active": false}`))
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("expected status %d, got %d", http.StatusNotFound, resp.StatusCode)
+		}
+	})
+
+	t.Run("ERROR Score not found", func(t *testing.T) {
+		service, mock := newMockService(t)
+		mock.ExpectQuery("SELECT .* FROM games_scores WHERE id = \\$1").
+			WithArgs(scoreID).
+			WillReturnError(sql.ErrNoRows)
+
+		w := httptest.NewRecorder()
+		service.UpdateScoreByIDHandler(w, newRequest(`{"is_active": false}`))
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("expected status %d, got %d", http.StatusNotFound, resp.StatusCode)
+		}
+	})
+
+	t.Run("ERROR Failed score validation check", func(t *testing.T) {
+		service, mock := newMockService(t)
+		now := time.Now()
+		mock.ExpectQuery("SELECT .* FROM games_scores WHERE id = \\$1").
+			WithArgs(scoreID).
+			WillReturnRows(sqlmock.NewRows(scoreColumns).AddRow(scoreID, now, now, 1, true, gameID, 2, 5000))
+		mock.ExpectQuery("SELECT .* FROM games_list WHERE id = \\$1").
+			WithArgs(gameID).
+			WillReturnRows(sqlmock.NewRows(gameColumns).AddRow(
+				gameID, now, now, 1, true, "Game One", "Description One", "logo1.png", "src1", "controls1", true, "points", "desc", 1, 1000000, 0,
+			))
+
+		w := httptest.NewRecorder()
+		service.UpdateScoreByIDHandler(w, newRequest(`{"score": -1}`))
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusUnprocessableEntity {
+			t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, resp.StatusCode)
+		}
+	})
+
+	t.Run("ERROR Edit conflict", func(t *testing.T) {
+		service, mock := newMockService(t)
+		now := time.Now()
+		mock.ExpectQuery("SELECT .* FROM games_scores WHERE id = \\$1").
+			WithArgs(scoreID).
+			WillReturnRows(sqlmock.NewRows(scoreColumns).AddRow(scoreID, now, now, 1, true, gameID, 2, 5000))
+		mock.ExpectQuery("SELECT .* FROM games_list WHERE id = \\$1").
+			WithArgs(gameID).
+			WillReturnRows(sqlmock.NewRows(gameColumns).AddRow(
+				gameID, now, now, 1, true, "Game One", "Description One", "logo1.png", "src1", "controls1", true, "points", "desc", 1, 1000000, 0,
+			))
+
+		mock.ExpectQuery("UPDATE games_scores").
+			WillReturnError(sql.ErrNoRows)
+
+		w := httptest.NewRecorder()
+		service.UpdateScoreByIDHandler(w, newRequest(`{"is_active": false}`))
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusConflict {
+			t.Errorf("expected status %d, got %d", http.StatusConflict, resp.StatusCode)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+}
+
+func TestDeleteScoreByIDHandler(t *testing.T) {
+	gameID := int64(1)
+	scoreID := int64(7)
+	ownerID := int64(2)
+	endpoint := fmt.Sprintf("/api/games/%d/scores/%d", gameID, scoreID)
+	scoreColumns := []string{"id", "created_at", "updated_at", "version", "is_active", "game_id", "user_id", "score"}
+
+	newRequest := func(user *auth.User) *http.Request {
+		req := httptest.NewRequest(http.MethodDelete, endpoint, nil)
+		req = param.InjectID(req, gameID)
+		req = param.Inject(req, "scoreId", strconv.FormatInt(scoreID, 10))
+		return auth.ContextSetUser(req, user)
+	}
+
+	t.Run("SUCCESS Owner deletes own score", func(t *testing.T) {
+		service, mock := newMockService(t)
+		now := time.Now()
+		mock.ExpectQuery("SELECT .* FROM games_scores WHERE id = \\$1").
+			WithArgs(scoreID).
+			WillReturnRows(sqlmock.NewRows(scoreColumns).AddRow(scoreID, now, now, 1, true, gameID, ownerID, 5000))
+
+		mock.ExpectExec("DELETE FROM games_scores WHERE id = \\$1").
+			WithArgs(scoreID, int32(1)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		w := httptest.NewRecorder()
+		service.DeleteScoreByIDHandler(w, newRequest(&auth.User{ID: ownerID}))
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("SUCCESS Moderator hides another user's score", func(t *testing.T) {
+		service, mock := newMockService(t)
+		service.Authorizer = &mockAuthorizer{permissions: auth.Permissions{auth.PermissionScoresModerate}}
+		now := time.Now()
+		mock.ExpectQuery("SELECT .* FROM games_scores WHERE id = \\$1").
+			WithArgs(scoreID).
+			WillReturnRows(sqlmock.NewRows(scoreColumns).AddRow(scoreID, now, now, 1, true, gameID, ownerID, 5000))
+
+		mock.ExpectQuery("UPDATE games_scores").
+			WithArgs(int64(5000), false, scoreID, int32(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"updated_at", "version"}).AddRow(now, 2))
+
+		w := httptest.NewRecorder()
+		service.DeleteScoreByIDHandler(w, newRequest(&auth.User{ID: 99, RoleID: auth.RoleAdmin}))
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("ERROR Owner deletes a score hidden by a moderator", func(t *testing.T) {
+		service, mock := newMockService(t)
+		service.Authorizer = &mockAuthorizer{permissions: auth.Permissions{auth.PermissionScoresModerate}}
+		now := time.Now()
+
+		// The moderator hides the score
+		mock.ExpectQuery("SELECT .* FROM games_scores WHERE id = \\$1").
+			WithArgs(scoreID).
+			WillReturnRows(sqlmock.NewRows(scoreColumns).AddRow(scoreID, now, now, 1, true, gameID, ownerID, 5000))
+		mock.ExpectQuery("UPDATE games_scores").
+			WithArgs(int64(5000), false, scoreID, int32(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"updated_at", "version"}).AddRow(now, 2))
+
+		w := httptest.NewRecorder()
+		service.DeleteScoreByIDHandler(w, newRequest(&auth.User{ID: 99, RoleID: auth.RoleAdmin}))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+
+		// The owner then tries to delete it, no DELETE may reach the database
+		mock.ExpectQuery("SELECT .* FROM games_scores WHERE id = \\$1").
+			WithArgs(scoreID).
+			WillReturnRows(sqlmock.NewRows(scoreColumns).AddRow(scoreID, now, now, 2, false, gameID, ownerID, 5000))
+
+		w = httptest.NewRecorder()
+		service.DeleteScoreByIDHandler(w, newRequest(&auth.User{ID: ownerID}))
+
+		if w.Code != http.StatusForbidden {
+			t.Errorf("expected status %d, got %d", http.StatusForbidden, w.Code)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("ERROR Score hidden while the owner deletes it", func(t *testing.T) {
+		service, mock := newMockService(t)
+		now := time.Now()
+		mock.ExpectQuery("SELECT .* FROM games_scores WHERE id = \\$1").
+			WithArgs(scoreID).
+			WillReturnRows(sqlmock.NewRows(scoreColumns).AddRow(scoreID, now, now, 1, true, gameID, ownerID, 5000))
+
+		mock.ExpectExec("DELETE FROM games_scores WHERE id = \\$1 AND version = \\$2 AND is_active = true").
+			WithArgs(scoreID, int32(1)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		w := httptest.NewRecorder()
+		service.DeleteScoreByIDHandler(w, newRequest(&auth.User{ID: ownerID}))
+
+		if w.Code != http.StatusConflict {
+			t.Errorf("expected status %d, got %d", http.StatusConflict, w.Code)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("ERROR User deletes another user's score", func(t *testing.T) {
+		service, mock := newMockService(t)
+		now := time.Now()
+		mock.ExpectQuery("SELECT .* FROM games_scores WHERE id = \\$1").
+			WithArgs(scoreID).
+			WillReturnRows(sqlmock.NewRows(scoreColumns).AddRow(scoreID, now, now, 1, true, gameID, ownerID, 5000))
+
+		w := httptest.NewRecorder()
+		service.DeleteScoreByIDHandler(w, newRequest(&auth.User{ID: 99, RoleID: auth.RoleBasic}))
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("expected status %d, got %d", http.StatusForbidden, resp.StatusCode)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("ERROR Permission lookup fails", func(t *testing.T) {
+		service, mock := newMockService(t)
+		service.Authorizer = &mockAuthorizer{err: database.ErrMockDatabase}
+		now := time.Now()
+		mock.ExpectQuery("SELECT .* FROM games_scores WHERE id = \\$1").
+			WithArgs(scoreID).
+			WillReturnRows(sqlmock.NewRows(scoreColumns).AddRow(scoreID, now, now, 1, true, gameID, ownerID, 5000))
+
+		w := httptest.NewRecorder()
+		service.DeleteScoreByIDHandler(w, newRequest(&auth.User{ID: 99}))
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusInternalServerError {
+			t.Errorf("expected status %d, got %d", http.StatusInternalServerError, resp.StatusCode)
+		}
+	})
+
+	t.Run("ERROR Score not found", func(t *testing.T) {
+		service, mock := newMockService(t)
+		mock.ExpectQuery("SELECT .* FROM games_scores WHERE id = \\$1").
+			WithArgs(scoreID).
+			WillReturnError(sql.ErrNoRows)
+
+		w := httptest.NewRecorder()
+		service.DeleteScoreByIDHandler(w, newRequest(&auth.User{ID: ownerID}))
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("expected status %d, got %d", http.StatusNotFound, resp.StatusCode)
+		}
+	})
+}
+
+func TestGetUserRankByGameIDHandler(t *testing.T) {
+	gameID := int64(1)
+	endpoint := fmt.Sprintf("/api/games/%d/scores/user/rank", gameID)
+	user := &auth.User{ID: 2}
+	gameColumns := []string{
+		"id", "created_at", "updated_at", "version", "is_active",
+		"name", "description", "logo", "src", "controls", "has_score", "score_type", "score_order", "score_min", "score_max", "score_rate_max",
+	}
+	rankColumns := []string{"id", "game_id", "user_id", "name", "profile_picture", "score", "created_at", "updated_at", "version", "rank", "percent_rank", "total"}
+
+	t.Run("SUCCESS Retrieved user rank", func(t *testing.T) {
+		service, mock := newMockService(t)
+		now := time.Now()
+		mock.ExpectQuery("SELECT .* FROM games_list WHERE id = \\$1").
+			WithArgs(gameID).
+			WillReturnRows(sqlmock.NewRows(gameColumns).AddRow(
+				gameID, now, now, 1, true, "Game One", "Description One", "logo1.png", "src1", "controls1", true, "points", "desc", 1, 1000000, 0,
+			))
+
+		mock.ExpectQuery("WITH best AS").
+			WithArgs(gameID, time.Time{}, user.ID, 1).
+			WillReturnRows(sqlmock.NewRows(rankColumns).
+				AddRow(1, gameID, 3, "User Three", "", 600, now, now, 1, 1, 0.0, 3).
+				AddRow(2, gameID, user.ID, "User Two", "", 500, now, now, 1, 2, 0.5, 3).
+				AddRow(3, gameID, 4, "User Four", "", 400, now, now, 1, 3, 1.0, 3))
+
+		req := httptest.NewRequest(http.MethodGet, endpoint+"?neighbors=1", nil)
+		req = param.InjectID(req, gameID)
+		req = auth.ContextSetUser(req, user)
+		w := httptest.NewRecorder()
+
+		service.GetUserRankByGameIDHandler(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
+		}
+
+		var jsonResponse struct {
+			Rank PlayerRank `json:"rank"`
+		}
+
+		if err := json.NewDecoder(resp.Body).Decode(&jsonResponse); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+
+		if jsonResponse.Rank.Best.Rank != 2 || jsonResponse.Rank.Percentile != 50 {
+			t.Errorf("expected rank 2 at the 50th percentile, got %+v", jsonResponse.Rank)
+		}
+		if len(jsonResponse.Rank.Above) != 1 || len(jsonResponse.Rank.Below) != 1 {
+			t.Errorf("expected one neighbor on each side, got %d above and %d below", len(jsonResponse.Rank.Above), len(jsonResponse.Rank.Below))
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("ERROR User has no ranked score", func(t *testing.T) {
+		service, mock := newMockService(t)
+		now := time.Now()
+		mock.ExpectQuery("SELECT .* FROM games_list WHERE id = \\$1").
+			WithArgs(gameID).
+			WillReturnRows(sqlmock.NewRows(gameColumns).AddRow(
+				gameID, now, now, 1, true, "Game One", "Description One", "logo1.png", "src1", "controls1", true, "points", "desc", 1, 1000000, 0,
+			))
+
+		mock.ExpectQuery("WITH best AS").
+			WithArgs(gameID, time.Time{}, user.ID, 5).
+			WillReturnRows(sqlmock.NewRows(rankColumns))
+
+		req := httptest.NewRequest(http.MethodGet, endpoint, nil)
+		req = param.InjectID(req, gameID)
+		req = auth.ContextSetUser(req, user)
+		w := httptest.NewRecorder()
+
+		service.GetUserRankByGameIDHandler(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("expected status %d, got %d", http.StatusNotFound, resp.StatusCode)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("ERROR Failed neighbors validation check", func(t *testing.T) {
+		service, _ := newMockService(t)
+
+		req := httptest.NewRequest(http.MethodGet, endpoint+"?neighbors=100", nil)
+		req = param.InjectID(req, gameID)
+		req = auth.ContextSetUser(req, user)
+		w := httptest.NewRecorder()
+
+		service.GetUserRankByGameIDHandler(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusUnprocessableEntity {
+			t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, resp.StatusCode)
+		}
+	})
+}
+
+func TestPostPlaySessionHandler(t *testing.T) {
+	gameID := int64(1)
+	endpoint := fmt.Sprintf("/api/games/%d/sessions", gameID)
+	user := &auth.User{ID: 2}
+	gameColumns := []string{
+		"id", "created_at", "updated_at", "version", "is_active",
+		"name", "description", "logo", "src", "controls", "has_score", "score_type", "score_order", "score_min", "score_max", "score_rate_max",
+	}
+
+	t.Run("SUCCESS Play session started", func(t *testing.T) {
+		service, mock := newMockService(t)
+		now := time.Now()
+		mock.ExpectQuery("SELECT .* FROM games_list WHERE id = \\$1").
+			WithArgs(gameID).
+			WillReturnRows(sqlmock.NewRows(gameColumns).AddRow(
+				gameID, now, now, 1, true, "Game One", "Description One", "logo1.png", "src1", "controls1", true, "points", "desc", 1, 1000000, 0,
+			))
+
+		mock.ExpectExec("INSERT INTO games_play_sessions").
+			WithArgs(sqlmock.AnyArg(), gameID, user.ID, sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		req := httptest.NewRequest(http.MethodPost, endpoint, nil)
+		req = param.InjectID(req, gameID)
+		req = auth.ContextSetUser(req, user)
+		w := httptest.NewRecorder()
+
+		service.PostPlaySessionHandler(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusCreated {
+			t.Errorf("expected status %d, got %d", http.StatusCreated, resp.StatusCode)
+		}
+
+		var jsonResponse struct {
+			Session PlaySession `json:"session"`
+		}
+
+		if err := json.NewDecoder(resp.Body).Decode(&jsonResponse); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+
+		session, err := ParsePlaySession(jsonResponse.Session.Ticket, service.Config.SessionSecret)
+		if err != nil {
+			t.Fatalf("expected a valid ticket, got %v", err)
+		}
+		if session.GameID != gameID || session.UserID != user.ID {
+			t.Errorf("expected ticket for game %d and user %d, got %+v", gameID, user.ID, session)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("ERROR Game does not track scores", func(t *testing.T) {
+		service, mock := newMockService(t)
+		now := time.Now()
+		mock.ExpectQuery("SELECT .* FROM games_list WHERE id = \\$1").
+			WithArgs(gameID).
+			WillReturnRows(sqlmock.NewRows(gameColumns).AddRow(
+				gameID, now, now, 1, true, "Game One", "Description One", "logo1.png", "src1", "controls1", false, "points", "desc", 1, 1000000, 0,
+			))
+
+		req := httptest.NewRequest(http.MethodPost, endpoint, nil)
+		req = param.InjectID(req, gameID)
+		req = auth.ContextSetUser(req, user)
+		w := httptest.NewRecorder()
+
+		service.PostPlaySessionHandler(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusInternalServerError {
+			t.Errorf("expected status %d, got %d", http.StatusInternalServerError, resp.StatusCode)
+		}
+	})
+
+	t.Run("ERROR DB error game not found", func(t *testing.T) {
+		service, mock := newMockService(t)
+		mock.ExpectQuery("SELECT .* FROM games_list WHERE id = \\$1").
+			WithArgs(gameID).
+			WillReturnError(database.ErrRecordNotFound)
+
+		req := httptest.NewRequest(http.MethodPost, endpoint, nil)
+		req = param.InjectID(req, gameID)
+		req = auth.ContextSetUser(req, user)
+		w := httptest.NewRecorder()
+
+		service.PostPlaySessionHandler(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("expected status %d, got %d", http.StatusNotFound, resp.StatusCode)
+		}
+	})
+}
+
+func TestPostGameVersionHandler(t *testing.T) {
+	gameID := int64(1)
+	endpoint := fmt.Sprintf("/api/games/%d/versions", gameID)
+	user := &auth.User{ID: 2}
+	gameColumns := []string{
+		"id", "created_at", "updated_at", "version", "is_active",
+		"name", "description", "logo", "src", "controls", "has_score", "score_type", "score_order", "score_min", "score_max", "score_rate_max",
+	}
+
+	newUploadRequest := func(t *testing.T, bundle []byte, activate string) *http.Request {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		if bundle != nil {
+			fw, err := mw.CreateFormFile("bundle", "game.zip")
+			if err != nil {
+				t.Fatalf("failed to create form file: %v", err)
+			}
+			fw.Write(bundle)
+		}
+		if activate != "" {
+			mw.WriteField("activate", activate)
+		}
+		mw.Close()
+
+		req := httptest.NewRequest(http.MethodPost, endpoint, &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		req = param.InjectID(req, gameID)
+		return auth.ContextSetUser(req, user)
+	}
+
+	t.Run("SUCCESS Version uploaded and activated", func(t *testing.T) {
+		service, mock := newMockService(t)
+		now := time.Now()
+		mock.ExpectQuery("SELECT .* FROM games_list WHERE id = \\$1").
+			WithArgs(gameID).
+			WillReturnRows(sqlmock.NewRows(gameColumns).AddRow(
+				gameID, now, now, 1, true, "Game One", "Description One", "logo1.png", "src1", "controls1", true, "points", "desc", 1, 1000000, 0,
+			))
+		mock.ExpectQuery("INSERT INTO game_versions").
+			WithArgs(gameID, sqlmock.AnyArg(), sqlmock.AnyArg(), 2, &user.ID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "version", "is_active", "number"}).
+				AddRow(1, now, now, 1, true, 3))
+		mock.ExpectQuery("UPDATE games_list").
+			WithArgs("Game One", "Description One", "logo1.png", "/play/1/3/", "controls1", true, "points", "desc", 1, 1000000, 0, true, gameID, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"updated_at", "version"}).AddRow(now, 2))
+
+		bundle := newBundle(t, map[string]string{"index.html": "<canvas></canvas>", "game.js": "run()"})
+		w := httptest.NewRecorder()
+
+		service.PostGameVersionHandler(w, newUploadRequest(t, bundle, ""))
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d", http.StatusCreated, resp.StatusCode)
+		}
+
+		var jsonResponse struct {
+			Version GameVersion `json:"version"`
+			Game    Game        `json:"game"`
+		}
+
+		if err := json.NewDecoder(resp.Body).Decode(&jsonResponse); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+
+		if jsonResponse.Game.Src != "/play/1/3/" || jsonResponse.Version.Src != "/play/1/3/" {
+			t.Errorf("expected game to point at version 3, got %+v", jsonResponse)
+		}
+
+		obj, err := service.Storage.Get(context.Background(), bundleKey(jsonResponse.Version.Checksum, "index.html"))
+		if err != nil {
+			t.Fatalf("expected bundle to be stored, got %v", err)
+		}
+		obj.Body.Close()
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("SUCCESS Version uploaded without activating", func(t *testing.T) {
+		service, mock := newMockService(t)
+		now := time.Now()
+		mock.ExpectQuery("SELECT .* FROM games_list WHERE id = \\$1").
+			WithArgs(gameID).
+			WillReturnRows(sqlmock.NewRows(gameColumns).AddRow(
+				gameID, now, now, 1, true, "Game One", "Description One", "logo1.png", "src1", "controls1", true, "points", "desc", 1, 1000000, 0,
+			))
+		mock.ExpectQuery("INSERT INTO game_versions").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "version", "is_active", "number"}).
+				AddRow(1, now, now, 1, true, 3))
+
+		bundle := newBundle(t, map[string]string{"index.html": "<canvas></canvas>"})
+		w := httptest.NewRecorder()
+
+		service.PostGameVersionHandler(w, newUploadRequest(t, bundle, "false"))
+
+		if w.Code != http.StatusCreated {
+			t.Errorf("expected status %d, got %d", http.StatusCreated, w.Code)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	tests := []struct {
+		name     string
+		bundle   []byte
+		activate string
+		message  string
+	}{
+		{"ERROR Missing bundle", nil, "", "must be provided"},
+		{"ERROR Bundle missing index.html", newBundle(t, map[string]string{"game.js": "run()"}), "", "must contain index.html"},
+		{"ERROR Bundle too large", bytes.Repeat([]byte("a"), 2<<20), "", "must not be larger than"},
+		{"ERROR Invalid activate value", newBundle(t, map[string]string{"index.html": ""}), "maybe", "must be a boolean value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, mock := newMockService(t)
+			now := time.Now()
+			mock.ExpectQuery("SELECT .* FROM games_list WHERE id = \\$1").
+				WithArgs(gameID).
+				WillReturnRows(sqlmock.NewRows(gameColumns).AddRow(
+					gameID, now, now, 1, true, "Game One", "Description One", "logo1.png", "src1", "controls1", true, "points", "desc", 1, 1000000, 0,
+				))
+
+			w := httptest.NewRecorder()
+
+			service.PostGameVersionHandler(w, newUploadRequest(t, tt.bundle, tt.activate))
+
+			if w.Code != http.StatusUnprocessableEntity {
+				t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, w.Code)
+			}
+			if !strings.Contains(w.Body.String(), tt.message) {
+				t.Errorf("expected error message %q, got %s", tt.message, w.Body.String())
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestActivateGameVersionHandler(t *testing.T) {
+	gameID := int64(1)
+	endpoint := fmt.Sprintf("/api/games/%d/versions/2/activate", gameID)
+	gameColumns := []string{
+		"id", "created_at", "updated_at", "version", "is_active",
+		"name", "description", "logo", "src", "controls", "has_score", "score_type", "score_order", "score_min", "score_max", "score_rate_max",
+	}
+	versionColumns := []string{"id", "created_at", "updated_at", "version", "is_active", "game_id", "number", "checksum", "size", "file_count", "uploaded_by"}
+
+	t.Run("SUCCESS Version activated", func(t *testing.T) {
+		service, mock := newMockService(t)
+		now := time.Now()
+		mock.ExpectQuery("SELECT .* FROM game_versions WHERE game_id = \\$1 AND number = \\$2").
+			WithArgs(gameID, 2).
+			WillReturnRows(sqlmock.NewRows(versionColumns).AddRow(5, now, now, 1, true, gameID, 2, "abc", 100, 3, nil))
+		mock.ExpectQuery("SELECT .* FROM games_list WHERE id = \\$1").
+			WithArgs(gameID).
+			WillReturnRows(sqlmock.NewRows(gameColumns).AddRow(
+				gameID, now, now, 1, true, "Game One", "Description One", "logo1.png", "/play/1/3/", "controls1", true, "points", "desc", 1, 1000000, 0,
+			))
+		mock.ExpectQuery("UPDATE games_list").
+			WithArgs("Game One", "Description One", "logo1.png", "/play/1/2/", "controls1", true, "points", "desc", 1, 1000000, 0, true, gameID, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"updated_at", "version"}).AddRow(now, 2))
+
+		req := httptest.NewRequest(http.MethodPut, endpoint, nil)
+		req = param.InjectID(req, gameID)
+		req = param.Inject(req, "version", "2")
+		req = auth.ContextSetUser(req, admin)
+		w := httptest.NewRecorder()
+
+		service.ActivateGameVersionHandler(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("ERROR Version not found", func(t *testing.T) {
+		service, mock := newMockService(t)
+		mock.ExpectQuery("SELECT .* FROM game_versions").
+			WithArgs(gameID, 9).
+			WillReturnError(sql.ErrNoRows)
+
+		req := httptest.NewRequest(http.MethodPut, endpoint, nil)
+		req = param.InjectID(req, gameID)
+		req = param.Inject(req, "version", "9")
+		req = auth.ContextSetUser(req, admin)
+		w := httptest.NewRecorder()
+
+		service.ActivateGameVersionHandler(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+		}
+	})
+}
+
+func TestPlayGameHandler(t *testing.T) {
+	gameID := int64(1)
+	versionColumns := []string{"id", "created_at", "updated_at", "version", "is_active", "game_id", "number", "checksum", "size", "file_count", "uploaded_by"}
+
+	tests := []struct {
+		name        string
+		filepath    string
+		isActive    bool
+		status      int
+		contentType string
+	}{
+		{"SUCCESS Serves index.html", "/", true, http.StatusOK, "text/html"},
+		{"SUCCESS Serves nested asset", "/assets/game.js", true, http.StatusOK, "text/javascript"},
+		{"ERROR File not found", "/missing.js", true, http.StatusNotFound, ""},
+		{"ERROR Directory", "/assets", true, http.StatusNotFound, ""},
+		{"ERROR Path traversal", "/../../service.go", true, http.StatusNotFound, ""},
+		{"ERROR Version removed", "/", false, http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, mock := newMockService(t)
+			now := time.Now()
+
+			bundle, err := unpackBundle(context.Background(), newBundle(t, map[string]string{
+				"index.html":     "<canvas></canvas>",
+				"assets/game.js": "run()",
+			}), service.Storage)
+			if err != nil {
+				t.Fatalf("failed to unpack bundle: %v", err)
+			}
+
+			mock.ExpectQuery("SELECT .* FROM game_versions WHERE game_id = \\$1 AND number = \\$2").
+				WithArgs(gameID, 1).
+				WillReturnRows(sqlmock.NewRows(versionColumns).AddRow(5, now, now, 1, tt.isActive, gameID, 1, bundle.checksum, 100, 2, nil))
+
+			req := httptest.NewRequest(http.MethodGet, "/play/1/1"+tt.filepath, nil)
+			req = param.InjectID(req, gameID)
+			req = param.Inject(req, "version", "1")
+			req = param.Inject(req, "filepath", tt.filepath)
+			w := httptest.NewRecorder()
+
+			service.PlayGameHandler(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, w.Code)
+			}
+			if tt.status == http.StatusOK {
+				if !strings.HasPrefix(w.Header().Get("Content-Type"), tt.contentType) {
+					t.Errorf("expected content type %s, got %s", tt.contentType, w.Header().Get("Content-Type"))
+				}
+				if w.Header().Get("Content-Security-Policy") != PlayContentSecurityPolicy {
+					t.Errorf("expected sandboxed CSP, got %s", w.Header().Get("Content-Security-Policy"))
+				}
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unmet expectations: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package games
+
+import (
+	"time"
+
+	"github.com/navazjm/pixelarcade/internal/webapp/utils/validator"
+)
+
+type Window string
+
+const (
+	WindowDaily   Window = "daily"
+	WindowWeekly  Window = "weekly"
+	WindowMonthly Window = "monthly"
+	WindowAll     Window = "all"
+)
+
+// Start returns the beginning of the window containing now, computed in the given
+// location so e.g. daily boards reset at local midnight. Weeks start on Monday. The
+// all-time window returns the zero time.
+func (w Window) Start(now time.Time, loc *time.Location) time.Time {
+	now = now.In(loc)
+	year, month, day := now.Date()
+
+	switch w {
+	case WindowDaily:
+		return time.Date(year, month, day, 0, 0, 0, 0, loc)
+	case WindowWeekly:
+		daysSinceMonday := (int(now.Weekday()) + 6) % 7
+		return time.Date(year, month, day-daysSinceMonday, 0, 0, 0, 0, loc)
+	case WindowMonthly:
+		return time.Date(year, month, 1, 0, 0, 0, 0, loc)
+	default:
+		return time.Time{}
+	}
+}
+
+type LeaderboardFilters struct {
+	Window Window `json:"window"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+}
+
+func ValidateWindow(v *validator.Validator, w Window) {
+	v.Check(validator.PermittedValue(w, WindowDaily, WindowWeekly, WindowMonthly, WindowAll), "window", "must be one of daily, weekly, monthly or all")
+}
+
+func ValidateLeaderboardFilters(v *validator.Validator, f LeaderboardFilters) {
+	ValidateWindow(v, f.Window)
+	v.Check(f.Limit > 0, "limit", "must be greater than zero")
+	v.Check(f.Limit <= 100, "limit", "must be a maximum of 100")
+	v.Check(f.Offset >= 0, "offset", "must be zero or greater")
+	v.Check(f.Offset <= 10_000, "offset", "must be a maximum of 10000")
+}
+
+type RankedScore struct {
+	Score
+	Rank int64 `json:"rank"`
+}
+
+// PlayerRank describes where a player's best score places them on a leaderboard,
+// along with the entries immediately above and below them.
+type PlayerRank struct {
+	Best       *RankedScore   `json:"best"`
+	Percentile float64        `json:"percentile"`
+	Total      int64          `json:"total"`
+	Above      []*RankedScore `json:"above"`
+	Below      []*RankedScore `json:"below"`
+}
+
+func ValidateNeighbors(v *validator.Validator, neighbors int) {
+	v.Check(neighbors >= 0, "neighbors", "must be zero or greater")
+	v.Check(neighbors <= 25, "neighbors", "must be a maximum of 25")
+}
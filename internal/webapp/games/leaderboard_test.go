@@ -0,0 +1,70 @@
+package games
+
+import (
+	"testing"
+	"time"
+
+	"github.com/navazjm/pixelarcade/internal/webapp/utils/validator"
+)
+
+func TestWindow_Start(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+
+	// Thursday 2024-03-14 02:30 UTC is still Wednesday evening in New York
+	now := time.Date(2024, 3, 14, 2, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		window   Window
+		expected time.Time
+	}{
+		{WindowDaily, time.Date(2024, 3, 13, 0, 0, 0, 0, loc)},
+		{WindowWeekly, time.Date(2024, 3, 11, 0, 0, 0, 0, loc)},
+		{WindowMonthly, time.Date(2024, 3, 1, 0, 0, 0, 0, loc)},
+		{WindowAll, time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.window), func(t *testing.T) {
+			if got := tt.window.Start(now, loc); !got.Equal(tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+
+	// Sundays belong to the week that started on the previous Monday
+	sunday := time.Date(2024, 3, 17, 12, 0, 0, 0, time.UTC)
+	if got := WindowWeekly.Start(sunday, time.UTC); !got.Equal(time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected week to start on Monday 2024-03-11, got %v", got)
+	}
+}
+
+func TestValidateLeaderboardFilters(t *testing.T) {
+	tests := []struct {
+		name     string
+		filters  LeaderboardFilters
+		errorKey string
+	}{
+		{"Valid filters", LeaderboardFilters{Window: WindowWeekly, Limit: 50, Offset: 0}, ""},
+		{"Unknown window", LeaderboardFilters{Window: "yearly", Limit: 50}, "window"},
+		{"Zero limit", LeaderboardFilters{Window: WindowAll, Limit: 0}, "limit"},
+		{"Limit too large", LeaderboardFilters{Window: WindowAll, Limit: 101}, "limit"},
+		{"Negative offset", LeaderboardFilters{Window: WindowAll, Limit: 50, Offset: -1}, "offset"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateLeaderboardFilters(v, tt.filters)
+
+			if tt.errorKey == "" && !v.Valid() {
+				t.Errorf("expected validation to pass, but got errors: %v", v.Errors)
+			}
+			if _, ok := v.Errors[tt.errorKey]; tt.errorKey != "" && !ok {
+				t.Errorf("expected validation error for %q, got %v", tt.errorKey, v.Errors)
+			}
+		})
+	}
+}
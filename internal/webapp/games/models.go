@@ -4,8 +4,10 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"time"
 
+	"github.com/navazjm/pixelarcade/internal/webapp/auth"
 	"github.com/navazjm/pixelarcade/internal/webapp/utils/database"
 )
 
@@ -21,10 +23,23 @@ type Model struct {
 
 func (m Model) InsertGame(game *Game) error {
 	query := `
-        INSERT INTO games_list (name, description, logo, src, controls, has_score, is_active) 
-        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        INSERT INTO games_list (name, description, logo, src, controls, has_score, score_type, score_order, score_min, score_max, score_rate_max, is_active) 
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
         RETURNING id, created_at, updated_at, version`
-	args := []any{game.Name, game.Description, game.Logo, game.Src, game.Controls, game.HasScore, game.IsActive}
+	args := []any{
+		game.Name,
+		game.Description,
+		game.Logo,
+		game.Src,
+		game.Controls,
+		game.HasScore,
+		game.ScoreType,
+		game.ScoreOrder,
+		game.ScoreMin,
+		game.ScoreMax,
+		game.ScoreRateMax,
+		game.IsActive,
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
@@ -62,6 +77,11 @@ func (m Model) GetGames() ([]*Game, error) {
 			&game.Src,
 			&game.Controls,
 			&game.HasScore,
+			&game.ScoreType,
+			&game.ScoreOrder,
+			&game.ScoreMin,
+			&game.ScoreMax,
+			&game.ScoreRateMax,
 		)
 		if err != nil {
 			return nil, err
@@ -103,6 +123,11 @@ func (m Model) GetGameByID(id int64) (*Game, error) {
 		&game.Src,
 		&game.Controls,
 		&game.HasScore,
+		&game.ScoreType,
+		&game.ScoreOrder,
+		&game.ScoreMin,
+		&game.ScoreMax,
+		&game.ScoreRateMax,
 	)
 
 	if err != nil {
@@ -127,8 +152,8 @@ func (m Model) ExistsGameByID(id int64) (bool, error) {
 func (m Model) UpdateGameByID(game *Game) error {
 	query := `
         UPDATE games_list
-        SET name = $1, description = $2, logo = $3, src = $4, controls = $5, has_score = $6, is_active = $7, version = version + 1, updated_at = now()
-        WHERE id = $8 and version = $9
+        SET name = $1, description = $2, logo = $3, src = $4, controls = $5, has_score = $6, score_type = $7, score_order = $8, score_min = $9, score_max = $10, score_rate_max = $11, is_active = $12, version = version + 1, updated_at = now()
+        WHERE id = $13 and version = $14
         RETURNING updated_at, version`
 
 	args := []any{
@@ -138,6 +163,11 @@ func (m Model) UpdateGameByID(game *Game) error {
 		game.Src,
 		game.Controls,
 		game.HasScore,
+		game.ScoreType,
+		game.ScoreOrder,
+		game.ScoreMin,
+		game.ScoreMax,
+		game.ScoreRateMax,
 		game.IsActive,
 		game.ID,
 		game.Version,
@@ -207,19 +237,26 @@ func (m Model) InsertScore(score *Score) error {
 	return m.DB.QueryRowContext(ctx, query, args...).Scan(&score.ID, &score.CreatedAt, &score.UpdatedAt, &score.Version)
 }
 
-func (m *Model) GetScoresByGameID(gameID int64) ([]*Score, error) {
-	query := `
-        SELECT s.id, s.game_id, s.user_id, u.name, u.profile_picture, s.score, s.created_at, s.updated_at, s.version
-        FROM games_scores s
-        JOIN auth_user u ON s.user_id = u.uid
-        WHERE s.game_id = $1
-        ORDER BY s.score DESC
-        LIMIT 50`
+// GetScoresByGameID returns a page of the leaderboard for scores submitted since the
+// given time, best first according to the game's score order. Only each user's best
+// active score is ranked, ties going to whoever reached the score first.
+func (m *Model) GetScoresByGameID(gameID int64, order ScoreOrder, since time.Time, filters LeaderboardFilters) ([]*Score, error) {
+	query := fmt.Sprintf(`
+        SELECT s.id, s.game_id, s.user_id, u.name, COALESCE(u.profile_picture, ''), s.score, s.created_at, s.updated_at, s.version
+        FROM (
+            SELECT DISTINCT ON (user_id) *
+            FROM games_scores
+            WHERE game_id = $1 AND is_active = true AND created_at >= $2
+            ORDER BY user_id, score %[1]s, created_at
+        ) s
+        JOIN auth_users u ON s.user_id = u.id
+        ORDER BY s.score %[1]s, s.created_at
+        LIMIT $3 OFFSET $4`, order.SQL())
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	rows, err := m.DB.QueryContext(ctx, query, gameID)
+	rows, err := m.DB.QueryContext(ctx, query, gameID, since, filters.Limit, filters.Offset)
 	if err != nil {
 		return nil, err
 	}
@@ -242,6 +279,7 @@ func (m *Model) GetScoresByGameID(gameID int64) ([]*Score, error) {
 		if err != nil {
 			return nil, err
 		}
+		score.UserProfilePicture = auth.ProfilePictureURL(score.UserID, score.UserProfilePicture)
 		scores = append(scores, &score)
 	}
 
@@ -252,13 +290,99 @@ func (m *Model) GetScoresByGameID(gameID int64) ([]*Score, error) {
 	return scores, nil
 }
 
-func (m *Model) GetUsersScoresByGameID(gameID int64, userID int64) ([]*Score, error) {
-	query := `
-        SELECT s.*, u.name, u.profile_picture
+// GetUserRankByGameID ranks every player's best active score submitted since the given
+// time, using the same ordering as GetScoresByGameID, and returns the user's entry
+// along with up to neighbors entries on either side. Returns ErrRecordNotFound if the
+// user has no ranked score.
+func (m *Model) GetUserRankByGameID(gameID int64, userID int64, order ScoreOrder, since time.Time, neighbors int) (*PlayerRank, error) {
+	query := fmt.Sprintf(`
+        WITH best AS (
+            SELECT DISTINCT ON (user_id) *
+            FROM games_scores
+            WHERE game_id = $1 AND is_active = true AND created_at >= $2
+            ORDER BY user_id, score %[1]s, created_at
+        ), ranked AS (
+            SELECT b.id, b.game_id, b.user_id, u.name, COALESCE(u.profile_picture, '') AS profile_picture, b.score, b.created_at, b.updated_at, b.version,
+                ROW_NUMBER() OVER (ORDER BY b.score %[1]s, b.created_at) AS rank,
+                PERCENT_RANK() OVER (ORDER BY b.score %[1]s, b.created_at) AS percent_rank,
+                COUNT(*) OVER () AS total
+            FROM best b
+            JOIN auth_users u ON b.user_id = u.id
+        ), player AS (
+            SELECT rank FROM ranked WHERE user_id = $3
+        )
+        SELECT r.id, r.game_id, r.user_id, r.name, r.profile_picture, r.score, r.created_at, r.updated_at, r.version, r.rank, r.percent_rank, r.total
+        FROM ranked r, player p
+        WHERE r.rank BETWEEN p.rank - $4 AND p.rank + $4
+        ORDER BY r.rank`, order.SQL())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, gameID, since, userID, neighbors)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rank := &PlayerRank{
+		Above: []*RankedScore{},
+		Below: []*RankedScore{},
+	}
+	for rows.Next() {
+		var entry RankedScore
+		var percentRank float64
+		err := rows.Scan(
+			&entry.ID,
+			&entry.GameID,
+			&entry.UserID,
+			&entry.UserName,
+			&entry.UserProfilePicture,
+			&entry.Score.Score,
+			&entry.CreatedAt,
+			&entry.UpdatedAt,
+			&entry.Version,
+			&entry.Rank,
+			&percentRank,
+			&rank.Total,
+		)
+		if err != nil {
+			return nil, err
+		}
+		entry.IsActive = true
+		entry.UserProfilePicture = auth.ProfilePictureURL(entry.UserID, entry.UserProfilePicture)
+
+		switch {
+		case entry.UserID == userID:
+			rank.Best = &entry
+			// PERCENT_RANK is 0 for first place, so flip it to report the share of
+			// players ranked at or below the user
+			rank.Percentile = (1 - percentRank) * 100
+		case rank.Best == nil:
+			rank.Above = append(rank.Above, &entry)
+		default:
+			rank.Below = append(rank.Below, &entry)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if rank.Best == nil {
+		return nil, database.ErrRecordNotFound
+	}
+
+	return rank, nil
+}
+
+func (m *Model) GetUsersScoresByGameID(gameID int64, userID int64, order ScoreOrder) ([]*Score, error) {
+	query := fmt.Sprintf(`
+        SELECT s.*, u.name, COALESCE(u.profile_picture, '')
         FROM games_scores s
-        JOIN auth_users u ON s.user_id = u.uid
+        JOIN auth_users u ON s.user_id = u.id
         WHERE s.game_id = $1 and s.user_id = $2
-        ORDER BY s.score DESC`
+        ORDER BY s.score %s`, order.SQL())
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
@@ -287,6 +411,7 @@ func (m *Model) GetUsersScoresByGameID(gameID int64, userID int64) ([]*Score, er
 		if err != nil {
 			return nil, err
 		}
+		score.UserProfilePicture = auth.ProfilePictureURL(score.UserID, score.UserProfilePicture)
 		scores = append(scores, &score)
 	}
 
@@ -297,6 +422,44 @@ func (m *Model) GetUsersScoresByGameID(gameID int64, userID int64) ([]*Score, er
 	return scores, nil
 }
 
+func (m Model) GetScoreByID(id int64) (*Score, error) {
+	if id < 1 {
+		return nil, database.ErrRecordNotFound
+	}
+
+	query := `
+        SELECT *
+        FROM games_scores
+        WHERE id = $1`
+
+	var score Score
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&score.ID,
+		&score.CreatedAt,
+		&score.UpdatedAt,
+		&score.Version,
+		&score.IsActive,
+		&score.GameID,
+		&score.UserID,
+		&score.Score,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, database.ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &score, nil
+}
+
 func (m Model) ExistsScoreByID(id int64) (bool, error) {
 	query := `SELECT EXISTS(SELECT 1 FROM games_scores WHERE id = $1)`
 	var exists bool
@@ -329,19 +492,21 @@ func (m Model) UpdateScoreByID(score *Score) error {
 	return nil
 }
 
-func (m Model) DeleteScoreByID(id int64) error {
+// DeleteScoreByID deletes the score if it is still active and at the given version.
+// Returns ErrEditConflict otherwise, e.g. if a moderator hid it in the meantime.
+func (m Model) DeleteScoreByID(id int64, version int32) error {
 	if id < 1 {
 		return database.ErrRecordNotFound
 	}
 
 	query := `
         DELETE FROM games_scores
-        WHERE id = $1`
+        WHERE id = $1 AND version = $2 AND is_active = true`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	result, err := m.DB.ExecContext(ctx, query, id)
+	result, err := m.DB.ExecContext(ctx, query, id, version)
 	if err != nil {
 		return err
 	}
@@ -352,8 +517,198 @@ func (m Model) DeleteScoreByID(id int64) error {
 	}
 
 	if rowsAffected == 0 {
-		return database.ErrRecordNotFound
+		return database.ErrEditConflict
+	}
+
+	return nil
+}
+
+//==============================================================================
+//
+// Play Sessions
+//
+//==============================================================================
+
+func (m Model) InsertPlaySession(session *PlaySession) error {
+	query := `
+        INSERT INTO games_play_sessions (nonce, game_id, user_id, started_at, expiry)
+        VALUES ($1, $2, $3, $4, $5)`
+
+	args := []any{session.Nonce, session.GameID, session.UserID, session.StartedAt, session.Expiry}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, args...)
+	return err
+}
+
+// ConsumePlaySession marks the session as used so its ticket can't be redeemed again.
+// Returns ErrUsedTicket if the session doesn't exist or was already used.
+func (m Model) ConsumePlaySession(session *PlaySession) error {
+	query := `
+        UPDATE games_play_sessions
+        SET used_at = now()
+        WHERE nonce = $1 AND game_id = $2 AND user_id = $3 AND used_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, session.Nonce, session.GameID, session.UserID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrUsedTicket
+	}
+
+	return nil
+}
+
+func (m Model) InsertScoreRejection(rejection *ScoreRejection) error {
+	query := `
+        INSERT INTO games_score_rejections (game_id, user_id, score, reason, nonce)
+        VALUES ($1, $2, $3, $4, NULLIF($5, ''))
+        RETURNING id, created_at`
+
+	args := []any{rejection.GameID, rejection.UserID, rejection.Score, rejection.Reason, rejection.Nonce}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&rejection.ID, &rejection.CreatedAt)
+}
+
+//==============================================================================
+//
+// Game Versions
+//
+//==============================================================================
+
+// InsertGameVersion records an uploaded bundle as the game's next version number.
+// Returns ErrEditConflict if another version was uploaded at the same time.
+func (m Model) InsertGameVersion(gameVersion *GameVersion) error {
+	query := `
+        INSERT INTO game_versions (game_id, number, checksum, size, file_count, uploaded_by)
+        VALUES ($1, (SELECT COALESCE(MAX(number), 0) + 1 FROM game_versions WHERE game_id = $1), $2, $3, $4, $5)
+        RETURNING id, created_at, updated_at, version, is_active, number`
+
+	args := []any{gameVersion.GameID, gameVersion.Checksum, gameVersion.Size, gameVersion.FileCount, gameVersion.UploadedBy}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(
+		&gameVersion.ID,
+		&gameVersion.CreatedAt,
+		&gameVersion.UpdatedAt,
+		&gameVersion.Version,
+		&gameVersion.IsActive,
+		&gameVersion.Number,
+	)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "game_versions_game_id_number_key"`:
+			return database.ErrEditConflict
+		default:
+			return err
+		}
 	}
 
+	gameVersion.Src = PlaySrc(gameVersion.GameID, gameVersion.Number)
 	return nil
 }
+
+func (m Model) GetGameVersionsByGameID(gameID int64) ([]*GameVersion, error) {
+	query := `
+        SELECT id, created_at, updated_at, version, is_active, game_id, number, checksum, size, file_count, uploaded_by
+        FROM game_versions
+        WHERE game_id = $1
+        ORDER BY number DESC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	gameVersions := []*GameVersion{}
+	for rows.Next() {
+		var gameVersion GameVersion
+		err := rows.Scan(
+			&gameVersion.ID,
+			&gameVersion.CreatedAt,
+			&gameVersion.UpdatedAt,
+			&gameVersion.Version,
+			&gameVersion.IsActive,
+			&gameVersion.GameID,
+			&gameVersion.Number,
+			&gameVersion.Checksum,
+			&gameVersion.Size,
+			&gameVersion.FileCount,
+			&gameVersion.UploadedBy,
+		)
+		if err != nil {
+			return nil, err
+		}
+		gameVersion.Src = PlaySrc(gameVersion.GameID, gameVersion.Number)
+		gameVersions = append(gameVersions, &gameVersion)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return gameVersions, nil
+}
+
+func (m Model) GetGameVersion(gameID int64, number int32) (*GameVersion, error) {
+	if gameID < 1 || number < 1 {
+		return nil, database.ErrRecordNotFound
+	}
+
+	query := `
+        SELECT id, created_at, updated_at, version, is_active, game_id, number, checksum, size, file_count, uploaded_by
+        FROM game_versions
+        WHERE game_id = $1 AND number = $2`
+
+	var gameVersion GameVersion
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, gameID, number).Scan(
+		&gameVersion.ID,
+		&gameVersion.CreatedAt,
+		&gameVersion.UpdatedAt,
+		&gameVersion.Version,
+		&gameVersion.IsActive,
+		&gameVersion.GameID,
+		&gameVersion.Number,
+		&gameVersion.Checksum,
+		&gameVersion.Size,
+		&gameVersion.FileCount,
+		&gameVersion.UploadedBy,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, database.ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	gameVersion.Src = PlaySrc(gameVersion.GameID, gameVersion.Number)
+	return &gameVersion, nil
+}
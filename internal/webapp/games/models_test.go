@@ -2,6 +2,7 @@ package games
 
 import (
 	"database/sql"
+	"errors"
 	"regexp"
 	"testing"
 	"time"
@@ -32,12 +33,16 @@ func TestInsertGame(t *testing.T) {
 		Src:         "src",
 		Controls:    "WASD",
 		HasScore:    true,
+		ScoreType:   ScoreTypePoints,
+		ScoreOrder:  ScoreOrderDesc,
+		ScoreMin:    1,
+		ScoreMax:    1000000,
 		IsActive:    true,
 	}
 
 	// Test Case 1: Successful insertion
 	mock.ExpectQuery("INSERT INTO games_list .* RETURNING id, created_at, updated_at, version").
-		WithArgs(game.Name, game.Description, game.Logo, game.Src, game.Controls, game.HasScore, game.IsActive).
+		WithArgs(game.Name, game.Description, game.Logo, game.Src, game.Controls, game.HasScore, game.ScoreType, game.ScoreOrder, game.ScoreMin, game.ScoreMax, game.ScoreRateMax, game.IsActive).
 		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "version"}).
 			AddRow(1, time.Now(), time.Now(), 1))
 
@@ -86,9 +91,9 @@ func TestGetGames(t *testing.T) {
 
 	// Test Case 1: Valid select with multiple games
 	mock.ExpectQuery("SELECT .* FROM games_list ORDER BY name").
-		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "version", "is_active", "name", "description", "logo", "src", "controls", "has_score"}).
-			AddRow(1, time.Now(), time.Now(), 1, true, "Game One", "Description One", "logo1.png", "src1", "WASD", true).
-			AddRow(2, time.Now(), time.Now(), 1, true, "Game Two", "Description Two", "logo2.png", "src2", "Arrow Keys", false))
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "version", "is_active", "name", "description", "logo", "src", "controls", "has_score", "score_type", "score_order", "score_min", "score_max", "score_rate_max"}).
+			AddRow(1, time.Now(), time.Now(), 1, true, "Game One", "Description One", "logo1.png", "src1", "WASD", true, "points", "desc", 1, 1000000, 0).
+			AddRow(2, time.Now(), time.Now(), 1, true, "Game Two", "Description Two", "logo2.png", "src2", "Arrow Keys", false, "points", "desc", 1, 1000000, 0))
 
 	games, err := model.GetGames()
 	if err != nil {
@@ -105,7 +110,7 @@ func TestGetGames(t *testing.T) {
 
 	// Test Case 2: No games found
 	mock.ExpectQuery("SELECT .* FROM games_list ORDER BY name").
-		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "version", "is_active", "name", "description", "logo", "src", "controls", "has_score"}))
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "version", "is_active", "name", "description", "logo", "src", "controls", "has_score", "score_type", "score_order", "score_min", "score_max", "score_rate_max"}))
 
 	games, err = model.GetGames()
 	if err != nil {
@@ -127,8 +132,8 @@ func TestGetGames(t *testing.T) {
 
 	// Test Case 4: Row scan error
 	mock.ExpectQuery("SELECT .* FROM games_list ORDER BY name").
-		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "version", "is_active", "name", "description", "logo", "src", "controls", "has_score"}).
-			AddRow(1, time.Now(), time.Now(), 1, true, "Game One", "Description One", "logo1.png", "src1", "WASD", "invalid_bool")) // has_score should be boolean
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "version", "is_active", "name", "description", "logo", "src", "controls", "has_score", "score_type", "score_order", "score_min", "score_max", "score_rate_max"}).
+			AddRow(1, time.Now(), time.Now(), 1, true, "Game One", "Description One", "logo1.png", "src1", "WASD", "invalid_bool", "points", "desc", 1, 1000000, 0)) // has_score should be boolean
 
 	_, err = model.GetGames()
 	if err == nil {
@@ -153,8 +158,8 @@ func TestGetGameByID(t *testing.T) {
 	// Test Case 1: Valid game retrieval
 	mock.ExpectQuery("SELECT .* FROM games_list WHERE id = \\$1").
 		WithArgs(1).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "version", "is_active", "name", "description", "logo", "src", "controls", "has_score"}).
-			AddRow(1, time.Now(), time.Now(), 1, true, "Game One", "Description One", "logo1.png", "src1", "WASD", true))
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "version", "is_active", "name", "description", "logo", "src", "controls", "has_score", "score_type", "score_order", "score_min", "score_max", "score_rate_max"}).
+			AddRow(1, time.Now(), time.Now(), 1, true, "Game One", "Description One", "logo1.png", "src1", "WASD", true, "points", "desc", 1, 1000000, 0))
 
 	game, err := model.GetGameByID(1)
 	if err != nil {
@@ -194,8 +199,8 @@ func TestGetGameByID(t *testing.T) {
 	// Test Case 5: Row scan error
 	mock.ExpectQuery("SELECT .* FROM games_list WHERE id = \\$1").
 		WithArgs(3).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "version", "is_active", "name", "description", "logo", "src", "controls", "has_score"}).
-			AddRow(3, time.Now(), time.Now(), 1, true, "Game Three", "Description Three", "logo3.png", "src3", "Arrow Keys", "invalid_bool")) // has_score should be boolean
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "version", "is_active", "name", "description", "logo", "src", "controls", "has_score", "score_type", "score_order", "score_min", "score_max", "score_rate_max"}).
+			AddRow(3, time.Now(), time.Now(), 1, true, "Game Three", "Description Three", "logo3.png", "src3", "Arrow Keys", "invalid_bool", "points", "desc", 1, 1000000, 0)) // has_score should be boolean
 
 	_, err = model.GetGameByID(3)
 	if err == nil {
@@ -271,10 +276,10 @@ func TestUpdateGameByID(t *testing.T) {
 	// Test Case 1: Successful update
 	mock.ExpectQuery(regexp.QuoteMeta(`
         UPDATE games_list
-        SET name = $1, description = $2, logo = $3, src = $4, controls = $5, has_score = $6, is_active = $7, version = version + 1, updated_at = now()
-        WHERE id = $8 and version = $9
+        SET name = $1, description = $2, logo = $3, src = $4, controls = $5, has_score = $6, score_type = $7, score_order = $8, score_min = $9, score_max = $10, score_rate_max = $11, is_active = $12, version = version + 1, updated_at = now()
+        WHERE id = $13 and version = $14
         RETURNING updated_at, version`)).
-		WithArgs("Updated Game", "Updated Description", "updated_logo.png", "updated_src", "WASD", true, true, 1, 1).
+		WithArgs("Updated Game", "Updated Description", "updated_logo.png", "updated_src", "WASD", true, "points", "desc", 1, 1000000, 0, true, 1, 1).
 		WillReturnRows(sqlmock.NewRows([]string{"updated_at", "version"}).
 			AddRow(time.Now(), 2))
 
@@ -286,6 +291,10 @@ func TestUpdateGameByID(t *testing.T) {
 		Src:         "updated_src",
 		Controls:    "WASD",
 		HasScore:    true,
+		ScoreType:   ScoreTypePoints,
+		ScoreOrder:  ScoreOrderDesc,
+		ScoreMin:    1,
+		ScoreMax:    1000000,
 		IsActive:    true,
 		Version:     1,
 	}
@@ -298,10 +307,10 @@ func TestUpdateGameByID(t *testing.T) {
 	// Test Case 2: Edit conflict (no rows updated)
 	mock.ExpectQuery(regexp.QuoteMeta(`
         UPDATE games_list
-        SET name = $1, description = $2, logo = $3, src = $4, controls = $5, has_score = $6, is_active = $7, version = version + 1, updated_at = now()
-        WHERE id = $8 and version = $9
+        SET name = $1, description = $2, logo = $3, src = $4, controls = $5, has_score = $6, score_type = $7, score_order = $8, score_min = $9, score_max = $10, score_rate_max = $11, is_active = $12, version = version + 1, updated_at = now()
+        WHERE id = $13 and version = $14
         RETURNING updated_at, version`)).
-		WithArgs("Outdated Game", "Old Description", "old_logo.png", "old_src", "Arrow Keys", false, false, 2, 1).
+		WithArgs("Outdated Game", "Old Description", "old_logo.png", "old_src", "Arrow Keys", false, "points", "desc", 1, 1000000, 0, false, 2, 1).
 		WillReturnError(sql.ErrNoRows)
 
 	game = &Game{
@@ -312,6 +321,10 @@ func TestUpdateGameByID(t *testing.T) {
 		Src:         "old_src",
 		Controls:    "Arrow Keys",
 		HasScore:    false,
+		ScoreType:   ScoreTypePoints,
+		ScoreOrder:  ScoreOrderDesc,
+		ScoreMin:    1,
+		ScoreMax:    1000000,
 		IsActive:    false,
 		Version:     1,
 	}
@@ -324,10 +337,10 @@ func TestUpdateGameByID(t *testing.T) {
 	// Test Case 3: Database error
 	mock.ExpectQuery(regexp.QuoteMeta(`
         UPDATE games_list
-        SET name = $1, description = $2, logo = $3, src = $4, controls = $5, has_score = $6, is_active = $7, version = version + 1, updated_at = now()
-        WHERE id = $8 and version = $9
+        SET name = $1, description = $2, logo = $3, src = $4, controls = $5, has_score = $6, score_type = $7, score_order = $8, score_min = $9, score_max = $10, score_rate_max = $11, is_active = $12, version = version + 1, updated_at = now()
+        WHERE id = $13 and version = $14
         RETURNING updated_at, version`)).
-		WithArgs("Broken Game", "Broken Description", "broken_logo.png", "broken_src", "None", false, true, 3, 2).
+		WithArgs("Broken Game", "Broken Description", "broken_logo.png", "broken_src", "None", false, "points", "desc", 1, 1000000, 0, true, 3, 2).
 		WillReturnError(sql.ErrConnDone)
 
 	game = &Game{
@@ -338,6 +351,10 @@ func TestUpdateGameByID(t *testing.T) {
 		Src:         "broken_src",
 		Controls:    "None",
 		HasScore:    false,
+		ScoreType:   ScoreTypePoints,
+		ScoreOrder:  ScoreOrderDesc,
+		ScoreMin:    1,
+		ScoreMax:    1000000,
 		IsActive:    true,
 		Version:     2,
 	}
@@ -477,15 +494,17 @@ func TestGetScoresByGameID(t *testing.T) {
 	defer mockDB.Close()
 
 	model := &Model{DB: mockDB}
+	since := time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)
+	filters := LeaderboardFilters{Window: WindowWeekly, Limit: 50, Offset: 0}
 
 	// Test Case 1: Successful retrieval
-	mock.ExpectQuery("SELECT s.*, u.* FROM games_scores s JOIN auth_user u ON s.user_id = u.uid WHERE s.game_id = \\$1 ORDER BY s.score DESC LIMIT 50").
-		WithArgs(10).
+	mock.ExpectQuery("SELECT DISTINCT ON \\(user_id\\) \\* FROM games_scores WHERE game_id = \\$1 AND is_active = true AND created_at >= \\$2 ORDER BY user_id, score DESC, created_at \\) s JOIN auth_users u ON s.user_id = u.id ORDER BY s.score DESC, s.created_at LIMIT \\$3 OFFSET \\$4").
+		WithArgs(10, since, 50, 0).
 		WillReturnRows(sqlmock.NewRows([]string{"id", "game_id", "user_id", "name", "profile_picture", "score", "created_at", "updated_at", "version"}).
 			AddRow(1, 10, 42, "Alice", "alice.png", 5000, time.Now(), time.Now(), 1).
-			AddRow(2, 10, 43, "Bob", "bob.png", 3000, time.Now(), time.Now(), 1))
+			AddRow(2, 10, 43, "Bob", "", 3000, time.Now(), time.Now(), 1))
 
-	scores, err := model.GetScoresByGameID(10)
+	scores, err := model.GetScoresByGameID(10, ScoreOrderDesc, since, filters)
 	if err != nil {
 		t.Errorf("expected no error, got %v", err)
 	}
@@ -498,12 +517,27 @@ func TestGetScoresByGameID(t *testing.T) {
 		t.Errorf("unexpected score order or data mismatch")
 	}
 
-	// Test Case 2: No scores found (empty result set)
-	mock.ExpectQuery("SELECT .* FROM games_scores .* WHERE s.game_id = \\$1 ORDER BY s.score DESC LIMIT 50").
-		WithArgs(999).
+	if scores[0].UserProfilePicture != "alice.png" || scores[1].UserProfilePicture != "/api/users/43/avatar.png" {
+		t.Errorf("expected identicon for users without a profile picture, got %q and %q", scores[0].UserProfilePicture, scores[1].UserProfilePicture)
+	}
+
+	// Test Case 2: Lowest score first for ascending games
+	mock.ExpectQuery("ORDER BY user_id, score ASC, created_at \\) s .* ORDER BY s.score ASC, s.created_at LIMIT \\$3 OFFSET \\$4").
+		WithArgs(11, since, 50, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "game_id", "user_id", "name", "profile_picture", "score", "created_at", "updated_at", "version"}).
+			AddRow(3, 11, 42, "Alice", "alice.png", 61000, time.Now(), time.Now(), 1))
+
+	scores, err = model.GetScoresByGameID(11, ScoreOrderAsc, since, filters)
+	if err != nil || len(scores) != 1 {
+		t.Errorf("expected 1 score and no error, got %d (err: %v)", len(scores), err)
+	}
+
+	// Test Case 3: No scores found (empty result set)
+	mock.ExpectQuery("SELECT .* FROM games_scores .* LIMIT \\$3 OFFSET \\$4").
+		WithArgs(999, since, 50, 0).
 		WillReturnRows(sqlmock.NewRows([]string{})) // No rows returned
 
-	scores, err = model.GetScoresByGameID(999)
+	scores, err = model.GetScoresByGameID(999, ScoreOrderDesc, since, filters)
 	if err != nil {
 		t.Errorf("expected no error for empty result, got %v", err)
 	}
@@ -511,12 +545,12 @@ func TestGetScoresByGameID(t *testing.T) {
 		t.Errorf("expected empty scores, got %d", len(scores))
 	}
 
-	// Test Case 3: Database error
-	mock.ExpectQuery("SELECT .* FROM games_scores .* WHERE s.game_id = \\$1 ORDER BY s.score DESC LIMIT 50").
-		WithArgs(20).
+	// Test Case 4: Database error
+	mock.ExpectQuery("SELECT .* FROM games_scores .* LIMIT \\$3 OFFSET \\$4").
+		WithArgs(20, since, 50, 0).
 		WillReturnError(sql.ErrConnDone)
 
-	scores, err = model.GetScoresByGameID(20)
+	scores, err = model.GetScoresByGameID(20, ScoreOrderDesc, since, filters)
 	if err != sql.ErrConnDone {
 		t.Errorf("expected sql.ErrConnDone, got %v", err)
 	}
@@ -524,13 +558,13 @@ func TestGetScoresByGameID(t *testing.T) {
 		t.Errorf("expected nil scores on error, got %v", scores)
 	}
 
-	// Test Case 4: Row scan error (corrupted data)
-	mock.ExpectQuery("SELECT .* FROM games_scores .* WHERE s.game_id = \\$1 ORDER BY s.score DESC LIMIT 50").
-		WithArgs(30).
+	// Test Case 5: Row scan error (corrupted data)
+	mock.ExpectQuery("SELECT .* FROM games_scores .* LIMIT \\$3 OFFSET \\$4").
+		WithArgs(30, since, 50, 0).
 		WillReturnRows(sqlmock.NewRows([]string{"id", "game_id", "user_id", "name", "profile_picture", "score", "created_at", "updated_at", "version"}).
 			AddRow(nil, 30, 99, "Charlie", "charlie.png", 1500, time.Now(), time.Now(), 1)) // `id` is nil, causing scan error
 
-	scores, err = model.GetScoresByGameID(30)
+	scores, err = model.GetScoresByGameID(30, ScoreOrderDesc, since, filters)
 	if err == nil {
 		t.Errorf("expected an error due to row scan failure, got nil")
 	}
@@ -541,6 +575,68 @@ func TestGetScoresByGameID(t *testing.T) {
 	}
 }
 
+func TestGetUserRankByGameID(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	model := &Model{DB: mockDB}
+	since := time.Time{}
+	columns := []string{"id", "game_id", "user_id", "name", "profile_picture", "score", "created_at", "updated_at", "version", "rank", "percent_rank", "total"}
+
+	// Test Case 1: User ranked with neighbors on both sides
+	mock.ExpectQuery("WITH best AS \\( SELECT DISTINCT ON \\(user_id\\) \\* FROM games_scores .* ROW_NUMBER\\(\\) OVER .* WHERE r.rank BETWEEN p.rank - \\$4 AND p.rank \\+ \\$4 ORDER BY r.rank").
+		WithArgs(10, since, 42, 1).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(1, 10, 41, "Alice", "alice.png", 6000, time.Now(), time.Now(), 1, 4, 0.75, 5).
+			AddRow(2, 10, 42, "Bob", "", 5000, time.Now(), time.Now(), 1, 5, 1.0, 5))
+
+	rank, err := model.GetUserRankByGameID(10, 42, ScoreOrderDesc, since, 1)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if rank.Best.Rank != 5 || rank.Best.Score.Score != 5000 || rank.Total != 5 {
+		t.Errorf("unexpected best entry: %+v (total %d)", rank.Best, rank.Total)
+	}
+	if rank.Percentile != 0 {
+		t.Errorf("expected percentile 0 for last place, got %f", rank.Percentile)
+	}
+	if len(rank.Above) != 1 || rank.Above[0].UserName != "Alice" {
+		t.Errorf("expected Alice above, got %v", rank.Above)
+	}
+	if len(rank.Below) != 0 {
+		t.Errorf("expected no entries below, got %d", len(rank.Below))
+	}
+
+	// Test Case 2: User has no ranked score
+	mock.ExpectQuery("WITH best AS").
+		WithArgs(10, since, 99, 1).
+		WillReturnRows(sqlmock.NewRows(columns))
+
+	_, err = model.GetUserRankByGameID(10, 99, ScoreOrderDesc, since, 1)
+	if err != database.ErrRecordNotFound {
+		t.Errorf("expected record not found error, got %v", err)
+	}
+
+	// Test Case 3: Database error
+	mock.ExpectQuery("WITH best AS").
+		WithArgs(20, since, 42, 1).
+		WillReturnError(sql.ErrConnDone)
+
+	_, err = model.GetUserRankByGameID(20, 42, ScoreOrderDesc, since, 1)
+	if err != sql.ErrConnDone {
+		t.Errorf("expected sql.ErrConnDone, got %v", err)
+	}
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
 func TestGetUsersScoresByGameID(t *testing.T) {
 	mockDB, mock, err := sqlmock.New()
 	if err != nil {
@@ -554,13 +650,13 @@ func TestGetUsersScoresByGameID(t *testing.T) {
 	userID := int64(42)
 
 	// Test Case 1: Successful retrieval
-	mock.ExpectQuery("SELECT s.*, u.name, u.profile_picture FROM games_scores s JOIN auth_users u ON s.user_id = u.uid WHERE s.game_id = \\$1 and s.user_id = \\$2 ORDER BY s.score DESC").
+	mock.ExpectQuery("SELECT s.*, u.name, COALESCE\\(u.profile_picture, ''\\) FROM games_scores s JOIN auth_users u ON s.user_id = u.id WHERE s.game_id = \\$1 and s.user_id = \\$2 ORDER BY s.score DESC").
 		WithArgs(gameID, userID).
 		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "version", "is_active", "game_id", "user_id", "score", "name", "profile_picture"}).
 			AddRow(1, time.Now(), time.Now(), 1, true, gameID, userID, 5000, "Alice", "alice.png").
 			AddRow(2, time.Now(), time.Now(), 1, true, gameID, userID, 4000, "Alice", "alice.png"))
 
-	scores, err := model.GetUsersScoresByGameID(gameID, userID)
+	scores, err := model.GetUsersScoresByGameID(gameID, userID, ScoreOrderDesc)
 	if err != nil {
 		t.Errorf("expected no error, got %v", err)
 	}
@@ -578,7 +674,7 @@ func TestGetUsersScoresByGameID(t *testing.T) {
 		WithArgs(999, 999).
 		WillReturnRows(sqlmock.NewRows([]string{})) // No rows returned
 
-	scores, err = model.GetUsersScoresByGameID(999, 999)
+	scores, err = model.GetUsersScoresByGameID(999, 999, ScoreOrderDesc)
 	if err != nil {
 		t.Errorf("expected no error for empty result, got %v", err)
 	}
@@ -591,7 +687,7 @@ func TestGetUsersScoresByGameID(t *testing.T) {
 		WithArgs(20, 50).
 		WillReturnError(sql.ErrConnDone)
 
-	scores, err = model.GetUsersScoresByGameID(20, 50)
+	scores, err = model.GetUsersScoresByGameID(20, 50, ScoreOrderDesc)
 	if err != sql.ErrConnDone {
 		t.Errorf("expected sql.ErrConnDone, got %v", err)
 	}
@@ -605,7 +701,7 @@ func TestGetUsersScoresByGameID(t *testing.T) {
 		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "version", "is_active", "game_id", "user_id", "score", "name", "profile_picture"}).
 			AddRow(nil, time.Now(), time.Now(), 1, true, 30, 60, 3500, "Charlie", "charlie.png")) // `id` is nil, causing scan error
 
-	scores, err = model.GetUsersScoresByGameID(30, 60)
+	scores, err = model.GetUsersScoresByGameID(30, 60, ScoreOrderDesc)
 	if err == nil {
 		t.Errorf("expected an error due to row scan failure, got nil")
 	}
@@ -616,6 +712,62 @@ func TestGetUsersScoresByGameID(t *testing.T) {
 	}
 }
 
+func TestGetScoreByID(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	model := Model{DB: mockDB}
+
+	// Test Case 1: Valid score retrieval
+	mock.ExpectQuery("SELECT .* FROM games_scores WHERE id = \\$1").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "version", "is_active", "game_id", "user_id", "score"}).
+			AddRow(1, time.Now(), time.Now(), 1, true, 10, 20, 5000))
+
+	score, err := model.GetScoreByID(1)
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	if score.ID != 1 || score.GameID != 10 || score.UserID != 20 || score.Score != 5000 {
+		t.Errorf("unexpected score values: %+v", score)
+	}
+
+	// Test Case 2: Invalid ID (less than 1)
+	_, err = model.GetScoreByID(0)
+	if err != database.ErrRecordNotFound {
+		t.Errorf("expected record not found error, got %v", err)
+	}
+
+	// Test Case 3: Score ID not found
+	mock.ExpectQuery("SELECT .* FROM games_scores WHERE id = \\$1").
+		WithArgs(99).
+		WillReturnError(sql.ErrNoRows)
+
+	_, err = model.GetScoreByID(99)
+	if err != database.ErrRecordNotFound {
+		t.Errorf("expected record not found error, got %v", err)
+	}
+
+	// Test Case 4: Database error
+	mock.ExpectQuery("SELECT .* FROM games_scores WHERE id = \\$1").
+		WithArgs(2).
+		WillReturnError(sql.ErrConnDone)
+
+	_, err = model.GetScoreByID(2)
+	if err != sql.ErrConnDone {
+		t.Errorf("expected sql.ErrConnDone, got %v", err)
+	}
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
 func TestExistsScoreByID(t *testing.T) {
 	mockDB, mock, err := sqlmock.New()
 	if err != nil {
@@ -759,23 +911,23 @@ func TestDeleteScoreByID(t *testing.T) {
 	model := Model{DB: mockDB}
 
 	// Test Case 1: Successful deletion
-	mock.ExpectExec("DELETE FROM games_scores WHERE id = \\$1").
-		WithArgs(1).
+	mock.ExpectExec("DELETE FROM games_scores WHERE id = \\$1 AND version = \\$2 AND is_active = true").
+		WithArgs(1, 1).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
-	err = model.DeleteScoreByID(1)
+	err = model.DeleteScoreByID(1, 1)
 	if err != nil {
 		t.Errorf("expected no error, got %v", err)
 	}
 
-	// Test Case 2: Record not found (no rows deleted)
+	// Test Case 2: Hidden or changed since it was read (no rows deleted)
 	mock.ExpectExec("DELETE FROM games_scores WHERE id = \\$1").
-		WithArgs(2).
+		WithArgs(2, 1).
 		WillReturnResult(sqlmock.NewResult(0, 0))
 
-	err = model.DeleteScoreByID(2)
-	if err != database.ErrRecordNotFound {
-		t.Errorf("expected ErrRecordNotFound, got %v", err)
+	err = model.DeleteScoreByID(2, 1)
+	if err != database.ErrEditConflict {
+		t.Errorf("expected ErrEditConflict, got %v", err)
 	}
 
 	// Test Case 3: Invalid ID (negative or zero)
@@ -784,17 +936,264 @@ func TestDeleteScoreByID(t *testing.T) {
 		t.Errorf("expected ErrRecordNotFound for ID 0, got %v", err)
 	}
 
-	err = model.DeleteScoreByID(-5)
+	err = model.DeleteScoreByID(-5, 1)
 	if err != database.ErrRecordNotFound {
 		t.Errorf("expected ErrRecordNotFound for negative ID, got %v", err)
 	}
 
 	// Test Case 4: Database error
 	mock.ExpectExec("DELETE FROM games_scores WHERE id = \\$1").
-		WithArgs(3).
+		WithArgs(3, 1).
+		WillReturnError(sql.ErrConnDone)
+
+	err = model.DeleteScoreByID(3, 1)
+	if err != sql.ErrConnDone {
+		t.Errorf("expected sql.ErrConnDone, got %v", err)
+	}
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestInsertPlaySession(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	model := Model{DB: mockDB}
+	now := time.Now()
+	session := &PlaySession{GameID: 1, UserID: 2, Nonce: "nonce", StartedAt: now, Expiry: now.Add(time.Hour)}
+
+	// Test Case 1: Successful insertion
+	mock.ExpectExec("INSERT INTO games_play_sessions").
+		WithArgs("nonce", 1, 2, session.StartedAt, session.Expiry).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = model.InsertPlaySession(session)
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	// Test Case 2: Database error
+	mock.ExpectExec("INSERT INTO games_play_sessions").
+		WillReturnError(sql.ErrConnDone)
+
+	err = model.InsertPlaySession(session)
+	if err != sql.ErrConnDone {
+		t.Errorf("expected sql.ErrConnDone, got %v", err)
+	}
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestConsumePlaySession(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	model := Model{DB: mockDB}
+	session := &PlaySession{GameID: 1, UserID: 2, Nonce: "nonce"}
+
+	// Test Case 1: Unused session consumed
+	mock.ExpectExec("UPDATE games_play_sessions SET used_at = now\\(\\) WHERE nonce = \\$1 AND game_id = \\$2 AND user_id = \\$3 AND used_at IS NULL").
+		WithArgs("nonce", 1, 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = model.ConsumePlaySession(session)
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	// Test Case 2: Session already used
+	mock.ExpectExec("UPDATE games_play_sessions").
+		WithArgs("nonce", 1, 2).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = model.ConsumePlaySession(session)
+	if err != ErrUsedTicket {
+		t.Errorf("expected ErrUsedTicket, got %v", err)
+	}
+
+	// Test Case 3: Database error
+	mock.ExpectExec("UPDATE games_play_sessions").
+		WillReturnError(sql.ErrConnDone)
+
+	err = model.ConsumePlaySession(session)
+	if err != sql.ErrConnDone {
+		t.Errorf("expected sql.ErrConnDone, got %v", err)
+	}
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestInsertScoreRejection(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	model := Model{DB: mockDB}
+	rejection := &ScoreRejection{GameID: 1, UserID: 2, Score: 100, Reason: RejectionImpossible, Nonce: "nonce"}
+
+	// Test Case 1: Successful insertion
+	mock.ExpectQuery("INSERT INTO games_score_rejections .* RETURNING id, created_at").
+		WithArgs(1, 2, 100, RejectionImpossible, "nonce").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, time.Now()))
+
+	err = model.InsertScoreRejection(rejection)
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if rejection.ID != 1 {
+		t.Errorf("expected rejection ID to be 1, got %d", rejection.ID)
+	}
+
+	// Test Case 2: Database error
+	mock.ExpectQuery("INSERT INTO games_score_rejections").
+		WillReturnError(sql.ErrConnDone)
+
+	err = model.InsertScoreRejection(rejection)
+	if err != sql.ErrConnDone {
+		t.Errorf("expected sql.ErrConnDone, got %v", err)
+	}
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestInsertGameVersion(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	model := Model{DB: mockDB}
+	userID := int64(2)
+	gameVersion := &GameVersion{GameID: 1, Checksum: "abc", Size: 100, FileCount: 3, UploadedBy: &userID}
+	now := time.Now()
+
+	// Test Case 1: Successful insertion
+	mock.ExpectQuery("INSERT INTO game_versions .* VALUES \\(\\$1, \\(SELECT COALESCE\\(MAX\\(number\\), 0\\) \\+ 1 FROM game_versions WHERE game_id = \\$1\\), \\$2, \\$3, \\$4, \\$5\\)").
+		WithArgs(1, "abc", 100, 3, &userID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "version", "is_active", "number"}).
+			AddRow(1, now, now, 1, true, 4))
+
+	err = model.InsertGameVersion(gameVersion)
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if gameVersion.Number != 4 || gameVersion.Src != "/play/1/4/" {
+		t.Errorf("expected version 4 served from /play/1/4/, got %d %s", gameVersion.Number, gameVersion.Src)
+	}
+
+	// Test Case 2: Concurrent upload took the version number
+	mock.ExpectQuery("INSERT INTO game_versions").
+		WillReturnError(errors.New(`pq: duplicate key value violates unique constraint "game_versions_game_id_number_key"`))
+
+	err = model.InsertGameVersion(gameVersion)
+	if err != database.ErrEditConflict {
+		t.Errorf("expected edit conflict error, got %v", err)
+	}
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestGetGameVersion(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	model := Model{DB: mockDB}
+	now := time.Now()
+	columns := []string{"id", "created_at", "updated_at", "version", "is_active", "game_id", "number", "checksum", "size", "file_count", "uploaded_by"}
+
+	// Test Case 1: Version found
+	mock.ExpectQuery("SELECT .* FROM game_versions WHERE game_id = \\$1 AND number = \\$2").
+		WithArgs(1, 2).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(5, now, now, 1, true, 1, 2, "abc", 100, 3, nil))
+
+	gameVersion, err := model.GetGameVersion(1, 2)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if gameVersion.Checksum != "abc" || gameVersion.UploadedBy != nil || gameVersion.Src != "/play/1/2/" {
+		t.Errorf("unexpected game version: %+v", gameVersion)
+	}
+
+	// Test Case 2: Version not found
+	mock.ExpectQuery("SELECT .* FROM game_versions").
+		WithArgs(1, 3).
+		WillReturnError(sql.ErrNoRows)
+
+	_, err = model.GetGameVersion(1, 3)
+	if err != database.ErrRecordNotFound {
+		t.Errorf("expected record not found error, got %v", err)
+	}
+
+	// Test Case 3: Invalid version number
+	_, err = model.GetGameVersion(1, 0)
+	if err != database.ErrRecordNotFound {
+		t.Errorf("expected record not found error, got %v", err)
+	}
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestGetGameVersionsByGameID(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	model := Model{DB: mockDB}
+	now := time.Now()
+	columns := []string{"id", "created_at", "updated_at", "version", "is_active", "game_id", "number", "checksum", "size", "file_count", "uploaded_by"}
+
+	// Test Case 1: Versions found, newest first
+	mock.ExpectQuery("SELECT .* FROM game_versions WHERE game_id = \\$1 ORDER BY number DESC").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(6, now, now, 1, true, 1, 2, "def", 100, 3, 2).
+			AddRow(5, now, now, 1, true, 1, 1, "abc", 100, 3, nil))
+
+	gameVersions, err := model.GetGameVersionsByGameID(1)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(gameVersions) != 2 || gameVersions[0].Number != 2 || *gameVersions[0].UploadedBy != 2 {
+		t.Errorf("unexpected game versions: %+v", gameVersions)
+	}
+
+	// Test Case 2: Database error
+	mock.ExpectQuery("SELECT .* FROM game_versions").
 		WillReturnError(sql.ErrConnDone)
 
-	err = model.DeleteScoreByID(3)
+	_, err = model.GetGameVersionsByGameID(1)
 	if err != sql.ErrConnDone {
 		t.Errorf("expected sql.ErrConnDone, got %v", err)
 	}
@@ -1,6 +1,7 @@
 package games
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/navazjm/pixelarcade/internal/webapp/utils/validator"
@@ -19,6 +20,8 @@ type Score struct {
 	UserProfilePicture string    `json:"user_profile_picture"` // derived from inner join w/ users_preferences table
 }
 
-func ValidateScore(v *validator.Validator, score int64) {
-	v.Check(score > 0, "score", "must be non negative number")
+// ValidateScore checks the score falls within the bounds configured for the game.
+func ValidateScore(v *validator.Validator, game *Game, score int64) {
+	v.Check(score >= game.ScoreMin, "score", fmt.Sprintf("must be at least %d", game.ScoreMin))
+	v.Check(score <= game.ScoreMax, "score", fmt.Sprintf("must be at most %d", game.ScoreMax))
 }
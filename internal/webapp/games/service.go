@@ -4,32 +4,93 @@ import (
 	"database/sql"
 	"log/slog"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/navazjm/pixelarcade/internal/webapp/audit"
+	"github.com/navazjm/pixelarcade/internal/webapp/auth"
+	"github.com/navazjm/pixelarcade/internal/webapp/storage"
 	"github.com/navazjm/pixelarcade/internal/webapp/utils/logger"
 )
 
+// Authorizer reports whether a user has been granted a permission. Used by handlers
+// whose access depends on both ownership and permissions, e.g. deleting a score.
+type Authorizer interface {
+	HasPermission(user *auth.User, code string) (bool, error)
+}
+
+type Config struct {
+	// Timezone the daily, weekly and monthly leaderboard windows are computed in
+	Timezone *time.Location
+	// Key used to sign play session tickets
+	SessionSecret []byte
+	// How long a play session ticket can be used to submit a score
+	SessionTTL time.Duration
+	// Maximum size in bytes of an uploaded game bundle
+	BundleMaxSize int64
+}
+
 type Service struct {
-	Models Model
-	Logger *slog.Logger
+	Models     Model
+	Logger     *slog.Logger
+	Authorizer Authorizer
+	Storage    storage.Blob
+	Audit      audit.Recorder
+	Config     *Config
 }
 
-func NewService(db *sql.DB, logger *slog.Logger) *Service {
+func NewService(db *sql.DB, logger *slog.Logger, authorizer Authorizer, blob storage.Blob, auditor audit.Recorder, cfg *Config) *Service {
 	return &Service{
-		Models: Model{DB: db},
-		Logger: logger,
+		Models:     Model{DB: db},
+		Logger:     logger,
+		Authorizer: authorizer,
+		Storage:    blob,
+		Audit:      auditor,
+		Config:     cfg,
 	}
 }
 
+//==============================================================================
+//
+// Mock Service for testing purposes
+//
+//==============================================================================
+
+type mockAuthorizer struct {
+	permissions auth.Permissions
+	err         error
+}
+
+func (a *mockAuthorizer) HasPermission(user *auth.User, code string) (bool, error) {
+	if a.err != nil {
+		return false, a.err
+	}
+	return a.permissions.Include(code), nil
+}
+
 func newMockService(t *testing.T) (*Service, sqlmock.Sqlmock) {
 	mockDB, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("failed to create mock DB: %v", err)
 	}
 
+	blob, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create mock storage: %v", err)
+	}
+
 	service := &Service{
-		Models: Model{DB: mockDB},
-		Logger: logger.NewMock(),
+		Models:     Model{DB: mockDB},
+		Logger:     logger.NewMock(),
+		Authorizer: &mockAuthorizer{},
+		Storage:    blob,
+		Audit:      audit.NewMock(),
+		Config: &Config{
+			Timezone:      time.UTC,
+			SessionSecret: []byte("mock-session-secret"),
+			SessionTTL:    2 * time.Hour,
+			BundleMaxSize: 1 << 20,
+		},
 	}
 
 	return service, mock
@@ -0,0 +1,154 @@
+package games
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidTicket = errors.New("invalid play session ticket")
+	ErrExpiredTicket = errors.New("expired play session ticket")
+	ErrUsedTicket    = errors.New("play session ticket already used")
+)
+
+// Reasons recorded alongside rejected score submissions
+const (
+	RejectionInvalidTicket = "invalid_ticket"
+	RejectionExpiredTicket = "expired_ticket"
+	RejectionUsedTicket    = "used_ticket"
+	RejectionImpossible    = "impossible_score"
+)
+
+// scoreGracePeriod allows for network latency between the client finishing a run
+// and the score arriving, so times close to the elapsed session time are accepted.
+const scoreGracePeriod = 5 * time.Second
+
+// PlaySession is issued when a player starts a game. Its signed ticket must accompany
+// the score submitted at the end of the run and can only be used once.
+type PlaySession struct {
+	Ticket    string    `json:"ticket"`
+	GameID    int64     `json:"game_id"`
+	UserID    int64     `json:"-"`
+	Nonce     string    `json:"-"`
+	StartedAt time.Time `json:"started_at"`
+	Expiry    time.Time `json:"expiry"`
+}
+
+func generatePlaySession(gameID, userID int64, ttl time.Duration, secret []byte) (*PlaySession, error) {
+	if ttl <= 0 {
+		return nil, fmt.Errorf("TTL must be positive")
+	}
+
+	randomBytes := make([]byte, 16)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	// Truncate to the precision carried by the ticket so the parsed session matches
+	now := time.Now().Truncate(time.Millisecond)
+	session := &PlaySession{
+		GameID:    gameID,
+		UserID:    userID,
+		Nonce:     base64.RawURLEncoding.EncodeToString(randomBytes),
+		StartedAt: now,
+		Expiry:    now.Add(ttl),
+	}
+	session.Ticket = session.sign(secret)
+
+	return session, nil
+}
+
+func (ps *PlaySession) payload() string {
+	return fmt.Sprintf("%d.%d.%d.%d.%s", ps.GameID, ps.UserID, ps.StartedAt.UnixMilli(), ps.Expiry.UnixMilli(), ps.Nonce)
+}
+
+func (ps *PlaySession) sign(secret []byte) string {
+	payload := ps.payload()
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// ParsePlaySession verifies the ticket's signature and returns the session it
+// describes. Expiry and whether the ticket was already used are checked separately.
+func ParsePlaySession(ticket string, secret []byte) (*PlaySession, error) {
+	encodedPayload, encodedSignature, ok := strings.Cut(ticket, ".")
+	if !ok {
+		return nil, ErrInvalidTicket
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, ErrInvalidTicket
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return nil, ErrInvalidTicket
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, ErrInvalidTicket
+	}
+
+	fields := strings.Split(string(payload), ".")
+	if len(fields) != 5 {
+		return nil, ErrInvalidTicket
+	}
+
+	var values [4]int64
+	for i := range values {
+		values[i], err = strconv.ParseInt(fields[i], 10, 64)
+		if err != nil {
+			return nil, ErrInvalidTicket
+		}
+	}
+
+	return &PlaySession{
+		Ticket:    ticket,
+		GameID:    values[0],
+		UserID:    values[1],
+		StartedAt: time.UnixMilli(values[2]),
+		Expiry:    time.UnixMilli(values[3]),
+		Nonce:     fields[4],
+	}, nil
+}
+
+// IsPossibleScore reports whether the score could have been reached in the time
+// elapsed since the session started. Times can't exceed the elapsed time, and other
+// score types can't grow faster than the game's configured rate.
+func IsPossibleScore(game *Game, score int64, elapsed time.Duration) bool {
+	elapsed += scoreGracePeriod
+
+	switch {
+	case game.ScoreType == ScoreTypeTimeMS:
+		return score <= elapsed.Milliseconds()
+	case game.ScoreRateMax > 0:
+		return score <= int64(elapsed.Seconds()*float64(game.ScoreRateMax))
+	default:
+		return true
+	}
+}
+
+type ScoreRejection struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	GameID    int64     `json:"game_id"`
+	UserID    int64     `json:"user_id"`
+	Score     int64     `json:"score"`
+	Reason    string    `json:"reason"`
+	Nonce     string    `json:"-"`
+}
@@ -0,0 +1,93 @@
+package games
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPlaySession_SignAndParse(t *testing.T) {
+	secret := []byte("secret")
+
+	session, err := generatePlaySession(1, 2, time.Hour, secret)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	parsed, err := ParsePlaySession(session.Ticket, secret)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if parsed.GameID != 1 || parsed.UserID != 2 || parsed.Nonce != session.Nonce {
+		t.Errorf("parsed session does not match: %+v", parsed)
+	}
+	if !parsed.StartedAt.Equal(session.StartedAt) || !parsed.Expiry.Equal(session.Expiry) {
+		t.Errorf("expected times %v/%v, got %v/%v", session.StartedAt, session.Expiry, parsed.StartedAt, parsed.Expiry)
+	}
+
+	_, err = generatePlaySession(1, 2, 0, secret)
+	if err == nil {
+		t.Errorf("expected error for non-positive TTL")
+	}
+}
+
+func TestParsePlaySession_Invalid(t *testing.T) {
+	secret := []byte("secret")
+
+	session, err := generatePlaySession(1, 2, time.Hour, secret)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// Swap in a payload claiming a different user while keeping the signature
+	forged := &PlaySession{GameID: 1, UserID: 3, Nonce: session.Nonce, StartedAt: session.StartedAt, Expiry: session.Expiry}
+	forgedPayload, _, _ := strings.Cut(forged.sign(secret), ".")
+	_, signature, _ := strings.Cut(session.Ticket, ".")
+
+	tests := []struct {
+		name   string
+		ticket string
+		secret []byte
+	}{
+		{"Empty ticket", "", secret},
+		{"Missing signature", "payload", secret},
+		{"Wrong secret", session.Ticket, []byte("other")},
+		{"Forged payload", forgedPayload + "." + signature, secret},
+		{"Malformed encoding", "!!!.???", secret},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParsePlaySession(tt.ticket, tt.secret)
+			if err != ErrInvalidTicket {
+				t.Errorf("expected ErrInvalidTicket, got %v", err)
+			}
+		})
+	}
+}
+
+func TestIsPossibleScore(t *testing.T) {
+	tests := []struct {
+		name     string
+		game     *Game
+		score    int64
+		elapsed  time.Duration
+		expected bool
+	}{
+		{"Time within elapsed", &Game{ScoreType: ScoreTypeTimeMS}, 60_000, time.Minute, true},
+		{"Time within grace period", &Game{ScoreType: ScoreTypeTimeMS}, 64_000, time.Minute, true},
+		{"Time longer than elapsed", &Game{ScoreType: ScoreTypeTimeMS}, 120_000, time.Minute, false},
+		{"Points within rate", &Game{ScoreType: ScoreTypePoints, ScoreRateMax: 10}, 600, time.Minute, true},
+		{"Points faster than rate", &Game{ScoreType: ScoreTypePoints, ScoreRateMax: 10}, 10_000, time.Minute, false},
+		{"Points without rate", &Game{ScoreType: ScoreTypePoints}, 1_000_000, time.Second, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsPossibleScore(tt.game, tt.score, tt.elapsed); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
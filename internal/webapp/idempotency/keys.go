@@ -0,0 +1,40 @@
+package idempotency
+
+import (
+	"crypto/sha256"
+	"net/http"
+	"time"
+
+	"github.com/navazjm/pixelarcade/internal/webapp/utils/validator"
+)
+
+const HeaderIdempotencyKey = "Idempotency-Key"
+
+// HeaderReplayed is set on responses replayed from a previous request
+const HeaderReplayed = "Idempotent-Replayed"
+
+// Key is a client supplied idempotency key along with the response to the first
+// request made with it. A zero Status means that request is still being processed.
+type Key struct {
+	UserID      int64
+	Key         string
+	RequestHash []byte
+	Status      int
+	Header      http.Header
+	Body        []byte
+	Expiry      time.Time
+}
+
+// hashRequest fingerprints a request so a key reused for a different request can be
+// detected rather than replaying an unrelated response.
+func hashRequest(r *http.Request, body []byte) []byte {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	hash.Write(body)
+	return hash.Sum(nil)
+}
+
+func ValidateKey(v *validator.Validator, key string) {
+	v.Check(key != "", "idempotency_key", "must be provided")
+	v.Check(len(key) <= 255, "idempotency_key", "must not be more than 255 bytes long")
+}
@@ -0,0 +1,157 @@
+package idempotency
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/navazjm/pixelarcade/internal/webapp/auth"
+	"github.com/navazjm/pixelarcade/internal/webapp/utils/database"
+	"github.com/navazjm/pixelarcade/internal/webapp/utils/response"
+	"github.com/navazjm/pixelarcade/internal/webapp/utils/validator"
+)
+
+// responseRecorder passes the response through to the client while keeping a copy
+// to store against the idempotency key.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rr *responseRecorder) WriteHeader(status int) {
+	rr.status = status
+	rr.ResponseWriter.WriteHeader(status)
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	if rr.status == 0 {
+		rr.status = http.StatusOK
+	}
+	rr.body.Write(b)
+	return rr.ResponseWriter.Write(b)
+}
+
+// Idempotent lets authenticated clients safely retry a request by sending the same
+// Idempotency-Key header. The first response for a key is stored per user and
+// replayed for the configured TTL instead of running the handler again. Requests
+// without the header, or from anonymous users, are passed straight through.
+// Must be wrapped by the authentication middleware.
+func (s *Service) Idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keyValue := r.Header.Get(HeaderIdempotencyKey)
+		user := auth.ContextGetUser(r)
+
+		if keyValue == "" || user.IsAnonymous() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		v := validator.New()
+		if ValidateKey(v, keyValue); !v.Valid() {
+			response.FailedValidation(w, r, s.Logger, v.Errors)
+			return
+		}
+
+		// Read the body up front to fingerprint the request, then restore it for the
+		// handler
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1_048_576))
+		if err != nil {
+			response.BadRequest(w, r, s.Logger, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		key := &Key{
+			UserID:      user.ID,
+			Key:         keyValue,
+			RequestHash: hashRequest(r, body),
+			Expiry:      time.Now().Add(s.Config.ClaimTTL),
+		}
+
+		claimed, err := s.Models.Claim(key)
+		if err != nil {
+			response.ServerError(w, r, s.Logger, err)
+			return
+		}
+
+		if !claimed {
+			s.replay(w, r, key)
+			return
+		}
+
+		// Keys nobody can replay anymore are cleaned up as new ones are claimed
+		err = s.Models.DeleteExpired()
+		if err != nil {
+			response.LogError(r, s.Logger, err)
+		}
+
+		// Server errors are not stored so the client can retry with the same key. The
+		// claim is released when the handler panics too, the panic is still left to
+		// the recovery middleware.
+		completed := false
+		defer func() {
+			if !completed {
+				err := s.Models.Release(key.UserID, key.Key)
+				if err != nil {
+					response.LogError(r, s.Logger, err)
+				}
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+
+		if recorder.status == 0 || recorder.status >= http.StatusInternalServerError {
+			return
+		}
+		completed = true
+
+		key.Status = recorder.status
+		key.Header = recorder.Header().Clone()
+		key.Body = recorder.body.Bytes()
+		key.Expiry = time.Now().Add(s.Config.TTL)
+
+		err = s.Models.Complete(key)
+		if err != nil {
+			response.LogError(r, s.Logger, err)
+		}
+	})
+}
+
+func (s *Service) replay(w http.ResponseWriter, r *http.Request, key *Key) {
+	stored, err := s.Models.Get(key.UserID, key.Key)
+	if err != nil {
+		switch {
+		// The claim was released between our attempt and the lookup
+		case errors.Is(err, database.ErrRecordNotFound):
+			response.IdempotencyKeyInUse(w, r, s.Logger)
+		default:
+			response.ServerError(w, r, s.Logger, err)
+		}
+		return
+	}
+
+	if !bytes.Equal(stored.RequestHash, key.RequestHash) {
+		response.IdempotencyKeyMismatch(w, r, s.Logger)
+		return
+	}
+
+	if stored.Status == 0 {
+		response.IdempotencyKeyInUse(w, r, s.Logger)
+		return
+	}
+
+	// Headers set by outer middleware for this request take precedence
+	for name, values := range stored.Header {
+		if _, ok := w.Header()[name]; !ok {
+			w.Header()[name] = values
+		}
+	}
+	w.Header().Set(HeaderReplayed, "true")
+
+	w.WriteHeader(stored.Status)
+	w.Write(stored.Body)
+}
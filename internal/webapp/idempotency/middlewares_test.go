@@ -0,0 +1,227 @@
+package idempotency
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/navazjm/pixelarcade/internal/webapp/auth"
+)
+
+// expiresIn matches an expiry the duration from now
+type expiresIn time.Duration
+
+func (d expiresIn) Match(v driver.Value) bool {
+	expiry, ok := v.(time.Time)
+	if !ok {
+		return false
+	}
+	return time.Until(expiry).Round(time.Second) == time.Duration(d)
+}
+
+func TestIdempotent(t *testing.T) {
+	user := &auth.User{ID: 1}
+	reqBody := `{"score": 100}`
+
+	calls := 0
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != reqBody {
+			t.Errorf("expected handler to receive the original body, got %q", body)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"score":{"id":1}}`))
+	})
+
+	newRequest := func(key string, body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/api/games/1/scores", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(HeaderIdempotencyKey, key)
+		}
+		return auth.ContextSetUser(req, user)
+	}
+
+	t.Run("SUCCESS Passes through without key", func(t *testing.T) {
+		service, mock := newMockService(t)
+		calls = 0
+
+		w := httptest.NewRecorder()
+		service.Idempotent(next)(w, newRequest("", reqBody))
+
+		if calls != 1 || w.Code != http.StatusCreated {
+			t.Errorf("expected handler to run once with 201, got %d calls and %d", calls, w.Code)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("SUCCESS First request stores the response", func(t *testing.T) {
+		service, mock := newMockService(t)
+		calls = 0
+
+		// Claimed briefly, then kept for the whole TTL once the response is stored
+		mock.ExpectQuery("INSERT INTO idempotency_keys").
+			WithArgs(user.ID, "abc", sqlmock.AnyArg(), expiresIn(time.Minute)).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(user.ID))
+		mock.ExpectExec("DELETE FROM idempotency_keys WHERE expiry < now\\(\\)").
+			WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectExec("UPDATE idempotency_keys").
+			WithArgs(user.ID, "abc", http.StatusCreated, []byte(`{"Content-Type":["application/json"]}`), []byte(`{"score":{"id":1}}`), expiresIn(24*time.Hour)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		w := httptest.NewRecorder()
+		service.Idempotent(next)(w, newRequest("abc", reqBody))
+
+		if calls != 1 || w.Code != http.StatusCreated {
+			t.Errorf("expected handler to run once with 201, got %d calls and %d", calls, w.Code)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("SUCCESS Retry replays the stored response", func(t *testing.T) {
+		service, mock := newMockService(t)
+		calls = 0
+		req := newRequest("abc", reqBody)
+		hash := hashRequest(req, []byte(reqBody))
+
+		mock.ExpectQuery("INSERT INTO idempotency_keys").
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectQuery("SELECT request_hash, status, header, body, expiry FROM idempotency_keys").
+			WithArgs(user.ID, "abc").
+			WillReturnRows(sqlmock.NewRows([]string{"request_hash", "status", "header", "body", "expiry"}).
+				AddRow(hash, http.StatusCreated, []byte(`{"Content-Type":["application/json"]}`), []byte(`{"score":{"id":1}}`), time.Now().Add(time.Hour)))
+
+		w := httptest.NewRecorder()
+		service.Idempotent(next)(w, req)
+
+		if calls != 0 {
+			t.Errorf("expected handler not to run, got %d calls", calls)
+		}
+		if w.Code != http.StatusCreated || w.Body.String() != `{"score":{"id":1}}` {
+			t.Errorf("expected replayed 201 response, got %d %s", w.Code, w.Body.String())
+		}
+		if w.Header().Get(HeaderReplayed) != "true" || w.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("expected replayed headers, got %v", w.Header())
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("ERROR Key reused for a different request", func(t *testing.T) {
+		service, mock := newMockService(t)
+		calls = 0
+
+		mock.ExpectQuery("INSERT INTO idempotency_keys").
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectQuery("SELECT request_hash, status, header, body, expiry FROM idempotency_keys").
+			WillReturnRows(sqlmock.NewRows([]string{"request_hash", "status", "header", "body", "expiry"}).
+				AddRow([]byte("other"), http.StatusCreated, nil, nil, time.Now().Add(time.Hour)))
+
+		w := httptest.NewRecorder()
+		service.Idempotent(next)(w, newRequest("abc", reqBody))
+
+		if calls != 0 || w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected 422 without running handler, got %d calls and %d", calls, w.Code)
+		}
+	})
+
+	t.Run("ERROR Original request still in progress", func(t *testing.T) {
+		service, mock := newMockService(t)
+		calls = 0
+		req := newRequest("abc", reqBody)
+		hash := hashRequest(req, []byte(reqBody))
+
+		mock.ExpectQuery("INSERT INTO idempotency_keys").
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectQuery("SELECT request_hash, status, header, body, expiry FROM idempotency_keys").
+			WillReturnRows(sqlmock.NewRows([]string{"request_hash", "status", "header", "body", "expiry"}).
+				AddRow(hash, nil, nil, nil, time.Now().Add(time.Hour)))
+
+		w := httptest.NewRecorder()
+		service.Idempotent(next)(w, req)
+
+		if calls != 0 || w.Code != http.StatusConflict {
+			t.Errorf("expected 409 without running handler, got %d calls and %d", calls, w.Code)
+		}
+	})
+
+	t.Run("SUCCESS Server errors release the key", func(t *testing.T) {
+		service, mock := newMockService(t)
+		failing := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		})
+
+		mock.ExpectQuery("INSERT INTO idempotency_keys").
+			WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(user.ID))
+		mock.ExpectExec("DELETE FROM idempotency_keys WHERE expiry").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("DELETE FROM idempotency_keys WHERE user_id").
+			WithArgs(user.ID, "abc").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		w := httptest.NewRecorder()
+		service.Idempotent(failing)(w, newRequest("abc", reqBody))
+
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("expected 500, got %d", w.Code)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("SUCCESS Handler panics release the key", func(t *testing.T) {
+		service, mock := newMockService(t)
+		panicking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		})
+
+		mock.ExpectQuery("INSERT INTO idempotency_keys").
+			WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(user.ID))
+		mock.ExpectExec("DELETE FROM idempotency_keys WHERE expiry").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("DELETE FROM idempotency_keys WHERE user_id").
+			WithArgs(user.ID, "abc").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		func() {
+			defer func() {
+				if err := recover(); err != "boom" {
+					t.Errorf("expected the panic to be passed on, got %v", err)
+				}
+			}()
+			service.Idempotent(panicking)(httptest.NewRecorder(), newRequest("abc", reqBody))
+		}()
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("ERROR Key too long", func(t *testing.T) {
+		service, _ := newMockService(t)
+
+		w := httptest.NewRecorder()
+		service.Idempotent(next)(w, newRequest(strings.Repeat("a", 256), reqBody))
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected 422, got %d", w.Code)
+		}
+	})
+}
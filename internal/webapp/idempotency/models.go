@@ -0,0 +1,133 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/navazjm/pixelarcade/internal/webapp/utils/database"
+)
+
+type Model struct {
+	DB *sql.DB
+}
+
+// Claim stores the key as in progress, taking over a previous claim only once it has
+// expired. Returns false if the key is already claimed by an earlier request.
+func (m Model) Claim(key *Key) (bool, error) {
+	query := `
+        INSERT INTO idempotency_keys (user_id, key, request_hash, expiry)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (user_id, key) DO UPDATE
+        SET request_hash = EXCLUDED.request_hash, status = NULL, header = NULL, body = NULL, expiry = EXCLUDED.expiry
+        WHERE idempotency_keys.expiry < now()
+        RETURNING user_id`
+
+	args := []any{key.UserID, key.Key, key.RequestHash, key.Expiry}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var userID int64
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+func (m Model) Get(userID int64, key string) (*Key, error) {
+	query := `
+        SELECT request_hash, status, header, body, expiry
+        FROM idempotency_keys
+        WHERE user_id = $1 AND key = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result := Key{UserID: userID, Key: key}
+	var status sql.NullInt32
+	var header []byte
+
+	err := m.DB.QueryRowContext(ctx, query, userID, key).Scan(
+		&result.RequestHash,
+		&status,
+		&header,
+		&result.Body,
+		&result.Expiry,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, database.ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	result.Status = int(status.Int32)
+	if header != nil {
+		err = json.Unmarshal(header, &result.Header)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &result, nil
+}
+
+// Complete stores the response so later requests with the same key replay it until the
+// key's expiry, which is extended past that of the claim.
+func (m Model) Complete(key *Key) error {
+	header, err := json.Marshal(key.Header)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        UPDATE idempotency_keys
+        SET status = $3, header = $4, body = $5, expiry = $6
+        WHERE user_id = $1 AND key = $2`
+
+	args := []any{key.UserID, key.Key, key.Status, header, key.Body, key.Expiry}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err = m.DB.ExecContext(ctx, query, args...)
+	return err
+}
+
+// Release deletes the claim so the request can be retried with the same key.
+func (m Model) Release(userID int64, key string) error {
+	query := `
+        DELETE FROM idempotency_keys
+        WHERE user_id = $1 AND key = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, userID, key)
+	return err
+}
+
+// DeleteExpired deletes the keys past their expiry, which are never replayed again.
+func (m Model) DeleteExpired() error {
+	query := `
+        DELETE FROM idempotency_keys
+        WHERE expiry < now()`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query)
+	return err
+}
@@ -0,0 +1,182 @@
+package idempotency
+
+import (
+	"database/sql"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/navazjm/pixelarcade/internal/webapp/utils/database"
+)
+
+func TestClaim(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	model := Model{DB: mockDB}
+	key := &Key{UserID: 1, Key: "abc", RequestHash: []byte("hash"), Expiry: time.Now().Add(time.Hour)}
+
+	// Test Case 1: New key claimed
+	mock.ExpectQuery("INSERT INTO idempotency_keys .* ON CONFLICT \\(user_id, key\\) DO UPDATE .* WHERE idempotency_keys.expiry < now\\(\\) RETURNING user_id").
+		WithArgs(1, "abc", []byte("hash"), key.Expiry).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(1))
+
+	claimed, err := model.Claim(key)
+	if err != nil || !claimed {
+		t.Errorf("expected key to be claimed, got %v (err: %v)", claimed, err)
+	}
+
+	// Test Case 2: Key already claimed
+	mock.ExpectQuery("INSERT INTO idempotency_keys").
+		WillReturnError(sql.ErrNoRows)
+
+	claimed, err = model.Claim(key)
+	if err != nil || claimed {
+		t.Errorf("expected key not to be claimed, got %v (err: %v)", claimed, err)
+	}
+
+	// Test Case 3: Database error
+	mock.ExpectQuery("INSERT INTO idempotency_keys").
+		WillReturnError(sql.ErrConnDone)
+
+	_, err = model.Claim(key)
+	if err != sql.ErrConnDone {
+		t.Errorf("expected sql.ErrConnDone, got %v", err)
+	}
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestGet(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	model := Model{DB: mockDB}
+	columns := []string{"request_hash", "status", "header", "body", "expiry"}
+
+	// Test Case 1: Completed key
+	mock.ExpectQuery("SELECT request_hash, status, header, body, expiry FROM idempotency_keys WHERE user_id = \\$1 AND key = \\$2").
+		WithArgs(1, "abc").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow([]byte("hash"), 201, []byte(`{"Content-Type":["application/json"]}`), []byte(`{"ok":true}`), time.Now()))
+
+	key, err := model.Get(1, "abc")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if key.Status != http.StatusCreated || key.Header.Get("Content-Type") != "application/json" || string(key.Body) != `{"ok":true}` {
+		t.Errorf("unexpected key: %+v", key)
+	}
+
+	// Test Case 2: Key still in progress
+	mock.ExpectQuery("SELECT request_hash, status, header, body, expiry FROM idempotency_keys").
+		WithArgs(1, "pending").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow([]byte("hash"), nil, nil, nil, time.Now()))
+
+	key, err = model.Get(1, "pending")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if key.Status != 0 {
+		t.Errorf("expected zero status for in progress key, got %d", key.Status)
+	}
+
+	// Test Case 3: Key not found
+	mock.ExpectQuery("SELECT request_hash, status, header, body, expiry FROM idempotency_keys").
+		WithArgs(1, "missing").
+		WillReturnError(sql.ErrNoRows)
+
+	_, err = model.Get(1, "missing")
+	if err != database.ErrRecordNotFound {
+		t.Errorf("expected record not found error, got %v", err)
+	}
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestCompleteAndRelease(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	model := Model{DB: mockDB}
+	key := &Key{
+		UserID: 1,
+		Key:    "abc",
+		Status: http.StatusCreated,
+		Header: http.Header{"Content-Type": {"application/json"}},
+		Body:   []byte(`{"ok":true}`),
+		Expiry: time.Now().Add(24 * time.Hour),
+	}
+
+	mock.ExpectExec("UPDATE idempotency_keys SET status = \\$3, header = \\$4, body = \\$5, expiry = \\$6 WHERE user_id = \\$1 AND key = \\$2").
+		WithArgs(1, "abc", http.StatusCreated, []byte(`{"Content-Type":["application/json"]}`), []byte(`{"ok":true}`), key.Expiry).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = model.Complete(key)
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	mock.ExpectExec("DELETE FROM idempotency_keys WHERE user_id = \\$1 AND key = \\$2").
+		WithArgs(1, "abc").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = model.Release(1, "abc")
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestDeleteExpired(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	model := Model{DB: mockDB}
+
+	// Test Case 1: Expired keys deleted
+	mock.ExpectExec("DELETE FROM idempotency_keys WHERE expiry < now\\(\\)").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	err = model.DeleteExpired()
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	// Test Case 2: Database error
+	mock.ExpectExec("DELETE FROM idempotency_keys").
+		WillReturnError(sql.ErrConnDone)
+
+	err = model.DeleteExpired()
+	if err != sql.ErrConnDone {
+		t.Errorf("expected sql.ErrConnDone, got %v", err)
+	}
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package idempotency
+
+import (
+	"database/sql"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/navazjm/pixelarcade/internal/webapp/utils/logger"
+)
+
+type Config struct {
+	// How long a stored response is replayed for the same key
+	TTL time.Duration
+	// How long a key stays claimed by a request that is still being processed, so a
+	// key whose request never completed, e.g. because the server stopped, can be
+	// retried
+	ClaimTTL time.Duration
+}
+
+type Service struct {
+	Models Model
+	Logger *slog.Logger
+	Config *Config
+}
+
+func NewService(db *sql.DB, logger *slog.Logger, cfg *Config) *Service {
+	return &Service{
+		Models: Model{DB: db},
+		Logger: logger,
+		Config: cfg,
+	}
+}
+
+func newMockService(t *testing.T) (*Service, sqlmock.Sqlmock) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock DB: %v", err)
+	}
+
+	service := &Service{
+		Models: Model{DB: mockDB},
+		Logger: logger.NewMock(),
+		Config: &Config{TTL: 24 * time.Hour, ClaimTTL: time.Minute},
+	}
+
+	return service, mock
+}
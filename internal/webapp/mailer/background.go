@@ -0,0 +1,82 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Background wraps a Mailer so messages are delivered off the request goroutine.
+// Failed deliveries are retried with a linear backoff, and in-flight messages
+// can be drained with Shutdown before the process exits.
+type Background struct {
+	Mailer  Mailer
+	Logger  *slog.Logger
+	Retries int
+	Backoff time.Duration
+	wg      sync.WaitGroup
+}
+
+func NewBackground(m Mailer, logger *slog.Logger, retries int) *Background {
+	if retries < 1 {
+		retries = 1
+	}
+
+	return &Background{
+		Mailer:  m,
+		Logger:  logger,
+		Retries: retries,
+		Backoff: 500 * time.Millisecond,
+	}
+}
+
+// Send queues the message for delivery and returns immediately. Delivery errors
+// are logged rather than returned since the caller has already moved on.
+func (b *Background) Send(recipient, templateFile string, data any) error {
+	b.wg.Add(1)
+
+	go func() {
+		defer b.wg.Done()
+
+		defer func() {
+			if err := recover(); err != nil {
+				b.Logger.Error(fmt.Sprintf("%v", err), "recipient", recipient, "template", templateFile)
+			}
+		}()
+
+		var err error
+		for attempt := 1; attempt <= b.Retries; attempt++ {
+			err = b.Mailer.Send(recipient, templateFile, data)
+			if err == nil {
+				return
+			}
+
+			if attempt < b.Retries {
+				time.Sleep(time.Duration(attempt) * b.Backoff)
+			}
+		}
+
+		b.Logger.Error(err.Error(), "recipient", recipient, "template", templateFile, "attempts", b.Retries)
+	}()
+
+	return nil
+}
+
+// Shutdown blocks until every queued message has been handled or the context is
+// done, whichever happens first.
+func (b *Background) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
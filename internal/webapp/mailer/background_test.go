@@ -0,0 +1,94 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/navazjm/pixelarcade/internal/webapp/utils/logger"
+)
+
+type flakyMailer struct {
+	failures int32
+	calls    atomic.Int32
+}
+
+func (m *flakyMailer) Send(recipient, templateFile string, data any) error {
+	if m.calls.Add(1) <= m.failures {
+		return errors.New("temporary failure")
+	}
+	return nil
+}
+
+func TestBackground_SendRetries(t *testing.T) {
+	inner := &flakyMailer{failures: 2}
+	b := NewBackground(inner, logger.NewMock(), 3)
+	b.Backoff = time.Millisecond
+
+	err := b.Send("mike@test.com", "user_welcome.tmpl", nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	err = b.Shutdown(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if inner.calls.Load() != 3 {
+		t.Errorf("expected 3 delivery attempts, got %d", inner.calls.Load())
+	}
+}
+
+func TestBackground_SendGivesUp(t *testing.T) {
+	inner := &flakyMailer{failures: 10}
+	b := NewBackground(inner, logger.NewMock(), 2)
+	b.Backoff = time.Millisecond
+
+	b.Send("mike@test.com", "user_welcome.tmpl", nil)
+	b.Shutdown(context.Background())
+
+	if inner.calls.Load() != 2 {
+		t.Errorf("expected 2 delivery attempts, got %d", inner.calls.Load())
+	}
+}
+
+func TestBackground_ShutdownTimeout(t *testing.T) {
+	inner := &flakyMailer{failures: 10}
+	b := NewBackground(inner, logger.NewMock(), 5)
+	b.Backoff = time.Second
+
+	b.Send("mike@test.com", "user_welcome.tmpl", nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := b.Shutdown(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		cfg     Config
+		wantErr bool
+	}{
+		{Config{Backend: BackendLog}, false},
+		{Config{Backend: BackendSMTP, Host: "localhost", Port: 25, Sender: "no-reply@pixelarcade.dev"}, false},
+		{Config{Backend: BackendOutbox, OutboxDir: t.TempDir()}, false},
+		{Config{Backend: BackendOutbox}, true},
+		{Config{Backend: "carrier-pigeon"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cfg.Backend, func(t *testing.T) {
+			_, err := New(&tt.cfg, logger.NewMock())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error: %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
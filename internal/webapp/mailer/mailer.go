@@ -0,0 +1,224 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"sync"
+	tt "text/template"
+	"time"
+)
+
+// Email templates are embedded into the binary so the webapp can be shipped as a
+// single executable. Each template file must define the "subject", "plainBody" and
+// "htmlBody" named templates.
+//
+//go:embed "templates"
+var templateFS embed.FS
+
+const (
+	BackendLog    = "log"
+	BackendSMTP   = "smtp"
+	BackendOutbox = "outbox"
+)
+
+type Config struct {
+	Backend   string
+	Host      string
+	Port      int
+	Username  string
+	Password  string
+	Sender    string
+	OutboxDir string
+	Retries   int
+}
+
+type Mailer interface {
+	Send(recipient, templateFile string, data any) error
+}
+
+// New returns the Mailer for the configured backend, wrapped so messages are
+// delivered in the background with retries.
+func New(cfg *Config, logger *slog.Logger) (*Background, error) {
+	var m Mailer
+
+	switch cfg.Backend {
+	case BackendLog:
+		m = NewLog(logger)
+	case BackendSMTP:
+		m = NewSMTP(cfg.Host, cfg.Port, cfg.Username, cfg.Password, cfg.Sender)
+	case BackendOutbox:
+		outbox, err := NewOutbox(cfg.OutboxDir, cfg.Sender)
+		if err != nil {
+			return nil, err
+		}
+		m = outbox
+	default:
+		return nil, fmt.Errorf("unknown mailer backend %q", cfg.Backend)
+	}
+
+	return NewBackground(m, logger, cfg.Retries), nil
+}
+
+type Message struct {
+	Recipient string
+	Subject   string
+	PlainBody string
+	HTMLBody  string
+}
+
+// NewMessage renders the given template file with the provided dynamic data and
+// returns a message ready to be handed off to a Mailer implementation.
+func NewMessage(recipient, templateFile string, data any) (*Message, error) {
+	textTmpl, err := tt.New("").ParseFS(templateFS, "templates/"+templateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	subject := new(bytes.Buffer)
+	err = textTmpl.ExecuteTemplate(subject, "subject", data)
+	if err != nil {
+		return nil, err
+	}
+
+	plainBody := new(bytes.Buffer)
+	err = textTmpl.ExecuteTemplate(plainBody, "plainBody", data)
+	if err != nil {
+		return nil, err
+	}
+
+	// The HTML body is parsed with html/template so dynamic data is escaped.
+	htmlTmpl, err := template.New("").ParseFS(templateFS, "templates/"+templateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	htmlBody := new(bytes.Buffer)
+	err = htmlTmpl.ExecuteTemplate(htmlBody, "htmlBody", data)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &Message{
+		Recipient: recipient,
+		Subject:   subject.String(),
+		PlainBody: plainBody.String(),
+		HTMLBody:  htmlBody.String(),
+	}
+
+	return msg, nil
+}
+
+// Bytes encodes the message as an RFC 5322 email with plain text and HTML
+// alternatives, ready to be handed to an SMTP server or written to disk.
+func (msg *Message) Bytes(sender string) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	mw := multipart.NewWriter(buf)
+
+	fmt.Fprintf(buf, "From: %s\r\n", sender)
+	fmt.Fprintf(buf, "To: %s\r\n", msg.Recipient)
+	fmt.Fprintf(buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(buf, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", mw.Boundary())
+
+	parts := []struct {
+		contentType string
+		body        string
+	}{
+		{"text/plain; charset=utf-8", msg.PlainBody},
+		{"text/html; charset=utf-8", msg.HTMLBody},
+	}
+
+	for _, part := range parts {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", part.contentType)
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+
+		pw, err := mw.CreatePart(header)
+		if err != nil {
+			return nil, err
+		}
+
+		qw := quotedprintable.NewWriter(pw)
+		_, err = qw.Write([]byte(part.body))
+		if err != nil {
+			return nil, err
+		}
+
+		err = qw.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := mw.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Log is a Mailer which writes rendered messages to the logger instead of
+// delivering them. Useful in development when no mail server is available.
+type Log struct {
+	Logger *slog.Logger
+}
+
+func NewLog(logger *slog.Logger) *Log {
+	return &Log{Logger: logger}
+}
+
+func (m *Log) Send(recipient, templateFile string, data any) error {
+	msg, err := NewMessage(recipient, templateFile, data)
+	if err != nil {
+		return err
+	}
+
+	m.Logger.Info("email", "recipient", msg.Recipient, "subject", msg.Subject, "body", msg.PlainBody)
+	return nil
+}
+
+// ============================================================================
+// Mock Mailer for testing purposes
+// ============================================================================
+
+type Mock struct {
+	mu   sync.Mutex
+	Sent []*Message
+	Err  error
+}
+
+func (m *Mock) Send(recipient, templateFile string, data any) error {
+	if m.Err != nil {
+		return m.Err
+	}
+
+	msg, err := NewMessage(recipient, templateFile, data)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Sent = append(m.Sent, msg)
+
+	return nil
+}
+
+func (m *Mock) Messages() []*Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*Message(nil), m.Sent...)
+}
+
+func NewMock() *Mock {
+	return &Mock{}
+}
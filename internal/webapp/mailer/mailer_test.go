@@ -0,0 +1,69 @@
+package mailer
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/navazjm/pixelarcade/internal/webapp/utils/logger"
+)
+
+func TestNewMessage(t *testing.T) {
+	data := map[string]any{
+		"userName":        "<mike>",
+		"activationToken": "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+		"activationURL":   "http://localhost:3000/activate?token=ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	}
+
+	msg, err := NewMessage("mike@test.com", "user_welcome.tmpl", data)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if msg.Recipient != "mike@test.com" {
+		t.Errorf("expected recipient 'mike@test.com', got %s", msg.Recipient)
+	}
+	if msg.Subject != "Welcome to PixelArcade!" {
+		t.Errorf("unexpected subject %q", msg.Subject)
+	}
+	if !strings.Contains(msg.PlainBody, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") {
+		t.Errorf("expected plain body to contain activation token")
+	}
+	if !strings.Contains(msg.HTMLBody, "&lt;mike&gt;") {
+		t.Errorf("expected html body to escape dynamic data")
+	}
+}
+
+func TestNewMessage_MissingTemplate(t *testing.T) {
+	_, err := NewMessage("mike@test.com", "does_not_exist.tmpl", nil)
+	if err == nil {
+		t.Error("expected error for missing template, got nil")
+	}
+}
+
+func TestLog_Send(t *testing.T) {
+	m := NewLog(logger.NewMock())
+
+	err := m.Send("mike@test.com", "user_welcome.tmpl", map[string]any{})
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+func TestMock_Send(t *testing.T) {
+	m := NewMock()
+
+	err := m.Send("mike@test.com", "user_welcome.tmpl", map[string]any{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(m.Messages()) != 1 {
+		t.Errorf("expected 1 sent message, got %d", len(m.Messages()))
+	}
+
+	m.Err = errors.New("mock mailer error")
+	err = m.Send("mike@test.com", "user_welcome.tmpl", map[string]any{})
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+}
@@ -0,0 +1,53 @@
+package mailer
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Outbox is a Mailer which writes each message to a .eml file in a local
+// directory instead of delivering it, so dev and tests can run offline. The
+// files can be opened directly with any email client.
+type Outbox struct {
+	Dir    string
+	Sender string
+}
+
+func NewOutbox(dir, sender string) (*Outbox, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("missing outbox directory")
+	}
+
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Outbox{Dir: dir, Sender: sender}, nil
+}
+
+func (m *Outbox) Send(recipient, templateFile string, data any) error {
+	msg, err := NewMessage(recipient, templateFile, data)
+	if err != nil {
+		return err
+	}
+
+	body, err := msg.Bytes(m.Sender)
+	if err != nil {
+		return err
+	}
+
+	suffix := make([]byte, 4)
+	_, err = rand.Read(suffix)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), hex.EncodeToString(suffix))
+
+	return os.WriteFile(filepath.Join(m.Dir, name), body, 0o644)
+}
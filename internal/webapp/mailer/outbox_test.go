@@ -0,0 +1,49 @@
+package mailer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOutbox_Send(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "outbox")
+
+	m, err := NewOutbox(dir, "PixelArcade <no-reply@pixelarcade.dev>")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	data := map[string]any{"userName": "Mike", "activationToken": "ABCDEFGHIJKLMNOPQRSTUVWXYZ"}
+	err = m.Send("mike@test.com", "user_welcome.tmpl", data)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil {
+		t.Fatalf("failed to list outbox: %v", err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("expected 1 .eml file, got %d", len(files))
+	}
+
+	contents, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("failed to read .eml file: %v", err)
+	}
+
+	for _, want := range []string{"To: mike@test.com", "Subject: Welcome to PixelArcade!", "multipart/alternative"} {
+		if !strings.Contains(string(contents), want) {
+			t.Errorf("expected .eml file to contain %q", want)
+		}
+	}
+}
+
+func TestNewOutbox_MissingDir(t *testing.T) {
+	_, err := NewOutbox("", "no-reply@pixelarcade.dev")
+	if err == nil {
+		t.Error("expected error for missing directory, got nil")
+	}
+}
@@ -0,0 +1,118 @@
+package mailer
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"time"
+)
+
+type SMTP struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	Sender   string
+	Timeout  time.Duration
+
+	// Used for STARTTLS instead of the system defaults when set, e.g. to trust the
+	// certificate of a test server
+	tlsConfig *tls.Config
+}
+
+func NewSMTP(host string, port int, username, password, sender string) *SMTP {
+	return &SMTP{
+		Host:     host,
+		Port:     port,
+		Username: username,
+		Password: password,
+		Sender:   sender,
+		Timeout:  10 * time.Second,
+	}
+}
+
+func (m *SMTP) Send(recipient, templateFile string, data any) error {
+	msg, err := NewMessage(recipient, templateFile, data)
+	if err != nil {
+		return err
+	}
+
+	body, err := msg.Bytes(m.Sender)
+	if err != nil {
+		return err
+	}
+
+	// The sender may include a display name, e.g. "PixelArcade <no-reply@pixelarcade.dev>",
+	// but the SMTP envelope only accepts the bare address.
+	from, err := mail.ParseAddress(m.Sender)
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(m.Host, fmt.Sprint(m.Port))
+	conn, err := net.DialTimeout("tcp", addr, m.Timeout)
+	if err != nil {
+		return err
+	}
+
+	err = conn.SetDeadline(time.Now().Add(m.Timeout))
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, m.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		tlsConfig := m.tlsConfig
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{ServerName: m.Host}
+		}
+
+		err = client.StartTLS(tlsConfig)
+		if err != nil {
+			return err
+		}
+	}
+
+	if m.Username != "" {
+		err = client.Auth(smtp.PlainAuth("", m.Username, m.Password, m.Host))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = client.Mail(from.Address)
+	if err != nil {
+		return err
+	}
+
+	err = client.Rcpt(recipient)
+	if err != nil {
+		return err
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(body)
+	if err != nil {
+		return err
+	}
+
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
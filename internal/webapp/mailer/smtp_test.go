@@ -0,0 +1,162 @@
+package mailer
+
+import (
+	"bufio"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeSMTPServer accepts a single SMTP session and returns everything received
+// after the DATA command. With a TLS config, it offers STARTTLS and only accepts
+// AUTH PLAIN once the connection is encrypted.
+func fakeSMTPServer(t *testing.T, tlsConfig *tls.Config) (string, <-chan string) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start fake SMTP server: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	received := make(chan string, 1)
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		write := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		encrypted := false
+
+		write("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO") && tlsConfig != nil && !encrypted:
+				write("250-localhost")
+				write("250 STARTTLS")
+			case strings.HasPrefix(cmd, "EHLO") && encrypted:
+				write("250-localhost")
+				write("250 AUTH PLAIN")
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				write("250 localhost")
+			case cmd == "STARTTLS":
+				write("220 Ready to start TLS")
+				tlsConn := tls.Server(conn, tlsConfig)
+				if err := tlsConn.Handshake(); err != nil {
+					return
+				}
+				conn, r, encrypted = tlsConn, bufio.NewReader(tlsConn), true
+			case strings.HasPrefix(cmd, "AUTH"):
+				if !encrypted {
+					write("530 Must issue a STARTTLS command first")
+					continue
+				}
+				write("235 Authentication successful")
+			case strings.HasPrefix(cmd, "MAIL"), strings.HasPrefix(cmd, "RCPT"):
+				write("250 OK")
+			case cmd == "DATA":
+				write("354 End data with <CR><LF>.<CR><LF>")
+				var data strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					data.WriteString(l)
+				}
+				received <- data.String()
+				write("250 OK")
+			case cmd == "QUIT":
+				write("221 Bye")
+				return
+			default:
+				write("250 OK")
+			}
+		}
+	}()
+
+	return ln.Addr().String(), received
+}
+
+func TestSMTP_Send(t *testing.T) {
+	addr, received := fakeSMTPServer(t, nil)
+	host, port, _ := net.SplitHostPort(addr)
+	portNum, _ := strconv.Atoi(port)
+
+	m := NewSMTP(host, portNum, "", "", "PixelArcade <no-reply@pixelarcade.dev>")
+
+	err := m.Send("mike@test.com", "user_welcome.tmpl", map[string]any{"userName": "Mike"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	data := <-received
+	if !strings.Contains(data, "To: mike@test.com") {
+		t.Errorf("expected message to be addressed to mike@test.com, got %s", data)
+	}
+}
+
+func TestSMTP_SendSTARTTLS(t *testing.T) {
+	// Borrow the certificate httptest generates for 127.0.0.1, and the pool trusting it
+	tlsServer := httptest.NewTLSServer(http.NotFoundHandler())
+	defer tlsServer.Close()
+	rootCAs := tlsServer.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+
+	addr, received := fakeSMTPServer(t, &tls.Config{Certificates: tlsServer.TLS.Certificates})
+	host, port, _ := net.SplitHostPort(addr)
+	portNum, _ := strconv.Atoi(port)
+
+	m := NewSMTP(host, portNum, "mike", "secret", "PixelArcade <no-reply@pixelarcade.dev>")
+	m.tlsConfig = &tls.Config{ServerName: host, RootCAs: rootCAs}
+
+	err := m.Send("mike@test.com", "user_welcome.tmpl", map[string]any{"userName": "Mike"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	data := <-received
+	if !strings.Contains(data, "To: mike@test.com") {
+		t.Errorf("expected message to be addressed to mike@test.com, got %s", data)
+	}
+}
+
+func TestSMTP_SendSTARTTLSServerName(t *testing.T) {
+	addr, _ := fakeSMTPServer(t, &tls.Config{})
+	host, port, _ := net.SplitHostPort(addr)
+	portNum, _ := strconv.Atoi(port)
+
+	m := NewSMTP(host, portNum, "", "", "PixelArcade <no-reply@pixelarcade.dev>")
+
+	// The default config verifies the certificate against the host, instead of failing
+	// for lack of a server name
+	err := m.Send("mike@test.com", "user_welcome.tmpl", map[string]any{"userName": "Mike"})
+	if err == nil || strings.Contains(err.Error(), "ServerName") {
+		t.Errorf("expected the certificate to be verified, got %v", err)
+	}
+}
+
+func TestSMTP_SendInvalidSender(t *testing.T) {
+	m := NewSMTP("127.0.0.1", 25, "", "", "not an address")
+
+	err := m.Send("mike@test.com", "user_welcome.tmpl", map[string]any{})
+	if err == nil {
+		t.Error("expected error for invalid sender, got nil")
+	}
+}
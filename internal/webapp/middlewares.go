@@ -3,9 +3,12 @@ package webapp
 import (
 	"fmt"
 	"net/http"
+	"strings"
 
-	"golang.org/x/time/rate"
-
+	"github.com/navazjm/pixelarcade/internal/webapp/auth"
+	"github.com/navazjm/pixelarcade/internal/webapp/ratelimit"
+	"github.com/navazjm/pixelarcade/internal/webapp/utils/clientip"
+	"github.com/navazjm/pixelarcade/internal/webapp/utils/requestid"
 	"github.com/navazjm/pixelarcade/internal/webapp/utils/response"
 )
 
@@ -36,9 +39,32 @@ func (app *Application) secureHeaders(next http.Handler) http.Handler {
 	})
 }
 
+// requestID tags every request with an ID, echoed in the response, so log lines and
+// audit events of a request can be tied together. An ID set by a proxy in front of the
+// webapp is kept if it is safe to log.
+func (app *Application) requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestid.Header)
+		if !requestid.Valid(id) {
+			id = requestid.New()
+		}
+
+		w.Header().Set(requestid.Header, id)
+		next.ServeHTTP(w, requestid.Set(r, id))
+	})
+}
+
+// realIP resolves the address of the client once for the rest of the chain, looking
+// past the trusted proxies in front of the webapp.
+func (app *Application) realIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, clientip.Set(r, clientip.FromRequest(r, app.Config.TrustedProxies)))
+	})
+}
+
 func (app *Application) logRequest(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		app.Logger.Info(fmt.Sprintf("%s - %s %s %s", r.RemoteAddr, r.Proto, r.Method, r.URL.RequestURI()))
+		app.Logger.Info(fmt.Sprintf("%s - %s %s %s", clientip.Get(r), r.Proto, r.Method, r.URL.RequestURI()), "request_id", requestid.Get(r))
 
 		next.ServeHTTP(w, r)
 	})
@@ -62,7 +88,9 @@ func (app *Application) enforceCORS(next http.Handler) http.Handler {
 		w.Header().Add("Vary", "Origin")
 		w.Header().Add("Vary", "Access-Control-Request-Method")
 		origin := r.Header.Get("Origin")
-		if origin != "" {
+		// Hosted games run sandboxed with a "null" origin and fetch their own public
+		// files, which are served with their own CORS headers
+		if origin != "" && !strings.HasPrefix(r.URL.Path, "/play/") {
 			app.Logger.Info("CORS request", "origin", origin)
 			trusted := false
 			for i := range app.Config.TrustedOrigins {
@@ -81,7 +109,7 @@ func (app *Application) enforceCORS(next http.Handler) http.Handler {
 			// Handle pre-flight requests (OPTIONS)
 			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
 				w.Header().Set("Access-Control-Allow-Methods", "OPTIONS, GET, POST, PUT, PATCH, DELETE")
-				w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+				w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, Idempotency-Key")
 
 				w.WriteHeader(http.StatusOK)
 				return
@@ -91,17 +119,60 @@ func (app *Application) enforceCORS(next http.Handler) http.Handler {
 	})
 }
 
-func (app *Application) rateLimit(next http.Handler) http.Handler {
-	// Initialize a new rate limiter which allows an average of 2 requests per second,
-	// with a maximum of 8 requests in a single ‘burst’.
-	limiter := rate.NewLimiter(2, 8)
+// rateLimitByIP limits the requests of each IP, so a single noisy client can't slow the
+// site down for everyone else. Runs before authentication, so requests with made up
+// auth tokens are turned away before they cost a lookup.
+func (app *Application) rateLimitByIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.takeRateLimit(w, r, "ip:"+clientip.Get(r)) {
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
 
+// rateLimitByUser also limits the requests of each authenticated user, so switching
+// between addresses doesn't get a user around their limit. Runs after authentication
+// for that reason.
+func (app *Application) rateLimitByUser(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if !limiter.Allow() {
-			response.RateLimitExceeded(w, r, app.Logger)
+		user := auth.ContextGetUser(r)
+		if !user.IsAnonymous() && !app.takeRateLimit(w, r, fmt.Sprintf("user:%d", user.ID)) {
 			return
 		}
 
 		next.ServeHTTP(w, r)
 	})
 }
+
+// takeRateLimit counts the request against the limit of the key, and responds with a
+// 429 if it's exceeded. Reports whether the request may go on.
+func (app *Application) takeRateLimit(w http.ResponseWriter, r *http.Request, key string) bool {
+	cfg := &app.Config.RateLimit
+	if !cfg.Enabled {
+		return true
+	}
+
+	// Route groups have buckets of their own. Only the API counts against the default
+	// limit, pages load many frontend assets and game bundle files at once.
+	rule := cfg.Match(r.URL.Path)
+	if rule.Prefix == "" && !isAPIPath(r.URL.Path) {
+		return true
+	}
+
+	res, err := app.RateLimiter.Take(r.Context(), rule.Prefix+" "+key, rule)
+	if err != nil {
+		// An unreachable store shouldn't take the whole site down with it
+		app.Logger.Error(err.Error(), "request_id", requestid.Get(r))
+		return true
+	}
+
+	ratelimit.WriteHeaders(w, res)
+	if !res.Allowed {
+		response.RateLimitExceeded(w, r, app.Logger)
+		return false
+	}
+
+	return true
+}
@@ -1,10 +1,19 @@
 package webapp
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
 	"strings"
 	"testing"
+
+	"github.com/navazjm/pixelarcade/internal/webapp/auth"
+	"github.com/navazjm/pixelarcade/internal/webapp/ratelimit"
+	"github.com/navazjm/pixelarcade/internal/webapp/utils/clientip"
+	"github.com/navazjm/pixelarcade/internal/webapp/utils/requestid"
 )
 
 func normalizeHeader(header string) string {
@@ -62,6 +71,51 @@ func TestLogRequest(t *testing.T) {
 	}
 }
 
+func TestRequestID(t *testing.T) {
+	app := setupTestApp()
+
+	var seen string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = requestid.Get(r)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	handler := app.requestID(next)
+
+	tests := []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{name: "Generated when missing", incoming: "", keep: false},
+		{name: "Kept from proxy", incoming: "req_01.abc-DEF", keep: true},
+		{name: "Replaced when unsafe", incoming: "abc\ndef", keep: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.incoming != "" {
+				req.Header.Set(requestid.Header, tt.incoming)
+			}
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			got := rec.Header().Get(requestid.Header)
+			if !requestid.Valid(got) {
+				t.Fatalf("expected a valid request ID header, got %q", got)
+			}
+			if seen != got {
+				t.Errorf("expected handler to see request ID %q, got %q", got, seen)
+			}
+			if (got == tt.incoming) != tt.keep {
+				t.Errorf("expected incoming ID kept to be %v, got %q", tt.keep, got)
+			}
+		})
+	}
+}
+
 func TestRecoverPanic(t *testing.T) {
 	app := setupTestApp()
 
@@ -118,6 +172,18 @@ func TestEnforceCORS(t *testing.T) {
 	if rec.Code != http.StatusForbidden {
 		t.Errorf("expected status %d, got %d", http.StatusForbidden, rec.Code)
 	}
+
+	// Test a sandboxed game requesting its own files
+	req = httptest.NewRequest(http.MethodGet, "/play/1/1/game.wasm", nil)
+	req.Header.Set("Origin", "null")
+	rec = httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	// Assert that the request is left to the play handler
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
 }
 
 func TestRateLimit(t *testing.T) {
@@ -155,3 +221,156 @@ func TestRateLimit(t *testing.T) {
 		}
 	}
 }
+
+func TestRateLimit_PerClient(t *testing.T) {
+	app := setupTestApp()
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	handler := app.rateLimitByIP(app.rateLimitByUser(next))
+
+	newRequest := func(path, ip string, user *auth.User) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req = clientip.Set(req, ip)
+		return auth.ContextSetUser(req, user)
+	}
+
+	// Exhaust the burst of one client
+	for i := 0; i < 8; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, newRequest("/api/games", "192.0.2.1", auth.AnonymousUser))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, newRequest("/api/games", "192.0.2.1", auth.AnonymousUser))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected status %d, got %d", http.StatusTooManyRequests, rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "1" || rec.Header().Get("RateLimit-Limit") != "8" || rec.Header().Get("RateLimit-Remaining") != "0" {
+		t.Errorf("unexpected rate limit headers: %v", rec.Header())
+	}
+
+	// Another address is unaffected
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, newRequest("/api/games", "192.0.2.2", auth.AnonymousUser))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	// Signed in users have a limit of their own, whichever address they come from
+	for i := 0; i < 9; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, newRequest("/api/games", fmt.Sprintf("198.51.100.%d", i), &auth.User{ID: 1}))
+
+		expected := http.StatusOK
+		if i == 8 {
+			expected = http.StatusTooManyRequests
+		}
+		if rec.Code != expected {
+			t.Errorf("expected request %d of the user to get status %d, got %d", i+1, expected, rec.Code)
+		}
+	}
+
+	// Route groups are limited separately, and by their own rule
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, newRequest("/api/auth/login", "192.0.2.1", auth.AnonymousUser))
+
+		expected := http.StatusOK
+		if i == 2 {
+			expected = http.StatusTooManyRequests
+		}
+		if rec.Code != expected {
+			t.Errorf("expected login attempt %d to get status %d, got %d", i+1, expected, rec.Code)
+		}
+	}
+
+	// Nothing is limited when disabled
+	app.Config.RateLimit.Enabled = false
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, newRequest("/api/games", "192.0.2.1", auth.AnonymousUser))
+	if rec.Code != http.StatusOK || rec.Header().Get("RateLimit-Limit") != "" {
+		t.Errorf("expected request to pass through unchecked, got status %d", rec.Code)
+	}
+}
+
+func TestRateLimit_StaticFiles(t *testing.T) {
+	app := setupTestApp()
+
+	handler := app.rateLimitByIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// A game bundle and the frontend have more files than the burst of the default
+	// limit, all loaded at once
+	paths := []string{"/", "/favicon.ico"}
+	for i := 0; i < 12; i++ {
+		paths = append(paths, fmt.Sprintf("/play/1/2/assets/sprite%d.png", i), fmt.Sprintf("/assets/chunk%d.js", i))
+	}
+
+	for _, path := range paths {
+		req := clientip.Set(httptest.NewRequest(http.MethodGet, path, nil), "192.0.2.1")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status %d for %s, got %d", http.StatusOK, path, rec.Code)
+		}
+	}
+
+	// The API is still limited
+	req := clientip.Set(httptest.NewRequest(http.MethodGet, "/api/games", nil), "192.0.2.1")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Header().Get("RateLimit-Remaining") != "7" {
+		t.Errorf("expected the API request to be the first counted, got %q remaining", rec.Header().Get("RateLimit-Remaining"))
+	}
+}
+
+type failingStore struct{}
+
+func (failingStore) Take(ctx context.Context, key string, rule ratelimit.Rule) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("connection refused")
+}
+
+func TestRateLimit_StoreUnavailable(t *testing.T) {
+	app := setupTestApp()
+	app.RateLimiter = failingStore{}
+
+	handler := app.rateLimitByIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := auth.ContextSetUser(httptest.NewRequest(http.MethodGet, "/api/games", nil), auth.AnonymousUser)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	// Requests are let through rather than failing
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+}
+
+func TestRealIP(t *testing.T) {
+	app := setupTestApp()
+	app.Config.TrustedProxies = []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+
+	var seen string
+	handler := app.realIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = clientip.Get(r)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.2:1234"
+	req.Header.Set(clientip.HeaderForwardedFor, "198.51.100.7")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if seen != "198.51.100.7" {
+		t.Errorf("expected client IP to be read from the trusted proxy header, got %q", seen)
+	}
+}
@@ -0,0 +1,173 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Mock identity provider for testing purposes
+// ============================================================================
+
+type mockGrant struct {
+	claims    Claims
+	challenge string
+}
+
+// MockIdP is a minimal in-process OpenID Connect provider supporting discovery,
+// the authorization code + PKCE flow and RS256 signed ID tokens.
+type MockIdP struct {
+	Server   *httptest.Server
+	ClientID string
+
+	key    *rsa.PrivateKey
+	mu     sync.Mutex
+	grants map[string]mockGrant
+}
+
+func NewMockIdP(clientID string) *MockIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+
+	m := &MockIdP{
+		ClientID: clientID,
+		key:      key,
+		grants:   make(map[string]mockGrant),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(discovery{
+			Issuer:                m.Issuer(),
+			AuthorizationEndpoint: m.Issuer() + "/authorize",
+			TokenEndpoint:         m.Issuer() + "/token",
+			JWKSURI:               m.Issuer() + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jwks{Keys: []jwk{{
+			Kty: "RSA",
+			Kid: "mock",
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", m.handleToken)
+
+	m.Server = httptest.NewServer(mux)
+	return m
+}
+
+func (m *MockIdP) Issuer() string {
+	return m.Server.URL
+}
+
+func (m *MockIdP) Close() {
+	m.Server.Close()
+}
+
+// Authorize simulates the user signing in at the provider with the given
+// claims. It returns the code and state the provider would send to the redirect URL.
+func (m *MockIdP) Authorize(authCodeURL string, claims Claims) (code, state string, err error) {
+	u, err := url.Parse(authCodeURL)
+	if err != nil {
+		return "", "", err
+	}
+
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" {
+		return "", "", fmt.Errorf("mock idp: missing S256 code challenge")
+	}
+
+	code, err = RandomString()
+	if err != nil {
+		return "", "", err
+	}
+
+	claims.Nonce = q.Get("nonce")
+
+	m.mu.Lock()
+	m.grants[code] = mockGrant{claims: claims, challenge: q.Get("code_challenge")}
+	m.mu.Unlock()
+
+	return code, q.Get("state"), nil
+}
+
+func (m *MockIdP) handleToken(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	m.mu.Lock()
+	grant, ok := m.grants[r.PostForm.Get("code")]
+	delete(m.grants, r.PostForm.Get("code"))
+	m.mu.Unlock()
+
+	if !ok || S256Challenge(r.PostForm.Get("code_verifier")) != grant.challenge {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+
+	idToken, err := m.Sign(grant.claims)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"access_token": "mock-access-token",
+		"token_type":   "Bearer",
+		"id_token":     idToken,
+	})
+}
+
+// Sign issues an RS256 ID token for the given claims, filling in the issuer,
+// audience and expiry when they are not set.
+func (m *MockIdP) Sign(claims Claims) (string, error) {
+	if claims.Issuer == "" {
+		claims.Issuer = m.Issuer()
+	}
+	if claims.Audience == nil {
+		claims.Audience = m.ClientID
+	}
+	if claims.Expiry == 0 {
+		claims.Expiry = time.Now().Add(5 * time.Minute).Unix()
+	}
+	claims.IssuedAt = time.Now().Unix()
+
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "kid": "mock", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sum := sha256.Sum256([]byte(signed))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, m.key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
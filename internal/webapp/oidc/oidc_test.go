@@ -0,0 +1,213 @@
+package oidc
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestProvider(t *testing.T) (*Provider, *MockIdP) {
+	t.Helper()
+
+	idp := NewMockIdP("pixelarcade")
+	t.Cleanup(idp.Close)
+
+	provider := NewProvider(Config{
+		Name:        "mock",
+		Issuer:      idp.Issuer(),
+		ClientID:    "pixelarcade",
+		RedirectURL: "http://localhost:8080/api/auth/oauth/mock/callback",
+	})
+
+	return provider, idp
+}
+
+func TestProvider_AuthCodeURL(t *testing.T) {
+	provider, _ := newTestProvider(t)
+
+	authURL, err := provider.AuthCodeURL(context.Background(), "state", "nonce", "verifier")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("failed to parse auth URL: %v", err)
+	}
+
+	q := u.Query()
+	expected := map[string]string{
+		"response_type":         "code",
+		"client_id":             "pixelarcade",
+		"state":                 "state",
+		"nonce":                 "nonce",
+		"code_challenge":        S256Challenge("verifier"),
+		"code_challenge_method": "S256",
+		"scope":                 "openid email profile",
+	}
+	for key, want := range expected {
+		if q.Get(key) != want {
+			t.Errorf("expected %s to be %q, got %q", key, want, q.Get(key))
+		}
+	}
+}
+
+func TestProvider_Exchange(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("SUCCESS Valid code and verifier", func(t *testing.T) {
+		provider, idp := newTestProvider(t)
+
+		authURL, _ := provider.AuthCodeURL(ctx, "state", "nonce", "verifier")
+		code, _, err := idp.Authorize(authURL, Claims{Subject: "123", Email: "mike@test.com", EmailVerified: true})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		claims, err := provider.Exchange(ctx, code, "verifier", "nonce")
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if claims.Email != "mike@test.com" || !claims.EmailVerified {
+			t.Errorf("unexpected claims %+v", claims)
+		}
+	})
+
+	t.Run("ERROR Wrong code verifier", func(t *testing.T) {
+		provider, idp := newTestProvider(t)
+
+		authURL, _ := provider.AuthCodeURL(ctx, "state", "nonce", "verifier")
+		code, _, _ := idp.Authorize(authURL, Claims{Subject: "123"})
+
+		_, err := provider.Exchange(ctx, code, "wrong-verifier", "nonce")
+		if err == nil {
+			t.Error("expected error, got nil")
+		}
+	})
+
+	t.Run("ERROR Nonce mismatch", func(t *testing.T) {
+		provider, idp := newTestProvider(t)
+
+		authURL, _ := provider.AuthCodeURL(ctx, "state", "nonce", "verifier")
+		code, _, _ := idp.Authorize(authURL, Claims{Subject: "123"})
+
+		_, err := provider.Exchange(ctx, code, "verifier", "other-nonce")
+		if !errors.Is(err, ErrNonceMismatch) {
+			t.Errorf("expected ErrNonceMismatch, got %v", err)
+		}
+	})
+}
+
+func TestProvider_Verify(t *testing.T) {
+	ctx := context.Background()
+	provider, idp := newTestProvider(t)
+
+	tests := []struct {
+		name    string
+		claims  Claims
+		wantErr bool
+	}{
+		{"Valid token", Claims{Subject: "123"}, false},
+		{"Wrong issuer", Claims{Subject: "123", Issuer: "https://evil.example.com"}, true},
+		{"Wrong audience", Claims{Subject: "123", Audience: "someone-else"}, true},
+		{"Audience list", Claims{Subject: "123", Audience: []any{"someone-else", "pixelarcade"}}, false},
+		{"Expired token", Claims{Subject: "123", Expiry: time.Now().Add(-time.Minute).Unix()}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idToken, err := idp.Sign(tt.claims)
+			if err != nil {
+				t.Fatalf("failed to sign token: %v", err)
+			}
+
+			_, err = provider.Verify(ctx, idToken)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error: %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	t.Run("Tampered payload", func(t *testing.T) {
+		idToken, _ := idp.Sign(Claims{Subject: "123"})
+		other, _ := idp.Sign(Claims{Subject: "456"})
+
+		parts := strings.Split(idToken, ".")
+		otherParts := strings.Split(other, ".")
+		tampered := parts[0] + "." + otherParts[1] + "." + parts[2]
+
+		_, err := provider.Verify(ctx, tampered)
+		if !errors.Is(err, ErrInvalidIDToken) {
+			t.Errorf("expected ErrInvalidIDToken, got %v", err)
+		}
+	})
+}
+
+// countingTransport counts the requests made to each path.
+type countingTransport struct {
+	mu       sync.Mutex
+	requests map[string]int
+}
+
+func (c *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	c.requests[r.URL.Path]++
+	c.mu.Unlock()
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func (c *countingTransport) count(path string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.requests[path]
+}
+
+func TestProvider_VerifyUnknownKey(t *testing.T) {
+	ctx := context.Background()
+	provider, idp := newTestProvider(t)
+
+	transport := &countingTransport{requests: map[string]int{}}
+	provider.HTTPClient.Transport = transport
+
+	idToken, _ := idp.Sign(Claims{Subject: "123"})
+	_, err := provider.Verify(ctx, idToken)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// Tokens with made up key ids don't fetch the JWKS again right after it was fetched
+	parts := strings.Split(idToken, ".")
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","kid":"forged"}`))
+	forged := header + "." + parts[1] + "." + parts[2]
+
+	for range 5 {
+		_, err = provider.Verify(ctx, forged)
+		if !errors.Is(err, ErrInvalidIDToken) {
+			t.Errorf("expected ErrInvalidIDToken, got %v", err)
+		}
+	}
+
+	if n := transport.count("/jwks"); n != 1 {
+		t.Errorf("expected the JWKS to be fetched once, got %d", n)
+	}
+	if n := transport.count("/.well-known/openid-configuration"); n != 1 {
+		t.Errorf("expected the discovery document to be fetched once, got %d", n)
+	}
+}
+
+func TestS256Challenge(t *testing.T) {
+	// Test vector from RFC 7636, Appendix B
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	expected := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	if got := S256Challenge(verifier); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
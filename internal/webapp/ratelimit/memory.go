@@ -0,0 +1,89 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Memory keeps a token bucket per client key in memory, meaning every replica of the
+// webapp limits clients on its own. Buckets of clients idle for longer than the idle
+// timeout are dropped, so the map doesn't grow with every address that ever made a
+// request.
+type Memory struct {
+	mu        sync.Mutex
+	clients   map[string]*client
+	idle      time.Duration
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type client struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func NewMemory(idleTimeout time.Duration) *Memory {
+	return &Memory{
+		clients:   make(map[string]*client),
+		idle:      idleTimeout,
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// Take takes a request from the bucket of the given key, creating it from the rule on
+// first use. Never fails.
+func (l *Memory) Take(ctx context.Context, key string, rule Rule) (Result, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastSweep) >= l.idle {
+		l.sweep(now)
+	}
+
+	c, ok := l.clients[key]
+	if !ok {
+		c = &client{limiter: rate.NewLimiter(rate.Limit(rule.Rate), rule.Burst)}
+		l.clients[key] = c
+	}
+	c.lastSeen = now
+
+	allowed := c.limiter.AllowN(now, 1)
+	tokens := c.limiter.TokensAt(now)
+
+	res := Result{
+		Allowed:   allowed,
+		Limit:     rule.Burst,
+		Remaining: max(int(math.Floor(tokens)), 0),
+		Reset:     refill(float64(rule.Burst)-tokens, rule.Rate),
+	}
+	if !allowed {
+		res.RetryAfter = refill(1-tokens, rule.Rate)
+	}
+
+	return res, nil
+}
+
+// sweep drops the buckets of clients idle for longer than the idle timeout. Runs at
+// most once per timeout, as part of a request, rather than in a goroutine of its own.
+func (l *Memory) sweep(now time.Time) {
+	for key, c := range l.clients {
+		if now.Sub(c.lastSeen) >= l.idle {
+			delete(l.clients, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// refill returns how long it takes to refill the given number of tokens.
+func refill(tokens, rate float64) time.Duration {
+	if tokens <= 0 || rate <= 0 {
+		return 0
+	}
+	return time.Duration(tokens / rate * float64(time.Second))
+}
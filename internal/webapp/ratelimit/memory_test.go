@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func newTestMemory(idle time.Duration) (*Memory, *time.Time) {
+	now := time.Now()
+	l := NewMemory(idle)
+	l.lastSweep = now
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func take(t *testing.T, store Store, key string, rule Rule) Result {
+	t.Helper()
+
+	res, err := store.Take(context.Background(), key, rule)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	return res
+}
+
+func TestMemory_Take(t *testing.T) {
+	l, now := newTestMemory(time.Minute)
+	rule := Rule{Rate: 2, Burst: 3}
+
+	for i := 0; i < 3; i++ {
+		res := take(t, l, "ip:192.0.2.1", rule)
+		if !res.Allowed {
+			t.Fatalf("expected request %d to be allowed", i+1)
+		}
+		if res.Remaining != 2-i {
+			t.Errorf("expected %d remaining requests, got %d", 2-i, res.Remaining)
+		}
+	}
+
+	res := take(t, l, "ip:192.0.2.1", rule)
+	if res.Allowed {
+		t.Fatalf("expected request over the burst to be refused")
+	}
+	if res.RetryAfter != 500*time.Millisecond {
+		t.Errorf("expected to retry after 500ms, got %v", res.RetryAfter)
+	}
+	if res.Reset != 1500*time.Millisecond {
+		t.Errorf("expected bucket to be full after 1.5s, got %v", res.Reset)
+	}
+
+	// Other clients have their own bucket
+	if res := take(t, l, "ip:192.0.2.2", rule); !res.Allowed {
+		t.Errorf("expected another client to be allowed")
+	}
+
+	// The bucket refills over time
+	*now = now.Add(500 * time.Millisecond)
+	if res := take(t, l, "ip:192.0.2.1", rule); !res.Allowed {
+		t.Errorf("expected request to be allowed once a token was refilled")
+	}
+}
+
+func TestMemory_Sweep(t *testing.T) {
+	l, now := newTestMemory(time.Minute)
+	rule := Rule{Rate: 2, Burst: 3}
+
+	take(t, l, "ip:192.0.2.1", rule)
+	*now = now.Add(30 * time.Second)
+	take(t, l, "ip:192.0.2.2", rule)
+
+	// Only the first client has been idle for a full minute
+	*now = now.Add(30 * time.Second)
+	take(t, l, "ip:192.0.2.2", rule)
+
+	if _, ok := l.clients["ip:192.0.2.1"]; ok {
+		t.Errorf("expected idle client to be evicted")
+	}
+	if _, ok := l.clients["ip:192.0.2.2"]; !ok {
+		t.Errorf("expected active client to be kept")
+	}
+}
@@ -0,0 +1,106 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/navazjm/pixelarcade/internal/webapp/utils/response"
+)
+
+const (
+	BackendMemory = "memory"
+	BackendRedis  = "redis"
+)
+
+type Config struct {
+	// Requests are let through unchecked when disabled, e.g. when a proxy in front of
+	// the webapp already limits them
+	Enabled bool
+	Backend string
+	// How long a client's limiter is kept after its last request by the memory backend
+	IdleTimeout time.Duration
+	// Server shared by every replica of the webapp, e.g.
+	// redis://:password@localhost:6379/0
+	RedisURL string
+	// Limit applied to requests not matching any route group
+	Default Rule
+	// Limits of route groups, e.g. a stricter one for logging in
+	Routes []Rule
+}
+
+// Rule is a token bucket refilled at Rate tokens per second and holding up to Burst
+// tokens. Each request takes one.
+type Rule struct {
+	// Path prefix of the route group, empty for the default rule
+	Prefix string
+	Rate   float64
+	Burst  int
+}
+
+// Window returns how long it takes to refill an empty bucket. Stores counting requests
+// over a sliding window rather than keeping a bucket allow Burst requests per Window.
+func (rule Rule) Window() time.Duration {
+	return time.Duration(float64(rule.Burst) / rule.Rate * float64(time.Second))
+}
+
+// Store keeps track of the requests made by each client. The memory store is enough
+// for a single replica of the webapp, once there are more they have to share a store
+// for limits to hold.
+type Store interface {
+	// Take counts a request against the limit of the key, returning whether it's
+	// allowed.
+	Take(ctx context.Context, key string, rule Rule) (Result, error)
+}
+
+// New returns the Store for the configured backend.
+func New(cfg *Config) (Store, error) {
+	switch cfg.Backend {
+	case BackendMemory:
+		return NewMemory(cfg.IdleTimeout), nil
+	case BackendRedis:
+		return NewRedis(cfg.RedisURL)
+	default:
+		return nil, fmt.Errorf("unknown rate limit backend %q", cfg.Backend)
+	}
+}
+
+// Match returns the rule of the route group with the longest prefix matching the path,
+// or the default rule. Route groups are limited separately, so a client hitting the
+// login limit can still browse the rest of the site.
+func (c *Config) Match(path string) Rule {
+	match := c.Default
+	for _, rule := range c.Routes {
+		if strings.HasPrefix(path, rule.Prefix) && len(rule.Prefix) > len(match.Prefix) {
+			match = rule
+		}
+	}
+	return match
+}
+
+// Result describes the state of a client's bucket after taking a request from it.
+type Result struct {
+	Allowed bool
+	// Size of the bucket
+	Limit int
+	// Requests that can be made right away
+	Remaining int
+	// Until the bucket is full again
+	Reset time.Duration
+	// Until the next request is allowed, zero if this one was
+	RetryAfter time.Duration
+}
+
+// WriteHeaders sets the RateLimit-* headers describing the result, and Retry-After if
+// the request was refused.
+func WriteHeaders(w http.ResponseWriter, res Result) {
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	w.Header().Set("RateLimit-Reset", response.Seconds(res.Reset))
+	if !res.Allowed {
+		response.RetryAfter(w, res.RetryAfter)
+	}
+}
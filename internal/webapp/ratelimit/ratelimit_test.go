@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestConfig_Match(t *testing.T) {
+	cfg := &Config{
+		Default: Rule{Rate: 2, Burst: 8},
+		Routes: []Rule{
+			{Prefix: "/api/auth", Rate: 1, Burst: 4},
+			{Prefix: "/api/auth/login", Rate: 0.2, Burst: 5},
+		},
+	}
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{path: "/api/games", expected: ""},
+		{path: "/api/auth/user", expected: "/api/auth"},
+		{path: "/api/auth/login", expected: "/api/auth/login"},
+	}
+
+	for _, tt := range tests {
+		rule := cfg.Match(tt.path)
+		if rule.Prefix != tt.expected {
+			t.Errorf("expected %q to match route group %q, got %q", tt.path, tt.expected, rule.Prefix)
+		}
+	}
+}
+
+func TestWriteHeaders(t *testing.T) {
+	w := httptest.NewRecorder()
+	WriteHeaders(w, Result{Allowed: true, Limit: 8, Remaining: 7, Reset: 500 * time.Millisecond})
+
+	if w.Header().Get("RateLimit-Limit") != "8" || w.Header().Get("RateLimit-Remaining") != "7" || w.Header().Get("RateLimit-Reset") != "1" {
+		t.Errorf("unexpected rate limit headers: %v", w.Header())
+	}
+	if w.Header().Get("Retry-After") != "" {
+		t.Errorf("expected no Retry-After header for an allowed request")
+	}
+
+	w = httptest.NewRecorder()
+	WriteHeaders(w, Result{Allowed: false, Limit: 8, Remaining: 0, Reset: 4 * time.Second, RetryAfter: 1500 * time.Millisecond})
+
+	if w.Header().Get("Retry-After") != "2" {
+		t.Errorf("expected Retry-After header to be 2, got %q", w.Header().Get("Retry-After"))
+	}
+}
+
+func TestNew(t *testing.T) {
+	store, err := New(&Config{Backend: BackendMemory, IdleTimeout: time.Minute})
+	if _, ok := store.(*Memory); !ok || err != nil {
+		t.Errorf("expected memory store, got %T (%v)", store, err)
+	}
+
+	store, err = New(&Config{Backend: BackendRedis, RedisURL: "redis://localhost:6379"})
+	if _, ok := store.(*Redis); !ok || err != nil {
+		t.Errorf("expected Redis store, got %T (%v)", store, err)
+	}
+
+	_, err = New(&Config{Backend: BackendRedis, RedisURL: "localhost:6379"})
+	if err == nil {
+		t.Errorf("expected error for invalid Redis URL, got none")
+	}
+
+	_, err = New(&Config{Backend: "memcached"})
+	if err == nil {
+		t.Errorf("expected error for unknown backend, got none")
+	}
+}
@@ -0,0 +1,301 @@
+package ratelimit
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Connections kept open between requests
+const redisMaxIdleConns = 8
+
+// Redis counts requests in a Redis server shared by every replica of the webapp, using
+// a sliding window. Each key has a counter per fixed window, and the count of the
+// previous window is weighted by how much of it still overlaps the sliding window.
+// It only speaks the handful of commands it needs, so any server implementing the
+// Redis protocol works, e.g. Valkey or KeyDB.
+type Redis struct {
+	Addr     string
+	Password string
+	DB       int
+	// Prepended to every key, so the server can be shared with other applications
+	Prefix  string
+	Timeout time.Duration
+
+	idle chan *redisConn
+	now  func() time.Time
+}
+
+// NewRedis returns a store connecting to the server at the given URL, e.g.
+// redis://:password@localhost:6379/0. Connections are opened on first use.
+func NewRedis(rawURL string) (*Redis, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "redis" || u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid Redis URL %q", rawURL)
+	}
+
+	r := &Redis{
+		Addr:    u.Host,
+		Prefix:  "ratelimit:",
+		Timeout: time.Second,
+		idle:    make(chan *redisConn, redisMaxIdleConns),
+		now:     time.Now,
+	}
+
+	if u.Port() == "" {
+		r.Addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if password, ok := u.User.Password(); ok {
+		r.Password = password
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		r.DB, err = strconv.Atoi(db)
+		if err != nil || r.DB < 0 {
+			return nil, fmt.Errorf("invalid Redis database %q", db)
+		}
+	}
+
+	return r, nil
+}
+
+func (r *Redis) Take(ctx context.Context, key string, rule Rule) (Result, error) {
+	window := rule.Window()
+	// Counters are indexed by window, an empty one can't be counted over
+	if window <= 0 {
+		return Result{}, fmt.Errorf("invalid rate limit rule, %v requests per second with a burst of %d", rule.Rate, rule.Burst)
+	}
+
+	now := r.now()
+	index := now.UnixNano() / int64(window)
+	// How far into the current fixed window we are, between 0 and 1
+	elapsed := float64(now.UnixNano()%int64(window)) / float64(window)
+
+	current := fmt.Sprintf("%s%s:%d", r.Prefix, key, index)
+	previous := fmt.Sprintf("%s%s:%d", r.Prefix, key, index-1)
+
+	// The counter outlives its window by a window, while it's the previous one
+	replies, err := r.do(ctx,
+		[]string{"MULTI"},
+		[]string{"INCR", current},
+		[]string{"PEXPIRE", current, strconv.FormatInt((2 * window).Milliseconds(), 10)},
+		[]string{"GET", previous},
+		[]string{"EXEC"},
+	)
+	if err != nil {
+		return Result{}, err
+	}
+
+	results, ok := replies[4].([]any)
+	if !ok || len(results) != 3 {
+		return Result{}, fmt.Errorf("unexpected Redis reply to EXEC: %v", replies[4])
+	}
+	count, ok := results[0].(int64)
+	if !ok {
+		return Result{}, fmt.Errorf("unexpected Redis reply to INCR: %v", results[0])
+	}
+	var previousCount int64
+	if b, ok := results[2].([]byte); ok {
+		previousCount, err = strconv.ParseInt(string(b), 10, 64)
+		if err != nil {
+			return Result{}, fmt.Errorf("unexpected Redis counter %q", b)
+		}
+	}
+
+	weighted := float64(previousCount)*(1-elapsed) + float64(count)
+	limit := float64(rule.Burst)
+
+	res := Result{
+		Allowed:   weighted <= limit,
+		Limit:     rule.Burst,
+		Remaining: max(int(math.Floor(limit-weighted)), 0),
+		Reset:     time.Duration((1 - elapsed) * float64(window)),
+	}
+
+	if !res.Allowed {
+		// Refused requests don't count, otherwise a client retrying too early would
+		// push its own limit further away
+		_, err = r.do(ctx, []string{"DECR", current})
+		if err != nil {
+			return Result{}, err
+		}
+
+		// The request fits once enough of the previous window has slid out, or else
+		// once the current window is over
+		res.RetryAfter = res.Reset
+		if room := limit - float64(count); room >= 0 && previousCount > 0 {
+			res.RetryAfter = time.Duration((1 - room/float64(previousCount) - elapsed) * float64(window))
+		}
+	}
+
+	return res, nil
+}
+
+// do sends the commands in a single round trip and returns their replies. Error
+// replies are returned as the error.
+func (r *Redis) do(ctx context.Context, cmds ...[]string) ([]any, error) {
+	conn, err := r.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(r.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	replies := make([]any, len(cmds))
+	err = conn.write(cmds...)
+	for i := 0; err == nil && i < len(cmds); i++ {
+		replies[i], err = conn.read()
+	}
+
+	if err != nil {
+		// Replies may be left unread, the connection can't be reused
+		conn.Close()
+		return nil, err
+	}
+	r.release(conn)
+
+	for _, reply := range replies {
+		if err, ok := reply.(redisError); ok {
+			return nil, err
+		}
+	}
+
+	return replies, nil
+}
+
+// conn returns an idle connection, or opens a new one.
+func (r *Redis) conn(ctx context.Context) (*redisConn, error) {
+	select {
+	case conn := <-r.idle:
+		return conn, nil
+	default:
+	}
+
+	dialer := net.Dialer{Timeout: r.Timeout}
+	c, err := dialer.DialContext(ctx, "tcp", r.Addr)
+	if err != nil {
+		return nil, err
+	}
+	conn := &redisConn{Conn: c, reader: bufio.NewReader(c)}
+	conn.SetDeadline(time.Now().Add(r.Timeout))
+
+	var setup [][]string
+	if r.Password != "" {
+		setup = append(setup, []string{"AUTH", r.Password})
+	}
+	if r.DB != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(r.DB)})
+	}
+
+	err = conn.write(setup...)
+	for i := 0; err == nil && i < len(setup); i++ {
+		var reply any
+		reply, err = conn.read()
+		if replyErr, ok := reply.(redisError); ok {
+			err = replyErr
+		}
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+func (r *Redis) release(conn *redisConn) {
+	select {
+	case r.idle <- conn:
+	default:
+		conn.Close()
+	}
+}
+
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+type redisConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+// write sends the commands as arrays of bulk strings.
+func (c *redisConn) write(cmds ...[]string) error {
+	var b strings.Builder
+	for _, cmd := range cmds {
+		fmt.Fprintf(&b, "*%d\r\n", len(cmd))
+		for _, arg := range cmd {
+			fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+		}
+	}
+
+	_, err := io.WriteString(c.Conn, b.String())
+	return err
+}
+
+// read parses a reply, returned as a string, redisError, int64, []byte, []any, or nil
+// for null bulk strings and arrays.
+func (c *redisConn) read() (any, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || !strings.HasSuffix(line, "\r\n") {
+		return nil, fmt.Errorf("malformed Redis reply %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return redisError(body), nil
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < -1 {
+			return nil, fmt.Errorf("malformed Redis bulk string length %q", body)
+		}
+		if n == -1 {
+			return nil, nil
+		}
+		b := make([]byte, n+2)
+		_, err = io.ReadFull(c.reader, b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < -1 {
+			return nil, fmt.Errorf("malformed Redis array length %q", body)
+		}
+		if n == -1 {
+			return nil, nil
+		}
+		items := make([]any, n)
+		for i := range items {
+			items[i], err = c.read()
+			if err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("unknown Redis reply type %q", kind)
+	}
+}
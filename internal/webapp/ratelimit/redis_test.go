@@ -0,0 +1,303 @@
+package ratelimit
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedisServer is a minimal in-process stand-in for a Redis server, speaking just
+// the commands the store uses. Expiries are recorded but never enforced, the store's
+// clock moves between windows instead. Requires the given password, if any.
+type fakeRedisServer struct {
+	mu       sync.Mutex
+	values   map[string]int64
+	expiries map[string]int64
+	password string
+	listener net.Listener
+}
+
+func newFakeRedisServer(t *testing.T, password string) *fakeRedisServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	srv := &fakeRedisServer{
+		values:   map[string]int64{},
+		expiries: map[string]int64{},
+		password: password,
+		listener: listener,
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go srv.serve(conn)
+		}
+	}()
+
+	return srv
+}
+
+func (srv *fakeRedisServer) URL() string {
+	if srv.password != "" {
+		return fmt.Sprintf("redis://:%s@%s/1", srv.password, srv.listener.Addr())
+	}
+	return "redis://" + srv.listener.Addr().String()
+}
+
+func (srv *fakeRedisServer) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	authenticated := srv.password == ""
+	var queued [][]string
+	inMulti := false
+
+	for {
+		cmd, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+
+		name := strings.ToUpper(cmd[0])
+		var reply string
+		switch {
+		case name == "AUTH":
+			authenticated = len(cmd) == 2 && cmd[1] == srv.password
+			reply = "+OK\r\n"
+			if !authenticated {
+				reply = "-WRONGPASS invalid password\r\n"
+			}
+		case !authenticated:
+			reply = "-NOAUTH Authentication required.\r\n"
+		case name == "MULTI":
+			inMulti = true
+			reply = "+OK\r\n"
+		case name == "EXEC":
+			inMulti = false
+			reply = fmt.Sprintf("*%d\r\n", len(queued))
+			for _, queuedCmd := range queued {
+				reply += srv.execute(queuedCmd)
+			}
+			queued = nil
+		case inMulti:
+			queued = append(queued, cmd)
+			reply = "+QUEUED\r\n"
+		default:
+			reply = srv.execute(cmd)
+		}
+
+		_, err = io.WriteString(conn, reply)
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (srv *fakeRedisServer) execute(cmd []string) string {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	switch strings.ToUpper(cmd[0]) {
+	case "SELECT":
+		return "+OK\r\n"
+	case "INCR":
+		srv.values[cmd[1]]++
+		return fmt.Sprintf(":%d\r\n", srv.values[cmd[1]])
+	case "DECR":
+		srv.values[cmd[1]]--
+		return fmt.Sprintf(":%d\r\n", srv.values[cmd[1]])
+	case "PEXPIRE":
+		ms, _ := strconv.ParseInt(cmd[2], 10, 64)
+		srv.expiries[cmd[1]] = ms
+		return ":1\r\n"
+	case "GET":
+		value, ok := srv.values[cmd[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		s := strconv.FormatInt(value, 10)
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s)
+	default:
+		return fmt.Sprintf("-ERR unknown command '%s'\r\n", cmd[0])
+	}
+}
+
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := make([]string, n)
+	for i := range cmd {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, err
+		}
+		b := make([]byte, size+2)
+		_, err = io.ReadFull(reader, b)
+		if err != nil {
+			return nil, err
+		}
+		cmd[i] = string(b[:size])
+	}
+
+	return cmd, nil
+}
+
+func newTestRedis(t *testing.T, url string, now *time.Time) *Redis {
+	t.Helper()
+
+	store, err := NewRedis(url)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	store.now = func() time.Time { return *now }
+	return store
+}
+
+func TestNewRedis(t *testing.T) {
+	tests := []struct {
+		url      string
+		addr     string
+		password string
+		db       int
+		valid    bool
+	}{
+		{url: "redis://localhost", addr: "localhost:6379", valid: true},
+		{url: "redis://:secret@10.0.0.5:6380/2", addr: "10.0.0.5:6380", password: "secret", db: 2, valid: true},
+		{url: "http://localhost:6379", valid: false},
+		{url: "redis://", valid: false},
+		{url: "redis://localhost/first", valid: false},
+	}
+
+	for _, tt := range tests {
+		store, err := NewRedis(tt.url)
+		if (err == nil) != tt.valid {
+			t.Errorf("expected %q valid to be %v, got error %v", tt.url, tt.valid, err)
+			continue
+		}
+		if tt.valid && (store.Addr != tt.addr || store.Password != tt.password || store.DB != tt.db) {
+			t.Errorf("unexpected store for %q: %+v", tt.url, store)
+		}
+	}
+}
+
+func TestRedis_Take(t *testing.T) {
+	srv := newFakeRedisServer(t, "secret")
+	rule := Rule{Rate: 1, Burst: 4}
+	// Start of a window of 4 seconds
+	now := time.Unix(1_000_000, 0)
+
+	// Two replicas of the webapp sharing the server
+	replicas := []*Redis{newTestRedis(t, srv.URL(), &now), newTestRedis(t, srv.URL(), &now)}
+
+	for i := 0; i < 4; i++ {
+		res := take(t, replicas[i%2], "ip:192.0.2.1", rule)
+		if !res.Allowed {
+			t.Fatalf("expected request %d to be allowed", i+1)
+		}
+		if res.Remaining != 3-i {
+			t.Errorf("expected %d remaining requests, got %d", 3-i, res.Remaining)
+		}
+	}
+
+	res := take(t, replicas[0], "ip:192.0.2.1", rule)
+	if res.Allowed {
+		t.Fatalf("expected request over the limit to be refused by the other replica")
+	}
+	if res.RetryAfter != 4*time.Second {
+		t.Errorf("expected to retry once the window is over, got %v", res.RetryAfter)
+	}
+
+	key := fmt.Sprintf("ratelimit:ip:192.0.2.1:%d", now.UnixNano()/int64(4*time.Second))
+	srv.mu.Lock()
+	if srv.values[key] != 4 {
+		t.Errorf("expected refused request not to be counted, got count %d", srv.values[key])
+	}
+	if srv.expiries[key] != 8000 {
+		t.Errorf("expected counter to expire after two windows, got %dms", srv.expiries[key])
+	}
+	srv.mu.Unlock()
+
+	// Other clients have their own counters
+	if res := take(t, replicas[1], "ip:192.0.2.2", rule); !res.Allowed {
+		t.Errorf("expected another client to be allowed")
+	}
+
+	// A quarter into the next window, 3 of the 4 previous requests still count
+	now = now.Add(5 * time.Second)
+	if res := take(t, replicas[0], "ip:192.0.2.1", rule); !res.Allowed || res.Remaining != 0 {
+		t.Errorf("expected one request to be allowed, got %+v", res)
+	}
+
+	res = take(t, replicas[1], "ip:192.0.2.1", rule)
+	if res.Allowed {
+		t.Fatalf("expected request over the sliding window limit to be refused")
+	}
+	if res.RetryAfter != time.Second {
+		t.Errorf("expected to retry once another previous request slid out, got %v", res.RetryAfter)
+	}
+}
+
+func TestRedis_Errors(t *testing.T) {
+	rule := Rule{Rate: 1, Burst: 4}
+	now := time.Now()
+
+	t.Run("ERROR Wrong password", func(t *testing.T) {
+		srv := newFakeRedisServer(t, "secret")
+		store := newTestRedis(t, strings.Replace(srv.URL(), "secret", "wrong", 1), &now)
+
+		_, err := store.Take(context.Background(), "ip:192.0.2.1", rule)
+		if err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+			t.Errorf("expected an authentication error, got %v", err)
+		}
+	})
+
+	t.Run("ERROR Empty bucket", func(t *testing.T) {
+		srv := newFakeRedisServer(t, "")
+		store := newTestRedis(t, srv.URL(), &now)
+
+		_, err := store.Take(context.Background(), "ip:192.0.2.1", Rule{Rate: 1, Burst: 0})
+		if err == nil {
+			t.Errorf("expected an error, got none")
+		}
+	})
+
+	t.Run("ERROR Server unreachable", func(t *testing.T) {
+		srv := newFakeRedisServer(t, "")
+		url := srv.URL()
+		srv.listener.Close()
+
+		store := newTestRedis(t, url, &now)
+
+		_, err := store.Take(context.Background(), "ip:192.0.2.1", rule)
+		if err == nil {
+			t.Errorf("expected an error, got none")
+		}
+	})
+}
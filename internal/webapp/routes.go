@@ -6,6 +6,7 @@ import (
 
 	"github.com/julienschmidt/httprouter"
 
+	"github.com/navazjm/pixelarcade/internal/webapp/auth"
 	"github.com/navazjm/pixelarcade/internal/webapp/utils/json"
 	"github.com/navazjm/pixelarcade/internal/webapp/utils/response"
 )
@@ -14,11 +15,12 @@ func (app *Application) Routes() http.Handler {
 	router := httprouter.New()
 
 	router.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// since url included "api", we return not found handler instead of returning react FE
-		if strings.Contains(r.URL.Path, "api") {
+		// since url is part of the api, we return not found handler instead of returning react FE
+		if isAPIPath(r.URL.Path) {
 			response.NotFound(w, r, app.Logger)
 			return
 		}
+		app.serveStatic(w, r)
 	})
 
 	router.MethodNotAllowed = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -29,17 +31,58 @@ func (app *Application) Routes() http.Handler {
 
 	router.HandlerFunc(http.MethodPost, "/api/auth/register", app.AuthService.RegisterNewUserHandler)
 	router.HandlerFunc(http.MethodPost, "/api/auth/login", app.AuthService.LoginUserHandler)
+	router.HandlerFunc(http.MethodPost, "/api/auth/login/2fa", app.AuthService.LoginTwoFactorHandler)
 	router.HandlerFunc(http.MethodDelete, "/api/auth/logout", app.AuthService.RequireAuthenticatedUser(app.AuthService.LogoutUserHandler))
+	router.HandlerFunc(http.MethodGet, "/api/auth/sessions", app.AuthService.RequireAuthenticatedUser(app.AuthService.GetSessionsHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/auth/sessions/:id", app.AuthService.RequireAuthenticatedUser(app.AuthService.DeleteSessionHandler))
+	router.HandlerFunc(http.MethodPost, "/api/auth/2fa", app.AuthService.RequireAuthenticatedUser(app.AuthService.EnrollTwoFactorHandler))
+	router.HandlerFunc(http.MethodPut, "/api/auth/2fa", app.AuthService.RequireAuthenticatedUser(app.AuthService.ConfirmTwoFactorHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/auth/2fa", app.AuthService.RequireAuthenticatedUser(app.AuthService.DisableTwoFactorHandler))
 	router.HandlerFunc(http.MethodGet, "/api/auth/user", app.AuthService.RequireAuthenticatedUser(app.AuthService.GetCurrentUserHandler))
 	router.HandlerFunc(http.MethodPatch, "/api/auth/user", app.AuthService.RequireAuthenticatedUser(app.AuthService.UpdateCurrentUserHandler))
+	router.HandlerFunc(http.MethodPut, "/api/auth/user/avatar", app.AuthService.RequireVerifiedUser(app.AuthService.UpdateCurrentUserAvatarHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/auth/user/avatar", app.AuthService.RequireAuthenticatedUser(app.AuthService.DeleteCurrentUserAvatarHandler))
+	router.HandlerFunc(http.MethodPut, "/api/auth/user/activate", app.AuthService.ActivateUserHandler)
+	router.HandlerFunc(http.MethodPut, "/api/auth/user/email", app.AuthService.ConfirmEmailChangeHandler)
+	router.HandlerFunc(http.MethodPost, "/api/auth/password-reset", app.AuthService.RequestPasswordResetHandler)
+	router.HandlerFunc(http.MethodPut, "/api/auth/password", app.AuthService.ResetPasswordHandler)
+	router.HandlerFunc(http.MethodGet, "/api/auth/oauth/:provider/start", app.AuthService.OAuthStartHandler)
+	router.HandlerFunc(http.MethodGet, "/api/auth/oauth/:provider/callback", app.AuthService.OAuthCallbackHandler)
+	router.HandlerFunc(http.MethodGet, "/api/users/:id/avatar.png", app.AuthService.GetIdenticonHandler)
+	router.HandlerFunc(http.MethodGet, "/api/users/:id/avatars/:hash/:file", app.AuthService.GetAvatarHandler)
+
+	router.HandlerFunc(http.MethodGet, "/api/admin/users", app.AuthService.RequirePermission(auth.PermissionUsersAdmin, app.AuthService.GetUsersHandler))
+	router.HandlerFunc(http.MethodGet, "/api/admin/users/:id", app.AuthService.RequirePermission(auth.PermissionUsersAdmin, app.AuthService.GetUserHandler))
+	router.HandlerFunc(http.MethodPatch, "/api/admin/users/:id", app.AuthService.RequirePermission(auth.PermissionUsersAdmin, app.AuthService.UpdateUserHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/admin/users/:id", app.AuthService.RequirePermission(auth.PermissionUsersAdmin, app.AuthService.DeleteUserHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/admin/users/:id/sessions", app.AuthService.RequirePermission(auth.PermissionUsersAdmin, app.AuthService.DeleteUserSessionsHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/admin/users/:id/lock", app.AuthService.RequirePermission(auth.PermissionUsersAdmin, app.AuthService.UnlockUserHandler))
+	router.HandlerFunc(http.MethodGet, "/api/admin/audit", app.AuthService.RequirePermission(auth.PermissionUsersAdmin, app.AuditService.GetEventsHandler))
+
+	// Editing the catalog changes what every player runs, so a password alone isn't enough
+	editCatalog := func(next http.HandlerFunc) http.HandlerFunc {
+		return app.AuthService.RequirePermission(auth.PermissionGamesWrite, app.AuthService.RequireTwoFactor(next))
+	}
 
 	router.HandlerFunc(http.MethodGet, "/api/games", app.GamesService.GetGamesHandler)
+	router.HandlerFunc(http.MethodPost, "/api/games", editCatalog(app.GamesService.PostGameHandler))
 	router.HandlerFunc(http.MethodGet, "/api/games/:id", app.GamesService.GetGameByIDHandler)
-	router.HandlerFunc(http.MethodPost, "/api/games/:id/scores", app.AuthService.RequireAuthenticatedUser(app.GamesService.PostScoreHandler))
+	router.HandlerFunc(http.MethodPatch, "/api/games/:id", editCatalog(app.GamesService.UpdateGameByIDHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/games/:id", editCatalog(app.GamesService.DeleteGameByIDHandler))
+	router.HandlerFunc(http.MethodGet, "/api/games/:id/versions", editCatalog(app.GamesService.GetGameVersionsHandler))
+	router.HandlerFunc(http.MethodPost, "/api/games/:id/versions", editCatalog(app.GamesService.PostGameVersionHandler))
+	router.HandlerFunc(http.MethodPut, "/api/games/:id/versions/:version/activate", editCatalog(app.GamesService.ActivateGameVersionHandler))
+	router.HandlerFunc(http.MethodPost, "/api/games/:id/sessions", app.AuthService.RequireVerifiedUser(app.GamesService.PostPlaySessionHandler))
+	router.HandlerFunc(http.MethodPost, "/api/games/:id/scores", app.AuthService.RequireVerifiedUser(app.IdempotencyService.Idempotent(app.GamesService.PostScoreHandler)))
 	router.HandlerFunc(http.MethodGet, "/api/games/:id/scores", app.GamesService.GetScoresByGameIDHandler)
 	router.HandlerFunc(http.MethodGet, "/api/games/:id/scores/user", app.AuthService.RequireAuthenticatedUser(app.GamesService.GetUserScoresByGameIDHandler))
+	router.HandlerFunc(http.MethodGet, "/api/games/:id/scores/user/rank", app.AuthService.RequireAuthenticatedUser(app.GamesService.GetUserRankByGameIDHandler))
+	router.HandlerFunc(http.MethodPatch, "/api/games/:id/scores/:scoreId", app.AuthService.RequirePermission(auth.PermissionScoresModerate, app.GamesService.UpdateScoreByIDHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/games/:id/scores/:scoreId", app.AuthService.RequireAuthenticatedUser(app.GamesService.DeleteScoreByIDHandler))
 
-	return app.recoverPanic(app.secureHeaders(app.logRequest(app.enforceCORS(app.rateLimit(app.AuthService.Authenticate(router))))))
+	router.HandlerFunc(http.MethodGet, "/play/:id/:version/*filepath", app.GamesService.PlayGameHandler)
+
+	return app.recoverPanic(app.requestID(app.realIP(app.secureHeaders(app.logRequest(app.enforceCORS(app.rateLimitByIP(app.AuthService.Authenticate(app.rateLimitByUser(router)))))))))
 }
 
 func (app *Application) healthcheckHandler(w http.ResponseWriter, r *http.Request) {
@@ -56,3 +99,7 @@ func (app *Application) healthcheckHandler(w http.ResponseWriter, r *http.Reques
 		response.ServerError(w, r, app.Logger, err)
 	}
 }
+
+func isAPIPath(path string) bool {
+	return path == "/api" || strings.HasPrefix(path, "/api/")
+}
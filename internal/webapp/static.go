@@ -0,0 +1,105 @@
+package webapp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/navazjm/pixelarcade/internal/webapp/utils/response"
+)
+
+const (
+	// Vite writes every bundled asset to assets/ with a content hash in its filename, so
+	// a given URL never changes content and can be cached for as long as browsers allow.
+	staticAssetsDir    = "assets/"
+	staticIndexFile    = "index.html"
+	cacheControlAssets = "public, max-age=31536000, immutable"
+	cacheControlNone   = "no-cache"
+)
+
+// Serves the built React frontend from app.Static. Any path that doesn't match a file and
+// doesn't look like one, e.g. /games/snake, falls back to index.html so the client side
+// router can handle it.
+func (app *Application) serveStatic(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		response.MethodNotAllowed(w, r, app.Logger)
+		return
+	}
+
+	if app.Static == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if name == "" {
+		name = staticIndexFile
+	}
+
+	err := app.serveStaticFile(w, r, name)
+	if err == nil {
+		return
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		response.ServerError(w, r, app.Logger, err)
+		return
+	}
+
+	// Missing files with an extension are genuine 404s, e.g. a stale asset URL, and
+	// shouldn't be answered with the HTML document.
+	if path.Ext(name) != "" && name != staticIndexFile {
+		http.NotFound(w, r)
+		return
+	}
+
+	err = app.serveStaticFile(w, r, staticIndexFile)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			http.NotFound(w, r)
+			return
+		}
+		response.ServerError(w, r, app.Logger, err)
+	}
+}
+
+// Writes the named file from app.Static. Returns fs.ErrNotExist for directories so the
+// caller can fall back to index.html.
+func (app *Application) serveStaticFile(w http.ResponseWriter, r *http.Request, name string) error {
+	file, err := app.Static.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fs.ErrNotExist
+	}
+
+	content, ok := file.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return err
+		}
+		content = bytes.NewReader(data)
+	}
+
+	if strings.HasPrefix(name, staticAssetsDir) {
+		w.Header().Set("Cache-Control", cacheControlAssets)
+	} else {
+		w.Header().Set("Cache-Control", cacheControlNone)
+	}
+
+	// ServeContent sets Content-Type from the file extension, and handles range and
+	// conditional requests using the modification time.
+	http.ServeContent(w, r, name, info.ModTime(), content)
+	return nil
+}
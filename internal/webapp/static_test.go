@@ -0,0 +1,79 @@
+package webapp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestServeStatic(t *testing.T) {
+	app := setupTestApp()
+	// Every case comes from the same client, more than the default burst allows
+	app.Config.RateLimit.Enabled = false
+	app.Static = fstest.MapFS{
+		"index.html":              {Data: []byte("<!doctype html><div id=\"root\"></div>")},
+		"favicon.ico":             {Data: []byte("icon")},
+		"assets/index-a1b2c3.js":  {Data: []byte("console.log('pixelarcade')")},
+		"assets/index-d4e5f6.css": {Data: []byte("body{}")},
+	}
+
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		status       int
+		contentType  string
+		cacheControl string
+		body         string
+	}{
+		{"SUCCESS Root serves index", http.MethodGet, "/", http.StatusOK, "text/html", cacheControlNone, "<!doctype html>"},
+		{"SUCCESS Client route falls back to index", http.MethodGet, "/games/snake", http.StatusOK, "text/html", cacheControlNone, "<!doctype html>"},
+		{"SUCCESS Hashed script is cached", http.MethodGet, "/assets/index-a1b2c3.js", http.StatusOK, "text/javascript", cacheControlAssets, "console.log"},
+		{"SUCCESS Hashed stylesheet is cached", http.MethodGet, "/assets/index-d4e5f6.css", http.StatusOK, "text/css", cacheControlAssets, "body{}"},
+		{"SUCCESS Unhashed file is not cached", http.MethodGet, "/favicon.ico", http.StatusOK, "", cacheControlNone, "icon"},
+		{"SUCCESS Assets directory falls back to index", http.MethodGet, "/assets", http.StatusOK, "text/html", cacheControlNone, "<!doctype html>"},
+		{"ERROR Missing asset", http.MethodGet, "/assets/index-old.js", http.StatusNotFound, "", "", ""},
+		{"ERROR Path traversal", http.MethodGet, "/../../etc/passwd", http.StatusOK, "text/html", cacheControlNone, "<!doctype html>"},
+		{"ERROR Method not allowed", http.MethodPost, "/games/snake", http.StatusMethodNotAllowed, "", "", ""},
+		{"ERROR API path is not served", http.MethodGet, "/api/unknown", http.StatusNotFound, "application/json", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			rec := httptest.NewRecorder()
+
+			// Routes are rebuilt for each request so the global rate limiter doesn't kick in
+			app.Routes().ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, rec.Code)
+			}
+			if tt.contentType != "" && !strings.HasPrefix(rec.Header().Get("Content-Type"), tt.contentType) {
+				t.Errorf("expected content type %s, got %s", tt.contentType, rec.Header().Get("Content-Type"))
+			}
+			if tt.cacheControl != "" && rec.Header().Get("Cache-Control") != tt.cacheControl {
+				t.Errorf("expected cache control %q, got %q", tt.cacheControl, rec.Header().Get("Cache-Control"))
+			}
+			if !strings.Contains(rec.Body.String(), tt.body) {
+				t.Errorf("expected body to contain %q, got %q", tt.body, rec.Body.String())
+			}
+		})
+	}
+
+	t.Run("ERROR Frontend not built", func(t *testing.T) {
+		app := setupTestApp()
+		app.Static = fstest.MapFS{}
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+
+		app.Routes().ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("expected status %d, got %d", http.StatusNotFound, rec.Code)
+		}
+	})
+}
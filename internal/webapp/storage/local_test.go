@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLocal(t *testing.T) {
+	ctx := context.Background()
+
+	l, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	err = l.Put(ctx, "avatars/1.png", strings.NewReader("png"), 3, "image/png")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	obj, err := l.Get(ctx, "avatars/1.png")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	data, _ := io.ReadAll(obj.Body)
+	obj.Body.Close()
+	if string(data) != "png" || obj.ContentType != "image/png" || obj.Size != 3 {
+		t.Errorf("unexpected object: %+v %q", obj, data)
+	}
+
+	// Overwriting replaces the object
+	err = l.Put(ctx, "avatars/1.png", strings.NewReader("new"), 3, "image/png")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	err = l.Delete(ctx, "avatars/1.png")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	_, err = l.Get(ctx, "avatars/1.png")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound after delete, got %v", err)
+	}
+
+	// Deleting a missing object succeeds
+	err = l.Delete(ctx, "avatars/1.png")
+	if err != nil {
+		t.Errorf("expected no error deleting missing object, got %v", err)
+	}
+
+	// Directories aren't objects
+	_, err = l.Get(ctx, "avatars")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for directory, got %v", err)
+	}
+}
+
+func TestLocal_InvalidKey(t *testing.T) {
+	ctx := context.Background()
+
+	l, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	for _, key := range []string{"", ".", "../secrets", "/etc/passwd", "avatars//1.png", `avatars\1.png`} {
+		err = l.Put(ctx, key, strings.NewReader("png"), 3, "image/png")
+		if err == nil {
+			t.Errorf("expected error for key %q, got nil", key)
+		}
+	}
+}
+
+func TestNewLocal_MissingConfig(t *testing.T) {
+	_, err := NewLocal("")
+	if err == nil {
+		t.Error("expected error for missing directory, got nil")
+	}
+}
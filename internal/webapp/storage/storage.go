@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+const (
+	BackendLocal = "local"
+	BackendS3    = "s3"
+)
+
+type Config struct {
+	Backend string
+	// Directory blobs are written to by the local backend
+	Dir string
+	// Endpoint of the S3 compatible service, e.g. https://s3.us-east-1.amazonaws.com
+	// or http://localhost:9000 for MinIO
+	S3Endpoint  string
+	S3Region    string
+	S3Bucket    string
+	S3AccessKey string
+	S3SecretKey string
+}
+
+// Blob stores opaque objects, e.g. profile pictures and game bundles, under
+// slash separated keys such as "avatars/1.png".
+type Blob interface {
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	// Get returns ErrNotFound if no object is stored under the key. The caller must
+	// close the returned object's body.
+	Get(ctx context.Context, key string) (*Object, error)
+	// Delete succeeds if no object is stored under the key.
+	Delete(ctx context.Context, key string) error
+}
+
+type Object struct {
+	Body        io.ReadCloser
+	ContentType string
+	Size        int64
+	ModTime     time.Time
+}
+
+// New returns the Blob for the configured backend.
+func New(cfg *Config) (Blob, error) {
+	switch cfg.Backend {
+	case BackendLocal:
+		return NewLocal(cfg.Dir)
+	case BackendS3:
+		return NewS3(cfg.S3Endpoint, cfg.S3Region, cfg.S3Bucket, cfg.S3AccessKey, cfg.S3SecretKey)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Backend)
+	}
+}
+
+// ServeObject writes the object as the response body. Seekable bodies, i.e. local
+// files, also get range and conditional request support. The caller is responsible
+// for closing the object's body.
+func ServeObject(w http.ResponseWriter, r *http.Request, name string, obj *Object) error {
+	w.Header().Set("Content-Type", obj.ContentType)
+
+	if content, ok := obj.Body.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, name, obj.ModTime, content)
+		return nil
+	}
+
+	if obj.Size >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(obj.Size, 10))
+	}
+	w.WriteHeader(http.StatusOK)
+
+	_, err := io.Copy(w, obj.Body)
+	return err
+}
+
+// validateKey rejects keys that could escape the storage root or map to different
+// objects depending on the backend, e.g. "../secrets" or "avatars//1.png".
+func validateKey(key string) error {
+	if !fs.ValidPath(key) || key == "." || strings.Contains(key, `\`) {
+		return fmt.Errorf("invalid blob key %q", key)
+	}
+	return nil
+}
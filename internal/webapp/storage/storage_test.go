@@ -0,0 +1,30 @@
+package storage
+
+import (
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	blob, err := New(&Config{Backend: BackendLocal, Dir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, ok := blob.(*Local); !ok {
+		t.Errorf("expected local backend, got %T", blob)
+	}
+
+	blob, err = New(&Config{Backend: BackendS3, S3Endpoint: "http://localhost:9000", S3Region: "us-east-1", S3Bucket: "pixelarcade", S3AccessKey: "access", S3SecretKey: "secret"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, ok := blob.(*S3); !ok {
+		t.Errorf("expected S3 backend, got %T", blob)
+	}
+}
+
+func TestNew_UnknownBackend(t *testing.T) {
+	_, err := New(&Config{Backend: "ftp"})
+	if err == nil {
+		t.Error("expected error for unknown backend, got nil")
+	}
+}
@@ -0,0 +1,81 @@
+package clientip
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// Header set by proxies in front of the webapp, listing the addresses a request was
+// forwarded for, the client first
+const HeaderForwardedFor = "X-Forwarded-For"
+
+type contextKey string
+
+const CtxKeyClientIP = contextKey("client_ip")
+
+// FromRequest returns the address of the client that sent the request. The
+// X-Forwarded-For header is only honored when the request comes from one of the trusted
+// proxies, as anyone else can put whatever they want in it. It is read from the right,
+// skipping trusted proxies, so a client can't spoof its address by sending the header
+// itself.
+func FromRequest(r *http.Request, trusted []netip.Prefix) string {
+	remote := remoteAddr(r)
+
+	addr, err := netip.ParseAddr(remote)
+	if err != nil || !isTrusted(addr, trusted) {
+		return remote
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values(HeaderForwardedFor), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			// The header is malformed from here on, the last valid hop is the best guess
+			break
+		}
+		remote = hop.Unmap().String()
+		if !isTrusted(hop, trusted) {
+			break
+		}
+	}
+
+	return remote
+}
+
+// Set returns a copy of the request with the given client IP in its context.
+func Set(r *http.Request, ip string) *http.Request {
+	ctx := context.WithValue(r.Context(), CtxKeyClientIP, ip)
+	return r.WithContext(ctx)
+}
+
+// Get returns the client IP of the request, falling back to the address it was received
+// from if none was set.
+func Get(r *http.Request) string {
+	ip, ok := r.Context().Value(CtxKeyClientIP).(string)
+	if !ok {
+		return remoteAddr(r)
+	}
+	return ip
+}
+
+// remoteAddr returns the address the request was received from, without the port.
+func remoteAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func isTrusted(addr netip.Addr, trusted []netip.Prefix) bool {
+	addr = addr.Unmap()
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
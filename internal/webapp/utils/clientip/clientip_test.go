@@ -0,0 +1,57 @@
+package clientip
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestFromRequest(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("::1/128")}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		expected   string
+	}{
+		{name: "Direct client", remoteAddr: "192.0.2.1:1234", expected: "192.0.2.1"},
+		{name: "Header ignored from untrusted peer", remoteAddr: "192.0.2.1:1234", forwarded: []string{"198.51.100.7"}, expected: "192.0.2.1"},
+		{name: "Client behind trusted proxy", remoteAddr: "10.0.0.2:1234", forwarded: []string{"198.51.100.7"}, expected: "198.51.100.7"},
+		{name: "Client behind chain of trusted proxies", remoteAddr: "10.0.0.2:1234", forwarded: []string{"198.51.100.7, 10.0.0.3"}, expected: "198.51.100.7"},
+		{name: "Spoofed hop left of the client", remoteAddr: "10.0.0.2:1234", forwarded: []string{"203.0.113.9, 198.51.100.7"}, expected: "198.51.100.7"},
+		{name: "Multiple headers", remoteAddr: "10.0.0.2:1234", forwarded: []string{"203.0.113.9", "198.51.100.7"}, expected: "198.51.100.7"},
+		{name: "Trusted proxy without header", remoteAddr: "10.0.0.2:1234", expected: "10.0.0.2"},
+		{name: "Malformed hop", remoteAddr: "10.0.0.2:1234", forwarded: []string{"198.51.100.7, garbage, 10.0.0.3"}, expected: "10.0.0.3"},
+		{name: "IPv6 trusted proxy", remoteAddr: "[::1]:1234", forwarded: []string{"2001:db8::1"}, expected: "2001:db8::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwarded {
+				r.Header.Add(HeaderForwardedFor, value)
+			}
+
+			ip := FromRequest(r, trusted)
+			if ip != tt.expected {
+				t.Errorf("expected client IP %q, got %q", tt.expected, ip)
+			}
+		})
+	}
+}
+
+func TestSetAndGet(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+
+	if ip := Get(r); ip != "192.0.2.1" {
+		t.Errorf("expected the remote address without a client IP set, got %q", ip)
+	}
+
+	r = Set(r, "198.51.100.7")
+	if ip := Get(r); ip != "198.51.100.7" {
+		t.Errorf("expected client IP %q, got %q", "198.51.100.7", ip)
+	}
+}
@@ -2,29 +2,100 @@ package param
 
 import (
 	"context"
-	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 
 	"github.com/julienschmidt/httprouter"
+
+	"github.com/navazjm/pixelarcade/internal/webapp/utils/validator"
 )
 
 // Retrieve the "id" URL parameter from the current request context, then convert it to
 // an integer and return it. If the operation isn't successful, return 0 and an error.
 func ReadID(r *http.Request) (int64, error) {
+	return ReadNamedID(r, "id")
+}
+
+// Retrieve a named ID URL parameter, e.g. "scoreId", from the current request context.
+// Behaves the same as ReadID.
+func ReadNamedID(r *http.Request, key string) (int64, error) {
 	params := httprouter.ParamsFromContext(r.Context())
 
-	id, err := strconv.ParseInt(params.ByName("id"), 10, 64)
+	id, err := strconv.ParseInt(params.ByName(key), 10, 64)
 	if err != nil || id < 1 {
-		return 0, errors.New("invalid id parameter")
+		return 0, fmt.Errorf("invalid %s parameter", key)
 	}
 
 	return id, nil
 }
 
+// Retrieve a named URL parameter from the current request context. Returns an empty
+// string if the parameter does not exist.
+func ReadString(r *http.Request, key string) string {
+	params := httprouter.ParamsFromContext(r.Context())
+	return params.ByName(key)
+}
+
+// Injects a named parameter into the request's context for testing, keeping any
+// parameters previously injected.
+func Inject(r *http.Request, key, value string) *http.Request {
+	params := httprouter.ParamsFromContext(r.Context())
+	params = append(params, httprouter.Param{Key: key, Value: value})
+	ctx := context.WithValue(r.Context(), httprouter.ParamsKey, params)
+	return r.WithContext(ctx)
+}
+
 // Injects an ID into the request's context for testing.
 func InjectID(r *http.Request, id int64) *http.Request {
 	params := httprouter.Params{httprouter.Param{Key: "id", Value: strconv.FormatInt(id, 10)}}
 	ctx := context.WithValue(r.Context(), httprouter.ParamsKey, params)
 	return r.WithContext(ctx)
 }
+
+// Retrieve a query string value from the request URL, or the provided default value
+// if no matching key could be found.
+func ReadQueryString(r *http.Request, key string, defaultValue string) string {
+	s := r.URL.Query().Get(key)
+	if s == "" {
+		return defaultValue
+	}
+
+	return s
+}
+
+// Retrieve a query string value from the request URL and convert it to an integer. If
+// no matching key could be found the default value is returned, and if the value
+// couldn't be converted an error message is recorded in the provided Validator.
+func ReadQueryInt(r *http.Request, key string, defaultValue int, v *validator.Validator) int {
+	s := r.URL.Query().Get(key)
+	if s == "" {
+		return defaultValue
+	}
+
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		v.AddError(key, "must be an integer value")
+		return defaultValue
+	}
+
+	return i
+}
+
+// Retrieve an optional boolean query string value from the request URL. Returns nil if
+// no matching key could be found, and if the value couldn't be converted an error
+// message is recorded in the provided Validator.
+func ReadQueryBool(r *http.Request, key string, v *validator.Validator) *bool {
+	s := r.URL.Query().Get(key)
+	if s == "" {
+		return nil
+	}
+
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		v.AddError(key, "must be a boolean value")
+		return nil
+	}
+
+	return &b
+}
@@ -8,6 +8,8 @@ import (
 	"testing"
 
 	"github.com/julienschmidt/httprouter"
+
+	"github.com/navazjm/pixelarcade/internal/webapp/utils/validator"
 )
 
 func TestReadID(t *testing.T) {
@@ -131,3 +133,94 @@ func TestInjectID(t *testing.T) {
 		})
 	}
 }
+
+func TestReadStringAndInject(t *testing.T) {
+	r, err := http.NewRequest("GET", "/api/auth/oauth/mock/start", nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+
+	if got := ReadString(r, "provider"); got != "" {
+		t.Errorf("expected empty string for missing param, got %q", got)
+	}
+
+	r = InjectID(r, 1)
+	r = Inject(r, "provider", "mock")
+
+	if got := ReadString(r, "provider"); got != "mock" {
+		t.Errorf("expected provider %q, got %q", "mock", got)
+	}
+
+	// Previously injected params must be preserved
+	id, err := ReadID(r)
+	if err != nil || id != 1 {
+		t.Errorf("expected ID 1, got %d (err: %v)", id, err)
+	}
+}
+
+func TestReadNamedID(t *testing.T) {
+	r, err := http.NewRequest("GET", "/api/games/1/scores/7", nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+
+	r = InjectID(r, 1)
+	r = Inject(r, "scoreId", "7")
+
+	id, err := ReadNamedID(r, "scoreId")
+	if err != nil || id != 7 {
+		t.Errorf("expected scoreId 7, got %d (err: %v)", id, err)
+	}
+
+	_, err = ReadNamedID(r, "missing")
+	if err == nil || err.Error() != "invalid missing parameter" {
+		t.Errorf("expected invalid missing parameter error, got %v", err)
+	}
+}
+
+func TestReadQueryString(t *testing.T) {
+	r := httptest.NewRequest("GET", "/api/games/1/scores?window=daily", nil)
+
+	if got := ReadQueryString(r, "window", "all"); got != "daily" {
+		t.Errorf("expected %q, got %q", "daily", got)
+	}
+	if got := ReadQueryString(r, "missing", "all"); got != "all" {
+		t.Errorf("expected default %q, got %q", "all", got)
+	}
+}
+
+func TestReadQueryInt(t *testing.T) {
+	r := httptest.NewRequest("GET", "/api/games/1/scores?limit=25&offset=abc", nil)
+	v := validator.New()
+
+	if got := ReadQueryInt(r, "limit", 50, v); got != 25 {
+		t.Errorf("expected 25, got %d", got)
+	}
+	if got := ReadQueryInt(r, "missing", 50, v); got != 50 {
+		t.Errorf("expected default 50, got %d", got)
+	}
+	if got := ReadQueryInt(r, "offset", 0, v); got != 0 {
+		t.Errorf("expected default 0 for invalid value, got %d", got)
+	}
+	if _, ok := v.Errors["offset"]; !ok {
+		t.Errorf("expected validation error for offset, got %v", v.Errors)
+	}
+}
+
+func TestReadQueryBool(t *testing.T) {
+	r := httptest.NewRequest("GET", "/api/admin/users?is_active=false&is_verified=maybe", nil)
+	v := validator.New()
+
+	if got := ReadQueryBool(r, "is_active", v); got == nil || *got {
+		t.Errorf("expected false, got %v", got)
+	}
+	if got := ReadQueryBool(r, "missing", v); got != nil {
+		t.Errorf("expected nil for missing value, got %v", *got)
+	}
+	if got := ReadQueryBool(r, "is_verified", v); got != nil {
+		t.Errorf("expected nil for invalid value, got %v", *got)
+	}
+	if _, ok := v.Errors["is_verified"]; !ok {
+		t.Errorf("expected validation error for is_verified, got %v", v.Errors)
+	}
+}
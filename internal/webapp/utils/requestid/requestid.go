@@ -0,0 +1,48 @@
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+
+	"github.com/navazjm/pixelarcade/internal/webapp/utils/validator"
+)
+
+// Header a request ID is read from, when set by a proxy in front of the webapp, and
+// echoed back in
+const Header = "X-Request-ID"
+
+type contextKey string
+
+const CtxKeyRequestID = contextKey("request_id")
+
+// New returns a random request ID.
+func New() string {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+var validRX = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// Valid reports whether an incoming request ID is safe to log and store as is.
+func Valid(id string) bool {
+	return validator.Matches(id, validRX)
+}
+
+// Set returns a copy of the request with the given request ID in its context.
+func Set(r *http.Request, id string) *http.Request {
+	ctx := context.WithValue(r.Context(), CtxKeyRequestID, id)
+	return r.WithContext(ctx)
+}
+
+// Get returns the ID of the request, or an empty string if none was set.
+func Get(r *http.Request) string {
+	id, _ := r.Context().Value(CtxKeyRequestID).(string)
+	return id
+}
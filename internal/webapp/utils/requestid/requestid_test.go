@@ -0,0 +1,49 @@
+package requestid
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	a, b := New(), New()
+	if a == b {
+		t.Errorf("expected unique request IDs, got %q twice", a)
+	}
+	if !Valid(a) {
+		t.Errorf("expected generated request ID %q to be valid", a)
+	}
+}
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		id       string
+		expected bool
+	}{
+		{"0f8fad5b-d9cb-469f-a165-70867728950e", true},
+		{"req_01.abc-DEF", true},
+		{"", false},
+		{strings.Repeat("a", 65), false},
+		{"id with spaces", false},
+		{"id\nLevel=ERROR", false},
+	}
+
+	for _, tt := range tests {
+		if Valid(tt.id) != tt.expected {
+			t.Errorf("expected Valid(%q) to be %v", tt.id, tt.expected)
+		}
+	}
+}
+
+func TestSetAndGet(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	if got := Get(r); got != "" {
+		t.Errorf("expected empty request ID, got %q", got)
+	}
+
+	r = Set(r, "abc")
+	if got := Get(r); got != "abc" {
+		t.Errorf("expected request ID %q, got %q", "abc", got)
+	}
+}
@@ -3,7 +3,10 @@ package response
 import (
 	"fmt"
 	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/navazjm/pixelarcade/internal/webapp/utils/json"
 )
@@ -84,7 +87,55 @@ func PermissionDenied(w http.ResponseWriter, r *http.Request, logger *slog.Logge
 	Error(w, r, logger, http.StatusForbidden, message)
 }
 
+func VerificationRequired(w http.ResponseWriter, r *http.Request, logger *slog.Logger) {
+	message := "your user account must be verified to access this resource"
+	Error(w, r, logger, http.StatusForbidden, message)
+}
+
+func TwoFactorRequired(w http.ResponseWriter, r *http.Request, logger *slog.Logger) {
+	message := "you must log in with two-factor authentication to access this resource"
+	Error(w, r, logger, http.StatusForbidden, message)
+}
+
+func AccountInactive(w http.ResponseWriter, r *http.Request, logger *slog.Logger) {
+	message := "your user account has been deactivated"
+	Error(w, r, logger, http.StatusForbidden, message)
+}
+
+func AccountLocked(w http.ResponseWriter, r *http.Request, logger *slog.Logger, retryAfter time.Duration) {
+	RetryAfter(w, retryAfter)
+
+	message := "your user account is temporarily locked due to too many failed login attempts"
+	Error(w, r, logger, http.StatusLocked, message)
+}
+
+func AccountExists(w http.ResponseWriter, r *http.Request, logger *slog.Logger) {
+	message := "an account with this email address already exists, please sign in again to link it"
+	Error(w, r, logger, http.StatusConflict, message)
+}
+
+// RetryAfter sets the Retry-After header to the duration.
+func RetryAfter(w http.ResponseWriter, d time.Duration) {
+	w.Header().Set("Retry-After", Seconds(d))
+}
+
+// Seconds formats a duration for headers like Retry-After, rounded up to whole seconds
+// so clients waiting that long are never early.
+func Seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
 func OriginNotAllowed(w http.ResponseWriter, r *http.Request, logger *slog.Logger, origin string) {
 	message := fmt.Sprintf("request origin '%s' is not allowed", origin)
 	Error(w, r, logger, http.StatusForbidden, message)
 }
+
+func IdempotencyKeyInUse(w http.ResponseWriter, r *http.Request, logger *slog.Logger) {
+	message := "a request with this idempotency key is still being processed, please try again"
+	Error(w, r, logger, http.StatusConflict, message)
+}
+
+func IdempotencyKeyMismatch(w http.ResponseWriter, r *http.Request, logger *slog.Logger) {
+	message := "this idempotency key was already used for a different request"
+	Error(w, r, logger, http.StatusUnprocessableEntity, message)
+}
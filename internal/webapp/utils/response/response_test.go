@@ -7,6 +7,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"log/slog"
 
@@ -170,6 +171,102 @@ func TestPermissionDenied(t *testing.T) {
 	}
 }
 
+func TestVerificationRequired(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/test-uri", nil)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	VerificationRequired(w, r, logger)
+
+	resp := w.Result()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected status %d, got %d", http.StatusForbidden, resp.StatusCode)
+	}
+
+	var env pa_json.Envelope
+	err := json.NewDecoder(resp.Body).Decode(&env)
+	if err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+
+	if env["error"] != "your user account must be verified to access this resource" {
+		t.Errorf("expected error message 'your user account must be verified to access this resource', got '%s'", env["error"])
+	}
+}
+
+func TestTwoFactorRequired(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/games", nil)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	TwoFactorRequired(w, r, logger)
+
+	resp := w.Result()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected status %d, got %d", http.StatusForbidden, resp.StatusCode)
+	}
+
+	var env pa_json.Envelope
+	err := json.NewDecoder(resp.Body).Decode(&env)
+	if err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+
+	if env["error"] != "you must log in with two-factor authentication to access this resource" {
+		t.Errorf("expected error message 'you must log in with two-factor authentication to access this resource', got '%s'", env["error"])
+	}
+}
+
+func TestAccountInactive(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/auth/login", nil)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	AccountInactive(w, r, logger)
+
+	resp := w.Result()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected status %d, got %d", http.StatusForbidden, resp.StatusCode)
+	}
+
+	var env pa_json.Envelope
+	err := json.NewDecoder(resp.Body).Decode(&env)
+	if err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+
+	if env["error"] != "your user account has been deactivated" {
+		t.Errorf("expected error message 'your user account has been deactivated', got '%s'", env["error"])
+	}
+}
+
+func TestAccountLocked(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/auth/login", nil)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	AccountLocked(w, r, logger, 90*time.Second+time.Millisecond)
+
+	resp := w.Result()
+	if resp.StatusCode != http.StatusLocked {
+		t.Errorf("expected status %d, got %d", http.StatusLocked, resp.StatusCode)
+	}
+
+	if resp.Header.Get("Retry-After") != "91" {
+		t.Errorf("expected Retry-After header to be 91, got %q", resp.Header.Get("Retry-After"))
+	}
+
+	var env pa_json.Envelope
+	err := json.NewDecoder(resp.Body).Decode(&env)
+	if err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+
+	if env["error"] != "your user account is temporarily locked due to too many failed login attempts" {
+		t.Errorf("unexpected error message '%s'", env["error"])
+	}
+}
+
 func TestOriginNotAllowed(t *testing.T) {
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest(http.MethodGet, "/test-uri", nil)
@@ -193,3 +290,49 @@ func TestOriginNotAllowed(t *testing.T) {
 		t.Errorf("expected error message 'request origin '%s' is not allowed', got '%s'", origin, env["error"])
 	}
 }
+
+func TestIdempotencyKeyInUse(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/test-uri", nil)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	IdempotencyKeyInUse(w, r, logger)
+
+	resp := w.Result()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected status %d, got %d", http.StatusConflict, resp.StatusCode)
+	}
+
+	var env pa_json.Envelope
+	err := json.NewDecoder(resp.Body).Decode(&env)
+	if err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+
+	if env["error"] != "a request with this idempotency key is still being processed, please try again" {
+		t.Errorf("unexpected error message '%s'", env["error"])
+	}
+}
+
+func TestIdempotencyKeyMismatch(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/test-uri", nil)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	IdempotencyKeyMismatch(w, r, logger)
+
+	resp := w.Result()
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, resp.StatusCode)
+	}
+
+	var env pa_json.Envelope
+	err := json.NewDecoder(resp.Body).Decode(&env)
+	if err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+
+	if env["error"] != "this idempotency key was already used for a different request" {
+		t.Errorf("unexpected error message '%s'", env["error"])
+	}
+}
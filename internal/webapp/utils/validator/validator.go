@@ -1,8 +1,10 @@
 package validator
 
 import (
+	"net/url"
 	"regexp"
 	"slices"
+	"strings"
 )
 
 // Declare a regular expression for sanity checking the format of email addresses
@@ -61,6 +63,26 @@ func Matches(value string, rx *regexp.Regexp) bool {
 	return rx.MatchString(value)
 }
 
+// IsURL returns true if a string value is an absolute http(s) URL or a path on
+// this server, e.g. "/games/snake/logo.png". Paths starting with "//" or "/\" are
+// refused, browsers treat both as protocol-relative URLs to another host.
+func IsURL(value string) bool {
+	if strings.HasPrefix(value, "/") {
+		if strings.HasPrefix(value, "//") || strings.HasPrefix(value, `/\`) {
+			return false
+		}
+		_, err := url.ParseRequestURI(value)
+		return err == nil
+	}
+
+	u, err := url.ParseRequestURI(value)
+	if err != nil {
+		return false
+	}
+
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
 // Generic function which returns true if all values in a slice are unique.
 func Unique[T comparable](values []T) bool {
 	uniqueValues := make(map[T]bool)
@@ -114,6 +114,30 @@ func TestMatches(t *testing.T) {
 	}
 }
 
+func TestIsURL(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected bool
+	}{
+		{"https://pixelarcade.dev/games/snake", true},
+		{"http://localhost:8080/logo.png", true},
+		{"/games/snake/logo.png", true},
+		{"", false},
+		{"logo.png", false},
+		{"//evil.com/logo.png", false},
+		{`/\evil.com/logo.png`, false},
+		{`/\\evil.com/logo.png`, false},
+		{"javascript:alert(1)", false},
+		{"ftp://pixelarcade.dev/game.zip", false},
+	}
+
+	for _, tt := range tests {
+		if IsURL(tt.value) != tt.expected {
+			t.Errorf("Expected IsURL(%q) to be %v", tt.value, tt.expected)
+		}
+	}
+}
+
 func TestUnique(t *testing.T) {
 	// Test unique function
 	if !Unique([]int{1, 2, 3, 4, 5}) {
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS auth_roles_permissions;
+DROP TABLE IF EXISTS auth_permissions;
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS auth_permissions (
+    -- base fields
+    id SMALLSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    version INTEGER NOT NULL DEFAULT 1,
+    is_active BOOLEAN NOT NULL DEFAULT FALSE,
+    -- fields specific to permissions
+    code TEXT UNIQUE NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS auth_roles_permissions (
+    role_id SMALLINT NOT NULL REFERENCES auth_roles ON DELETE CASCADE,
+    permission_id SMALLINT NOT NULL REFERENCES auth_permissions ON DELETE CASCADE,
+    PRIMARY KEY (role_id, permission_id)
+);
+
+INSERT INTO auth_permissions (id, code, is_active) VALUES (1, 'games:write', TRUE);
+INSERT INTO auth_permissions (id, code, is_active) VALUES (2, 'scores:moderate', TRUE);
+INSERT INTO auth_permissions (id, code, is_active) VALUES (3, 'users:admin', TRUE);
+
+-- Needed to update Postgres autoincrement value
+SELECT setval(pg_get_serial_sequence('auth_permissions', 'id'), (SELECT MAX(id) FROM auth_permissions));
+
+-- Admin role is granted every permission
+INSERT INTO auth_roles_permissions (role_id, permission_id) SELECT 2, id FROM auth_permissions;
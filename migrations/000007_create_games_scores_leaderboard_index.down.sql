@@ -0,0 +1 @@
+DROP INDEX IF EXISTS games_scores_leaderboard_idx;
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS games_scores_leaderboard_idx ON games_scores (game_id, score DESC, created_at);
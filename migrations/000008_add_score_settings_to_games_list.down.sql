@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS games_scores_leaderboard_asc_idx;
+
+ALTER TABLE games_list
+    DROP CONSTRAINT IF EXISTS games_list_score_bounds_check,
+    DROP COLUMN IF EXISTS score_max,
+    DROP COLUMN IF EXISTS score_min,
+    DROP COLUMN IF EXISTS score_order,
+    DROP COLUMN IF EXISTS score_type;
@@ -0,0 +1,9 @@
+ALTER TABLE games_list
+    ADD COLUMN IF NOT EXISTS score_type TEXT NOT NULL DEFAULT 'points' CHECK (score_type IN ('points', 'time_ms', 'moves')),
+    ADD COLUMN IF NOT EXISTS score_order TEXT NOT NULL DEFAULT 'desc' CHECK (score_order IN ('desc', 'asc')),
+    ADD COLUMN IF NOT EXISTS score_min BIGINT NOT NULL DEFAULT 1 CHECK (score_min >= 0),
+    ADD COLUMN IF NOT EXISTS score_max BIGINT NOT NULL DEFAULT 9223372036854775807,
+    ADD CONSTRAINT games_list_score_bounds_check CHECK (score_max >= score_min);
+
+-- leaderboards for games where the lowest score wins, e.g. speedruns
+CREATE INDEX IF NOT EXISTS games_scores_leaderboard_asc_idx ON games_scores (game_id, score ASC, created_at);
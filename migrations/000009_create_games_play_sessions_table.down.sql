@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS games_score_rejections;
+DROP TABLE IF EXISTS games_play_sessions;
+ALTER TABLE games_list DROP COLUMN IF EXISTS score_rate_max;
@@ -0,0 +1,22 @@
+ALTER TABLE games_list ADD COLUMN IF NOT EXISTS score_rate_max BIGINT NOT NULL DEFAULT 0 CHECK (score_rate_max >= 0);
+
+CREATE TABLE IF NOT EXISTS games_play_sessions (
+    nonce TEXT PRIMARY KEY,
+    game_id BIGINT NOT NULL REFERENCES games_list ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES auth_users ON DELETE CASCADE,
+    started_at TIMESTAMPTZ NOT NULL,
+    expiry TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ -- nullable. set once a score has been submitted with the ticket
+);
+
+CREATE TABLE IF NOT EXISTS games_score_rejections (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    game_id BIGINT NOT NULL REFERENCES games_list ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES auth_users ON DELETE CASCADE,
+    score BIGINT NOT NULL,
+    reason TEXT NOT NULL,
+    nonce TEXT -- nullable. tickets that fail signature checks have no trusted nonce
+);
+
+CREATE INDEX IF NOT EXISTS games_score_rejections_game_id_idx ON games_score_rejections (game_id, created_at DESC);
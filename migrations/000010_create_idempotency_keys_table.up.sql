@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    user_id BIGINT NOT NULL REFERENCES auth_users ON DELETE CASCADE,
+    key TEXT NOT NULL,
+    request_hash BYTEA NOT NULL,
+    status INTEGER, -- nullable. set once the original request completes
+    header JSONB,
+    body BYTEA,
+    expiry TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (user_id, key)
+);
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS game_versions (
+    -- base fields
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    version INTEGER NOT NULL DEFAULT 1,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    -- fields specific to game versions
+    game_id BIGINT NOT NULL REFERENCES games_list ON DELETE CASCADE,
+    number INTEGER NOT NULL CHECK (number > 0),
+    checksum TEXT NOT NULL, -- sha256 of the uploaded bundle, names its storage directory
+    size BIGINT NOT NULL,
+    file_count INTEGER NOT NULL,
+    uploaded_by BIGINT REFERENCES auth_users ON DELETE SET NULL, -- nullable
+    UNIQUE (game_id, number)
+);
@@ -0,0 +1 @@
+UPDATE auth_users SET profile_picture = 'link-to-default.jpg' WHERE profile_picture = '';
@@ -0,0 +1 @@
+UPDATE auth_users SET profile_picture = '' WHERE profile_picture IS NULL OR profile_picture = 'link-to-default.jpg';
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    action TEXT NOT NULL,
+    -- no foreign keys, events must outlive the users and records they mention
+    actor_id BIGINT, -- nullable. anonymous requests, e.g. failed logins
+    target_type TEXT NOT NULL,
+    target_id BIGINT, -- nullable
+    request_id TEXT NOT NULL,
+    ip TEXT NOT NULL,
+    diff JSONB -- nullable
+);
+
+CREATE INDEX IF NOT EXISTS audit_events_actor_id_idx ON audit_events (actor_id);
+CREATE INDEX IF NOT EXISTS audit_events_target_idx ON audit_events (target_type, target_id);
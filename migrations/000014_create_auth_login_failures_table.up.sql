@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS auth_login_failures (
+    scope TEXT NOT NULL, -- account or ip
+    subject TEXT NOT NULL, -- lowercased email or IP address
+    count INTEGER NOT NULL,
+    last_failed_at TIMESTAMPTZ NOT NULL,
+    locked_until TIMESTAMPTZ, -- nullable
+    PRIMARY KEY (scope, subject)
+);
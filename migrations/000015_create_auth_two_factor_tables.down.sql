@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS auth_recovery_codes;
+DROP TABLE IF EXISTS auth_two_factor;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS auth_two_factor (
+    user_id BIGINT PRIMARY KEY REFERENCES auth_users ON DELETE CASCADE,
+    secret BYTEA NOT NULL,
+    confirmed_at TIMESTAMPTZ, -- nullable, set once enrollment is confirmed with a code
+    last_used_step BIGINT NOT NULL DEFAULT 0 -- time step of the last accepted code, so it can't be replayed
+);
+
+CREATE TABLE IF NOT EXISTS auth_recovery_codes (
+    hash BYTEA PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES auth_two_factor ON DELETE CASCADE
+);
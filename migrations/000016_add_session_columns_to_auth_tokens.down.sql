@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS auth_tokens_user_id_scope_idx;
+
+ALTER TABLE auth_tokens
+    DROP COLUMN IF EXISTS ip,
+    DROP COLUMN IF EXISTS user_agent,
+    DROP COLUMN IF EXISTS last_seen_at,
+    DROP COLUMN IF EXISTS created_at,
+    DROP COLUMN IF EXISTS id;
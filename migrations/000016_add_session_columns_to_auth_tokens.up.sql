@@ -0,0 +1,9 @@
+-- the hash can't be handed out, so sessions are listed and revoked by ID instead
+ALTER TABLE auth_tokens
+    ADD COLUMN IF NOT EXISTS id BIGSERIAL UNIQUE,
+    ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    ADD COLUMN IF NOT EXISTS last_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    ADD COLUMN IF NOT EXISTS user_agent TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS ip TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS auth_tokens_user_id_scope_idx ON auth_tokens (user_id, scope);
@@ -0,0 +1,2 @@
+ALTER TABLE auth_tokens
+    DROP COLUMN IF EXISTS email;
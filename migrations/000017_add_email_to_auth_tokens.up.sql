@@ -0,0 +1,3 @@
+-- the new address of email change tokens, only applied once the token is redeemed
+ALTER TABLE auth_tokens
+    ADD COLUMN IF NOT EXISTS email CITEXT NOT NULL DEFAULT '';
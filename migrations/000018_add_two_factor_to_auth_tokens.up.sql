@@ -0,0 +1,3 @@
+-- whether the session was started with a second factor, not just a password
+ALTER TABLE auth_tokens
+    ADD COLUMN IF NOT EXISTS two_factor BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS auth_login_failures_last_failed_at_idx;
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS auth_login_failures_last_failed_at_idx ON auth_login_failures (last_failed_at);